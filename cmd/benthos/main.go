@@ -49,7 +49,9 @@ import (
 	"github.com/Jeffail/benthos/lib/ratelimit"
 	"github.com/Jeffail/benthos/lib/stream"
 	strmmgr "github.com/Jeffail/benthos/lib/stream/manager"
+	"github.com/Jeffail/benthos/lib/test"
 	"github.com/Jeffail/benthos/lib/tracer"
+	"github.com/Jeffail/benthos/lib/util/text"
 	yaml "gopkg.in/yaml.v2"
 )
 
@@ -84,7 +86,10 @@ Set whether all fields should be shown when printing configuration via
 		"c", "", "Path to a configuration file",
 	)
 	lintConfig = flag.Bool(
-		"lint", false, "Lint the target configuration file, then exit",
+		"lint", false,
+		`
+Lint the target configuration file, then exit. Components are also constructed
+in a dry-run mode in order to detect errors such as missing resources`[1:],
 	)
 	strictConfig = flag.Bool(
 		"strict", false,
@@ -142,6 +147,12 @@ config with a websocket input and output and a jmespath processor.`[1:],
 		"list-tracers", false,
 		"Print a list of available tracer options, then exit",
 	)
+	printJSONSchema = flag.Bool(
+		"print-json-schema", false,
+		`
+Print a JSON Schema of the config format, including any loaded plugins, then
+exit. The schema can be used by editors to validate and autocomplete configs`[1:],
+	)
 	pluginsDir = flag.String(
 		"plugins-dir", "/usr/lib/benthos/plugins",
 		"EXPERIMENTAL: Specify a directory containing Benthos plugins",
@@ -178,6 +189,20 @@ When running Benthos in streams mode any files in this directory with a .json or
 pipeline, output), where the filename less the extension will be the id of the
 stream.`[1:],
 	)
+	streamsStoreDir = flag.String(
+		"streams-store-dir", "",
+		`
+When running Benthos in streams mode, persist the configs of streams created
+through the REST API as files within this directory, and restore them on start
+up. Streams loaded from --streams-dir are not persisted.`[1:],
+	)
+	streamsStoreCache = flag.String(
+		"streams-store-cache", "",
+		`
+When running Benthos in streams mode, persist the configs of streams created
+through the REST API within this cache resource, and restore them on start up.
+Streams loaded from --streams-dir are not persisted.`[1:],
+	)
 )
 
 //------------------------------------------------------------------------------
@@ -197,6 +222,7 @@ func bootstrap() (config.Type, []string) {
 	// Override default help printing
 	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, "Usage: benthos [flags...]")
+		fmt.Fprintln(os.Stderr, "       benthos [flags...] test [paths...]")
 		fmt.Fprintln(os.Stderr, "Flags:")
 		flag.PrintDefaults()
 	}
@@ -224,13 +250,23 @@ func bootstrap() (config.Type, []string) {
 		})
 	}
 
+	// If the user wants to run config unit tests we do so and then exit.
+	if flag.Arg(0) == "test" {
+		if test.Run(os.Stdout, flag.Args()[1:], test.DefaultSuffix) {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+
 	var lints []string
+	var readPath string
 	if len(*configPath) > 0 {
 		var err error
 		if lints, err = config.Read(*configPath, *swapEnvs, &conf); err != nil {
 			fmt.Fprintf(os.Stderr, "Configuration file read error: %v\n", err)
 			os.Exit(1)
 		}
+		readPath = *configPath
 	} else {
 		// Iterate default config paths
 		for _, path := range defaultPaths {
@@ -241,11 +277,20 @@ func bootstrap() (config.Type, []string) {
 					fmt.Fprintf(os.Stderr, "Configuration file read error: %v\n", err)
 					os.Exit(1)
 				}
+				readPath = path
 				break
 			}
 		}
 	}
 	if *lintConfig {
+		if len(readPath) > 0 {
+			semLints, err := config.ReadSemanticLints(readPath, *swapEnvs, conf)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Configuration file read error: %v\n", err)
+				os.Exit(1)
+			}
+			lints = append(lints, semLints...)
+		}
 		if len(lints) > 0 {
 			for _, l := range lints {
 				fmt.Fprintln(os.Stderr, l)
@@ -278,6 +323,7 @@ func bootstrap() (config.Type, []string) {
 			manager.AddExamples(&conf.Manager)
 			outConf = conf
 		}
+		outConf = text.RedactSecrets(outConf)
 
 		if *showConfigJSON {
 			if configJSON, err := json.Marshal(outConf); err == nil {
@@ -330,6 +376,21 @@ func bootstrap() (config.Type, []string) {
 		os.Exit(0)
 	}
 
+	if *printJSONSchema {
+		schema, err := config.JSONSchema()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "JSON Schema generation error: %v\n", err)
+			os.Exit(1)
+		}
+		schemaJSON, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "JSON Schema marshal error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(schemaJSON))
+		os.Exit(0)
+	}
+
 	if *printInputPlugins || *printOutputPlugins || *printProcessorPlugins || *printConditionPlugins {
 		if *printInputPlugins {
 			fmt.Println(input.PluginDescriptions())
@@ -399,7 +460,7 @@ func main() {
 
 	// Create our tracer type.
 	var trac tracer.Type
-	if trac, err = tracer.New(config.Tracer); err != nil {
+	if trac, err = tracer.New(config.Tracer, tracer.OptSetLogger(logger.NewModule(".tracer"))); err != nil {
 		logger.Errorf("Failed to initialise tracer: %v\n", err)
 		os.Exit(1)
 	}
@@ -411,7 +472,7 @@ func main() {
 		logger.Warnf("Failed to generate sanitised config: %v\n", err)
 	}
 	var httpServer *api.Type
-	if httpServer, err = api.New(Version, DateBuilt, config.HTTP, sanConf, logger, stats); err != nil {
+	if httpServer, err = api.New(Version, DateBuilt, config.HTTP, text.RedactSecrets(sanConf), logger, stats); err != nil {
 		logger.Errorf("Failed to initialise API: %v\n", err)
 		os.Exit(1)
 	}
@@ -428,12 +489,33 @@ func main() {
 
 	// Create data streams.
 	if *streamsMode {
-		streamMgr := strmmgr.New(
-			strmmgr.OptSetAPITimeout(time.Second*5),
+		mgrOpts := []func(*strmmgr.Type){
+			strmmgr.OptSetAPITimeout(time.Second * 5),
 			strmmgr.OptSetLogger(logger),
 			strmmgr.OptSetManager(manager),
 			strmmgr.OptSetStats(stats),
-		)
+		}
+		if len(*streamsStoreDir) > 0 && len(*streamsStoreCache) > 0 {
+			logger.Errorln("Only one of --streams-store-dir and --streams-store-cache can be set")
+			os.Exit(1)
+		}
+		if len(*streamsStoreDir) > 0 {
+			store, serr := strmmgr.NewDirectoryStore(*streamsStoreDir)
+			if serr != nil {
+				logger.Errorf("Failed to create streams store: %v\n", serr)
+				os.Exit(1)
+			}
+			mgrOpts = append(mgrOpts, strmmgr.OptSetStore(store))
+		}
+		if len(*streamsStoreCache) > 0 {
+			cache, serr := manager.GetCache(*streamsStoreCache)
+			if serr != nil {
+				logger.Errorf("Failed to obtain streams store cache '%v': %v\n", *streamsStoreCache, serr)
+				os.Exit(1)
+			}
+			mgrOpts = append(mgrOpts, strmmgr.OptSetStore(strmmgr.NewCacheStore(cache, "benthos_streams_")))
+		}
+		streamMgr := strmmgr.New(mgrOpts...)
 		var streamConfs map[string]stream.Config
 		if streamConfs, err = strmmgr.LoadStreamConfigsFromDirectory(true, *streamsDir); err != nil {
 			logger.Errorf("Failed to load stream configs: %v\n", err)
@@ -441,7 +523,13 @@ func main() {
 		}
 		dataStream = streamMgr
 		for id, conf := range streamConfs {
-			if err = streamMgr.Create(id, conf); err != nil {
+			// Streams from the directory take precedence over stored streams
+			// of the same ID, but are never written to the store as their
+			// configs may contain resolved secrets.
+			if err = streamMgr.CreateEphemeral(id, conf); err == strmmgr.ErrStreamExists {
+				err = streamMgr.UpdateEphemeral(id, conf, time.Second*5)
+			}
+			if err != nil {
 				logger.Errorf("Failed to create stream (%v): %v\n", id, err)
 				os.Exit(1)
 			}
@@ -491,15 +579,6 @@ func main() {
 
 	// Defer clean up.
 	defer func() {
-		go func() {
-			httpServer.Shutdown(context.Background())
-			select {
-			case <-httpServerClosedChan:
-			case <-time.After(exitTimeout / 2):
-				logger.Warnln("Service failed to close HTTP server gracefully in time.")
-			}
-		}()
-
 		go func() {
 			<-time.After(exitTimeout + time.Second)
 			logger.Warnln(
@@ -510,10 +589,26 @@ func main() {
 			os.Exit(1)
 		}()
 
+		// The HTTP server remains open whilst the stream drains in order to
+		// expose its progress.
 		timesOut := time.Now().Add(exitTimeout)
 		if err := dataStream.Stop(exitTimeout); err != nil {
 			os.Exit(1)
 		}
+		// A slow drain must not leave the HTTP server without any time to
+		// shut down gracefully.
+		httpTimeout := time.Until(timesOut)
+		if minTimeout := exitTimeout / 2; httpTimeout < minTimeout {
+			httpTimeout = minTimeout
+		}
+		go func() {
+			httpServer.Shutdown(context.Background())
+			select {
+			case <-httpServerClosedChan:
+			case <-time.After(httpTimeout):
+				logger.Warnln("Service failed to close HTTP server gracefully in time.")
+			}
+		}()
 		manager.CloseAsync()
 		if err := manager.WaitForClose(time.Until(timesOut)); err != nil {
 			logger.Warnf(
@@ -531,7 +626,7 @@ func main() {
 	// Wait for termination signal
 	select {
 	case <-sigChan:
-		logger.Infoln("Received SIGTERM, the service is closing.")
+		logger.Infoln("Received SIGTERM, the service is draining and will then close.")
 	case <-dataStreamClosedChan:
 		logger.Infoln("Pipeline has terminated. Shutting down the service.")
 	case <-httpServerClosedChan:
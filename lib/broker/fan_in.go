@@ -125,6 +125,19 @@ func (i *FanIn) loop() {
 	}
 }
 
+// DrainAsync triggers each input of the FanIn broker to stop consuming new
+// data, inputs that are unable to drain are closed instead. The broker closes
+// once all inputs have closed.
+func (i *FanIn) DrainAsync() {
+	for _, closable := range i.closables {
+		if d, ok := closable.(types.Drainer); ok {
+			d.DrainAsync()
+		} else {
+			closable.CloseAsync()
+		}
+	}
+}
+
 // CloseAsync shuts down the FanIn broker and stops processing requests.
 func (i *FanIn) CloseAsync() {
 	for _, closable := range i.closables {
@@ -46,10 +46,16 @@ type TypeSpec struct {
 // Constructors is a map of all buffer types with their specs.
 var Constructors = map[string]TypeSpec{}
 
+// Description returns a markdown formatted description of the buffer type.
+func (t TypeSpec) Description() string {
+	return t.description
+}
+
 //------------------------------------------------------------------------------
 
 // String constants representing each buffer type.
 const (
+	TypeDisk   = "disk"
 	TypeMemory = "memory"
 	TypeMMAP   = "mmap_file"
 	TypeNone   = "none"
@@ -60,6 +66,7 @@ const (
 // Config is the all encompassing configuration struct for all buffer types.
 type Config struct {
 	Type   string                  `json:"type" yaml:"type"`
+	Disk   single.DiskConfig       `json:"disk" yaml:"disk"`
 	Memory single.MemoryConfig     `json:"memory" yaml:"memory"`
 	Mmap   single.MmapBufferConfig `json:"mmap_file" yaml:"mmap_file"`
 	None   struct{}                `json:"none" yaml:"none"`
@@ -69,6 +76,7 @@ type Config struct {
 func NewConfig() Config {
 	return Config{
 		Type:   "none",
+		Disk:   single.NewDiskConfig(),
 		Memory: single.NewMemoryConfig(),
 		Mmap:   single.NewMmapBufferConfig(),
 		None:   struct{}{},
@@ -121,13 +129,15 @@ different options and their qualities:
 | --------- | ---------- | --------- | -------- |
 | Memory    | Highest    | Parallel  | RAM      |
 | Mmap File | High       | Single    | Disk     |
+| Disk      | Medium     | Single    | Disk     |
 
 #### Delivery Guarantees
 
 | Type      | On Restart | On Crash  | On Disk Corruption |
 | --------- | ---------- | --------- | ------------------ |
 | Memory    | Lost       | Lost      | Lost               |
-| Mmap File | Persisted  | Lost      | Lost               |`
+| Mmap File | Persisted  | Lost      | Lost               |
+| Disk      | Persisted  | Persisted | Partially Lost     |`
 
 // Descriptions returns a formatted string of collated descriptions of each type.
 func Descriptions() string {
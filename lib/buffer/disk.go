@@ -0,0 +1,78 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package buffer
+
+import (
+	"github.com/Jeffail/benthos/lib/buffer/single"
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeDisk] = TypeSpec{
+		constructor: NewDisk,
+		description: `
+The disk buffer type stores messages, including their metadata, in a write-ahead
+log of segment files within a directory. Messages are only removed from the log
+once they have been acknowledged by the output, and the position of the oldest
+unacknowledged message is tracked in a cursor file. When Benthos restarts, or
+recovers from a crash, all unacknowledged messages are delivered again, giving
+at-least-once delivery across process restarts.
+
+A new segment file is created each time the current one reaches
+` + "`segment_size`" + ` bytes, and segments are deleted once all of their
+messages are acknowledged. Writes block once the total size of unacknowledged
+messages reaches ` + "`limit`" + ` bytes.
+
+Every record is stored with a checksum. Partially written records left by a
+crash are truncated on start up, and if a corrupted record is found whilst
+reading then the remainder of its segment is skipped.
+
+### Sync Policy
+
+The field ` + "`sync_policy`" + ` determines how often writes are flushed to
+the disk with fsync:
+
+- ` + "`always`" + `: Flush after every write and acknowledgement. This is the
+  safest option but also the slowest.
+- ` + "`interval`" + `: Flush every ` + "`sync_interval`" + `. A power loss or
+  operating system crash may lose or redeliver messages from the last interval.
+- ` + "`never`" + `: Leave flushing to the operating system.
+
+Since written data is held by the operating system, a crash of the Benthos
+process alone does not lose data under any policy.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewDisk creates a buffer persisted to disk as a write-ahead log.
+func NewDisk(config Config, log log.Modular, stats metrics.Type) (Type, error) {
+	b, err := single.NewDisk(config.Disk, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewSingleWrapper(config, b, log, stats), nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,119 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package buffer
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/response"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func TestDiskBufferRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewConfig()
+	conf.Type = "disk"
+	conf.Disk.Path = dir
+
+	readOne := func(buf Type, res types.Response) {
+		t.Helper()
+		select {
+		case outTr, open := <-buf.TransactionChan():
+			if !open {
+				t.Fatal("buffer closed early")
+			}
+			if exp, act := `hello world`, string(outTr.Payload.Get(0).Get()); exp != act {
+				t.Errorf("Wrong message content: %s != %s", act, exp)
+			}
+			if exp, act := `bar`, outTr.Payload.Get(0).Metadata().Get("foo"); exp != act {
+				t.Errorf("Wrong message metadata: %s != %s", act, exp)
+			}
+			select {
+			case outTr.ResponseChan <- res:
+			case <-time.After(time.Second):
+				t.Fatal("Timed out")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out")
+		}
+	}
+
+	buf, err := New(conf, log.New(os.Stdout, logConfig), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tChan, resChan := make(chan types.Transaction), make(chan types.Response)
+	if err = buf.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{[]byte(`hello world`)})
+	msg.Get(0).Metadata().Set("foo", "bar")
+
+	select {
+	case tChan <- types.NewTransaction(msg, resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+	select {
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	// Fail to deliver the message before shutting down.
+	readOne(buf, response.NewError(errors.New("nope")))
+	buf.CloseAsync()
+	if err = buf.WaitForClose(time.Second * 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf, err = New(conf, log.New(os.Stdout, logConfig), metrics.DudType{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = buf.Consume(make(chan types.Transaction)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The unacknowledged message is delivered again after a restart.
+	readOne(buf, response.NewAck())
+
+	buf.CloseAsync()
+	if err = buf.WaitForClose(time.Second * 5); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,770 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package single
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// DiskConfig is config options for a disk based write-ahead log buffer.
+type DiskConfig struct {
+	Path         string `json:"directory" yaml:"directory"`
+	SegmentSize  int    `json:"segment_size" yaml:"segment_size"`
+	Limit        int    `json:"limit" yaml:"limit"`
+	SyncPolicy   string `json:"sync_policy" yaml:"sync_policy"`
+	SyncInterval string `json:"sync_interval" yaml:"sync_interval"`
+}
+
+// NewDiskConfig creates a new DiskConfig with default values.
+func NewDiskConfig() DiskConfig {
+	return DiskConfig{
+		Path:         "",
+		SegmentSize:  16 * 1024 * 1024,   // 16MiB
+		Limit:        1024 * 1024 * 1024, // 1GiB
+		SyncPolicy:   "interval",
+		SyncInterval: "1s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Sync policies supported by the disk buffer.
+const (
+	DiskSyncAlways   = "always"
+	DiskSyncInterval = "interval"
+	DiskSyncNever    = "never"
+)
+
+var (
+	// ErrBadRecordChecksum means a record read from a segment file did not
+	// match its checksum.
+	ErrBadRecordChecksum = errors.New("record checksum did not match")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+const (
+	segmentSuffix = ".seg"
+	cursorFile    = "cursor"
+
+	// Each record begins with the length of its payload followed by a CRC32
+	// (Castagnoli) checksum of the payload, both u32 big endian.
+	recordHeaderLen = 8
+
+	// The cursor file contains the segment index and offset of the oldest
+	// unacknowledged record, both u64 big endian, followed by a CRC32 of the
+	// preceding bytes.
+	cursorLen = 20
+)
+
+//------------------------------------------------------------------------------
+
+// Disk is a buffer that persists messages, including their metadata, to a
+// write-ahead log of segment files within a directory. Messages are only
+// removed from the log once they have been acknowledged, and the position of
+// the oldest unacknowledged message is tracked in a cursor file so that
+// reading resumes from it after a restart or crash.
+type Disk struct {
+	config DiskConfig
+
+	logger log.Modular
+	stats  metrics.Type
+
+	syncInterval time.Duration
+
+	mSyncErr    metrics.StatCounter
+	mCorruption metrics.StatCounter
+
+	writeIndex  int
+	writeOffset int
+	writeFile   *os.File
+	writeDirty  bool
+
+	readIndex  int
+	readOffset int
+	readSize   int
+	readFile   *os.File
+	pendingLen int
+
+	cursor      *os.File
+	cursorDirty bool
+
+	backlog int
+	closed  bool
+
+	cond       *sync.Cond
+	closedChan chan struct{}
+}
+
+// NewDisk creates a disk based write-ahead log buffer, recovering any messages
+// left unacknowledged within the target directory.
+func NewDisk(config DiskConfig, log log.Modular, stats metrics.Type) (*Disk, error) {
+	if len(config.Path) == 0 {
+		return nil, errors.New("a directory must be specified")
+	}
+	if config.SegmentSize <= recordHeaderLen {
+		return nil, fmt.Errorf("segment size must be greater than %v", recordHeaderLen)
+	}
+
+	d := &Disk{
+		config:      config,
+		logger:      log,
+		stats:       stats,
+		mSyncErr:    stats.GetCounter("sync.error"),
+		mCorruption: stats.GetCounter("corruption"),
+		cond:        sync.NewCond(&sync.Mutex{}),
+		closedChan:  make(chan struct{}),
+	}
+
+	switch config.SyncPolicy {
+	case DiskSyncAlways, DiskSyncNever:
+	case DiskSyncInterval:
+		var err error
+		if d.syncInterval, err = time.ParseDuration(config.SyncInterval); err != nil {
+			return nil, fmt.Errorf("failed to parse sync interval: %v", err)
+		}
+		if d.syncInterval <= 0 {
+			return nil, errors.New("sync interval must be greater than zero")
+		}
+	default:
+		return nil, fmt.Errorf("sync policy not recognised: %v", config.SyncPolicy)
+	}
+
+	if err := os.MkdirAll(config.Path, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := d.recover(); err != nil {
+		d.closeFiles()
+		return nil, err
+	}
+
+	d.logger.Infof("Storing messages to disk in: %s\n", config.Path)
+	if d.syncInterval > 0 {
+		go d.syncLoop()
+	}
+	return d, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (d *Disk) segmentPath(index int) string {
+	return path.Join(d.config.Path, fmt.Sprintf("%010d%v", index, segmentSuffix))
+}
+
+// listSegments returns the indexes of all segment files within the directory
+// in ascending order.
+func (d *Disk) listSegments() ([]int, error) {
+	infos, err := ioutil.ReadDir(d.config.Path)
+	if err != nil {
+		return nil, err
+	}
+	var indexes []int
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSuffix(name, segmentSuffix))
+		if err != nil {
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+// readCursor returns the segment index and offset stored within the cursor
+// file, or false if the cursor is missing or corrupt.
+func (d *Disk) readCursor() (int, int, bool) {
+	b := make([]byte, cursorLen)
+	if n, err := d.cursor.ReadAt(b, 0); err != nil || n != cursorLen {
+		return 0, 0, false
+	}
+	if crc32.Checksum(b[:16], crcTable) != binary.BigEndian.Uint32(b[16:]) {
+		return 0, 0, false
+	}
+	return int(binary.BigEndian.Uint64(b)), int(binary.BigEndian.Uint64(b[8:])), true
+}
+
+// writeCursor writes the current read position to the cursor file.
+func (d *Disk) writeCursor() error {
+	b := make([]byte, cursorLen)
+	binary.BigEndian.PutUint64(b, uint64(d.readIndex))
+	binary.BigEndian.PutUint64(b[8:], uint64(d.readOffset))
+	binary.BigEndian.PutUint32(b[16:], crc32.Checksum(b[:16], crcTable))
+	if _, err := d.cursor.WriteAt(b, 0); err != nil {
+		return err
+	}
+	d.cursorDirty = true
+	if d.config.SyncPolicy == DiskSyncAlways {
+		return d.syncCursor()
+	}
+	return nil
+}
+
+func (d *Disk) syncCursor() error {
+	if !d.cursorDirty {
+		return nil
+	}
+	d.cursorDirty = false
+	return d.cursor.Sync()
+}
+
+func (d *Disk) syncWrite() error {
+	if !d.writeDirty {
+		return nil
+	}
+	d.writeDirty = false
+	return d.writeFile.Sync()
+}
+
+// discardWrite removes any data written to the current segment beyond the
+// write offset, and returns an error if the data could not be removed.
+func (d *Disk) discardWrite() error {
+	if err := d.writeFile.Truncate(int64(d.writeOffset)); err != nil {
+		return err
+	}
+	d.writeFile.Seek(int64(d.writeOffset), io.SeekStart)
+	return nil
+}
+
+// scanSegment walks the records of an open segment file and returns the offset
+// immediately after the last valid record.
+func scanSegment(f *os.File) (int, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := int(info.Size())
+
+	header := make([]byte, recordHeaderLen)
+	offset := 0
+	for offset+recordHeaderLen <= size {
+		if _, err = f.ReadAt(header, int64(offset)); err != nil {
+			return offset, nil
+		}
+		recordLen := int(binary.BigEndian.Uint32(header))
+		if recordLen == 0 || offset+recordHeaderLen+recordLen > size {
+			return offset, nil
+		}
+		payload := make([]byte, recordLen)
+		if _, err = f.ReadAt(payload, int64(offset+recordHeaderLen)); err != nil {
+			return offset, nil
+		}
+		if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:]) {
+			return offset, nil
+		}
+		offset += recordHeaderLen + recordLen
+	}
+	return offset, nil
+}
+
+// recover opens the segment files and cursor within the directory, truncating
+// any partially written records left by a crash and deleting segments that
+// have already been acknowledged.
+func (d *Disk) recover() error {
+	var err error
+	if d.cursor, err = os.OpenFile(path.Join(d.config.Path, cursorFile), os.O_RDWR|os.O_CREATE, 0644); err != nil {
+		return fmt.Errorf("failed to open cursor: %v", err)
+	}
+
+	segments, err := d.listSegments()
+	if err != nil {
+		return fmt.Errorf("failed to list segments: %v", err)
+	}
+	if len(segments) == 0 {
+		segments = []int{0}
+	}
+
+	readIndex, readOffset, cursorOK := d.readCursor()
+	if !cursorOK {
+		if info, sErr := d.cursor.Stat(); sErr == nil && info.Size() > 0 {
+			d.logger.Warnln("Disk buffer cursor is corrupt, reading from the oldest segment")
+		}
+		readIndex, readOffset = segments[0], 0
+	}
+
+	// Remove segments that have been fully acknowledged.
+	for len(segments) > 1 && segments[0] < readIndex {
+		if err = os.Remove(d.segmentPath(segments[0])); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete segment: %v", err)
+		}
+		segments = segments[1:]
+	}
+	if segments[0] != readIndex {
+		readIndex, readOffset = segments[0], 0
+	}
+
+	// Open the newest segment for writing, truncating any partial record.
+	d.writeIndex = segments[len(segments)-1]
+	if d.writeFile, err = os.OpenFile(d.segmentPath(d.writeIndex), os.O_RDWR|os.O_CREATE, 0644); err != nil {
+		return fmt.Errorf("failed to open segment: %v", err)
+	}
+	if d.writeOffset, err = scanSegment(d.writeFile); err != nil {
+		return fmt.Errorf("failed to scan segment: %v", err)
+	}
+	var info os.FileInfo
+	if info, err = d.writeFile.Stat(); err != nil {
+		return fmt.Errorf("failed to stat segment: %v", err)
+	}
+	if int(info.Size()) > d.writeOffset {
+		d.logger.Warnf("Truncating %v bytes of incomplete records from segment %v\n", int(info.Size())-d.writeOffset, d.writeIndex)
+		if err = d.writeFile.Truncate(int64(d.writeOffset)); err != nil {
+			return fmt.Errorf("failed to truncate segment: %v", err)
+		}
+		if err = d.writeFile.Sync(); err != nil {
+			return fmt.Errorf("failed to sync segment: %v", err)
+		}
+	}
+	if _, err = d.writeFile.Seek(int64(d.writeOffset), io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek segment: %v", err)
+	}
+
+	// Calculate the backlog of unacknowledged records.
+	for _, index := range segments {
+		size := d.writeOffset
+		if index != d.writeIndex {
+			if info, err = os.Stat(d.segmentPath(index)); err != nil {
+				return fmt.Errorf("failed to stat segment: %v", err)
+			}
+			size = int(info.Size())
+		}
+		d.backlog += size
+	}
+
+	if err = d.openRead(readIndex); err != nil {
+		return err
+	}
+	if readOffset > d.readSize {
+		readOffset = d.readSize
+	}
+	d.readOffset = readOffset
+	d.backlog -= readOffset
+	return d.writeCursor()
+}
+
+// openRead opens a segment for reading from its beginning.
+func (d *Disk) openRead(index int) error {
+	if d.readFile != nil {
+		d.readFile.Close()
+		d.readFile = nil
+	}
+	f, err := os.Open(d.segmentPath(index))
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %v", err)
+	}
+	d.readFile = f
+	d.readIndex = index
+	d.readOffset = 0
+	d.readSize = d.writeOffset
+	if index != d.writeIndex {
+		var info os.FileInfo
+		if info, err = f.Stat(); err != nil {
+			return fmt.Errorf("failed to stat segment: %v", err)
+		}
+		d.readSize = int(info.Size())
+	}
+	return nil
+}
+
+// rotate seals the current write segment and opens the next one.
+func (d *Disk) rotate() error {
+	if err := d.writeFile.Sync(); err != nil {
+		return err
+	}
+	if d.readIndex == d.writeIndex {
+		d.readSize = d.writeOffset
+	}
+
+	f, err := os.OpenFile(d.segmentPath(d.writeIndex+1), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	d.writeFile.Close()
+	d.writeFile = f
+	d.writeIndex++
+	d.writeOffset = 0
+	d.writeDirty = false
+	return nil
+}
+
+// advanceSegment moves the reader onto the next segment and deletes the
+// segment it has finished with.
+func (d *Disk) advanceSegment() error {
+	prevIndex := d.readIndex
+	if err := d.openRead(prevIndex + 1); err != nil {
+		return err
+	}
+	if err := d.writeCursor(); err != nil {
+		return err
+	}
+	if err := os.Remove(d.segmentPath(prevIndex)); err != nil && !os.IsNotExist(err) {
+		d.logger.Errorf("Failed to delete segment %v: %v\n", prevIndex, err)
+	}
+	return nil
+}
+
+// syncLoop periodically flushes pending writes to disk.
+func (d *Disk) syncLoop() {
+	ticker := time.NewTicker(d.syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-d.closedChan:
+			return
+		}
+		d.cond.L.Lock()
+		if !d.closed {
+			if err := d.syncWrite(); err != nil {
+				d.logger.Errorf("Failed to sync segment: %v\n", err)
+				d.mSyncErr.Incr(1)
+			}
+			if err := d.syncCursor(); err != nil {
+				d.logger.Errorf("Failed to sync cursor: %v\n", err)
+				d.mSyncErr.Incr(1)
+			}
+		}
+		d.cond.L.Unlock()
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// closeFiles syncs and closes all open files.
+func (d *Disk) closeFiles() {
+	if d.writeFile != nil {
+		d.syncWrite()
+		d.writeFile.Close()
+	}
+	if d.readFile != nil {
+		d.readFile.Close()
+	}
+	if d.cursor != nil {
+		d.syncCursor()
+		d.cursor.Close()
+	}
+}
+
+// CloseOnceEmpty closes the disk buffer once the backlog reaches 0.
+func (d *Disk) CloseOnceEmpty() {
+	d.cond.L.Lock()
+	for d.backlog > 0 && !d.closed {
+		d.cond.Wait()
+	}
+	d.cond.L.Unlock()
+	d.Close()
+}
+
+// Close unblocks any blocked calls and prevents further reading or writing.
+// Messages that have not been acknowledged remain on disk.
+func (d *Disk) Close() {
+	d.cond.L.Lock()
+	defer d.cond.L.Unlock()
+	if d.closed {
+		return
+	}
+	d.closed = true
+	d.closeFiles()
+	close(d.closedChan)
+	d.cond.Broadcast()
+}
+
+// ShiftMessage acknowledges the last message read, removing it from the log.
+// Returns the backlog in bytes.
+func (d *Disk) ShiftMessage() (int, error) {
+	d.cond.L.Lock()
+	defer func() {
+		d.cond.Broadcast()
+		d.cond.L.Unlock()
+	}()
+
+	if d.closed {
+		return 0, types.ErrTypeClosed
+	}
+	if d.pendingLen == 0 {
+		return d.backlog, nil
+	}
+
+	d.readOffset += d.pendingLen
+	d.backlog -= d.pendingLen
+	d.pendingLen = 0
+	return d.backlog, d.writeCursor()
+}
+
+// NextMessage reads the oldest unacknowledged message, blocking until there is
+// something to read. The message is preserved until ShiftMessage is called.
+func (d *Disk) NextMessage() (types.Message, error) {
+	d.cond.L.Lock()
+	defer d.cond.L.Unlock()
+
+	for {
+		if d.closed {
+			return nil, types.ErrTypeClosed
+		}
+		if d.readIndex == d.writeIndex {
+			d.readSize = d.writeOffset
+		}
+		if d.readOffset < d.readSize {
+			break
+		}
+		if d.readIndex < d.writeIndex {
+			if err := d.advanceSegment(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		d.cond.Wait()
+	}
+
+	header := make([]byte, recordHeaderLen)
+	payload, err := func() ([]byte, error) {
+		if _, err := d.readFile.ReadAt(header, int64(d.readOffset)); err != nil {
+			return nil, err
+		}
+		recordLen := int(binary.BigEndian.Uint32(header))
+		if recordLen == 0 || d.readOffset+recordHeaderLen+recordLen > d.readSize {
+			return nil, types.ErrBlockCorrupted
+		}
+		payload := make([]byte, recordLen)
+		if _, err := d.readFile.ReadAt(payload, int64(d.readOffset+recordHeaderLen)); err != nil {
+			return nil, err
+		}
+		if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:]) {
+			return nil, ErrBadRecordChecksum
+		}
+		d.pendingLen = recordHeaderLen + recordLen
+		return payload, nil
+	}()
+	if err == nil {
+		var msg types.Message
+		if msg, err = decodeDiskMessage(payload); err == nil {
+			return msg, nil
+		}
+	}
+
+	// The remainder of this segment cannot be trusted, therefore skip to the
+	// end of it.
+	d.logger.Errorf("Skipping corrupted remainder of segment %v: %v\n", d.readIndex, err)
+	d.mCorruption.Incr(1)
+	d.pendingLen = 0
+	d.backlog -= d.readSize - d.readOffset
+	d.readOffset = d.readSize
+	if cErr := d.writeCursor(); cErr != nil {
+		d.logger.Errorf("Failed to write cursor: %v\n", cErr)
+	}
+	d.cond.Broadcast()
+	return nil, err
+}
+
+// PushMessage appends a new message to the log, blocking while the buffer is
+// at its limit. Returns the backlog in bytes.
+func (d *Disk) PushMessage(msg types.Message) (int, error) {
+	d.cond.L.Lock()
+	defer func() {
+		d.cond.Broadcast()
+		d.cond.L.Unlock()
+	}()
+
+	payload := encodeDiskMessage(msg)
+	recordLen := recordHeaderLen + len(payload)
+	if recordLen > d.config.SegmentSize || recordLen > d.config.Limit {
+		return 0, types.ErrMessageTooLarge
+	}
+
+	for d.backlog+recordLen > d.config.Limit && !d.closed {
+		d.cond.Wait()
+	}
+	if d.closed {
+		return 0, types.ErrTypeClosed
+	}
+
+	if d.writeOffset+recordLen > d.config.SegmentSize {
+		if err := d.rotate(); err != nil {
+			return d.backlog, fmt.Errorf("failed to rotate segment: %v", err)
+		}
+	}
+
+	record := make([]byte, recordLen)
+	binary.BigEndian.PutUint32(record, uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:], crc32.Checksum(payload, crcTable))
+	copy(record[recordHeaderLen:], payload)
+
+	if _, err := d.writeFile.Write(record); err != nil {
+		// Attempt to remove any partial record so that the log stays valid.
+		d.discardWrite()
+		return d.backlog, err
+	}
+	d.writeDirty = true
+	if d.config.SyncPolicy == DiskSyncAlways {
+		if err := d.syncWrite(); err != nil {
+			d.mSyncErr.Incr(1)
+			// The record is not durable and the message will be retried, remove
+			// it so that it isn't written twice. If it cannot be removed then it
+			// is kept so that our offsets remain consistent with the file.
+			if d.discardWrite() != nil {
+				d.writeOffset += recordLen
+				d.backlog += recordLen
+			}
+			return d.backlog, err
+		}
+	}
+
+	d.writeOffset += recordLen
+	d.backlog += recordLen
+	return d.backlog, nil
+}
+
+//------------------------------------------------------------------------------
+
+/*
+Messages are encoded into records with the following format, where all integers
+are u32 big endian:
+
+- Number of message parts
+- For each part:
+  - Number of metadata pairs
+  - For each metadata pair: key length, key, value length, value
+  - Content length, content
+*/
+
+func appendDiskUint32(b []byte, v int) []byte {
+	var lb [4]byte
+	binary.BigEndian.PutUint32(lb[:], uint32(v))
+	return append(b, lb[:]...)
+}
+
+func appendDiskBytes(b []byte, v []byte) []byte {
+	return append(appendDiskUint32(b, len(v)), v...)
+}
+
+// encodeDiskMessage serialises a message along with its metadata.
+func encodeDiskMessage(msg types.Message) []byte {
+	b := appendDiskUint32(nil, msg.Len())
+	msg.Iter(func(i int, p types.Part) error {
+		var keys []string
+		p.Metadata().Iter(func(k, v string) error {
+			keys = append(keys, k)
+			return nil
+		})
+		sort.Strings(keys)
+		b = appendDiskUint32(b, len(keys))
+		for _, k := range keys {
+			b = appendDiskBytes(b, []byte(k))
+			b = appendDiskBytes(b, []byte(p.Metadata().Get(k)))
+		}
+		b = appendDiskBytes(b, p.Get())
+		return nil
+	})
+	return b
+}
+
+type diskDecoder struct {
+	b []byte
+}
+
+func (d *diskDecoder) uint32() (int, error) {
+	if len(d.b) < 4 {
+		return 0, message.ErrBadMessageBytes
+	}
+	v := int(binary.BigEndian.Uint32(d.b))
+	d.b = d.b[4:]
+	return v, nil
+}
+
+func (d *diskDecoder) bytes() ([]byte, error) {
+	l, err := d.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if len(d.b) < l {
+		return nil, message.ErrBadMessageBytes
+	}
+	v := d.b[:l]
+	d.b = d.b[l:]
+	return v, nil
+}
+
+// decodeDiskMessage deserialises a message along with its metadata.
+func decodeDiskMessage(b []byte) (types.Message, error) {
+	dec := &diskDecoder{b: b}
+	numParts, err := dec.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if numParts > len(b) {
+		return nil, message.ErrBadMessageBytes
+	}
+
+	msg := message.New(nil)
+	for i := 0; i < numParts; i++ {
+		var numMeta int
+		if numMeta, err = dec.uint32(); err != nil {
+			return nil, err
+		}
+		if numMeta > len(b) {
+			return nil, message.ErrBadMessageBytes
+		}
+		meta := make(map[string]string, numMeta)
+		for j := 0; j < numMeta; j++ {
+			var k, v []byte
+			if k, err = dec.bytes(); err != nil {
+				return nil, err
+			}
+			if v, err = dec.bytes(); err != nil {
+				return nil, err
+			}
+			meta[string(k)] = string(v)
+		}
+		var content []byte
+		if content, err = dec.bytes(); err != nil {
+			return nil, err
+		}
+		part := message.NewPart(content)
+		for k, v := range meta {
+			part.Metadata().Set(k, v)
+		}
+		msg.Append(part)
+	}
+	return msg, nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,275 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package single
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func newTestDisk(t *testing.T, dir string, segmentSize int) *Disk {
+	t.Helper()
+
+	conf := NewDiskConfig()
+	conf.Path = dir
+	conf.SegmentSize = segmentSize
+	conf.SyncPolicy = DiskSyncAlways
+
+	d, err := NewDisk(conf, log.New(os.Stdout, logConfig), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func pushTestMessages(t *testing.T, d *Disk, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		part := message.NewPart([]byte(fmt.Sprintf("test%v", i)))
+		part.Metadata().Set("index", fmt.Sprintf("%v", i))
+		msg := message.New(nil)
+		msg.Append(part)
+		if _, err := d.PushMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readTestMessages(t *testing.T, d *Disk, from, to int, shift bool) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		m, err := d.NextMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp, act := fmt.Sprintf("test%v", i), string(m.Get(0).Get()); exp != act {
+			t.Errorf("Wrong message content: %v != %v", act, exp)
+		}
+		if exp, act := fmt.Sprintf("%v", i), m.Get(0).Metadata().Get("index"); exp != act {
+			t.Errorf("Wrong message metadata: %v != %v", act, exp)
+		}
+		if !shift {
+			return
+		}
+		if _, err = d.ShiftMessage(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiskBasic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := newTestDisk(t, dir, 200)
+	defer d.Close()
+
+	pushTestMessages(t, d, 0, 100)
+	readTestMessages(t, d, 0, 100, true)
+
+	if exp, act := 0, d.backlog; exp != act {
+		t.Errorf("Wrong backlog: %v != %v", act, exp)
+	}
+
+	segments, err := d.listSegments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 1, len(segments); exp != act {
+		t.Errorf("Acknowledged segments were not deleted: %v", segments)
+	}
+}
+
+func TestDiskRecoverUnacknowledged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := newTestDisk(t, dir, 200)
+	pushTestMessages(t, d, 0, 50)
+	readTestMessages(t, d, 0, 20, true)
+
+	// Read but do not acknowledge the next message.
+	readTestMessages(t, d, 20, 21, false)
+	d.Close()
+
+	d = newTestDisk(t, dir, 200)
+	defer d.Close()
+
+	pushTestMessages(t, d, 50, 60)
+	readTestMessages(t, d, 20, 60, true)
+}
+
+func TestDiskRecoverTornWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := newTestDisk(t, dir, 1000)
+	pushTestMessages(t, d, 0, 10)
+	d.Close()
+
+	// Simulate a crash part way through writing a record.
+	f, err := os.OpenFile(path.Join(dir, "0000000000.seg"), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write([]byte{0, 0, 0, 50, 1, 2, 3, 4, 't', 'e'}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	d = newTestDisk(t, dir, 1000)
+	defer d.Close()
+
+	pushTestMessages(t, d, 10, 20)
+	readTestMessages(t, d, 0, 20, true)
+}
+
+func TestDiskCorruptedRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := newTestDisk(t, dir, 100)
+	pushTestMessages(t, d, 0, 10)
+
+	segments, err := d.listSegments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) < 2 {
+		t.Fatalf("Expected multiple segments: %v", segments)
+	}
+
+	// Corrupt the payload of the first record.
+	f, err := os.OpenFile(path.Join(dir, "0000000000.seg"), os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteAt([]byte("X"), recordHeaderLen+10); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err = d.NextMessage(); err != ErrBadRecordChecksum {
+		t.Fatalf("Expected checksum error: %v", err)
+	}
+	if _, err = d.ShiftMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The remainder of the first segment is skipped.
+	m, err := d.NextMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index int
+	if _, err = fmt.Sscanf(string(m.Get(0).Get()), "test%d", &index); err != nil {
+		t.Fatal(err)
+	}
+	if index == 0 {
+		t.Error("Expected corrupted record to be skipped")
+	}
+	readTestMessages(t, d, index, 10, true)
+	d.Close()
+}
+
+func TestDiskLimitBlocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewDiskConfig()
+	conf.Path = dir
+	conf.SegmentSize = 1000
+	conf.Limit = 100
+	conf.SyncPolicy = DiskSyncNever
+
+	d, err := NewDisk(conf, log.New(os.Stdout, logConfig), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	pushTestMessages(t, d, 0, 2)
+
+	pushed := make(chan error)
+	go func() {
+		_, pErr := d.PushMessage(message.New([][]byte{[]byte("test2")}))
+		pushed <- pErr
+	}()
+
+	select {
+	case <-pushed:
+		t.Fatal("Push did not block at limit")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	readTestMessages(t, d, 0, 1, true)
+
+	select {
+	case pErr := <-pushed:
+		if pErr != nil {
+			t.Error(pErr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Push did not unblock")
+	}
+
+	tooLarge := message.New([][]byte{make([]byte, 200)})
+	if _, err = d.PushMessage(tooLarge); err != types.ErrMessageTooLarge {
+		t.Errorf("Expected message too large error: %v", err)
+	}
+}
+
+func TestDiskBadConfig(t *testing.T) {
+	conf := NewDiskConfig()
+	if _, err := NewDisk(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing directory")
+	}
+
+	conf.Path = "/tmp/does_not_matter"
+	conf.SyncPolicy = "sometimes"
+	if _, err := NewDisk(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad sync policy")
+	}
+}
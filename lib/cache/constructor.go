@@ -45,11 +45,18 @@ type TypeSpec struct {
 // Constructors is a map of all cache types with their specs.
 var Constructors = map[string]TypeSpec{}
 
+// Description returns a markdown formatted description of the cache type.
+func (t TypeSpec) Description() string {
+	return t.description
+}
+
 //------------------------------------------------------------------------------
 
 // String constants representing each cache type.
 const (
 	TypeDynamoDB  = "dynamodb"
+	TypeLFU       = "lfu"
+	TypeLRU       = "lru"
 	TypeMemcached = "memcached"
 	TypeMemory    = "memory"
 	TypeRedis     = "redis"
@@ -61,6 +68,8 @@ const (
 type Config struct {
 	Type      string          `json:"type" yaml:"type"`
 	DynamoDB  DynamoDBConfig  `json:"dynamodb" yaml:"dynamodb"`
+	LFU       LFUConfig       `json:"lfu" yaml:"lfu"`
+	LRU       LRUConfig       `json:"lru" yaml:"lru"`
 	Memcached MemcachedConfig `json:"memcached" yaml:"memcached"`
 	Memory    MemoryConfig    `json:"memory" yaml:"memory"`
 	Redis     RedisConfig     `json:"redis" yaml:"redis"`
@@ -71,6 +80,8 @@ func NewConfig() Config {
 	return Config{
 		Type:      "memory",
 		DynamoDB:  NewDynamoDBConfig(),
+		LFU:       NewLFUConfig(),
+		LRU:       NewLRUConfig(),
 		Memcached: NewMemcachedConfig(),
 		Memory:    NewMemoryConfig(),
 		Redis:     NewRedisConfig(),
@@ -0,0 +1,159 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"container/heap"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeLFU] = TypeSpec{
+		constructor: NewLFU,
+		description: `
+The lfu cache stores key/value pairs in memory, and is bounded by a maximum
+number of items (` + "`cap`" + `) and optionally a maximum number of bytes
+(` + "`max_bytes`" + `) counted from the sizes of keys and values. When either
+limit is exceeded the least frequently used items are evicted until the cache is
+within its limits again, where items used equally often are evicted in least
+recently used order. A limit of zero is unbounded.
+
+The usage count of an item is reset when it is evicted or deleted, and each
+write to a key counts as a use.
+
+Items can be given a default ` + "`ttl`" + `, after which they expire, and keys
+can also be given their own TTL, for example with the ` + "`ttl`" + ` field of
+the [` + "`cache`" + ` processor](../processors/README.md#cache). Expired items
+are removed when they are next accessed, or evicted as the cache fills.
+
+This cache is reset every time the service restarts.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// LFUConfig contains config fields for the LFU cache type.
+type LFUConfig struct {
+	Cap      int    `json:"cap" yaml:"cap"`
+	MaxBytes int64  `json:"max_bytes" yaml:"max_bytes"`
+	TTL      string `json:"ttl" yaml:"ttl"`
+}
+
+// NewLFUConfig creates a LFUConfig populated with default values.
+func NewLFUConfig() LFUConfig {
+	return LFUConfig{
+		Cap:      1000,
+		MaxBytes: 0,
+		TTL:      "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewLFU creates a new memory cache that evicts the least frequently used items
+// once its limits are reached.
+func NewLFU(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (types.Cache, error) {
+	c, err := newBoundedCache(
+		conf.LFU.Cap, conf.LFU.MaxBytes, conf.LFU.TTL, newLFUPolicy(), stats,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+//------------------------------------------------------------------------------
+
+// lfuHeap is a min-heap of items ordered by their usage count, and then by the
+// time they were last used.
+type lfuHeap []*boundedItem
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq == h[j].freq {
+		return h[i].tick < h[j].tick
+	}
+	return h[i].freq < h[j].freq
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+	item := x.(*boundedItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[:n-1]
+	return item
+}
+
+type lfuPolicy struct {
+	items lfuHeap
+	tick  int64
+}
+
+func newLFUPolicy() evictionPolicy {
+	return &lfuPolicy{}
+}
+
+func (l *lfuPolicy) add(item *boundedItem) {
+	l.tick++
+	item.freq++
+	item.tick = l.tick
+	heap.Push(&l.items, item)
+}
+
+func (l *lfuPolicy) touch(item *boundedItem) {
+	l.tick++
+	item.freq++
+	item.tick = l.tick
+	heap.Fix(&l.items, item.index)
+}
+
+func (l *lfuPolicy) remove(item *boundedItem) {
+	heap.Remove(&l.items, item.index)
+}
+
+func (l *lfuPolicy) victim() *boundedItem {
+	if len(l.items) == 0 {
+		return nil
+	}
+	return l.items[0]
+}
+
+//------------------------------------------------------------------------------
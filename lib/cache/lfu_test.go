@@ -0,0 +1,135 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func TestLFUCacheEvictCap(t *testing.T) {
+	conf := NewConfig()
+	conf.LFU.Cap = 3
+
+	stats := metrics.NewLocal()
+	c, err := NewLFU(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"a", "b", "c"} {
+		if err = c.Set(k, []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a and c are used more often than b, and c most recently.
+	for _, k := range []string{"a", "a", "c", "b", "c"} {
+		if _, err = c.Get(k); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = c.Set("d", []byte("d")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Get("b"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected b to be evicted: %v", err)
+	}
+
+	// d is now the least frequently used item.
+	if err = c.Set("e", []byte("e")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Get("d"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected d to be evicted: %v", err)
+	}
+	for _, k := range []string{"a", "c", "e"} {
+		if _, err = c.Get(k); err != nil {
+			t.Errorf("Expected %v to remain: %v", k, err)
+		}
+	}
+
+	if exp, act := int64(2), stats.GetCounters()["evicted"]; exp != act {
+		t.Errorf("Wrong count of evictions: %v != %v", act, exp)
+	}
+}
+
+func TestLFUCacheTies(t *testing.T) {
+	conf := NewConfig()
+	conf.LFU.Cap = 2
+
+	c, err := NewLFU(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"a", "b", "c"} {
+		if err = c.Set(k, []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = c.Get("a"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected a to be evicted: %v", err)
+	}
+	for _, k := range []string{"b", "c"} {
+		if _, err = c.Get(k); err != nil {
+			t.Errorf("Expected %v to remain: %v", k, err)
+		}
+	}
+}
+
+func TestLFUCacheDelete(t *testing.T) {
+	conf := NewConfig()
+	conf.LFU.Cap = 2
+
+	c, err := NewLFU(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"a", "b"} {
+		if err = c.Set(k, []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = c.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Add("c", []byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"b", "c"} {
+		if _, err = c.Get(k); err != nil {
+			t.Errorf("Expected %v to remain: %v", k, err)
+		}
+	}
+	if _, err = c.Get("a"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected a to be deleted: %v", err)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,366 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeLRU] = TypeSpec{
+		constructor: NewLRU,
+		description: `
+The lru cache stores key/value pairs in memory, and is bounded by a maximum
+number of items (` + "`cap`" + `) and optionally a maximum number of bytes
+(` + "`max_bytes`" + `) counted from the sizes of keys and values. When either
+limit is exceeded the least recently used items are evicted until the cache is
+within its limits again. A limit of zero is unbounded.
+
+Items can be given a default ` + "`ttl`" + `, after which they expire, and keys
+can also be given their own TTL, for example with the ` + "`ttl`" + ` field of
+the [` + "`cache`" + ` processor](../processors/README.md#cache). Expired items
+are removed when they are next accessed, or evicted as the cache fills.
+
+This cache is reset every time the service restarts.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// LRUConfig contains config fields for the LRU cache type.
+type LRUConfig struct {
+	Cap      int    `json:"cap" yaml:"cap"`
+	MaxBytes int64  `json:"max_bytes" yaml:"max_bytes"`
+	TTL      string `json:"ttl" yaml:"ttl"`
+}
+
+// NewLRUConfig creates a LRUConfig populated with default values.
+func NewLRUConfig() LRUConfig {
+	return LRUConfig{
+		Cap:      1000,
+		MaxBytes: 0,
+		TTL:      "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewLRU creates a new memory cache that evicts the least recently used items
+// once its limits are reached.
+func NewLRU(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (types.Cache, error) {
+	c, err := newBoundedCache(
+		conf.LRU.Cap, conf.LRU.MaxBytes, conf.LRU.TTL, newLRUPolicy(), stats,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+//------------------------------------------------------------------------------
+
+type boundedItem struct {
+	key     string
+	value   []byte
+	expires time.Time
+
+	// Fields used by eviction policies.
+	elem  *list.Element
+	freq  int64
+	tick  int64
+	index int
+}
+
+func (b *boundedItem) size() int64 {
+	return int64(len(b.key) + len(b.value))
+}
+
+func (b *boundedItem) expired(now time.Time) bool {
+	return !b.expires.IsZero() && !now.Before(b.expires)
+}
+
+// evictionPolicy tracks the usage of items in a bounded cache and chooses
+// which item should be evicted next. Items that are written to are removed and
+// added again, which counts as a use.
+type evictionPolicy interface {
+	add(item *boundedItem)
+	touch(item *boundedItem)
+	remove(item *boundedItem)
+	victim() *boundedItem
+}
+
+//------------------------------------------------------------------------------
+
+type lruPolicy struct {
+	order *list.List
+}
+
+func newLRUPolicy() evictionPolicy {
+	return &lruPolicy{order: list.New()}
+}
+
+func (l *lruPolicy) add(item *boundedItem) {
+	item.elem = l.order.PushFront(item)
+}
+
+func (l *lruPolicy) touch(item *boundedItem) {
+	l.order.MoveToFront(item.elem)
+}
+
+func (l *lruPolicy) remove(item *boundedItem) {
+	l.order.Remove(item.elem)
+	item.elem = nil
+}
+
+func (l *lruPolicy) victim() *boundedItem {
+	if back := l.order.Back(); back != nil {
+		return back.Value.(*boundedItem)
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// errItemTooLarge is returned when a single item exceeds the byte capacity of
+// a bounded cache.
+var errItemTooLarge = errors.New("item exceeds the byte capacity of the cache")
+
+// boundedCache is a memory cache bounded by an item count and a byte capacity,
+// where items are evicted according to a policy once a limit is exceeded.
+type boundedCache struct {
+	items    map[string]*boundedItem
+	policy   evictionPolicy
+	cap      int
+	maxBytes int64
+	bytes    int64
+	ttl      time.Duration
+
+	mKeys     metrics.StatGauge
+	mBytes    metrics.StatGauge
+	mEvicted  metrics.StatCounter
+	mExpired  metrics.StatCounter
+	mTooLarge metrics.StatCounter
+
+	mut sync.Mutex
+}
+
+func newBoundedCache(
+	cap int, maxBytes int64, ttlStr string, policy evictionPolicy, stats metrics.Type,
+) (*boundedCache, error) {
+	if cap < 0 {
+		return nil, fmt.Errorf("cap must not be negative: %v", cap)
+	}
+	if maxBytes < 0 {
+		return nil, fmt.Errorf("max_bytes must not be negative: %v", maxBytes)
+	}
+	var ttl time.Duration
+	if len(ttlStr) > 0 {
+		var err error
+		if ttl, err = time.ParseDuration(ttlStr); err != nil {
+			return nil, fmt.Errorf("failed to parse ttl string: %v", err)
+		}
+	}
+	return &boundedCache{
+		items:     map[string]*boundedItem{},
+		policy:    policy,
+		cap:       cap,
+		maxBytes:  maxBytes,
+		ttl:       ttl,
+		mKeys:     stats.GetGauge("keys"),
+		mBytes:    stats.GetGauge("bytes"),
+		mEvicted:  stats.GetCounter("evicted"),
+		mExpired:  stats.GetCounter("expired"),
+		mTooLarge: stats.GetCounter("error.too_large"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (b *boundedCache) removeItem(item *boundedItem) {
+	b.policy.remove(item)
+	delete(b.items, item.key)
+	b.bytes -= item.size()
+}
+
+// evictFor evicts items until there is room for a new item of a given size.
+func (b *boundedCache) evictFor(size int64) {
+	for (b.cap > 0 && len(b.items) >= b.cap) ||
+		(b.maxBytes > 0 && b.bytes+size > b.maxBytes) {
+		victim := b.policy.victim()
+		if victim == nil {
+			return
+		}
+		if victim.expired(time.Now()) {
+			b.mExpired.Incr(1)
+		} else {
+			b.mEvicted.Incr(1)
+		}
+		b.removeItem(victim)
+	}
+}
+
+func (b *boundedCache) updateGauges() {
+	b.mKeys.Set(int64(len(b.items)))
+	b.mBytes.Set(b.bytes)
+}
+
+// getLive returns an item if it exists and has not expired, expired items are
+// removed.
+func (b *boundedCache) getLive(key string, now time.Time) (*boundedItem, bool) {
+	item, exists := b.items[key]
+	if !exists {
+		return nil, false
+	}
+	if item.expired(now) {
+		b.mExpired.Incr(1)
+		b.removeItem(item)
+		b.updateGauges()
+		return nil, false
+	}
+	return item, true
+}
+
+func (b *boundedCache) set(key string, value []byte, ttl time.Duration, now time.Time) error {
+	if ttl == 0 {
+		ttl = b.ttl
+	}
+	var expires time.Time
+	if ttl > 0 {
+		expires = now.Add(ttl)
+	}
+
+	if b.maxBytes > 0 && int64(len(key)+len(value)) > b.maxBytes {
+		b.mTooLarge.Incr(1)
+		return errItemTooLarge
+	}
+
+	// Existing items are removed before evicting so that they cannot be
+	// chosen as the victim.
+	item, exists := b.items[key]
+	if exists {
+		b.removeItem(item)
+		item.value = value
+		item.expires = expires
+	} else {
+		item = &boundedItem{
+			key:     key,
+			value:   value,
+			expires: expires,
+		}
+	}
+
+	b.evictFor(item.size())
+	b.items[key] = item
+	b.bytes += item.size()
+	b.policy.add(item)
+
+	b.updateGauges()
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Get attempts to locate and return a cached value by its key, returns an error
+// if the key does not exist or has expired.
+func (b *boundedCache) Get(key string) ([]byte, error) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	item, exists := b.getLive(key, time.Now())
+	if !exists {
+		return nil, types.ErrKeyNotFound
+	}
+	b.policy.touch(item)
+	return item.value, nil
+}
+
+// Set attempts to set the value of a key.
+func (b *boundedCache) Set(key string, value []byte) error {
+	return b.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL attempts to set the value of a key with a TTL, where a TTL of zero
+// uses the default TTL of the cache.
+func (b *boundedCache) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	return b.set(key, value, ttl, time.Now())
+}
+
+// SetMulti attempts to set the value of multiple keys, returns an error if any
+// keys fail.
+func (b *boundedCache) SetMulti(items map[string][]byte) error {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	now := time.Now()
+	for k, v := range items {
+		if err := b.set(k, v, 0, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add attempts to set the value of a key only if the key does not already exist
+// and returns an error if the key already exists.
+func (b *boundedCache) Add(key string, value []byte) error {
+	return b.AddWithTTL(key, value, 0)
+}
+
+// AddWithTTL attempts to set the value of a key with a TTL only if the key does
+// not already exist and returns an error if the key already exists. A TTL of
+// zero uses the default TTL of the cache.
+func (b *boundedCache) AddWithTTL(key string, value []byte, ttl time.Duration) error {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	now := time.Now()
+	if _, exists := b.getLive(key, now); exists {
+		return types.ErrKeyAlreadyExists
+	}
+	return b.set(key, value, ttl, now)
+}
+
+// Delete attempts to remove a key.
+func (b *boundedCache) Delete(key string) error {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	if item, exists := b.items[key]; exists {
+		b.removeItem(item)
+		b.updateGauges()
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
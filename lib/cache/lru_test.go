@@ -0,0 +1,213 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func TestLRUCache(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeLRU
+
+	c, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expErr := types.ErrKeyNotFound
+	if _, act := c.Get("foo"); act != expErr {
+		t.Errorf("Wrong error returned: %v != %v", act, expErr)
+	}
+
+	if err = c.Set("foo", []byte("1")); err != nil {
+		t.Error(err)
+	}
+
+	exp := "1"
+	if act, err := c.Get("foo"); err != nil {
+		t.Error(err)
+	} else if string(act) != exp {
+		t.Errorf("Wrong result: %v != %v", string(act), exp)
+	}
+
+	if err = c.Add("bar", []byte("2")); err != nil {
+		t.Error(err)
+	}
+
+	expErr = types.ErrKeyAlreadyExists
+	if act := c.Add("foo", []byte("2")); expErr != act {
+		t.Errorf("Wrong error returned: %v != %v", act, expErr)
+	}
+
+	if err = c.SetMulti(map[string][]byte{
+		"foo": []byte("3"),
+		"baz": []byte("4"),
+	}); err != nil {
+		t.Error(err)
+	}
+
+	exp = "3"
+	if act, err := c.Get("foo"); err != nil {
+		t.Error(err)
+	} else if string(act) != exp {
+		t.Errorf("Wrong result: %v != %v", string(act), exp)
+	}
+
+	if err = c.Delete("foo"); err != nil {
+		t.Error(err)
+	}
+
+	expErr = types.ErrKeyNotFound
+	if _, act := c.Get("foo"); act != expErr {
+		t.Errorf("Wrong error returned: %v != %v", act, expErr)
+	}
+}
+
+func TestLRUCacheEvictCap(t *testing.T) {
+	conf := NewConfig()
+	conf.LRU.Cap = 3
+
+	stats := metrics.NewLocal()
+	c, err := NewLRU(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"a", "b", "c"} {
+		if err = c.Set(k, []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reading a makes b the least recently used.
+	if _, err = c.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Set("d", []byte("d")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = c.Get("b"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected b to be evicted: %v", err)
+	}
+	for _, k := range []string{"a", "c", "d"} {
+		if _, err = c.Get(k); err != nil {
+			t.Errorf("Expected %v to remain: %v", k, err)
+		}
+	}
+
+	if exp, act := int64(1), stats.GetCounters()["evicted"]; exp != act {
+		t.Errorf("Wrong count of evictions: %v != %v", act, exp)
+	}
+	if exp, act := int64(3), stats.GetCounters()["keys"]; exp != act {
+		t.Errorf("Wrong count of keys: %v != %v", act, exp)
+	}
+}
+
+func TestLRUCacheEvictBytes(t *testing.T) {
+	conf := NewConfig()
+	conf.LRU.Cap = 0
+	conf.LRU.MaxBytes = 10
+
+	stats := metrics.NewLocal()
+	c, err := NewLRU(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.Set("a", []byte("1234")); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Set("b", []byte("1234")); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := int64(10), stats.GetCounters()["bytes"]; exp != act {
+		t.Errorf("Wrong count of bytes: %v != %v", act, exp)
+	}
+
+	if err = c.Set("c", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Get("a"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected a to be evicted: %v", err)
+	}
+	if exp, act := int64(7), stats.GetCounters()["bytes"]; exp != act {
+		t.Errorf("Wrong count of bytes: %v != %v", act, exp)
+	}
+
+	if err = c.Set("d", []byte("this is too large")); err != errItemTooLarge {
+		t.Errorf("Wrong error returned: %v != %v", err, errItemTooLarge)
+	}
+	if _, err = c.Get("b"); err != nil {
+		t.Errorf("Expected b to remain: %v", err)
+	}
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	conf := NewConfig()
+	conf.LRU.TTL = "1h"
+
+	c, err := NewLRU(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ttlCache, ok := c.(types.CacheWithTTL)
+	if !ok {
+		t.Fatal("Expected cache to support TTLs")
+	}
+
+	if err = c.Set("foo", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err = ttlCache.SetWithTTL("bar", []byte("2"), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err = ttlCache.AddWithTTL("baz", []byte("3"), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	<-time.After(time.Millisecond * 10)
+
+	if _, err = c.Get("foo"); err != nil {
+		t.Error(err)
+	}
+	if _, err = c.Get("bar"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected bar to expire: %v", err)
+	}
+	if err = ttlCache.AddWithTTL("baz", []byte("4"), 0); err != nil {
+		t.Errorf("Expected add of expired key to succeed: %v", err)
+	}
+
+	conf.LRU.TTL = "nope"
+	if _, err = NewLRU(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad ttl")
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -156,11 +156,17 @@ func NewMemcached(
 //------------------------------------------------------------------------------
 
 // getItemFor returns a memcache.Item object ready to be stored in memcache
-func (m *Memcached) getItemFor(key string, value []byte) *memcache.Item {
+func (m *Memcached) getItemFor(key string, value []byte, ttl time.Duration) *memcache.Item {
+	expiration := m.conf.Memcached.TTL
+	if ttl > 0 {
+		// Memcached expirations are in seconds, round up so that short TTLs
+		// are not treated as no expiration.
+		expiration = int32((ttl + time.Second - 1) / time.Second)
+	}
 	return &memcache.Item{
 		Key:        m.conf.Memcached.Prefix + key,
 		Value:      value,
-		Expiration: m.conf.Memcached.TTL,
+		Expiration: expiration,
 	}
 }
 
@@ -193,15 +199,21 @@ func (m *Memcached) Get(key string) ([]byte, error) {
 
 // Set attempts to set the value of a key.
 func (m *Memcached) Set(key string, value []byte) error {
+	return m.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL attempts to set the value of a key with a TTL, where a TTL of zero
+// uses the configured TTL.
+func (m *Memcached) SetWithTTL(key string, value []byte, ttl time.Duration) error {
 	m.mSetCount.Incr(1)
 	tStarted := time.Now()
 
-	err := m.mc.Set(m.getItemFor(key, value))
+	err := m.mc.Set(m.getItemFor(key, value, ttl))
 	for i := 0; i < m.conf.Memcached.Retries && err != nil; i++ {
 		m.log.Errorf("Set command failed: %v\n", err)
 		<-time.After(m.retryPeriod)
 		m.mSetRetry.Incr(1)
-		err = m.mc.Set(m.getItemFor(key, value))
+		err = m.mc.Set(m.getItemFor(key, value, ttl))
 	}
 	if err != nil {
 		m.mSetFailed.Incr(1)
@@ -231,10 +243,17 @@ func (m *Memcached) SetMulti(items map[string][]byte) error {
 // Add attempts to set the value of a key only if the key does not already exist
 // and returns an error if the key already exists or if the operation fails.
 func (m *Memcached) Add(key string, value []byte) error {
+	return m.AddWithTTL(key, value, 0)
+}
+
+// AddWithTTL attempts to set the value of a key with a TTL only if the key does
+// not already exist and returns an error if the key already exists or if the
+// operation fails. A TTL of zero uses the configured TTL.
+func (m *Memcached) AddWithTTL(key string, value []byte, ttl time.Duration) error {
 	m.mAddCount.Incr(1)
 	tStarted := time.Now()
 
-	err := m.mc.Add(m.getItemFor(key, value))
+	err := m.mc.Add(m.getItemFor(key, value, ttl))
 	if memcache.ErrNotStored == err {
 		m.mAddFailedDupe.Incr(1)
 
@@ -248,7 +267,7 @@ func (m *Memcached) Add(key string, value []byte) error {
 		m.log.Errorf("Add command failed: %v\n", err)
 		<-time.After(m.retryPeriod)
 		m.mAddRetry.Incr(1)
-		if err := m.mc.Add(m.getItemFor(key, value)); memcache.ErrNotStored == err {
+		if err := m.mc.Add(m.getItemFor(key, value, ttl)); memcache.ErrNotStored == err {
 			m.mAddFailedDupe.Incr(1)
 
 			latency := int64(time.Since(tStarted))
@@ -43,7 +43,13 @@ during the next compaction.
 
 A compaction only occurs during a write where the time since the last compaction
 is above the compaction interval. It is therefore possible to obtain values of
-keys that have expired between compactions.`,
+keys that have expired between compactions.
+
+Keys can also be given their own TTL, for example with the ` + "`ttl`" + ` field of
+the [` + "`cache`" + ` processor](../processors/README.md#cache).
+
+This cache has no limit on its size, in order to bound the memory used by cached
+items use the [` + "`lru`" + `](#lru) or [` + "`lfu`" + `](#lfu) caches instead.`,
 	}
 }
 
@@ -68,6 +74,7 @@ func NewMemoryConfig() MemoryConfig {
 type item struct {
 	value []byte
 	ts    time.Time
+	ttl   time.Duration
 }
 
 // Memory is a memory based cache implementation.
@@ -112,7 +119,11 @@ func (m *Memory) compaction() {
 	}
 	m.mCompactions.Incr(1)
 	for k, v := range m.items {
-		if time.Since(v.ts) >= m.ttl {
+		ttl := m.ttl
+		if v.ttl > 0 {
+			ttl = v.ttl
+		}
+		if time.Since(v.ts) >= ttl {
 			delete(m.items, k)
 		}
 	}
@@ -133,9 +144,15 @@ func (m *Memory) Get(key string) ([]byte, error) {
 
 // Set attempts to set the value of a key.
 func (m *Memory) Set(key string, value []byte) error {
+	return m.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL attempts to set the value of a key with a TTL, where a TTL of zero
+// uses the default TTL of the cache.
+func (m *Memory) SetWithTTL(key string, value []byte, ttl time.Duration) error {
 	m.Lock()
 	m.compaction()
-	m.items[key] = item{value: value, ts: time.Now()}
+	m.items[key] = item{value: value, ts: time.Now(), ttl: ttl}
 	m.mKeys.Set(int64(len(m.items)))
 	m.Unlock()
 	return nil
@@ -157,13 +174,20 @@ func (m *Memory) SetMulti(items map[string][]byte) error {
 // Add attempts to set the value of a key only if the key does not already exist
 // and returns an error if the key already exists.
 func (m *Memory) Add(key string, value []byte) error {
+	return m.AddWithTTL(key, value, 0)
+}
+
+// AddWithTTL attempts to set the value of a key with a TTL only if the key does
+// not already exist and returns an error if the key already exists. A TTL of
+// zero uses the default TTL of the cache.
+func (m *Memory) AddWithTTL(key string, value []byte, ttl time.Duration) error {
 	m.Lock()
 	if _, exists := m.items[key]; exists {
 		m.Unlock()
 		return types.ErrKeyAlreadyExists
 	}
 	m.compaction()
-	m.items[key] = item{value: value, ts: time.Now()}
+	m.items[key] = item{value: value, ts: time.Now(), ttl: ttl}
 	m.mKeys.Set(int64(len(m.items)))
 	m.Unlock()
 	return nil
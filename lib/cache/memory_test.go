@@ -23,6 +23,7 @@ package cache
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/Jeffail/benthos/lib/log"
 	"github.com/Jeffail/benthos/lib/metrics"
@@ -141,4 +142,49 @@ func TestMemoryCacheCompaction(t *testing.T) {
 	}
 }
 
+func TestMemoryCacheKeyTTL(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Type = "memory"
+	conf.Memory.TTL = 3600
+	conf.Memory.CompactionInterval = ""
+
+	c, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, ok := c.(types.CacheWithTTL)
+	if !ok {
+		t.Fatal("Memory cache does not support TTLs")
+	}
+
+	if err = tc.SetWithTTL("foo", []byte("1"), time.Millisecond); err != nil {
+		t.Error(err)
+	}
+	if err = tc.AddWithTTL("bar", []byte("2"), 0); err != nil {
+		t.Error(err)
+	}
+
+	<-time.After(time.Millisecond * 5)
+
+	// This should trigger compaction.
+	if err = c.Set("baz", []byte("3")); err != nil {
+		t.Error(err)
+	}
+
+	expErr := types.ErrKeyNotFound
+	if _, act := c.Get("foo"); act != expErr {
+		t.Errorf("Wrong error returned: %v != %v", act, expErr)
+	}
+
+	exp := "2"
+	if act, err := c.Get("bar"); err != nil {
+		t.Error(err)
+	} else if string(act) != exp {
+		t.Errorf("Wrong result: %v != %v", string(act), exp)
+	}
+}
+
 //------------------------------------------------------------------------------
@@ -219,17 +219,26 @@ func (r *Redis) Get(key string) ([]byte, error) {
 
 // Set attempts to set the value of a key.
 func (r *Redis) Set(key string, value []byte) error {
+	return r.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL attempts to set the value of a key with a TTL, where a TTL of zero
+// uses the configured expiration.
+func (r *Redis) SetWithTTL(key string, value []byte, ttl time.Duration) error {
 	r.mSetCount.Incr(1)
 	tStarted := time.Now()
 
 	key = r.prefix + key
+	if ttl == 0 {
+		ttl = r.ttl
+	}
 
-	err := r.client.Set(key, value, r.ttl).Err()
+	err := r.client.Set(key, value, ttl).Err()
 	for i := 0; i < r.conf.Redis.Retries && err != nil; i++ {
 		r.log.Errorf("Set command failed: %v\n", err)
 		<-time.After(r.retryPeriod)
 		r.mSetRetry.Incr(1)
-		err = r.client.Set(key, value, r.ttl).Err()
+		err = r.client.Set(key, value, ttl).Err()
 	}
 	if err != nil {
 		r.mSetFailed.Incr(1)
@@ -259,12 +268,22 @@ func (r *Redis) SetMulti(items map[string][]byte) error {
 // Add attempts to set the value of a key only if the key does not already exist
 // and returns an error if the key already exists or if the operation fails.
 func (r *Redis) Add(key string, value []byte) error {
+	return r.AddWithTTL(key, value, 0)
+}
+
+// AddWithTTL attempts to set the value of a key with a TTL only if the key does
+// not already exist and returns an error if the key already exists or if the
+// operation fails. A TTL of zero uses the configured expiration.
+func (r *Redis) AddWithTTL(key string, value []byte, ttl time.Duration) error {
 	r.mAddCount.Incr(1)
 	tStarted := time.Now()
 
 	key = r.prefix + key
+	if ttl == 0 {
+		ttl = r.ttl
+	}
 
-	set, err := r.client.SetNX(key, value, r.ttl).Result()
+	set, err := r.client.SetNX(key, value, ttl).Result()
 	if !set {
 		r.mAddFailedDupe.Incr(1)
 
@@ -278,7 +297,7 @@ func (r *Redis) Add(key string, value []byte) error {
 		r.log.Errorf("Add command failed: %v\n", err)
 		<-time.After(r.retryPeriod)
 		r.mAddRetry.Incr(1)
-		if set, err = r.client.SetNX(key, value, r.ttl).Result(); !set {
+		if set, err = r.client.SetNX(key, value, ttl).Result(); !set {
 			r.mAddFailedDupe.Incr(1)
 
 			latency := int64(time.Since(tStarted))
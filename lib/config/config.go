@@ -78,6 +78,7 @@ type SanitisedConfig struct {
 	Buffer             interface{} `json:"buffer" yaml:"buffer"`
 	Pipeline           interface{} `json:"pipeline" yaml:"pipeline"`
 	Output             interface{} `json:"output" yaml:"output"`
+	DeadLetter         interface{} `json:"dead_letter,omitempty" yaml:"dead_letter,omitempty"`
 	Manager            interface{} `json:"resources" yaml:"resources"`
 	Logger             interface{} `json:"logger" yaml:"logger"`
 	Metrics            interface{} `json:"metrics" yaml:"metrics"`
@@ -106,6 +107,13 @@ func (c Type) Sanitised() (*SanitisedConfig, error) {
 		return nil, err
 	}
 
+	var deadLetterConf interface{}
+	if c.DeadLetter != nil {
+		if deadLetterConf, err = output.SanitiseConfig(*c.DeadLetter); err != nil {
+			return nil, err
+		}
+	}
+
 	var bufConf interface{}
 	bufConf, err = buffer.SanitiseConfig(c.Buffer)
 	if err != nil {
@@ -136,6 +144,7 @@ func (c Type) Sanitised() (*SanitisedConfig, error) {
 		Buffer:             bufConf,
 		Pipeline:           pipeConf,
 		Output:             outConf,
+		DeadLetter:         deadLetterConf,
 		Manager:            mgrConf,
 		Logger:             c.Logger,
 		Metrics:            metConf,
@@ -200,16 +209,25 @@ func AddExamples(conf *Type, examples ...string) {
 
 //------------------------------------------------------------------------------
 
-// Read will attempt to read a configuration file path into a structure. Returns
-// an array of lint messages or an error.
-func Read(path string, replaceEnvs bool, config *Type) ([]string, error) {
+func readBytes(path string, replaceEnvs bool) ([]byte, error) {
 	configBytes, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-
 	if replaceEnvs {
-		configBytes = text.ReplaceEnvVariables(configBytes)
+		if configBytes, err = text.ReplaceVariables(configBytes); err != nil {
+			return nil, err
+		}
+	}
+	return configBytes, nil
+}
+
+// Read will attempt to read a configuration file path into a structure. Returns
+// an array of lint messages or an error.
+func Read(path string, replaceEnvs bool, config *Type) ([]string, error) {
+	configBytes, err := readBytes(path, replaceEnvs)
+	if err != nil {
+		return nil, err
 	}
 
 	ext := filepath.Ext(path)
@@ -0,0 +1,354 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Jeffail/benthos/lib/buffer"
+	"github.com/Jeffail/benthos/lib/cache"
+	"github.com/Jeffail/benthos/lib/input"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/output"
+	"github.com/Jeffail/benthos/lib/processor"
+	"github.com/Jeffail/benthos/lib/processor/condition"
+	"github.com/Jeffail/benthos/lib/ratelimit"
+	"github.com/Jeffail/benthos/lib/tracer"
+	uconfig "github.com/Jeffail/benthos/lib/util/config"
+)
+
+//------------------------------------------------------------------------------
+
+// schemaComponent describes a component type that is given its own definition
+// within a JSON Schema.
+type schemaComponent struct {
+	name    string
+	conf    interface{}
+	descs   map[string]string
+	plugins map[string]uconfig.PluginDoc
+}
+
+func schemaComponents() []schemaComponent {
+	inputDescs := map[string]string{}
+	for k, v := range input.Constructors {
+		inputDescs[k] = v.Description()
+	}
+	outputDescs := map[string]string{}
+	for k, v := range output.Constructors {
+		outputDescs[k] = v.Description()
+	}
+	procDescs := map[string]string{}
+	for k, v := range processor.Constructors {
+		procDescs[k] = v.Description()
+	}
+	condDescs := map[string]string{}
+	for k, v := range condition.Constructors {
+		condDescs[k] = v.Description()
+	}
+	bufDescs := map[string]string{}
+	for k, v := range buffer.Constructors {
+		bufDescs[k] = v.Description()
+	}
+	cacheDescs := map[string]string{}
+	for k, v := range cache.Constructors {
+		cacheDescs[k] = v.Description()
+	}
+	rlDescs := map[string]string{}
+	for k, v := range ratelimit.Constructors {
+		rlDescs[k] = v.Description()
+	}
+	metDescs := map[string]string{}
+	for k, v := range metrics.Constructors {
+		metDescs[k] = v.Description()
+	}
+	tracDescs := map[string]string{}
+	for k, v := range tracer.Constructors {
+		tracDescs[k] = v.Description()
+	}
+
+	return []schemaComponent{
+		{name: "input", conf: input.NewConfig(), descs: inputDescs, plugins: input.PluginDocs()},
+		{name: "buffer", conf: buffer.NewConfig(), descs: bufDescs},
+		{name: "processor", conf: processor.NewConfig(), descs: procDescs, plugins: processor.PluginDocs()},
+		{name: "condition", conf: condition.NewConfig(), descs: condDescs, plugins: condition.PluginDocs()},
+		{name: "output", conf: output.NewConfig(), descs: outputDescs, plugins: output.PluginDocs()},
+		{name: "cache", conf: cache.NewConfig(), descs: cacheDescs},
+		{name: "rate_limit", conf: ratelimit.NewConfig(), descs: rlDescs},
+		{name: "metrics", conf: metrics.NewConfig(), descs: metDescs},
+		{name: "tracer", conf: tracer.NewConfig(), descs: tracDescs},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// schemaGen walks config structs in order to generate JSON Schemas, where any
+// component types are replaced with references to their definitions.
+type schemaGen struct {
+	refs     map[reflect.Type]string
+	visiting map[reflect.Type]struct{}
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"$ref": "#/definitions/" + name,
+	}
+}
+
+// genericJSON converts a config struct into the generic form that it would be
+// parsed as from a JSON document, which is used for default values.
+func genericJSON(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var g interface{}
+	if err = dec.Decode(&g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *schemaGen) object(t reflect.Type, def interface{}) map[string]interface{} {
+	// Guard against types that contain themselves.
+	if _, exists := g.visiting[t]; exists {
+		return map[string]interface{}{"type": "object"}
+	}
+	g.visiting[t] = struct{}{}
+	defer delete(g.visiting, t)
+
+	defMap, _ := def.(map[string]interface{})
+
+	props := map[string]interface{}{}
+	var allOf []interface{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		// Anonymous structs without a name are inlined, in which case an
+		// inlined component is referenced and otherwise the fields are
+		// merged.
+		if f.Anonymous && len(name) == 0 && ft.Kind() == reflect.Struct {
+			if ref, exists := g.refs[ft]; exists {
+				allOf = append(allOf, schemaRef(ref))
+				continue
+			}
+			inlined := g.object(ft, def)
+			if inlinedProps, ok := inlined["properties"].(map[string]interface{}); ok {
+				for k, v := range inlinedProps {
+					props[k] = v
+				}
+			}
+			if inlinedAllOf, ok := inlined["allOf"].([]interface{}); ok {
+				allOf = append(allOf, inlinedAllOf...)
+			}
+			continue
+		}
+		if len(f.PkgPath) > 0 {
+			continue
+		}
+		if len(name) == 0 {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type, defMap[name], false)
+	}
+
+	if len(props) == 0 && len(allOf) == 1 {
+		return allOf[0].(map[string]interface{})
+	}
+	s := map[string]interface{}{
+		"type": "object",
+	}
+	if len(props) > 0 {
+		s["properties"] = props
+	}
+	if len(allOf) > 0 {
+		s["allOf"] = allOf
+	}
+	return s
+}
+
+// schema returns a JSON Schema for a config type, where def is the generic
+// form of its default value. If root is false and the type is a component then
+// a reference to its definition is returned instead.
+func (g *schemaGen) schema(t reflect.Type, def interface{}, root bool) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if ref, exists := g.refs[t]; exists && !root {
+		return schemaRef(ref)
+	}
+
+	s := map[string]interface{}{}
+	switch t.Kind() {
+	case reflect.Struct:
+		return g.object(t, def)
+	case reflect.Map:
+		s["type"] = "object"
+		s["additionalProperties"] = g.schema(t.Elem(), nil, false)
+		return s
+	case reflect.Slice, reflect.Array:
+		// Byte slices are used for raw values of any type.
+		if t.Elem().Kind() != reflect.Uint8 {
+			s["type"] = "array"
+			s["items"] = g.schema(t.Elem(), nil, false)
+		}
+	case reflect.String:
+		s["type"] = "string"
+	case reflect.Bool:
+		s["type"] = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s["type"] = "integer"
+	case reflect.Float32, reflect.Float64:
+		s["type"] = "number"
+	}
+	if def != nil {
+		s["default"] = def
+	}
+	return s
+}
+
+func (g *schemaGen) component(c schemaComponent) (map[string]interface{}, error) {
+	def, err := genericJSON(c.conf)
+	if err != nil {
+		return nil, err
+	}
+	s := g.schema(reflect.TypeOf(c.conf), def, true)
+	props, _ := s["properties"].(map[string]interface{})
+	if props == nil {
+		props = map[string]interface{}{}
+		s["properties"] = props
+	}
+
+	typeNames := []string{}
+	for name, desc := range c.descs {
+		typeNames = append(typeNames, name)
+		if p, ok := props[name].(map[string]interface{}); ok {
+			p["description"] = strings.TrimSpace(desc)
+		}
+	}
+
+	pluginNames := []string{}
+	for name := range c.plugins {
+		pluginNames = append(pluginNames, name)
+	}
+	sort.Strings(pluginNames)
+
+	var pluginConds []interface{}
+	for _, name := range pluginNames {
+		typeNames = append(typeNames, name)
+
+		doc := c.plugins[name]
+		then := map[string]interface{}{}
+		if doc.Config != nil {
+			pluginDef, err := genericJSON(doc.Config)
+			if err != nil {
+				return nil, err
+			}
+			pluginSchema := g.schema(reflect.TypeOf(doc.Config), pluginDef, false)
+			if len(doc.Description) > 0 {
+				pluginSchema["description"] = strings.TrimSpace(doc.Description)
+			}
+			then["properties"] = map[string]interface{}{
+				"plugin": pluginSchema,
+			}
+		}
+		pluginConds = append(pluginConds, map[string]interface{}{
+			"if": map[string]interface{}{
+				"required": []string{"type"},
+				"properties": map[string]interface{}{
+					"type": map[string]interface{}{"const": name},
+				},
+			},
+			"then": then,
+		})
+	}
+	sort.Strings(typeNames)
+
+	typeProp, _ := props["type"].(map[string]interface{})
+	if typeProp == nil {
+		typeProp = map[string]interface{}{"type": "string"}
+		props["type"] = typeProp
+	}
+	typeProp["enum"] = typeNames
+
+	if len(pluginConds) > 0 {
+		allOf, _ := s["allOf"].([]interface{})
+		s["allOf"] = append(allOf, pluginConds...)
+	}
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
+
+// JSONSchema returns a JSON Schema (draft 7) of the Benthos config format,
+// generated from the registered types of each component and their
+// descriptions, including any registered plugins. This schema can be used by
+// editors in order to validate and autocomplete config files.
+func JSONSchema() (map[string]interface{}, error) {
+	components := schemaComponents()
+
+	g := &schemaGen{
+		refs:     map[reflect.Type]string{},
+		visiting: map[reflect.Type]struct{}{},
+	}
+	for _, c := range components {
+		g.refs[reflect.TypeOf(c.conf)] = c.name
+	}
+
+	definitions := map[string]interface{}{}
+	for _, c := range components {
+		s, err := g.component(c)
+		if err != nil {
+			return nil, err
+		}
+		definitions[c.name] = s
+	}
+
+	def, err := genericJSON(New())
+	if err != nil {
+		return nil, err
+	}
+	s := g.schema(reflect.TypeOf(Type{}), def, true)
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = "Benthos config"
+	s["definitions"] = definitions
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,135 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/input"
+	"github.com/Jeffail/benthos/lib/processor"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+//------------------------------------------------------------------------------
+
+func TestJSONSchemaDefinitions(t *testing.T) {
+	schema, err := JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defs, ok := schema["definitions"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Wrong definitions type: %T", schema["definitions"])
+	}
+	for _, name := range []string{
+		"input", "buffer", "processor", "condition", "output", "cache",
+		"rate_limit", "metrics", "tracer",
+	} {
+		if _, exists := defs[name]; !exists {
+			t.Errorf("Missing definition: %v", name)
+		}
+	}
+
+	procProps := defs["processor"].(map[string]interface{})["properties"].(map[string]interface{})
+	typeEnum := procProps["type"].(map[string]interface{})["enum"].([]string)
+	if exp, act := len(processor.Constructors), len(typeEnum); exp > act {
+		t.Errorf("Wrong count of processor types: %v > %v", exp, act)
+	}
+	if desc, _ := procProps["dedupe"].(map[string]interface{})["description"].(string); len(desc) == 0 {
+		t.Error("Expected dedupe processor description")
+	}
+	if exp, act := "#/definitions/condition", procProps["filter"].(map[string]interface{})["$ref"]; exp != act {
+		t.Errorf("Wrong filter processor schema: %v != %v", act, exp)
+	}
+
+	inProps := defs["input"].(map[string]interface{})["properties"].(map[string]interface{})
+	if exp, act := input.NewConfig().Type, inProps["type"].(map[string]interface{})["default"]; exp != act {
+		t.Errorf("Wrong default input type: %v != %v", act, exp)
+	}
+}
+
+func TestJSONSchemaValidation(t *testing.T) {
+	schema, err := JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loader := gojsonschema.NewGoLoader(schema)
+
+	tests := []struct {
+		name  string
+		conf  string
+		valid bool
+	}{
+		{
+			name:  "empty config",
+			conf:  `{}`,
+			valid: true,
+		},
+		{
+			name: "valid config",
+			conf: `{
+	"input": {
+		"type": "kafka",
+		"kafka": {"addresses": ["localhost:9092"], "topic": "foo"},
+		"processors": [{
+			"type": "filter",
+			"filter": {"type": "not", "not": {"type": "text", "text": {"arg": "bar"}}}
+		}]
+	},
+	"pipeline": {"processors": [{"type": "dedupe", "dedupe": {"cache": "foo"}}]},
+	"output": {
+		"type": "broker",
+		"broker": {"outputs": [{"type": "stdout"}]}
+	},
+	"resources": {"caches": {"foo": {"type": "lru", "lru": {"cap": 10}}}}
+}`,
+			valid: true,
+		},
+		{
+			name:  "unknown input type",
+			conf:  `{"input": {"type": "nope"}}`,
+			valid: false,
+		},
+		{
+			name:  "nested unknown processor type",
+			conf:  `{"output": {"type": "broker", "broker": {"outputs": [{"processors": [{"type": "nope"}]}]}}}`,
+			valid: false,
+		},
+		{
+			name:  "wrong field type",
+			conf:  `{"resources": {"caches": {"foo": {"type": "lru", "lru": {"cap": "ten"}}}}}`,
+			valid: false,
+		},
+	}
+
+	for _, test := range tests {
+		res, err := gojsonschema.Validate(loader, gojsonschema.NewStringLoader(test.conf))
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		if exp, act := test.valid, res.Valid(); exp != act {
+			t.Errorf("%v: Wrong validation result: %v != %v: %v", test.name, act, exp, res.Errors())
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,542 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/buffer"
+	"github.com/Jeffail/benthos/lib/cache"
+	"github.com/Jeffail/benthos/lib/input"
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/manager"
+	"github.com/Jeffail/benthos/lib/message/batch"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/output"
+	"github.com/Jeffail/benthos/lib/output/writer"
+	"github.com/Jeffail/benthos/lib/processor"
+	"github.com/Jeffail/benthos/lib/processor/condition"
+	"github.com/Jeffail/benthos/lib/ratelimit"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// lintManager is a stub types.Manager used for constructing components in a
+// dry-run mode, where only resources declared within a config exist.
+type lintManager struct {
+	caches     map[string]types.Cache
+	conditions map[string]types.Condition
+	rateLimits map[string]types.RateLimit
+}
+
+func (m *lintManager) RegisterEndpoint(path, desc string, h http.HandlerFunc) {}
+
+func (m *lintManager) GetCache(name string) (types.Cache, error) {
+	if c, exists := m.caches[name]; exists {
+		return c, nil
+	}
+	return nil, types.ErrCacheNotFound
+}
+
+func (m *lintManager) GetCondition(name string) (types.Condition, error) {
+	if c, exists := m.conditions[name]; exists {
+		return c, nil
+	}
+	return nil, types.ErrConditionNotFound
+}
+
+func (m *lintManager) GetRateLimit(name string) (types.RateLimit, error) {
+	if r, exists := m.rateLimits[name]; exists {
+		return r, nil
+	}
+	return nil, types.ErrRateLimitNotFound
+}
+
+func (m *lintManager) GetPipe(name string) (<-chan types.Transaction, error) {
+	return nil, types.ErrPipeNotFound
+}
+
+func (m *lintManager) SetPipe(name string, t <-chan types.Transaction)   {}
+func (m *lintManager) UnsetPipe(name string, t <-chan types.Transaction) {}
+
+func (m *lintManager) DryRun() bool {
+	return true
+}
+
+//------------------------------------------------------------------------------
+
+// lintResource is a placeholder for a declared resource that failed to build,
+// which prevents references to it from also being reported.
+type lintResource struct{}
+
+func (l lintResource) Get(key string) ([]byte, error) {
+	return nil, types.ErrKeyNotFound
+}
+func (l lintResource) Set(key string, value []byte) error                   { return nil }
+func (l lintResource) SetMulti(items map[string][]byte) error               { return nil }
+func (l lintResource) Add(key string, value []byte) error                   { return nil }
+func (l lintResource) Delete(key string) error                              { return nil }
+func (l lintResource) Check(msg types.Message) bool                         { return false }
+func (l lintResource) Access() (time.Duration, error)                       { return 0, nil }
+func (l lintResource) SetWithTTL(k string, v []byte, t time.Duration) error { return nil }
+func (l lintResource) AddWithTTL(k string, v []byte, t time.Duration) error { return nil }
+
+//------------------------------------------------------------------------------
+
+// localCaches and localRateLimits are the resource types that can be built
+// without establishing connections, the remaining types are only linted.
+var (
+	localCaches = map[string]struct{}{
+		cache.TypeLFU:    {},
+		cache.TypeLRU:    {},
+		cache.TypeMemory: {},
+	}
+	localRateLimits = map[string]struct{}{
+		ratelimit.TypeLocal:       {},
+		ratelimit.TypeTokenBucket: {},
+	}
+)
+
+var cacheTargetType = reflect.TypeOf(writer.CacheConfig{})
+
+//------------------------------------------------------------------------------
+
+type semanticLinter struct {
+	raw   []byte
+	lints []string
+
+	mgr       *lintManager
+	closables []types.Closable
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+func (l *semanticLinter) report(path string, err error) {
+	if line := yamlPathLine(l.raw, path); line > 0 {
+		l.lints = append(l.lints, fmt.Sprintf("line %v: %v: %v", line, path, err))
+		return
+	}
+	l.lints = append(l.lints, fmt.Sprintf("%v: %v", path, err))
+}
+
+func (l *semanticLinter) closable(c interface{}) {
+	if closer, ok := c.(types.Closable); ok {
+		l.closables = append(l.closables, closer)
+	}
+}
+
+func (l *semanticLinter) resources(conf manager.Config) {
+	l.mgr = &lintManager{
+		caches:     map[string]types.Cache{},
+		conditions: map[string]types.Condition{},
+		rateLimits: map[string]types.RateLimit{},
+	}
+
+	// Conditions are only ever looked up by name during construction, and
+	// can refer to each other, therefore they are all declared upfront.
+	for k := range conf.Conditions {
+		l.mgr.conditions[k] = lintResource{}
+	}
+
+	for _, k := range sortedKeys(conf.Caches) {
+		path := "resources.caches." + k
+		if _, exists := localCaches[conf.Caches[k].Type]; !exists {
+			l.typeFields(path, conf.Caches[k].Type, conf.Caches[k], cache.NewConfig())
+			l.mgr.caches[k] = lintResource{}
+			continue
+		}
+		c, err := cache.New(conf.Caches[k], l.mgr, l.log, l.stats)
+		if err != nil {
+			l.report(path, fmt.Errorf("failed to create cache '%v': %v", conf.Caches[k].Type, err))
+			l.mgr.caches[k] = lintResource{}
+			continue
+		}
+		l.closable(c)
+		l.mgr.caches[k] = c
+	}
+	for _, k := range sortedKeys(conf.RateLimits) {
+		path := "resources.rate_limits." + k
+		if _, exists := localRateLimits[conf.RateLimits[k].Type]; !exists {
+			l.typeFields(path, conf.RateLimits[k].Type, conf.RateLimits[k], ratelimit.NewConfig())
+			l.mgr.rateLimits[k] = lintResource{}
+			continue
+		}
+		r, err := ratelimit.New(conf.RateLimits[k], l.mgr, l.log, l.stats)
+		if err != nil {
+			l.report(path, fmt.Errorf("failed to create rate limit '%v': %v", conf.RateLimits[k].Type, err))
+			l.mgr.rateLimits[k] = lintResource{}
+			continue
+		}
+		l.closable(r)
+		l.mgr.rateLimits[k] = r
+	}
+	for _, k := range sortedKeys(conf.Conditions) {
+		l.condition("resources.conditions."+k, conf.Conditions[k])
+	}
+}
+
+func (l *semanticLinter) condition(path string, conf condition.Config) {
+	if _, err := condition.New(conf, l.mgr, l.log, l.stats); err != nil {
+		l.report(path, fmt.Errorf("failed to create condition '%v': %v", conf.Type, err))
+	}
+}
+
+func (l *semanticLinter) processors(path string, confs []processor.Config) {
+	for i, conf := range confs {
+		p, err := processor.New(conf, l.mgr, l.log, l.stats)
+		if err != nil {
+			l.report(fmt.Sprintf("%v[%v]", path, i), fmt.Errorf("failed to create processor '%v': %v", conf.Type, err))
+			continue
+		}
+		l.closables = append(l.closables, p)
+	}
+}
+
+func (l *semanticLinter) input(path string, conf input.Config) {
+	switch conf.Type {
+	case input.TypeBroker:
+		for i, c := range conf.Broker.Inputs {
+			l.input(fmt.Sprintf("%v.broker.inputs[%v]", path, i), c)
+		}
+	case input.TypeDynamic:
+		for _, k := range sortedKeys(conf.Dynamic.Inputs) {
+			l.input(path+".dynamic.inputs."+k, conf.Dynamic.Inputs[k])
+		}
+	case input.TypeReadUntil:
+		if conf.ReadUntil.Input != nil {
+			l.input(path+".read_until.input", *conf.ReadUntil.Input)
+		}
+	}
+	l.typeFields(path, conf.Type, conf, input.NewConfig())
+	l.processors(path+".processors", conf.Processors)
+}
+
+func (l *semanticLinter) output(path string, conf output.Config) {
+	switch conf.Type {
+	case output.TypeBroker:
+		for i, c := range conf.Broker.Outputs {
+			l.output(fmt.Sprintf("%v.broker.outputs[%v]", path, i), c)
+		}
+	case output.TypeDynamic:
+		for _, k := range sortedKeys(conf.Dynamic.Outputs) {
+			l.output(path+".dynamic.outputs."+k, conf.Dynamic.Outputs[k])
+		}
+	case output.TypeRetry:
+		if conf.Retry.Output != nil {
+			l.output(path+".retry.output", *conf.Retry.Output)
+		}
+	case output.TypeSwitch:
+		for i, c := range conf.Switch.Outputs {
+			l.output(fmt.Sprintf("%v.switch.outputs[%v].output", path, i), c.Output)
+		}
+	}
+	l.typeFields(path, conf.Type, conf, output.NewConfig())
+	if _, err := batch.NewPolicy(conf.Batching, l.log, l.stats); err != nil {
+		l.report(path+".batching", fmt.Errorf("failed to create batch policy: %v", err))
+	}
+	l.processors(path+".processors", conf.Processors)
+}
+
+func (l *semanticLinter) buffer(path string, conf buffer.Config) {
+	l.typeFields(path, conf.Type, conf, buffer.NewConfig())
+}
+
+// typeFields lints the section of a component config that matches its type
+// without constructing it, by checking that the resources it references exist,
+// that its conditions can be built and that any field with a duration default
+// can be parsed as one.
+func (l *semanticLinter) typeFields(path, typeStr string, conf, def interface{}) {
+	v, d := reflect.ValueOf(conf), reflect.ValueOf(def)
+	for i := 0; i < v.NumField(); i++ {
+		if name := jsonFieldName(v.Type().Field(i)); name == typeStr {
+			l.fields(path+"."+name, v.Field(i), d.Field(i))
+			return
+		}
+	}
+}
+
+func (l *semanticLinter) fields(path string, v, def reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		if def.IsValid() && !def.IsNil() {
+			def = def.Elem()
+		} else {
+			def = reflect.Value{}
+		}
+		l.fields(path, v.Elem(), def)
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			var elemDef reflect.Value
+			if def.IsValid() && i < def.Len() {
+				elemDef = def.Index(i)
+			}
+			l.fields(fmt.Sprintf("%v[%v]", path, i), v.Index(i), elemDef)
+		}
+	case reflect.Struct:
+		switch c := v.Interface().(type) {
+		case condition.Config:
+			l.condition(path, c)
+			return
+		case input.Config, output.Config, processor.Config:
+			// Linted separately.
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name := jsonFieldName(field)
+			if len(field.PkgPath) > 0 || name == "-" {
+				continue
+			}
+			fieldPath := path
+			if len(name) > 0 {
+				fieldPath = path + "." + name
+			}
+			var fieldDef reflect.Value
+			if def.IsValid() {
+				fieldDef = def.Field(i)
+			}
+			if f := v.Field(i); f.Kind() == reflect.String {
+				isCache := name == "cache" || name == "token_cache" ||
+					(name == "target" && v.Type() == cacheTargetType)
+				l.stringField(fieldPath, name, isCache, f.String(), fieldDef)
+			} else {
+				l.fields(fieldPath, f, fieldDef)
+			}
+		}
+	}
+}
+
+func (l *semanticLinter) stringField(path, name string, isCache bool, value string, def reflect.Value) {
+	if len(value) == 0 {
+		return
+	}
+	if isCache {
+		if _, err := l.mgr.GetCache(value); err != nil {
+			l.report(path, fmt.Errorf("failed to obtain cache '%v': %v", value, err))
+		}
+		return
+	}
+	if name == "rate_limit" {
+		if _, err := l.mgr.GetRateLimit(value); err != nil {
+			l.report(path, fmt.Errorf("failed to obtain rate limit '%v': %v", value, err))
+		}
+		return
+	}
+	if !def.IsValid() || def.Kind() != reflect.String || def.String() == value {
+		return
+	}
+	// Only fields with a default containing a unit are treated as durations.
+	defStr := def.String()
+	if !strings.ContainsAny(defStr, "smhnuµ") {
+		return
+	}
+	if _, err := time.ParseDuration(defStr); err != nil {
+		return
+	}
+	if _, err := time.ParseDuration(value); err != nil {
+		l.report(path, fmt.Errorf("failed to parse duration: %v", err))
+	}
+}
+
+func (l *semanticLinter) close() {
+	for _, c := range l.closables {
+		c.CloseAsync()
+	}
+	for _, c := range l.closables {
+		c.WaitForClose(time.Second)
+	}
+	l.closables = nil
+}
+
+//------------------------------------------------------------------------------
+
+// LintSemantic attempts to construct each resource, processor and condition of
+// a config in a dry-run mode, where only the resources declared within the
+// config exist, and returns lint results for any that fail. This catches
+// errors such as references to missing resources, unparseable durations and
+// invalid regular expressions.
+//
+// Inputs, outputs, buffers and caches or rate limits that would establish
+// connections are not constructed. Instead, the fields of their configs are
+// checked for references to missing cache and rate limit resources, conditions
+// that fail to build and durations that cannot be parsed.
+//
+// Lint results are labelled with the path of the offending component, and also
+// its line number when it can be found within the raw YAML config.
+func LintSemantic(rawBytes []byte, config Type) []string {
+	l := &semanticLinter{
+		raw:   rawBytes,
+		lints: []string{},
+		log:   log.Noop(),
+		stats: metrics.Noop(),
+	}
+	defer l.close()
+
+	l.resources(config.Manager)
+	l.input("input", config.Input)
+	l.buffer("buffer", config.Buffer)
+	l.processors("pipeline.processors", config.Pipeline.Processors)
+	l.output("output", config.Output)
+	if config.DeadLetter != nil {
+		l.output("dead_letter", *config.DeadLetter)
+	}
+	if len(config.SystemCloseTimeout) > 0 {
+		if _, err := time.ParseDuration(config.SystemCloseTimeout); err != nil {
+			l.report("shutdown_timeout", err)
+		}
+	}
+	return l.lints
+}
+
+// ReadSemanticLints reads a configuration file path and returns the results of
+// LintSemantic for the parsed config.
+func ReadSemanticLints(path string, replaceEnvs bool, config Type) ([]string, error) {
+	configBytes, err := readBytes(path, replaceEnvs)
+	if err != nil {
+		return nil, err
+	}
+	return LintSemantic(configBytes, config), nil
+}
+
+//------------------------------------------------------------------------------
+
+// jsonFieldName returns the name of a struct field within a config, which is
+// empty for inlined fields.
+func jsonFieldName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if i := strings.Index(tag, ","); i >= 0 {
+		tag = tag[:i]
+	}
+	if len(tag) == 0 && !field.Anonymous {
+		return strings.ToLower(field.Name)
+	}
+	return tag
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//------------------------------------------------------------------------------
+
+type yamlPathFrame struct {
+	indent int
+	seg    string
+}
+
+// yamlPathLine attempts to find the line number of a config path, in the form
+// used by lint results (e.g. pipeline.processors[0].text), within a block style
+// YAML document. Returns zero if the path could not be found.
+func yamlPathLine(raw []byte, path string) int {
+	var stack []yamlPathFrame
+	// Tracks the indent of a block scalar, the lines of which are skipped.
+	blockIndent := -1
+
+	current := func() string {
+		var b strings.Builder
+		for _, f := range stack {
+			if !strings.HasPrefix(f.seg, "[") && b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(f.seg)
+		}
+		return b.String()
+	}
+	pushKey := func(indent int, content string) bool {
+		i := strings.Index(content, ":")
+		if i <= 0 || (i+1 < len(content) && content[i+1] != ' ') {
+			return false
+		}
+		key := strings.Trim(strings.TrimSpace(content[:i]), `"'`)
+		stack = append(stack, yamlPathFrame{indent: indent, seg: key})
+		if v := strings.TrimSpace(content[i+1:]); strings.HasPrefix(v, "|") || strings.HasPrefix(v, ">") {
+			blockIndent = indent
+		}
+		return true
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		content := strings.TrimLeft(line, " ")
+		indent := len(line) - len(content)
+		if len(content) == 0 || content[0] == '#' {
+			continue
+		}
+		if blockIndent >= 0 {
+			if indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+
+		if content == "-" || strings.HasPrefix(content, "- ") {
+			// Sequence items may share the indent of their parent key, so only
+			// a previous item of the same indent is a sibling.
+			for len(stack) > 0 && stack[len(stack)-1].indent > indent {
+				stack = stack[:len(stack)-1]
+			}
+			index := 0
+			if n := len(stack); n > 0 && stack[n-1].indent == indent && strings.HasPrefix(stack[n-1].seg, "[") {
+				prev, _ := strconv.Atoi(strings.Trim(stack[n-1].seg, "[]"))
+				index = prev + 1
+				stack = stack[:n-1]
+			}
+			stack = append(stack, yamlPathFrame{indent: indent, seg: fmt.Sprintf("[%v]", index)})
+			if current() == path {
+				return lineNum
+			}
+			item := strings.TrimLeft(content[1:], " ")
+			pushKey(indent+len(content)-len(item), item)
+		} else {
+			for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+				stack = stack[:len(stack)-1]
+			}
+			if !pushKey(indent, content) {
+				continue
+			}
+		}
+		if current() == path {
+			return lineNum
+		}
+	}
+	return 0
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,283 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+//------------------------------------------------------------------------------
+
+func TestConfigSemanticLints(t *testing.T) {
+	type testObj struct {
+		name  string
+		conf  string
+		lints []string
+	}
+
+	tests := []testObj{
+		{
+			name:  "empty object",
+			conf:  `{}`,
+			lints: []string{},
+		},
+		{
+			name: "resources exist",
+			conf: `input:
+  type: stdin
+  processors:
+  - type: dedupe
+    dedupe:
+      cache: foo
+pipeline:
+  processors:
+  - type: filter
+    filter:
+      type: resource
+      resource: bar
+resources:
+  caches:
+    foo:
+      type: lru
+  conditions:
+    bar:
+      type: not
+      not:
+        type: resource
+        resource: baz
+    baz:
+      type: static
+      static: true`,
+			lints: []string{},
+		},
+		{
+			name: "missing cache",
+			conf: `pipeline:
+  processors:
+  - type: noop
+  - type: dedupe
+    dedupe:
+      cache: foo`,
+			lints: []string{
+				"line 4: pipeline.processors[1]: failed to create processor 'dedupe': cache not found",
+			},
+		},
+		{
+			name: "missing condition",
+			conf: `output:
+  type: broker
+  broker:
+    outputs:
+    - type: stdout
+    - type: stdout
+      processors:
+      - type: filter
+        filter:
+          type: resource
+          resource: foo`,
+			lints: []string{
+				"line 8: output.broker.outputs[1].processors[0]: failed to create processor 'filter': failed to construct condition 'resource': failed to obtain condition resource 'foo': condition not found",
+			},
+		},
+		{
+			name: "bad durations",
+			conf: `pipeline:
+  processors:
+  - type: throttle
+    throttle:
+      period: nah
+output:
+  type: stdout
+  batching:
+    period: nope
+shutdown_timeout: nope`,
+			lints: []string{
+				"line 3: pipeline.processors[0]: failed to create processor 'throttle': failed to parse period: ",
+				"line 8: output.batching: failed to create batch policy: failed to parse duration string: ",
+				"line 10: shutdown_timeout: ",
+			},
+		},
+		{
+			name: "bad resources",
+			conf: `resources:
+  caches:
+    foo:
+      type: memory
+      memory:
+        compaction_interval: nah
+  conditions:
+    bar:
+      type: text
+      text:
+        operator: regexp_exact
+        arg: "foo("
+pipeline:
+  processors:
+  - type: dedupe
+    dedupe:
+      cache: foo`,
+			lints: []string{
+				"line 3: resources.caches.foo: failed to create cache 'memory': failed to parse compaction interval string: ",
+				"line 8: resources.conditions.bar: failed to create condition 'text': operator 'regexp_exact': ",
+			},
+		},
+		{
+			name: "bad input output and buffer fields",
+			conf: `input:
+  type: tail
+  tail:
+    path: ./foo.log
+    cache: foo
+    poll_interval: nah
+buffer:
+  type: memory
+output:
+  type: http_client
+  http_client:
+    url: http://localhost:4195/post
+    rate_limit: bar
+dead_letter:
+  type: switch
+  switch:
+    outputs:
+    - condition:
+        type: resource
+        resource: baz
+      output:
+        type: cache
+        cache:
+          target: foo`,
+			lints: []string{
+				"line 5: input.tail.cache: failed to obtain cache 'foo': cache not found",
+				"line 6: input.tail.poll_interval: failed to parse duration: ",
+				"line 13: output.http_client.rate_limit: failed to obtain rate limit 'bar': rate limit not found",
+				"line 24: dead_letter.switch.outputs[0].output.cache.target: failed to obtain cache 'foo': cache not found",
+				"line 18: dead_letter.switch.outputs[0].condition: failed to create condition 'resource': ",
+			},
+		},
+		{
+			name: "network resources are not built",
+			conf: `resources:
+  caches:
+    foo:
+      type: redis
+      redis:
+        url: tcp://localhost:1
+        expiration: nah
+  rate_limits:
+    bar:
+      type: redis
+input:
+  type: tail
+  tail:
+    path: ./foo.log
+    cache: foo
+output:
+  type: http_client
+  http_client:
+    url: http://localhost:4195/post
+    rate_limit: bar`,
+			lints: []string{
+				"line 7: resources.caches.foo.redis.expiration: failed to parse duration: ",
+			},
+		},
+		{
+			name: "json config",
+			conf: `{"pipeline":{"processors":[{"type":"dedupe","dedupe":{"cache":"foo"}}]}}`,
+			lints: []string{
+				"pipeline.processors[0]: failed to create processor 'dedupe': cache not found",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		config := New()
+		if err := yaml.Unmarshal([]byte(test.conf), &config); err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		lints := LintSemantic([]byte(test.conf), config)
+		if len(lints) != len(test.lints) {
+			t.Errorf("%v: Wrong lint results: %v != %v", test.name, lints, test.lints)
+			continue
+		}
+		for i, exp := range test.lints {
+			if !strings.HasPrefix(lints[i], exp) {
+				t.Errorf("%v: Wrong lint result: %v != %v", test.name, lints[i], exp)
+			}
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func TestConfigYAMLPathLine(t *testing.T) {
+	raw := []byte(`# a comment
+input:
+  type: broker
+  broker:
+    inputs:
+    - type: stdin
+      processors:
+      - type: text
+        text:
+          arg: |
+            foo:
+              bar: baz
+          operator: foo
+    - type: kafka
+  processors:
+    -   type: noop
+    - type: dedupe
+      dedupe:
+        cache: nope
+resources:
+  caches:
+    "foo":
+      type: memory
+shutdown_timeout: nah
+`)
+
+	tests := map[string]int{
+		"input":                                             2,
+		"input.broker.inputs[0]":                            6,
+		"input.broker.inputs[0].processors[0]":              8,
+		"input.broker.inputs[0].text.operator":              0,
+		"input.broker.inputs[1]":                            14,
+		"input.processors[0]":                               16,
+		"input.processors[1]":                               17,
+		"input.processors[1].dedupe.cache":                  19,
+		"resources.caches.foo":                              22,
+		"shutdown_timeout":                                  24,
+		"output.processors":                                 0,
+		"input.broker.inputs[0].processors[0].text.arg.foo": 0,
+	}
+
+	for path, exp := range tests {
+		if act := yamlPathLine(raw, path); act != exp {
+			t.Errorf("Wrong line for path '%v': %v != %v", path, act, exp)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -57,6 +57,11 @@ Similarly, it is possible to declare queue bindings by adding objects to the
 TLS is automatic when connecting to an ` + "`amqps`" + ` URL, but custom
 settings can be enabled in the ` + "`tls`" + ` section.
 
+Setting ` + "`extract_traceparent`" + ` to ` + "`true`" + ` continues the traces of
+messages published with a [W3C](https://www.w3.org/TR/trace-context/)
+` + "`traceparent`" + ` header, as the tracing spans of those messages are
+created as its children.
+
 ### Metadata
 
 This input adds the following metadata fields to each message:
@@ -28,16 +28,22 @@ import (
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/Jeffail/benthos/lib/log"
 	"github.com/Jeffail/benthos/lib/message"
 	"github.com/Jeffail/benthos/lib/message/metadata"
+	"github.com/Jeffail/benthos/lib/message/roundtrip"
 	"github.com/Jeffail/benthos/lib/message/tracing"
 	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/processor"
 	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/text"
 	"github.com/Jeffail/benthos/lib/util/throttle"
 	"github.com/gorilla/websocket"
 	"github.com/opentracing/opentracing-go"
@@ -55,6 +61,16 @@ which is enabled when key and cert files are specified.
 You can leave the 'address' config field blank in order to use the instance wide
 HTTP server.
 
+When Benthos is shutting down this input stops accepting new requests, which
+are rejected with a 503 response, whilst requests that are already in flight are
+given the chance to be resolved.
+
+The tracing spans of messages are created as children of the span described by
+the headers of a request, either in the format of the configured
+[tracer](../tracers/README.md) or as a [W3C](https://www.w3.org/TR/trace-context/)
+` + "`traceparent`" + `. Messages sent over a websocket use the headers of the
+upgrade request.
+
 ### Metadata
 
 This input adds the following metadata fields to each message:
@@ -66,7 +82,36 @@ This input adds the following metadata fields to each message:
 ` + "```" + `
 
 You can access these metadata fields using
-[function interpolation](../config_interpolation.md#metadata).`,
+[function interpolation](../config_interpolation.md#metadata).
+
+### Synchronous Responses
+
+By default the response to a request only indicates whether the message was
+successfully delivered. It's possible to return the processed message in the
+body of the response instead by using the
+` + "[`sync_response`](../outputs/README.md#sync_response)" + ` output type.
+
+The status code and headers of a synchronous response are set with the
+` + "`sync_response`" + ` fields, which support
+[function interpolation](../config_interpolation.md#functions) resolved against
+the response message, allowing them to be set from metadata:
+
+` + "``` yaml" + `
+sync_response:
+  status: ${!metadata:http_status}
+  headers:
+    Content-Type: application/json
+` + "```" + `
+
+A response of a single message part is written directly as the body, whereas
+responses of multiple parts are written as a multipart message. If any part of
+the response has been flagged as having failed a processing step the request
+receives a 500 status code with the error as the body instead.
+
+Responses are subject to the same ` + "`timeout`" + ` as message delivery, and
+requests that exceed it receive a 408 status code. When messages are sent over
+a websocket the parts of each response are written back over the websocket as
+individual messages.`,
 	}
 }
 
@@ -80,6 +125,15 @@ type HTTPServerConfig struct {
 	Timeout  string `json:"timeout" yaml:"timeout"`
 	CertFile string `json:"cert_file" yaml:"cert_file"`
 	KeyFile  string `json:"key_file" yaml:"key_file"`
+
+	SyncResponse HTTPServerResponseConfig `json:"sync_response" yaml:"sync_response"`
+}
+
+// HTTPServerResponseConfig contains configuration fields for the synchronous
+// responses of the HTTPServer input type.
+type HTTPServerResponseConfig struct {
+	Status  string            `json:"status" yaml:"status"`
+	Headers map[string]string `json:"headers" yaml:"headers"`
 }
 
 // NewHTTPServerConfig creates a new HTTPServerConfig with default values.
@@ -91,6 +145,12 @@ func NewHTTPServerConfig() HTTPServerConfig {
 		Timeout:  "5s",
 		CertFile: "",
 		KeyFile:  "",
+		SyncResponse: HTTPServerResponseConfig{
+			Status: "200",
+			Headers: map[string]string{
+				"Content-Type": "application/octet-stream",
+			},
+		},
 	}
 }
 
@@ -112,8 +172,16 @@ type HTTPServer struct {
 	server  *http.Server
 	timeout time.Duration
 
+	responseStatus  *text.InterpolatedString
+	responseHeaders map[string]*text.InterpolatedString
+
 	transactions chan types.Transaction
 
+	// Held for reading by each POST request in order to allow a drain to wait
+	// until all in-flight requests are resolved.
+	handlerMut sync.RWMutex
+
+	closeOnce  sync.Once
 	closeChan  chan struct{}
 	closedChan chan struct{}
 
@@ -129,6 +197,7 @@ type HTTPServer struct {
 	mWSSucc     metrics.StatCounter
 	mAsyncErr   metrics.StatCounter
 	mAsyncSucc  metrics.StatCounter
+	mSyncErr    metrics.StatCounter
 }
 
 // NewHTTPServer creates a new HTTPServer input type.
@@ -150,13 +219,17 @@ func NewHTTPServer(conf Config, mgr types.Manager, log log.Modular, stats metric
 	}
 
 	h := HTTPServer{
-		running:      1,
-		conf:         conf,
-		stats:        stats,
-		log:          log,
-		mux:          mux,
-		server:       server,
-		timeout:      timeout,
+		running: 1,
+		conf:    conf,
+		stats:   stats,
+		log:     log,
+		mux:     mux,
+		server:  server,
+		timeout: timeout,
+
+		responseStatus:  text.NewInterpolatedString(conf.HTTPServer.SyncResponse.Status),
+		responseHeaders: map[string]*text.InterpolatedString{},
+
 		transactions: make(chan types.Transaction),
 		closeChan:    make(chan struct{}),
 		closedChan:   make(chan struct{}),
@@ -173,6 +246,11 @@ func NewHTTPServer(conf Config, mgr types.Manager, log log.Modular, stats metric
 		mWSSucc:     stats.GetCounter("ws.send.success"),
 		mAsyncErr:   stats.GetCounter("send.async_error"),
 		mAsyncSucc:  stats.GetCounter("send.async_success"),
+		mSyncErr:    stats.GetCounter("send.sync_error"),
+	}
+
+	for k, v := range conf.HTTPServer.SyncResponse.Headers {
+		h.responseHeaders[k] = text.NewInterpolatedString(v)
 	}
 
 	if mux != nil {
@@ -201,6 +279,15 @@ func (h *HTTPServer) postHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check again once the read lock is held as a drain may have started
+	// whilst we were acquiring it.
+	h.handlerMut.RLock()
+	defer h.handlerMut.RUnlock()
+	if atomic.LoadInt32(&h.running) != 1 {
+		http.Error(w, "Server closing", http.StatusServiceUnavailable)
+		return
+	}
+
 	if r.Method != "POST" {
 		http.Error(w, "Incorrect method", http.StatusMethodNotAllowed)
 		return
@@ -269,6 +356,9 @@ func (h *HTTPServer) postHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer tracing.FinishSpans(msg)
 
+	store := roundtrip.NewResultStore()
+	roundtrip.AddResultStore(msg, store)
+
 	h.mCount.Incr(1)
 	h.mPartsCount.Incr(int64(msg.Len()))
 
@@ -298,6 +388,9 @@ func (h *HTTPServer) postHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		h.mSucc.Incr(1)
+		if responseMsgs := store.Get(); len(responseMsgs) > 0 {
+			h.writeSyncResponse(w, responseMsgs)
+		}
 	case <-time.After(h.timeout):
 		h.mTimeout.Incr(1)
 		http.Error(w, "Request timed out", http.StatusRequestTimeout)
@@ -316,6 +409,67 @@ func (h *HTTPServer) postHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// writeSyncResponse writes a slice of messages stored as synchronous responses
+// of a request, the status code and headers of the response are resolved
+// against the first response message.
+func (h *HTTPServer) writeSyncResponse(w http.ResponseWriter, responseMsgs []types.Message) {
+	for _, responseMsg := range responseMsgs {
+		var failErr string
+		responseMsg.Iter(func(_ int, p types.Part) error {
+			if processor.HasFailed(p) && len(failErr) == 0 {
+				failErr = p.Metadata().Get(processor.FailFlagKey)
+			}
+			return nil
+		})
+		if len(failErr) > 0 {
+			h.mSyncErr.Incr(1)
+			http.Error(w, "Processing failed: "+failErr, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	firstMsg := responseMsgs[0]
+	for k, v := range h.responseHeaders {
+		w.Header().Set(k, v.Get(firstMsg))
+	}
+
+	statusCode := http.StatusOK
+	if statusStr := h.responseStatus.Get(firstMsg); len(statusStr) > 0 {
+		var err error
+		if statusCode, err = strconv.Atoi(statusStr); err != nil {
+			h.log.Errorf("Failed to parse sync response status code expression: %v\n", err)
+			statusCode = http.StatusOK
+		}
+	}
+
+	if len(responseMsgs) == 1 && firstMsg.Len() == 1 {
+		w.WriteHeader(statusCode)
+		w.Write(firstMsg.Get(0).Get())
+		return
+	}
+
+	writer := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+	w.WriteHeader(statusCode)
+
+	for _, responseMsg := range responseMsgs {
+		if err := responseMsg.Iter(func(_ int, p types.Part) error {
+			pw, err := writer.CreatePart(textproto.MIMEHeader{
+				"Content-Type": []string{"application/octet-stream"},
+			})
+			if err != nil {
+				return err
+			}
+			_, err = pw.Write(p.Get())
+			return err
+		}); err != nil {
+			h.log.Errorf("Failed to write sync response: %v\n", err)
+			return
+		}
+	}
+	writer.Close()
+}
+
 func (h *HTTPServer) wsHandler(w http.ResponseWriter, r *http.Request) {
 	var err error
 	defer func() {
@@ -334,6 +488,10 @@ func (h *HTTPServer) wsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer ws.Close()
 
+	// Messages of the connection continue the trace of the upgrade request.
+	carrier := opentracing.HTTPHeadersCarrier(r.Header)
+	clientSpanContext, serr := opentracing.GlobalTracer().Extract(opentracing.HTTPHeaders, carrier)
+
 	resChan := make(chan types.Response)
 	throt := throttle.New(throttle.OptCloseChan(h.closeChan))
 
@@ -359,7 +517,14 @@ func (h *HTTPServer) wsHandler(w http.ResponseWriter, r *http.Request) {
 		for _, c := range r.Cookies() {
 			meta.Set(c.Name, c.Value)
 		}
-		tracing.InitSpans("input_http_server_websocket", msg)
+		if serr == nil {
+			tracing.InitSpansFromParent("input_http_server_websocket", clientSpanContext, msg)
+		} else {
+			tracing.InitSpans("input_http_server_websocket", msg)
+		}
+
+		store := roundtrip.NewResultStore()
+		roundtrip.AddResultStore(msg, store)
 
 		select {
 		case h.transactions <- types.NewTransaction(msg, resChan):
@@ -380,6 +545,14 @@ func (h *HTTPServer) wsHandler(w http.ResponseWriter, r *http.Request) {
 				h.mSucc.Incr(1)
 				msgBytes = nil
 				throt.Reset()
+				for _, responseMsg := range store.Get() {
+					if err = responseMsg.Iter(func(_ int, p types.Part) error {
+						return ws.WriteMessage(websocket.BinaryMessage, p.Get())
+					}); err != nil {
+						h.log.Errorf("Failed to send sync response over websocket: %v\n", err)
+						err = nil
+					}
+				}
 			}
 		case <-h.closeChan:
 			return
@@ -446,11 +619,26 @@ func (h *HTTPServer) Connected() bool {
 	return true
 }
 
+// DrainAsync stops the HTTPServer input from accepting new requests, and
+// closes it once all in-flight POST requests have been resolved.
+func (h *HTTPServer) DrainAsync() {
+	if atomic.CompareAndSwapInt32(&h.running, 1, 0) {
+		go func() {
+			h.handlerMut.Lock()
+			h.closeOnce.Do(func() {
+				close(h.closeChan)
+			})
+			h.handlerMut.Unlock()
+		}()
+	}
+}
+
 // CloseAsync shuts down the HTTPServer input and stops processing requests.
 func (h *HTTPServer) CloseAsync() {
-	if atomic.CompareAndSwapInt32(&h.running, 1, 0) {
+	atomic.StoreInt32(&h.running, 0)
+	h.closeOnce.Do(func() {
 		close(h.closeChan)
-	}
+	})
 }
 
 // WaitForClose blocks until the HTTPServer input has closed down.
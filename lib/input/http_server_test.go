@@ -22,15 +22,24 @@ package input
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"testing"
 	"time"
 
 	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message/roundtrip"
+	"github.com/Jeffail/benthos/lib/message/tracing"
 	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/processor"
 	"github.com/Jeffail/benthos/lib/response"
+	"github.com/Jeffail/benthos/lib/tracer"
 	"github.com/Jeffail/benthos/lib/types"
+	"github.com/gorilla/websocket"
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
 )
 
 func TestHTTPBasic(t *testing.T) {
@@ -144,6 +153,110 @@ func TestHTTPBasic(t *testing.T) {
 	*/
 }
 
+func TestHTTPTraceParent(t *testing.T) {
+	tConf := tracer.NewConfig()
+	tConf.Type = tracer.TypeJaeger
+	tr, err := tracer.New(tConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		tr.Close()
+		opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+	}()
+
+	conf := NewConfig()
+	conf.HTTPServer.Address = "localhost:1247"
+	conf.HTTPServer.Path = "/testpost"
+	conf.HTTPServer.WSPath = "/testpost/ws"
+
+	h, err := NewHTTPServer(conf, nil, log.Noop(), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		h.CloseAsync()
+		if err := h.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	<-time.After(time.Millisecond * 500)
+
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	checkSpan := func(ts types.Transaction) {
+		t.Helper()
+		span := tracing.GetSpan(ts.Payload.Get(0))
+		if span == nil {
+			t.Fatal("Expected a span")
+		}
+		sc := span.Context().(jaeger.SpanContext)
+		if exp, act := (jaeger.TraceID{High: 0x4bf92f3577b34da6, Low: 0xa3ce929d0e0e4736}), sc.TraceID(); exp != act {
+			t.Errorf("Wrong trace id: %v != %v", act, exp)
+		}
+		if exp, act := jaeger.SpanID(0x00f067aa0ba902b7), sc.ParentID(); exp != act {
+			t.Errorf("Wrong parent span id: %v != %v", act, exp)
+		}
+	}
+
+	go func() {
+		req, err := http.NewRequest("POST", "http://localhost:1247/testpost", bytes.NewBuffer([]byte("foo")))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("Traceparent", traceParent)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		res.Body.Close()
+		if res.StatusCode != 200 {
+			t.Errorf("Wrong error code returned: %v", res.StatusCode)
+		}
+	}()
+
+	var ts types.Transaction
+	select {
+	case ts = <-h.TransactionChan():
+		checkSpan(ts)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for message")
+	}
+	select {
+	case ts.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for response")
+	}
+
+	header := http.Header{}
+	header.Set("Traceparent", traceParent)
+	ws, _, err := websocket.DefaultDialer.Dial("ws://localhost:1247/testpost/ws", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	if err = ws.WriteMessage(websocket.BinaryMessage, []byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ts = <-h.TransactionChan():
+		checkSpan(ts)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for message")
+	}
+	select {
+	case ts.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for response")
+	}
+}
+
 func TestHTTPBadRequests(t *testing.T) {
 	t.Parallel()
 
@@ -209,3 +322,184 @@ func TestHTTPTimeout(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestHTTPDrain(t *testing.T) {
+	t.Parallel()
+
+	conf := NewConfig()
+	conf.HTTPServer.Address = "localhost:1236"
+	conf.HTTPServer.Path = "/testpost"
+
+	h, err := NewHTTPServer(conf, nil, log.Noop(), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-time.After(time.Millisecond * 1000)
+
+	post := func() (int, error) {
+		res, perr := http.Post(
+			"http://localhost:1236/testpost",
+			"application/octet-stream",
+			bytes.NewBuffer([]byte("hello world")),
+		)
+		if perr != nil {
+			return 0, perr
+		}
+		return res.StatusCode, nil
+	}
+
+	inFlightChan := make(chan int)
+	go func() {
+		code, perr := post()
+		if perr != nil {
+			t.Error(perr)
+		}
+		inFlightChan <- code
+	}()
+
+	var ts types.Transaction
+	select {
+	case ts = <-h.TransactionChan():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for message")
+	}
+
+	h.(types.Drainer).DrainAsync()
+	if err = h.WaitForClose(time.Millisecond * 100); err != types.ErrTimeout {
+		t.Errorf("Expected timeout whilst draining, received: %v", err)
+	}
+
+	code, err := post()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := http.StatusServiceUnavailable, code; exp != act {
+		t.Errorf("Unexpected status code: %v != %v", act, exp)
+	}
+
+	select {
+	case ts.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for response")
+	}
+	select {
+	case code = <-inFlightChan:
+		if exp, act := http.StatusOK, code; exp != act {
+			t.Errorf("Unexpected status code: %v != %v", act, exp)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for in-flight request")
+	}
+
+	if err = h.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHTTPSyncResponse(t *testing.T) {
+	t.Parallel()
+
+	conf := NewConfig()
+	conf.HTTPServer.Address = "localhost:1234"
+	conf.HTTPServer.Path = "/testpost"
+	conf.HTTPServer.SyncResponse.Status = "${!metadata:status}"
+	conf.HTTPServer.SyncResponse.Headers["X-Foo"] = "${!metadata:foo}"
+
+	h, err := NewHTTPServer(conf, nil, log.Noop(), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-time.After(time.Millisecond * 1000)
+
+	go func() {
+		ts := <-h.TransactionChan()
+		resMsg := ts.Payload.Copy()
+		resMsg.Get(0).Set([]byte("HELLO WORLD"))
+		resMsg.Get(0).Metadata().Set("status", "201").Set("foo", "bar")
+		if serr := roundtrip.SetAsResponse(resMsg); serr != nil {
+			t.Error(serr)
+		}
+		ts.ResponseChan <- response.NewAck()
+	}()
+
+	res, err := http.Post(
+		"http://localhost:1234/testpost",
+		"application/octet-stream",
+		bytes.NewBuffer([]byte("hello world")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := http.StatusCreated, res.StatusCode; exp != act {
+		t.Errorf("Unexpected status code: %v != %v", act, exp)
+	}
+	if exp, act := "bar", res.Header.Get("X-Foo"); exp != act {
+		t.Errorf("Unexpected header: %v != %v", act, exp)
+	}
+	if exp, act := "application/octet-stream", res.Header.Get("Content-Type"); exp != act {
+		t.Errorf("Unexpected content type: %v != %v", act, exp)
+	}
+	resBytes, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "HELLO WORLD", string(resBytes); exp != act {
+		t.Errorf("Unexpected body: %v != %v", act, exp)
+	}
+
+	h.CloseAsync()
+	if err := h.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHTTPSyncResponseFailed(t *testing.T) {
+	t.Parallel()
+
+	conf := NewConfig()
+	conf.HTTPServer.Address = "localhost:1235"
+	conf.HTTPServer.Path = "/testpost"
+
+	h, err := NewHTTPServer(conf, nil, log.Noop(), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-time.After(time.Millisecond * 1000)
+
+	go func() {
+		ts := <-h.TransactionChan()
+		resMsg := ts.Payload.Copy()
+		processor.FlagErr(resMsg.Get(0), errors.New("test error"))
+		if serr := roundtrip.SetAsResponse(resMsg); serr != nil {
+			t.Error(serr)
+		}
+		ts.ResponseChan <- response.NewAck()
+	}()
+
+	res, err := http.Post(
+		"http://localhost:1235/testpost",
+		"application/octet-stream",
+		bytes.NewBuffer([]byte("hello world")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := http.StatusInternalServerError, res.StatusCode; exp != act {
+		t.Errorf("Unexpected status code: %v != %v", act, exp)
+	}
+	resBytes, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "Processing failed: test error\n", string(resBytes); exp != act {
+		t.Errorf("Unexpected body: %v != %v", act, exp)
+	}
+
+	h.CloseAsync()
+	if err := h.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
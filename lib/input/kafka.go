@@ -25,6 +25,7 @@ import (
 	"github.com/Jeffail/benthos/lib/log"
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/kafka/sasl"
 	"github.com/Jeffail/benthos/lib/util/tls"
 )
 
@@ -46,6 +47,12 @@ be split into individual messages with the ` + "`split`" + ` processor.
 The field ` + "`max_processing_period`" + ` should be set above the maximum
 estimated time taken to process a message.
 
+When ` + "`extract_traceparent`" + ` is set to ` + "`true`" + ` the tracing spans of
+messages are created as children of the span described by a
+[W3C](https://www.w3.org/TR/trace-context/) ` + "`traceparent`" + ` header,
+allowing traces to continue from the producer of a message. This requires a
+[tracer](../tracers/README.md) to be configured.
+
 The target version by default will be the oldest supported, as it is expected
 that the server will be backwards compatible. In order to support newer client
 features you should increase this version up to the known version of the target
@@ -53,6 +60,8 @@ server.
 
 ` + tls.Documentation + `
 
+` + sasl.Documentation + `
+
 ### Metadata
 
 This input adds the following metadata fields to each message:
@@ -63,6 +72,7 @@ This input adds the following metadata fields to each message:
 - kafka_partition
 - kafka_offset
 - kafka_timestamp_unix
+- kafka_consumer_group
 - All existing message headers (version 0.11+)
 ` + "```" + `
 
@@ -75,7 +85,7 @@ You can access these metadata fields using
 
 // NewKafka creates a new Kafka input type.
 func NewKafka(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
-	k, err := reader.NewKafka(conf.Kafka, log, stats)
+	k, err := reader.NewKafkaWithMgr(conf.Kafka, mgr, log, stats)
 	if err != nil {
 		return nil, err
 	}
@@ -25,6 +25,7 @@ import (
 	"github.com/Jeffail/benthos/lib/log"
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/kafka/sasl"
 	"github.com/Jeffail/benthos/lib/util/tls"
 )
 
@@ -45,8 +46,15 @@ be split into individual messages with the ` + "`split`" + ` processor.
 The field ` + "`max_processing_period`" + ` should be set above the maximum
 estimated time taken to process a message.
 
+When ` + "`extract_traceparent`" + ` is set to ` + "`true`" + ` the tracing spans of
+messages are created as children of the span described by a
+[W3C](https://www.w3.org/TR/trace-context/) ` + "`traceparent`" + ` header, if
+present.
+
 ` + tls.Documentation + `
 
+` + sasl.Documentation + `
+
 ### Metadata
 
 This input adds the following metadata fields to each message:
@@ -57,6 +65,7 @@ This input adds the following metadata fields to each message:
 - kafka_partition
 - kafka_offset
 - kafka_timestamp_unix
+- kafka_consumer_group
 - All existing message headers (version 0.11+)
 ` + "```" + `
 
@@ -69,7 +78,7 @@ You can access these metadata fields using
 
 // NewKafkaBalanced creates a new KafkaBalanced input type.
 func NewKafkaBalanced(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
-	k, err := reader.NewKafkaBalanced(conf.KafkaBalanced, log, stats)
+	k, err := reader.NewKafkaBalancedWithMgr(conf.KafkaBalanced, mgr, log, stats)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,86 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"github.com/Jeffail/benthos/lib/input/reader"
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeKinesisBalanced] = TypeSpec{
+		constructor: NewKinesisBalanced,
+		description: `
+Receives messages from all shards of a Kinesis stream, balancing the shards
+across any other ` + "`kinesis_balanced`" + ` inputs sharing the same
+` + "`client_id`" + ` and ` + "`dynamodb_table`" + `.
+
+Shards are claimed by writing leases into the DynamoDB table, which has the same
+layout as the checkpoint table of the ` + "`kinesis`" + ` input: a string
+` + "`namespace`" + ` primary key and a string ` + "`shard_id`" + ` sort key.
+Checkpoints written by a ` + "`kinesis`" + ` input with the same
+` + "`client_id`" + ` are therefore honoured.
+
+Every ` + "`rebalance_period`" + ` the shards of the stream are listed, leases
+that are held are renewed and, if this input holds fewer than its share of the
+shards, free leases are claimed. A lease that has not been renewed within its
+` + "`lease_period`" + ` is considered free. When no free leases are available
+a lease is taken from the consumer holding the most, which can result in a
+small number of messages being consumed twice during the hand over.
+
+When a shard is split or merged its child shards are only consumed once the
+parent shards have been read to their end, preserving the order of records with
+the same partition key. Child shards are always consumed from their beginning.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "``` text" + `
+- kinesis_shard
+- kinesis_stream
+` + "```" + `
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewKinesisBalanced creates a new AWS Kinesis balanced input type.
+func NewKinesisBalanced(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	k, err := reader.NewKinesisBalanced(conf.KinesisBalanced, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(
+		"kinesis_balanced",
+		reader.NewPreserver(k),
+		log, stats,
+	)
+}
+
+//------------------------------------------------------------------------------
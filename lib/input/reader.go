@@ -21,6 +21,7 @@
 package input
 
 import (
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -34,10 +35,29 @@ import (
 
 //------------------------------------------------------------------------------
 
+// States of the read loop of a Reader, which determine whether DrainAsync is
+// able to close the underlying reader.
+const (
+	// The loop is neither reading nor waiting on a message.
+	readerStateIdle int32 = iota
+
+	// The loop is blocked on a read, which may be interrupted by closing the
+	// reader.
+	readerStateReading
+
+	// A message has been read and is awaiting acknowledgement, the reader must
+	// remain open until it is resolved.
+	readerStateInFlight
+
+	// The reader was closed by DrainAsync whilst reading.
+	readerStateInterrupted
+)
+
 // Reader is an input implementation that reads messages from a reader.Type.
 type Reader struct {
 	running   int32
 	connected int32
+	state     int32
 
 	typeStr string
 	reader  reader.Type
@@ -50,8 +70,10 @@ type Reader struct {
 	transactions chan types.Transaction
 	responses    chan types.Response
 
-	closeChan  chan struct{}
-	closedChan chan struct{}
+	closeReaderOnce sync.Once
+	closeOnce       sync.Once
+	closeChan       chan struct{}
+	closedChan      chan struct{}
 }
 
 // NewReader creates a new Reader input type.
@@ -102,6 +124,7 @@ func (r *Reader) loop() {
 	)
 
 	defer func() {
+		r.closeReader()
 		err := r.reader.WaitForClose(time.Second)
 		for ; err != nil; err = r.reader.WaitForClose(time.Second) {
 		}
@@ -131,7 +154,14 @@ func (r *Reader) loop() {
 	mConn.Incr(1)
 	atomic.StoreInt32(&r.connected, 1)
 
-	for atomic.LoadInt32(&r.running) == 1 {
+	for {
+		// Flag that we are about to block on a read before checking whether
+		// we are still running, this allows DrainAsync to determine whether
+		// the reader needs closing in order to interrupt the read.
+		atomic.StoreInt32(&r.state, readerStateReading)
+		if atomic.LoadInt32(&r.running) != 1 {
+			return
+		}
 		msg, err := r.reader.Read()
 
 		// If our reader says it is not connected.
@@ -161,12 +191,22 @@ func (r *Reader) loop() {
 			}
 		}
 
+		// If DrainAsync closed the reader in order to interrupt the read then
+		// a message obtained regardless is still propagated, but as the reader
+		// is closed it might not be possible to acknowledge it, in which case
+		// it is left for the source to redeliver.
+		interrupted := !atomic.CompareAndSwapInt32(&r.state, readerStateReading, readerStateInFlight)
+		if interrupted && (err != nil || msg == nil) {
+			return
+		}
+
 		// Close immediately if our reader is closed.
 		if err == types.ErrTypeClosed {
 			return
 		}
 
 		if err != nil || msg == nil {
+			atomic.StoreInt32(&r.state, readerStateIdle)
 			if err != types.ErrTimeout && err != types.ErrNotConnected {
 				mReadError.Incr(1)
 				r.log.Errorf("Failed to read message: %v\n", err)
@@ -218,6 +258,9 @@ func (r *Reader) loop() {
 		if res.Error() != nil || !res.SkipAck() {
 			if err = r.reader.Acknowledge(res.Error()); err != nil {
 				mAckError.Incr(1)
+				if interrupted {
+					r.log.Warnf("Failed to acknowledge message read whilst draining, it may be redelivered: %v\n", err)
+				}
 			} else {
 				tTaken := time.Since(msg.CreatedAt()).Nanoseconds()
 				mLatency.Timing(tTaken)
@@ -225,6 +268,7 @@ func (r *Reader) loop() {
 			}
 		}
 		tracing.FinishSpans(msg)
+		atomic.StoreInt32(&r.state, readerStateIdle)
 	}
 }
 
@@ -240,12 +284,37 @@ func (r *Reader) Connected() bool {
 	return atomic.LoadInt32(&r.connected) == 1
 }
 
+func (r *Reader) closeReader() {
+	r.closeReaderOnce.Do(r.reader.CloseAsync)
+}
+
+// DrainAsync stops the Reader input from reading new messages, but unlike
+// CloseAsync the input continues to wait for in-flight messages to be
+// acknowledged before shutting down.
+func (r *Reader) DrainAsync() {
+	if !atomic.CompareAndSwapInt32(&r.running, 1, 0) {
+		return
+	}
+	if atomic.LoadInt32(&r.connected) == 0 {
+		// Nothing can be in flight whilst we are attempting to connect.
+		r.CloseAsync()
+		return
+	}
+	// Only interrupt the loop whilst it is blocked on a read, an in-flight
+	// message keeps the reader open until it is acknowledged, after which the
+	// loop observes that we are no longer running.
+	if atomic.CompareAndSwapInt32(&r.state, readerStateReading, readerStateInterrupted) {
+		r.closeReader()
+	}
+}
+
 // CloseAsync shuts down the Reader input and stops processing requests.
 func (r *Reader) CloseAsync() {
-	if atomic.CompareAndSwapInt32(&r.running, 1, 0) {
-		r.reader.CloseAsync()
+	atomic.StoreInt32(&r.running, 0)
+	r.closeReader()
+	r.closeOnce.Do(func() {
 		close(r.closeChan)
-	}
+	})
 }
 
 // WaitForClose blocks until the Reader input has closed down.
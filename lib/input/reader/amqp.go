@@ -30,6 +30,7 @@ import (
 
 	"github.com/Jeffail/benthos/lib/log"
 	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/message/tracing"
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/types"
 	btls "github.com/Jeffail/benthos/lib/util/tls"
@@ -54,15 +55,16 @@ type AMQPBindingConfig struct {
 
 // AMQPConfig contains configuration for the AMQP input type.
 type AMQPConfig struct {
-	URL             string                 `json:"url" yaml:"url"`
-	Queue           string                 `json:"queue" yaml:"queue"`
-	QueueDeclare    AMQPQueueDeclareConfig `json:"queue_declare" yaml:"queue_declare"`
-	BindingsDeclare []AMQPBindingConfig    `json:"bindings_declare" yaml:"bindings_declare"`
-	ConsumerTag     string                 `json:"consumer_tag" yaml:"consumer_tag"`
-	PrefetchCount   int                    `json:"prefetch_count" yaml:"prefetch_count"`
-	PrefetchSize    int                    `json:"prefetch_size" yaml:"prefetch_size"`
-	MaxBatchCount   int                    `json:"max_batch_count" yaml:"max_batch_count"`
-	TLS             btls.Config            `json:"tls" yaml:"tls"`
+	URL                string                 `json:"url" yaml:"url"`
+	Queue              string                 `json:"queue" yaml:"queue"`
+	QueueDeclare       AMQPQueueDeclareConfig `json:"queue_declare" yaml:"queue_declare"`
+	BindingsDeclare    []AMQPBindingConfig    `json:"bindings_declare" yaml:"bindings_declare"`
+	ConsumerTag        string                 `json:"consumer_tag" yaml:"consumer_tag"`
+	PrefetchCount      int                    `json:"prefetch_count" yaml:"prefetch_count"`
+	PrefetchSize       int                    `json:"prefetch_size" yaml:"prefetch_size"`
+	MaxBatchCount      int                    `json:"max_batch_count" yaml:"max_batch_count"`
+	ExtractTraceParent bool                   `json:"extract_traceparent" yaml:"extract_traceparent"`
+	TLS                btls.Config            `json:"tls" yaml:"tls"`
 }
 
 // NewAMQPConfig creates a new AMQPConfig with default values.
@@ -74,12 +76,13 @@ func NewAMQPConfig() AMQPConfig {
 			Enabled: false,
 			Durable: true,
 		},
-		ConsumerTag:     "benthos-consumer",
-		PrefetchCount:   10,
-		PrefetchSize:    0,
-		TLS:             btls.NewConfig(),
-		MaxBatchCount:   1,
-		BindingsDeclare: []AMQPBindingConfig{},
+		ConsumerTag:        "benthos-consumer",
+		PrefetchCount:      10,
+		PrefetchSize:       0,
+		TLS:                btls.NewConfig(),
+		MaxBatchCount:      1,
+		ExtractTraceParent: false,
+		BindingsDeclare:    []AMQPBindingConfig{},
 	}
 }
 
@@ -351,6 +354,9 @@ batchLoop:
 	if msg.Len() == 0 {
 		return nil, types.ErrTimeout
 	}
+	if a.conf.ExtractTraceParent {
+		tracing.InitSpansFromTraceParent("input_amqp", msg)
+	}
 	return msg, nil
 }
 
@@ -30,8 +30,10 @@ import (
 
 	"github.com/Jeffail/benthos/lib/log"
 	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/message/tracing"
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/kafka/sasl"
 	btls "github.com/Jeffail/benthos/lib/util/tls"
 	"github.com/Shopify/sarama"
 )
@@ -50,7 +52,9 @@ type KafkaConfig struct {
 	StartFromOldest     bool        `json:"start_from_oldest" yaml:"start_from_oldest"`
 	TargetVersion       string      `json:"target_version" yaml:"target_version"`
 	MaxBatchCount       int         `json:"max_batch_count" yaml:"max_batch_count"`
+	ExtractTraceParent  bool        `json:"extract_traceparent" yaml:"extract_traceparent"`
 	TLS                 btls.Config `json:"tls" yaml:"tls"`
+	SASL                sasl.Config `json:"sasl" yaml:"sasl"`
 }
 
 // NewKafkaConfig creates a new KafkaConfig with default values.
@@ -66,7 +70,9 @@ func NewKafkaConfig() KafkaConfig {
 		StartFromOldest:     true,
 		TargetVersion:       sarama.V1_0_0_0.String(),
 		MaxBatchCount:       1,
+		ExtractTraceParent:  false,
 		TLS:                 btls.NewConfig(),
+		SASL:                sasl.NewConfig(),
 	}
 }
 
@@ -95,6 +101,7 @@ type Kafka struct {
 
 	addresses []string
 	conf      KafkaConfig
+	mgr       types.Manager
 	stats     metrics.Type
 	log       log.Modular
 }
@@ -102,10 +109,19 @@ type Kafka struct {
 // NewKafka creates a new Kafka input type.
 func NewKafka(
 	conf KafkaConfig, log log.Modular, stats metrics.Type,
+) (*Kafka, error) {
+	return NewKafkaWithMgr(conf, types.NoopMgr(), log, stats)
+}
+
+// NewKafkaWithMgr creates a new Kafka input type with a manager, which is used
+// for obtaining the resources referenced by its config.
+func NewKafkaWithMgr(
+	conf KafkaConfig, mgr types.Manager, log log.Modular, stats metrics.Type,
 ) (*Kafka, error) {
 	k := Kafka{
 		offset:  0,
 		conf:    conf,
+		mgr:     mgr,
 		stats:   stats,
 		mRcvErr: stats.GetCounter("recv.error"),
 		log:     log,
@@ -208,6 +224,9 @@ func (k *Kafka) Connect() error {
 	if k.conf.TLS.Enabled {
 		config.Net.TLS.Config = k.tlsConf
 	}
+	if err = k.conf.SASL.Apply(k.mgr, config); err != nil {
+		return err
+	}
 
 	k.client, err = sarama.NewClient(k.addresses, config)
 	if err != nil {
@@ -308,6 +327,7 @@ func (k *Kafka) Read() (types.Message, error) {
 		meta.Set("kafka_topic", data.Topic)
 		meta.Set("kafka_offset", strconv.Itoa(int(data.Offset)))
 		meta.Set("kafka_timestamp_unix", strconv.FormatInt(data.Timestamp.Unix(), 10))
+		meta.Set("kafka_consumer_group", k.conf.ConsumerGroup)
 
 		msg.Append(part)
 	}
@@ -335,6 +355,9 @@ batchLoop:
 	if msg.Len() == 0 {
 		return nil, types.ErrTimeout
 	}
+	if k.conf.ExtractTraceParent {
+		tracing.InitSpansFromTraceParent("input_kafka", msg)
+	}
 	return msg, nil
 }
 
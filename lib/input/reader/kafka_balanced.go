@@ -33,8 +33,10 @@ import (
 
 	"github.com/Jeffail/benthos/lib/log"
 	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/message/tracing"
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/kafka/sasl"
 	btls "github.com/Jeffail/benthos/lib/util/tls"
 	"github.com/Shopify/sarama"
 )
@@ -70,7 +72,9 @@ type KafkaBalancedConfig struct {
 	StartFromOldest     bool                     `json:"start_from_oldest" yaml:"start_from_oldest"`
 	TargetVersion       string                   `json:"target_version" yaml:"target_version"`
 	MaxBatchCount       int                      `json:"max_batch_count" yaml:"max_batch_count"`
+	ExtractTraceParent  bool                     `json:"extract_traceparent" yaml:"extract_traceparent"`
 	TLS                 btls.Config              `json:"tls" yaml:"tls"`
+	SASL                sasl.Config              `json:"sasl" yaml:"sasl"`
 }
 
 // NewKafkaBalancedConfig creates a new KafkaBalancedConfig with default values.
@@ -86,7 +90,9 @@ func NewKafkaBalancedConfig() KafkaBalancedConfig {
 		StartFromOldest:     true,
 		TargetVersion:       sarama.V1_0_0_0.String(),
 		MaxBatchCount:       1,
+		ExtractTraceParent:  false,
 		TLS:                 btls.NewConfig(),
+		SASL:                sasl.NewConfig(),
 	}
 }
 
@@ -116,6 +122,7 @@ type KafkaBalanced struct {
 	mRebalanced metrics.StatCounter
 
 	conf  KafkaBalancedConfig
+	mgr   types.Manager
 	stats metrics.Type
 	log   log.Modular
 }
@@ -123,9 +130,18 @@ type KafkaBalanced struct {
 // NewKafkaBalanced creates a new KafkaBalanced input type.
 func NewKafkaBalanced(
 	conf KafkaBalancedConfig, log log.Modular, stats metrics.Type,
+) (*KafkaBalanced, error) {
+	return NewKafkaBalancedWithMgr(conf, types.NoopMgr(), log, stats)
+}
+
+// NewKafkaBalancedWithMgr creates a new KafkaBalanced input type with a
+// manager, which is used for obtaining the resources referenced by its config.
+func NewKafkaBalancedWithMgr(
+	conf KafkaBalancedConfig, mgr types.Manager, log log.Modular, stats metrics.Type,
 ) (*KafkaBalanced, error) {
 	k := KafkaBalanced{
 		conf:          conf,
+		mgr:           mgr,
 		stats:         stats,
 		groupCancelFn: func() {},
 		log:           log,
@@ -286,6 +302,9 @@ func (k *KafkaBalanced) Connect() error {
 	if k.conf.TLS.Enabled {
 		config.Net.TLS.Config = k.tlsConf
 	}
+	if err := k.conf.SASL.Apply(k.mgr, config); err != nil {
+		return err
+	}
 	if k.conf.StartFromOldest {
 		config.Consumer.Offsets.Initial = sarama.OffsetOldest
 	}
@@ -382,6 +401,7 @@ func (k *KafkaBalanced) Read() (types.Message, error) {
 		meta.Set("kafka_topic", data.Topic)
 		meta.Set("kafka_offset", strconv.Itoa(int(data.Offset)))
 		meta.Set("kafka_timestamp_unix", strconv.FormatInt(data.Timestamp.Unix(), 10))
+		meta.Set("kafka_consumer_group", k.conf.ConsumerGroup)
 
 		msg.Append(part)
 
@@ -411,6 +431,9 @@ batchLoop:
 	if msg.Len() == 0 {
 		return nil, types.ErrTimeout
 	}
+	if k.conf.ExtractTraceParent {
+		tracing.InitSpansFromTraceParent("input_kafka_balanced", msg)
+	}
 	return msg, nil
 }
 
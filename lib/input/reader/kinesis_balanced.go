@@ -0,0 +1,978 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	sess "github.com/Jeffail/benthos/lib/util/aws/session"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+	"github.com/gofrs/uuid"
+)
+
+//------------------------------------------------------------------------------
+
+// KinesisBalancedConfig is configuration values for the input type.
+type KinesisBalancedConfig struct {
+	sess.Config     `json:",inline" yaml:",inline"`
+	Stream          string `json:"stream" yaml:"stream"`
+	DynamoDBTable   string `json:"dynamodb_table" yaml:"dynamodb_table"`
+	ClientID        string `json:"client_id" yaml:"client_id"`
+	Limit           int64  `json:"limit" yaml:"limit"`
+	CommitPeriod    string `json:"commit_period" yaml:"commit_period"`
+	LeasePeriod     string `json:"lease_period" yaml:"lease_period"`
+	RebalancePeriod string `json:"rebalance_period" yaml:"rebalance_period"`
+	StartFromOldest bool   `json:"start_from_oldest" yaml:"start_from_oldest"`
+	Timeout         string `json:"timeout" yaml:"timeout"`
+}
+
+// NewKinesisBalancedConfig creates a new Config with default values.
+func NewKinesisBalancedConfig() KinesisBalancedConfig {
+	return KinesisBalancedConfig{
+		Config:          sess.NewConfig(),
+		Stream:          "",
+		DynamoDBTable:   "",
+		ClientID:        "benthos_consumer",
+		Limit:           100,
+		CommitPeriod:    "1s",
+		LeasePeriod:     "30s",
+		RebalancePeriod: "10s",
+		StartFromOldest: true,
+		Timeout:         "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Attribute names of the DynamoDB items used for checkpoints and leases.
+const (
+	kbAttrNamespace    = "namespace"
+	kbAttrShardID      = "shard_id"
+	kbAttrSequence     = "sequence"
+	kbAttrLeaseOwner   = "lease_owner"
+	kbAttrLeaseTimeout = "lease_timeout"
+	kbAttrClosed       = "closed"
+)
+
+// Condition expressions used when modifying leases.
+const (
+	kbCondLeaseFree = "attribute_not_exists(#owner) OR #timeout < :now"
+	kbCondLeaseHeld = "#owner = :owner"
+	kbCondLeaseOf   = "#owner = :victim"
+)
+
+// kinesisLease is the state of a shard as stored in DynamoDB.
+type kinesisLease struct {
+	shardID  string
+	sequence string
+	owner    string
+	timeout  time.Time
+	closed   bool
+}
+
+func kinesisLeaseFromItem(item map[string]*dynamodb.AttributeValue) *kinesisLease {
+	l := &kinesisLease{}
+	if v := item[kbAttrShardID]; v != nil && v.S != nil {
+		l.shardID = *v.S
+	}
+	if v := item[kbAttrSequence]; v != nil && v.S != nil {
+		l.sequence = *v.S
+	}
+	if v := item[kbAttrLeaseOwner]; v != nil && v.S != nil {
+		l.owner = *v.S
+	}
+	if v := item[kbAttrLeaseTimeout]; v != nil && v.N != nil {
+		if ms, err := strconv.ParseInt(*v.N, 10, 64); err == nil {
+			l.timeout = time.Unix(0, ms*int64(time.Millisecond))
+		}
+	}
+	if v := item[kbAttrClosed]; v != nil && v.BOOL != nil {
+		l.closed = *v.BOOL
+	}
+	return l
+}
+
+func kinesisTimeAttr(t time.Time) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)),
+	}
+}
+
+func isConditionFailed(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
+
+//------------------------------------------------------------------------------
+
+// kinesisShardRecords is a batch of records read from a shard. A batch with
+// finished set marks the end of a closed shard.
+type kinesisShardRecords struct {
+	shardID  string
+	sequence string
+	msg      types.Message
+	finished bool
+}
+
+// kinesisShardConsumer reads records from a single leased shard.
+type kinesisShardConsumer struct {
+	shardID    string
+	iterType   string
+	sequence   string
+	cancelFn   context.CancelFunc
+	closedChan chan struct{}
+}
+
+//------------------------------------------------------------------------------
+
+// KinesisBalanced is a benthos reader.Type implementation that reads messages
+// from all shards of an Amazon Kinesis stream, balancing shards across
+// consumers by claiming leases in a DynamoDB table.
+type KinesisBalanced struct {
+	conf      KinesisBalancedConfig
+	namespace string
+	owner     string
+
+	kinesis kinesisiface.KinesisAPI
+	dynamo  dynamodbiface.DynamoDBAPI
+
+	commitPeriod    time.Duration
+	leasePeriod     time.Duration
+	rebalancePeriod time.Duration
+	idlePeriod      time.Duration
+	timeout         time.Duration
+
+	cMut      sync.Mutex
+	consumers map[string]*kinesisShardConsumer
+
+	aMut       sync.Mutex
+	pending    map[string]string
+	acked      map[string]string
+	lastCommit time.Time
+
+	recordsChan   chan kinesisShardRecords
+	rebalanceChan chan struct{}
+
+	mRebalanced metrics.StatCounter
+	mClaimed    metrics.StatCounter
+	mStolen     metrics.StatCounter
+	mLost       metrics.StatCounter
+	mFinished   metrics.StatCounter
+
+	log   log.Modular
+	stats metrics.Type
+
+	started    int32
+	closeOnce  sync.Once
+	closeChan  chan struct{}
+	closedChan chan struct{}
+	shutOnce   sync.Once
+}
+
+// NewKinesisBalanced creates a new Amazon Kinesis balanced stream reader.Type.
+func NewKinesisBalanced(
+	conf KinesisBalancedConfig,
+	log log.Modular,
+	stats metrics.Type,
+) (*KinesisBalanced, error) {
+	if len(conf.Stream) == 0 {
+		return nil, errors.New("a stream must be specified")
+	}
+	if len(conf.DynamoDBTable) == 0 {
+		return nil, errors.New("a dynamodb_table must be specified")
+	}
+	u4, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	k := &KinesisBalanced{
+		conf:          conf,
+		namespace:     fmt.Sprintf("%v-%v", conf.ClientID, conf.Stream),
+		owner:         fmt.Sprintf("%v-%v", conf.ClientID, u4.String()),
+		idlePeriod:    time.Second,
+		consumers:     map[string]*kinesisShardConsumer{},
+		pending:       map[string]string{},
+		acked:         map[string]string{},
+		recordsChan:   make(chan kinesisShardRecords),
+		rebalanceChan: make(chan struct{}, 1),
+		mRebalanced:   stats.GetCounter("rebalanced"),
+		mClaimed:      stats.GetCounter("lease.claimed"),
+		mStolen:       stats.GetCounter("lease.stolen"),
+		mLost:         stats.GetCounter("lease.lost"),
+		mFinished:     stats.GetCounter("shard.finished"),
+		log:           log,
+		stats:         stats,
+		closeChan:     make(chan struct{}),
+		closedChan:    make(chan struct{}),
+	}
+	for _, d := range []struct {
+		field string
+		value string
+		ptr   *time.Duration
+	}{
+		{"commit period", conf.CommitPeriod, &k.commitPeriod},
+		{"lease period", conf.LeasePeriod, &k.leasePeriod},
+		{"rebalance period", conf.RebalancePeriod, &k.rebalancePeriod},
+		{"timeout", conf.Timeout, &k.timeout},
+	} {
+		if *d.ptr, err = time.ParseDuration(d.value); err != nil {
+			return nil, fmt.Errorf("failed to parse %v string: %v", d.field, err)
+		}
+	}
+	if k.leasePeriod <= k.rebalancePeriod {
+		return nil, errors.New("lease_period must be greater than rebalance_period")
+	}
+	return k, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (k *KinesisBalanced) leaseKey(shardID string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		kbAttrNamespace: {S: aws.String(k.namespace)},
+		kbAttrShardID:   {S: aws.String(shardID)},
+	}
+}
+
+// listShards returns all shards of the stream that are within the retention
+// period, including closed shards.
+func (k *KinesisBalanced) listShards(ctx context.Context) ([]*kinesis.Shard, error) {
+	var shards []*kinesis.Shard
+	input := &kinesis.ListShardsInput{
+		StreamName: aws.String(k.conf.Stream),
+	}
+	for {
+		res, err := k.kinesis.ListShardsWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, res.Shards...)
+		if res.NextToken == nil {
+			return shards, nil
+		}
+		input = &kinesis.ListShardsInput{
+			NextToken: res.NextToken,
+		}
+	}
+}
+
+// getLeases returns the stored state of all shards within our namespace.
+func (k *KinesisBalanced) getLeases(ctx context.Context) (map[string]*kinesisLease, error) {
+	leases := map[string]*kinesisLease{}
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(k.conf.DynamoDBTable),
+		ConsistentRead:         aws.Bool(true),
+		KeyConditionExpression: aws.String("#namespace = :namespace"),
+		ExpressionAttributeNames: map[string]*string{
+			"#namespace": aws.String(kbAttrNamespace),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":namespace": {S: aws.String(k.namespace)},
+		},
+	}
+	for {
+		res, err := k.dynamo.QueryWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range res.Items {
+			l := kinesisLeaseFromItem(item)
+			leases[l.shardID] = l
+		}
+		if len(res.LastEvaluatedKey) == 0 {
+			return leases, nil
+		}
+		input.ExclusiveStartKey = res.LastEvaluatedKey
+	}
+}
+
+// claimLease attempts to take the lease of a shard, either because it is free
+// or, when victim is non-empty, by stealing it from another consumer. Returns
+// the resulting state of the shard.
+func (k *KinesisBalanced) claimLease(ctx context.Context, shardID, victim string, now time.Time) (*kinesisLease, error) {
+	cond := kbCondLeaseFree
+	values := map[string]*dynamodb.AttributeValue{
+		":owner":   {S: aws.String(k.owner)},
+		":timeout": kinesisTimeAttr(now.Add(k.leasePeriod)),
+	}
+	if len(victim) > 0 {
+		cond = kbCondLeaseOf
+		values[":victim"] = &dynamodb.AttributeValue{S: aws.String(victim)}
+	} else {
+		values[":now"] = kinesisTimeAttr(now)
+	}
+	res, err := k.dynamo.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(k.conf.DynamoDBTable),
+		Key:                 k.leaseKey(shardID),
+		UpdateExpression:    aws.String("SET #owner = :owner, #timeout = :timeout"),
+		ConditionExpression: aws.String(cond),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner":   aws.String(kbAttrLeaseOwner),
+			"#timeout": aws.String(kbAttrLeaseTimeout),
+		},
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return kinesisLeaseFromItem(res.Attributes), nil
+}
+
+// renewLease extends the timeout of a lease we hold.
+func (k *KinesisBalanced) renewLease(ctx context.Context, shardID string, now time.Time) error {
+	_, err := k.dynamo.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(k.conf.DynamoDBTable),
+		Key:                 k.leaseKey(shardID),
+		UpdateExpression:    aws.String("SET #timeout = :timeout"),
+		ConditionExpression: aws.String(kbCondLeaseHeld),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner":   aws.String(kbAttrLeaseOwner),
+			"#timeout": aws.String(kbAttrLeaseTimeout),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner":   {S: aws.String(k.owner)},
+			":timeout": kinesisTimeAttr(now.Add(k.leasePeriod)),
+		},
+	})
+	return err
+}
+
+// updateLease modifies a lease we hold. The sequence is committed if it is
+// non-empty, and if release is true the lease is given up, marking the shard as
+// closed when finished is also true.
+func (k *KinesisBalanced) updateLease(ctx context.Context, shardID, sequence string, release, finished bool) error {
+	names := map[string]*string{
+		"#owner": aws.String(kbAttrLeaseOwner),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":owner": {S: aws.String(k.owner)},
+	}
+
+	var set string
+	if len(sequence) > 0 {
+		names["#sequence"] = aws.String(kbAttrSequence)
+		values[":sequence"] = &dynamodb.AttributeValue{S: aws.String(sequence)}
+		set = "#sequence = :sequence"
+	}
+	if finished {
+		names["#closed"] = aws.String(kbAttrClosed)
+		values[":closed"] = &dynamodb.AttributeValue{BOOL: aws.Bool(true)}
+		if len(set) > 0 {
+			set += ", "
+		}
+		set += "#closed = :closed"
+	}
+
+	var expr string
+	if len(set) > 0 {
+		expr = "SET " + set
+	}
+	if release {
+		names["#timeout"] = aws.String(kbAttrLeaseTimeout)
+		if len(expr) > 0 {
+			expr += " "
+		}
+		expr += "REMOVE #owner, #timeout"
+	}
+	if len(expr) == 0 {
+		return nil
+	}
+
+	_, err := k.dynamo.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(k.conf.DynamoDBTable),
+		Key:                       k.leaseKey(shardID),
+		UpdateExpression:          aws.String(expr),
+		ConditionExpression:       aws.String(kbCondLeaseHeld),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	return err
+}
+
+//------------------------------------------------------------------------------
+
+// rebalance discovers shards, renews the leases we hold and claims or steals
+// leases of shards that are ready to be consumed until our share is reached.
+// A shard is ready when it is not closed and each of its parents has either
+// been fully consumed or has expired from the stream.
+func (k *KinesisBalanced) rebalance() error {
+	ctx, done := context.WithTimeout(context.Background(), k.timeout)
+	defer done()
+
+	shards, err := k.listShards(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list shards: %v", err)
+	}
+	leases, err := k.getLeases(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get leases: %v", err)
+	}
+	now := time.Now()
+
+	// DynamoDB is called without holding cMut so that shard consumers aren't
+	// blocked whilst leases are renewed and claimed. Consumers are only ever
+	// started from here, therefore the consumers held meanwhile can only shrink.
+	k.cMut.Lock()
+	snapshot := make([]string, 0, len(k.consumers))
+	for shardID := range k.consumers {
+		snapshot = append(snapshot, shardID)
+	}
+	k.cMut.Unlock()
+
+	ours := make(map[string]struct{}, len(snapshot))
+	var lost []string
+	for _, shardID := range snapshot {
+		if err := k.renewLease(ctx, shardID, now); err != nil {
+			if isConditionFailed(err) {
+				lost = append(lost, shardID)
+				continue
+			}
+			k.log.Errorf("Failed to renew lease of shard '%v': %v\n", shardID, err)
+		}
+		ours[shardID] = struct{}{}
+	}
+
+	if len(lost) > 0 {
+		k.cMut.Lock()
+		for _, shardID := range lost {
+			if _, exists := k.consumers[shardID]; exists {
+				k.log.Infof("Lost lease of shard '%v'\n", shardID)
+				k.mLost.Incr(1)
+				k.stopConsumer(shardID)
+			}
+		}
+		k.cMut.Unlock()
+	}
+
+	listed := make(map[string]struct{}, len(shards))
+	for _, s := range shards {
+		listed[aws.StringValue(s.ShardId)] = struct{}{}
+	}
+	parentsDone := func(s *kinesis.Shard) bool {
+		for _, p := range []*string{s.ParentShardId, s.AdjacentParentShardId} {
+			pID := aws.StringValue(p)
+			if len(pID) == 0 {
+				continue
+			}
+			if _, exists := listed[pID]; !exists {
+				continue
+			}
+			if l, exists := leases[pID]; !exists || !l.closed {
+				return false
+			}
+		}
+		return true
+	}
+	isChild := func(s *kinesis.Shard) bool {
+		for _, p := range []*string{s.ParentShardId, s.AdjacentParentShardId} {
+			if _, exists := listed[aws.StringValue(p)]; exists {
+				return true
+			}
+		}
+		return false
+	}
+
+	var ready []*kinesis.Shard
+	owners := map[string]int{k.owner: len(ours)}
+	held := map[string]string{}
+	for _, s := range shards {
+		shardID := aws.StringValue(s.ShardId)
+		l, exists := leases[shardID]
+		if exists && l.closed {
+			continue
+		}
+		if !parentsDone(s) {
+			continue
+		}
+		ready = append(ready, s)
+		if _, exists := ours[shardID]; exists {
+			held[shardID] = k.owner
+			continue
+		}
+		if exists && len(l.owner) > 0 && l.owner != k.owner && l.timeout.After(now) {
+			owners[l.owner]++
+			held[shardID] = l.owner
+		}
+	}
+
+	target := (len(ready) + len(owners) - 1) / len(owners)
+	claim := func(s *kinesis.Shard, victim string) bool {
+		shardID := aws.StringValue(s.ShardId)
+		l, err := k.claimLease(ctx, shardID, victim, now)
+		if err != nil {
+			if !isConditionFailed(err) {
+				k.log.Errorf("Failed to claim lease of shard '%v': %v\n", shardID, err)
+			}
+			return false
+		}
+		iterType := kinesis.ShardIteratorTypeLatest
+		if k.conf.StartFromOldest || isChild(s) {
+			iterType = kinesis.ShardIteratorTypeTrimHorizon
+		}
+		if len(l.sequence) > 0 {
+			iterType = kinesis.ShardIteratorTypeAfterSequenceNumber
+		}
+		k.cMut.Lock()
+		k.startConsumer(shardID, iterType, l.sequence)
+		k.cMut.Unlock()
+		ours[shardID] = struct{}{}
+		return true
+	}
+
+	for _, s := range ready {
+		if len(ours) >= target {
+			break
+		}
+		if _, exists := held[aws.StringValue(s.ShardId)]; exists {
+			continue
+		}
+		if claim(s, "") {
+			k.log.Infof("Claimed lease of shard '%v'\n", aws.StringValue(s.ShardId))
+			k.mClaimed.Incr(1)
+		}
+	}
+
+	// If we are still short of our share then steal a single lease from the
+	// consumer holding the most, the remainder are balanced over subsequent
+	// rebalances.
+	if len(ours) < target {
+		var victim string
+		for owner, count := range owners {
+			if owner != k.owner && (len(victim) == 0 || count > owners[victim]) {
+				victim = owner
+			}
+		}
+		if len(victim) > 0 && owners[victim]-len(ours) > 1 {
+			for _, s := range ready {
+				if held[aws.StringValue(s.ShardId)] != victim {
+					continue
+				}
+				if claim(s, victim) {
+					k.log.Infof("Stole lease of shard '%v' from '%v'\n", aws.StringValue(s.ShardId), victim)
+					k.mStolen.Incr(1)
+					break
+				}
+			}
+		}
+	}
+
+	k.mRebalanced.Incr(1)
+	return nil
+}
+
+// startConsumer begins consuming a shard, must be called with cMut held.
+func (k *KinesisBalanced) startConsumer(shardID, iterType, sequence string) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	c := &kinesisShardConsumer{
+		shardID:    shardID,
+		iterType:   iterType,
+		sequence:   sequence,
+		cancelFn:   cancelFn,
+		closedChan: make(chan struct{}),
+	}
+	k.consumers[shardID] = c
+	go k.consume(ctx, c)
+}
+
+// stopConsumer stops consuming a shard, must be called with cMut held.
+func (k *KinesisBalanced) stopConsumer(shardID string) {
+	if c, exists := k.consumers[shardID]; exists {
+		c.cancelFn()
+		delete(k.consumers, shardID)
+	}
+	k.aMut.Lock()
+	delete(k.pending, shardID)
+	delete(k.acked, shardID)
+	k.aMut.Unlock()
+}
+
+func (k *KinesisBalanced) ownsShard(shardID string) bool {
+	k.cMut.Lock()
+	_, exists := k.consumers[shardID]
+	k.cMut.Unlock()
+	return exists
+}
+
+//------------------------------------------------------------------------------
+
+// getIter obtains a shard iterator for a consumer, starting after the last
+// record it read.
+func (k *KinesisBalanced) getIter(ctx context.Context, c *kinesisShardConsumer) (string, error) {
+	input := &kinesis.GetShardIteratorInput{
+		ShardId:           aws.String(c.shardID),
+		StreamName:        aws.String(k.conf.Stream),
+		ShardIteratorType: aws.String(c.iterType),
+	}
+	if len(c.sequence) > 0 {
+		input.ShardIteratorType = aws.String(kinesis.ShardIteratorTypeAfterSequenceNumber)
+		input.StartingSequenceNumber = aws.String(c.sequence)
+	}
+	res, err := k.kinesis.GetShardIteratorWithContext(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	if res.ShardIterator == nil {
+		return "", errors.New("failed to obtain shard iterator")
+	}
+	return *res.ShardIterator, nil
+}
+
+// consume reads records from a shard until either the end of the shard is
+// reached or the consumer is stopped.
+func (k *KinesisBalanced) consume(ctx context.Context, c *kinesisShardConsumer) {
+	defer close(c.closedChan)
+
+	wait := func(d time.Duration) bool {
+		select {
+		case <-time.After(d):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	var iter string
+	for {
+		if len(iter) == 0 {
+			var err error
+			if iter, err = k.getIter(ctx, c); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				k.log.Errorf("Failed to obtain iterator of shard '%v': %v\n", c.shardID, err)
+				if !wait(k.idlePeriod) {
+					return
+				}
+				continue
+			}
+		}
+
+		res, err := k.kinesis.GetRecordsWithContext(ctx, &kinesis.GetRecordsInput{
+			Limit:         aws.Int64(k.conf.Limit),
+			ShardIterator: aws.String(iter),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == kinesis.ErrCodeExpiredIteratorException {
+				k.log.Warnf("Iterator of shard '%v' expired, attempting to refresh\n", c.shardID)
+			} else {
+				k.log.Errorf("Failed to read shard '%v': %v\n", c.shardID, err)
+			}
+			iter = ""
+			if !wait(k.idlePeriod) {
+				return
+			}
+			continue
+		}
+
+		msg := message.New(nil)
+		for _, rec := range res.Records {
+			if rec.SequenceNumber != nil {
+				c.sequence = *rec.SequenceNumber
+			}
+			if rec.Data == nil {
+				continue
+			}
+			part := message.NewPart(rec.Data)
+			part.Metadata().Set("kinesis_shard", c.shardID)
+			part.Metadata().Set("kinesis_stream", k.conf.Stream)
+			msg.Append(part)
+		}
+		if msg.Len() > 0 {
+			select {
+			case k.recordsChan <- kinesisShardRecords{
+				shardID:  c.shardID,
+				sequence: c.sequence,
+				msg:      msg,
+			}:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if res.NextShardIterator == nil {
+			select {
+			case k.recordsChan <- kinesisShardRecords{
+				shardID:  c.shardID,
+				sequence: c.sequence,
+				finished: true,
+			}:
+			case <-ctx.Done():
+			}
+			return
+		}
+		iter = *res.NextShardIterator
+
+		if len(res.Records) == 0 && !wait(k.idlePeriod) {
+			return
+		}
+	}
+}
+
+// finishShard marks a fully consumed shard as closed and releases its lease so
+// that its children can be claimed.
+func (k *KinesisBalanced) finishShard(shardID string) {
+	k.aMut.Lock()
+	sequence := k.acked[shardID]
+	delete(k.acked, shardID)
+	k.aMut.Unlock()
+
+	ctx, done := context.WithTimeout(context.Background(), k.timeout)
+	defer done()
+
+	if err := k.updateLease(ctx, shardID, sequence, true, true); err != nil {
+		if isConditionFailed(err) {
+			k.log.Infof("Lost lease of shard '%v'\n", shardID)
+			k.mLost.Incr(1)
+		} else {
+			// The lease is left to expire, at which point the end of the shard
+			// is read again and finishing is reattempted.
+			k.log.Errorf("Failed to mark shard '%v' as finished: %v\n", shardID, err)
+		}
+	} else {
+		k.log.Infof("Finished consuming shard '%v'\n", shardID)
+		k.mFinished.Incr(1)
+	}
+
+	k.cMut.Lock()
+	k.stopConsumer(shardID)
+	k.cMut.Unlock()
+
+	select {
+	case k.rebalanceChan <- struct{}{}:
+	default:
+	}
+}
+
+// commit writes the sequences of acknowledged records to DynamoDB.
+func (k *KinesisBalanced) commit() error {
+	k.aMut.Lock()
+	acked := k.acked
+	k.acked = map[string]string{}
+	k.lastCommit = time.Now()
+	k.aMut.Unlock()
+
+	ctx, done := context.WithTimeout(context.Background(), k.timeout)
+	defer done()
+
+	var lost []string
+	var err error
+	for shardID, sequence := range acked {
+		if uErr := k.updateLease(ctx, shardID, sequence, false, false); uErr != nil {
+			if isConditionFailed(uErr) {
+				lost = append(lost, shardID)
+				continue
+			}
+			err = uErr
+			k.aMut.Lock()
+			if _, exists := k.acked[shardID]; !exists {
+				k.acked[shardID] = sequence
+			}
+			k.aMut.Unlock()
+		}
+	}
+
+	if len(lost) > 0 {
+		k.cMut.Lock()
+		for _, shardID := range lost {
+			if _, exists := k.consumers[shardID]; exists {
+				k.log.Infof("Lost lease of shard '%v'\n", shardID)
+				k.mLost.Incr(1)
+				k.stopConsumer(shardID)
+			}
+		}
+		k.cMut.Unlock()
+	}
+	return err
+}
+
+// loop periodically rebalances leases until the reader is closed.
+func (k *KinesisBalanced) loop() {
+	defer func() {
+		k.cMut.Lock()
+		consumers := make([]*kinesisShardConsumer, 0, len(k.consumers))
+		for _, c := range k.consumers {
+			c.cancelFn()
+			consumers = append(consumers, c)
+		}
+		k.cMut.Unlock()
+		for _, c := range consumers {
+			<-c.closedChan
+		}
+		close(k.closedChan)
+	}()
+
+	ticker := time.NewTicker(k.rebalancePeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-k.rebalanceChan:
+		case <-k.closeChan:
+			return
+		}
+		if err := k.rebalance(); err != nil {
+			k.log.Errorf("Failed to rebalance shards: %v\n", err)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Connect attempts to establish a connection to the target Kinesis stream and
+// claims an initial share of its shards.
+func (k *KinesisBalanced) Connect() error {
+	if atomic.LoadInt32(&k.started) == 1 {
+		return nil
+	}
+	select {
+	case <-k.closeChan:
+		return types.ErrTypeClosed
+	default:
+	}
+
+	if k.kinesis == nil || k.dynamo == nil {
+		sess, err := k.conf.GetSession()
+		if err != nil {
+			return err
+		}
+		k.kinesis = kinesis.New(sess)
+		k.dynamo = dynamodb.New(sess)
+	}
+
+	if err := k.rebalance(); err != nil {
+		return err
+	}
+
+	atomic.StoreInt32(&k.started, 1)
+	go k.loop()
+
+	k.log.Infof("Receiving Amazon Kinesis messages from stream: %v\n", k.conf.Stream)
+	return nil
+}
+
+// Read attempts to read a new message from any of the leased shards.
+func (k *KinesisBalanced) Read() (types.Message, error) {
+	if atomic.LoadInt32(&k.started) == 0 {
+		return nil, types.ErrNotConnected
+	}
+	timeout := time.After(k.timeout)
+	for {
+		select {
+		case recs := <-k.recordsChan:
+			if recs.finished {
+				// Messages are only read once prior messages are acknowledged,
+				// therefore the whole shard has been acknowledged by now.
+				k.finishShard(recs.shardID)
+				continue
+			}
+			if !k.ownsShard(recs.shardID) {
+				continue
+			}
+			k.aMut.Lock()
+			k.pending[recs.shardID] = recs.sequence
+			k.aMut.Unlock()
+			return recs.msg, nil
+		case <-timeout:
+			return nil, types.ErrTimeout
+		case <-k.closeChan:
+			return nil, types.ErrTypeClosed
+		}
+	}
+}
+
+// Acknowledge confirms whether or not our unacknowledged messages have been
+// successfully propagated or not.
+func (k *KinesisBalanced) Acknowledge(err error) error {
+	k.aMut.Lock()
+	if err == nil {
+		for shardID, sequence := range k.pending {
+			k.acked[shardID] = sequence
+		}
+	}
+	k.pending = map[string]string{}
+	due := time.Since(k.lastCommit) >= k.commitPeriod
+	k.aMut.Unlock()
+
+	if !due {
+		return nil
+	}
+	return k.commit()
+}
+
+// CloseAsync begins cleaning up resources used by this reader asynchronously.
+func (k *KinesisBalanced) CloseAsync() {
+	k.closeOnce.Do(func() {
+		close(k.closeChan)
+	})
+}
+
+// WaitForClose will block until either the reader is closed or a specified
+// timeout occurs. Once all shard consumers have stopped the acknowledged
+// sequences are committed and leases released.
+func (k *KinesisBalanced) WaitForClose(timeout time.Duration) error {
+	if atomic.LoadInt32(&k.started) == 0 {
+		return nil
+	}
+	select {
+	case <-k.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	k.shutOnce.Do(func() {
+		if err := k.commit(); err != nil {
+			k.log.Errorf("Failed to commit sequences: %v\n", err)
+		}
+
+		ctx, done := context.WithTimeout(context.Background(), k.timeout)
+		defer done()
+
+		k.cMut.Lock()
+		for shardID := range k.consumers {
+			if err := k.updateLease(ctx, shardID, "", true, false); err != nil && !isConditionFailed(err) {
+				k.log.Errorf("Failed to release lease of shard '%v': %v\n", shardID, err)
+			}
+			delete(k.consumers, shardID)
+		}
+		k.cMut.Unlock()
+	})
+	return nil
+}
+
+//------------------------------------------------------------------------------
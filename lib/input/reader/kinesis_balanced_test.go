@@ -0,0 +1,427 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+)
+
+//------------------------------------------------------------------------------
+
+type mockKinesisShard struct {
+	parent  string
+	records []string
+	closed  bool
+}
+
+// mockKinesis is an in memory stand-in for a Kinesis stream, where shard
+// iterators are of the form <shard>:<index>.
+type mockKinesis struct {
+	kinesisiface.KinesisAPI
+
+	mut    sync.Mutex
+	order  []string
+	shards map[string]*mockKinesisShard
+}
+
+func newMockKinesis() *mockKinesis {
+	return &mockKinesis{
+		shards: map[string]*mockKinesisShard{},
+	}
+}
+
+func (m *mockKinesis) addShard(id, parent string, closed bool, records ...string) {
+	m.mut.Lock()
+	m.order = append(m.order, id)
+	m.shards[id] = &mockKinesisShard{
+		parent:  parent,
+		records: records,
+		closed:  closed,
+	}
+	m.mut.Unlock()
+}
+
+func mockSequence(shardID string, i int) string {
+	return fmt.Sprintf("%v-%06d", shardID, i)
+}
+
+func (m *mockKinesis) ListShardsWithContext(ctx aws.Context, in *kinesis.ListShardsInput, opts ...request.Option) (*kinesis.ListShardsOutput, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	out := &kinesis.ListShardsOutput{}
+	for _, id := range m.order {
+		s := &kinesis.Shard{ShardId: aws.String(id)}
+		if p := m.shards[id].parent; len(p) > 0 {
+			s.ParentShardId = aws.String(p)
+		}
+		out.Shards = append(out.Shards, s)
+	}
+	return out, nil
+}
+
+func (m *mockKinesis) GetShardIteratorWithContext(ctx aws.Context, in *kinesis.GetShardIteratorInput, opts ...request.Option) (*kinesis.GetShardIteratorOutput, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	shardID := aws.StringValue(in.ShardId)
+	s, exists := m.shards[shardID]
+	if !exists {
+		return nil, awserr.New(kinesis.ErrCodeResourceNotFoundException, "shard not found", nil)
+	}
+	var i int
+	switch aws.StringValue(in.ShardIteratorType) {
+	case kinesis.ShardIteratorTypeLatest:
+		i = len(s.records)
+	case kinesis.ShardIteratorTypeAfterSequenceNumber:
+		seq := aws.StringValue(in.StartingSequenceNumber)
+		n, err := strconv.Atoi(seq[strings.LastIndex(seq, "-")+1:])
+		if err != nil {
+			return nil, err
+		}
+		i = n + 1
+	}
+	return &kinesis.GetShardIteratorOutput{
+		ShardIterator: aws.String(fmt.Sprintf("%v:%v", shardID, i)),
+	}, nil
+}
+
+func (m *mockKinesis) GetRecordsWithContext(ctx aws.Context, in *kinesis.GetRecordsInput, opts ...request.Option) (*kinesis.GetRecordsOutput, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	iter := aws.StringValue(in.ShardIterator)
+	shardID := iter[:strings.LastIndex(iter, ":")]
+	i, err := strconv.Atoi(iter[strings.LastIndex(iter, ":")+1:])
+	if err != nil {
+		return nil, err
+	}
+	s := m.shards[shardID]
+
+	out := &kinesis.GetRecordsOutput{}
+	for ; i < len(s.records) && int64(len(out.Records)) < aws.Int64Value(in.Limit); i++ {
+		out.Records = append(out.Records, &kinesis.Record{
+			Data:           []byte(s.records[i]),
+			SequenceNumber: aws.String(mockSequence(shardID, i)),
+		})
+	}
+	if !s.closed || i < len(s.records) {
+		out.NextShardIterator = aws.String(fmt.Sprintf("%v:%v", shardID, i))
+	}
+	return out, nil
+}
+
+//------------------------------------------------------------------------------
+
+// mockDynamoDB is an in memory stand-in for the lease table that understands
+// the expressions used by the kinesis_balanced reader.
+type mockDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+
+	mut   sync.Mutex
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func newMockDynamoDB() *mockDynamoDB {
+	return &mockDynamoDB{
+		items: map[string]map[string]*dynamodb.AttributeValue{},
+	}
+}
+
+func (m *mockDynamoDB) lease(shardID string) *kinesisLease {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	for _, item := range m.items {
+		if aws.StringValue(item[kbAttrShardID].S) == shardID {
+			return kinesisLeaseFromItem(item)
+		}
+	}
+	return nil
+}
+
+func (m *mockDynamoDB) QueryWithContext(ctx aws.Context, in *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	ns := aws.StringValue(in.ExpressionAttributeValues[":namespace"].S)
+	out := &dynamodb.QueryOutput{}
+	for _, item := range m.items {
+		if aws.StringValue(item[kbAttrNamespace].S) == ns {
+			out.Items = append(out.Items, item)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockDynamoDB) UpdateItemWithContext(ctx aws.Context, in *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	name := func(n string) string {
+		return aws.StringValue(in.ExpressionAttributeNames[n])
+	}
+	key := aws.StringValue(in.Key[kbAttrNamespace].S) + "/" + aws.StringValue(in.Key[kbAttrShardID].S)
+	item, exists := m.items[key]
+	if !exists {
+		item = map[string]*dynamodb.AttributeValue{}
+		for k, v := range in.Key {
+			item[k] = v
+		}
+	}
+
+	current := kinesisLeaseFromItem(item)
+	owner := current.owner
+	var condMet bool
+	switch aws.StringValue(in.ConditionExpression) {
+	case kbCondLeaseFree:
+		tout := current.timeout
+		now := kinesisLeaseFromItem(map[string]*dynamodb.AttributeValue{
+			kbAttrLeaseTimeout: in.ExpressionAttributeValues[":now"],
+		}).timeout
+		condMet = len(owner) == 0 || tout.Before(now)
+	case kbCondLeaseHeld:
+		condMet = owner == aws.StringValue(in.ExpressionAttributeValues[":owner"].S)
+	case kbCondLeaseOf:
+		condMet = owner == aws.StringValue(in.ExpressionAttributeValues[":victim"].S)
+	default:
+		return nil, fmt.Errorf("unexpected condition: %v", aws.StringValue(in.ConditionExpression))
+	}
+	if !condMet {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "condition failed", nil)
+	}
+
+	expr := aws.StringValue(in.UpdateExpression)
+	var set, remove string
+	if i := strings.Index(expr, "REMOVE "); i >= 0 {
+		remove = expr[i+len("REMOVE "):]
+		expr = expr[:i]
+	}
+	set = strings.TrimSpace(strings.TrimPrefix(expr, "SET "))
+	if len(set) > 0 {
+		for _, assign := range strings.Split(set, ",") {
+			kv := strings.Split(assign, "=")
+			item[name(strings.TrimSpace(kv[0]))] = in.ExpressionAttributeValues[strings.TrimSpace(kv[1])]
+		}
+	}
+	if len(remove) > 0 {
+		for _, n := range strings.Split(remove, ",") {
+			delete(item, name(strings.TrimSpace(n)))
+		}
+	}
+
+	m.items[key] = item
+	return &dynamodb.UpdateItemOutput{Attributes: item}, nil
+}
+
+//------------------------------------------------------------------------------
+
+func newTestKinesisBalanced(t *testing.T, k *mockKinesis, d *mockDynamoDB) *KinesisBalanced {
+	t.Helper()
+
+	conf := NewKinesisBalancedConfig()
+	conf.Stream = "foostream"
+	conf.DynamoDBTable = "footable"
+	conf.CommitPeriod = "0s"
+	conf.RebalancePeriod = "1h"
+	conf.LeasePeriod = "2h"
+	conf.Timeout = "100ms"
+
+	r, err := NewKinesisBalanced(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.kinesis = k
+	r.dynamo = d
+	r.idlePeriod = time.Millisecond * 10
+	if err = r.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func closeTestKinesisBalanced(t *testing.T, r *KinesisBalanced) {
+	t.Helper()
+
+	r.CloseAsync()
+	if err := r.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+func readKinesisBalanced(t *testing.T, r *KinesisBalanced, n int) []string {
+	t.Helper()
+
+	var res []string
+	deadline := time.Now().Add(time.Second * 5)
+	for len(res) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out after reading: %v", res)
+		}
+		msg, err := r.Read()
+		if err == types.ErrTimeout {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg.Iter(func(i int, p types.Part) error {
+			res = append(res, string(p.Get()))
+			return nil
+		})
+		if err = r.Acknowledge(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res
+}
+
+func (k *KinesisBalanced) consumerCount() int {
+	k.cMut.Lock()
+	defer k.cMut.Unlock()
+	return len(k.consumers)
+}
+
+//------------------------------------------------------------------------------
+
+func TestKinesisBalancedAllShards(t *testing.T) {
+	k, d := newMockKinesis(), newMockDynamoDB()
+	k.addShard("shard-0", "", false, "foo0", "foo1")
+	k.addShard("shard-1", "", false, "bar0")
+	k.addShard("shard-2", "", false, "baz0", "baz1", "baz2")
+
+	r := newTestKinesisBalanced(t, k, d)
+
+	res := map[string]bool{}
+	for _, v := range readKinesisBalanced(t, r, 6) {
+		res[v] = true
+	}
+	for _, exp := range []string{"foo0", "foo1", "bar0", "baz0", "baz1", "baz2"} {
+		if !res[exp] {
+			t.Errorf("Missing message: %v", exp)
+		}
+	}
+	closeTestKinesisBalanced(t, r)
+
+	if exp, act := mockSequence("shard-2", 2), d.lease("shard-2").sequence; exp != act {
+		t.Errorf("Wrong committed sequence: %v != %v", act, exp)
+	}
+	if l := d.lease("shard-0"); len(l.owner) > 0 {
+		t.Errorf("Lease not released: %v", l.owner)
+	}
+
+	// A restarted reader should resume from the committed sequences.
+	k.mut.Lock()
+	k.shards["shard-1"].records = append(k.shards["shard-1"].records, "bar1")
+	k.mut.Unlock()
+
+	r = newTestKinesisBalanced(t, k, d)
+	defer closeTestKinesisBalanced(t, r)
+
+	if act := readKinesisBalanced(t, r, 1); act[0] != "bar1" {
+		t.Errorf("Wrong message after restart: %v", act[0])
+	}
+}
+
+func TestKinesisBalancedLineage(t *testing.T) {
+	k, d := newMockKinesis(), newMockDynamoDB()
+	k.addShard("shard-0", "", true, "foo0", "foo1", "foo2")
+	k.addShard("shard-1", "shard-0", false, "bar0")
+	k.addShard("shard-2", "shard-0", false, "baz0")
+
+	r := newTestKinesisBalanced(t, k, d)
+	defer closeTestKinesisBalanced(t, r)
+
+	if exp, act := 1, r.consumerCount(); exp != act {
+		t.Errorf("Wrong count of consumed shards: %v != %v", act, exp)
+	}
+
+	res := readKinesisBalanced(t, r, 3)
+	for i, exp := range []string{"foo0", "foo1", "foo2"} {
+		if res[i] != exp {
+			t.Errorf("Wrong message %v: %v != %v", i, res[i], exp)
+		}
+	}
+
+	res = readKinesisBalanced(t, r, 2)
+	if !((res[0] == "bar0" && res[1] == "baz0") || (res[0] == "baz0" && res[1] == "bar0")) {
+		t.Errorf("Wrong child messages: %v", res)
+	}
+	if l := d.lease("shard-0"); !l.closed {
+		t.Error("Expected parent shard to be closed")
+	}
+}
+
+func TestKinesisBalancedRebalance(t *testing.T) {
+	k, d := newMockKinesis(), newMockDynamoDB()
+	for i := 0; i < 4; i++ {
+		k.addShard(fmt.Sprintf("shard-%v", i), "", false)
+	}
+
+	rOne := newTestKinesisBalanced(t, k, d)
+	defer closeTestKinesisBalanced(t, rOne)
+	if exp, act := 4, rOne.consumerCount(); exp != act {
+		t.Errorf("Wrong count of consumed shards: %v != %v", act, exp)
+	}
+
+	rTwo := newTestKinesisBalanced(t, k, d)
+	defer closeTestKinesisBalanced(t, rTwo)
+	if exp, act := 1, rTwo.consumerCount(); exp != act {
+		t.Errorf("Wrong count of consumed shards: %v != %v", act, exp)
+	}
+	if err := rTwo.rebalance(); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 2, rTwo.consumerCount(); exp != act {
+		t.Errorf("Wrong count of consumed shards: %v != %v", act, exp)
+	}
+
+	if err := rOne.rebalance(); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 2, rOne.consumerCount(); exp != act {
+		t.Errorf("Wrong count of consumed shards: %v != %v", act, exp)
+	}
+
+	owners := map[string]int{}
+	for i := 0; i < 4; i++ {
+		owners[d.lease(fmt.Sprintf("shard-%v", i)).owner]++
+	}
+	if exp, act := 2, owners[rOne.owner]; exp != act {
+		t.Errorf("Wrong count of leases: %v != %v", act, exp)
+	}
+	if exp, act := 2, owners[rTwo.owner]; exp != act {
+		t.Errorf("Wrong count of leases: %v != %v", act, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,542 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// TailConfig contains configuration fields for the Tail input type.
+type TailConfig struct {
+	Paths              []string `json:"paths" yaml:"paths"`
+	Cache              string   `json:"cache" yaml:"cache"`
+	PollInterval       string   `json:"poll_interval" yaml:"poll_interval"`
+	StartFromBeginning bool     `json:"start_from_beginning" yaml:"start_from_beginning"`
+	Delim              string   `json:"delimiter" yaml:"delimiter"`
+	MaxBuffer          int      `json:"max_buffer" yaml:"max_buffer"`
+}
+
+// NewTailConfig creates a new TailConfig with default values.
+func NewTailConfig() TailConfig {
+	return TailConfig{
+		Paths:              []string{},
+		Cache:              "",
+		PollInterval:       "1s",
+		StartFromBeginning: true,
+		Delim:              "",
+		MaxBuffer:          1000000,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// tailCheckpoint is the persisted progress of a tailed file. Checkpoints are
+// stored under both the path and the device and inode of a file, so that files
+// renamed by a rotation are resumed rather than read again.
+type tailCheckpoint struct {
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+// tailFile is an open file being tailed.
+type tailFile struct {
+	path  string
+	file  *os.File
+	inode uint64
+
+	// id identifies the file by its device and inode and is empty when the
+	// platform does not support it. seq is the order in which files were
+	// opened. moved is set once the file has been renamed to another path.
+	id    string
+	seq   uint64
+	moved bool
+
+	// offset is the position following the last line read, pos is the
+	// position of the file handle and buf contains the bytes between them.
+	offset int64
+	pos    int64
+	buf    []byte
+}
+
+// key returns the key that the file is tracked by, which is its device and
+// inode where supported and otherwise its path.
+func (f *tailFile) key() string {
+	if len(f.id) > 0 {
+		return f.id
+	}
+	return f.path
+}
+
+func (f *tailFile) rewind() error {
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	f.offset, f.pos, f.buf = 0, 0, nil
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Tail is an input type that follows files matching a list of glob patterns,
+// reading lines as they are appended and following truncations and rotations.
+// Progress through each file is checkpointed to a cache once acknowledged.
+type Tail struct {
+	conf         TailConfig
+	cache        types.Cache
+	delim        []byte
+	pollInterval time.Duration
+
+	fMut     sync.Mutex
+	files    map[string]*tailFile
+	draining []*tailFile
+	lastScan time.Time
+	scanned  bool
+	seq      uint64
+
+	pending map[*tailFile]tailCheckpoint
+
+	mRotated   metrics.StatCounter
+	mTruncated metrics.StatCounter
+
+	log   log.Modular
+	stats metrics.Type
+
+	closeOnce sync.Once
+	closeChan chan struct{}
+}
+
+// NewTail creates a new Tail input type.
+func NewTail(
+	conf TailConfig,
+	mgr types.Manager,
+	log log.Modular,
+	stats metrics.Type,
+) (*Tail, error) {
+	if len(conf.Paths) == 0 {
+		return nil, errors.New("at least one path must be specified")
+	}
+	for _, p := range conf.Paths {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("failed to parse path pattern '%v': %v", p, err)
+		}
+	}
+	t := &Tail{
+		conf:       conf,
+		delim:      []byte(conf.Delim),
+		files:      map[string]*tailFile{},
+		pending:    map[*tailFile]tailCheckpoint{},
+		mRotated:   stats.GetCounter("rotated"),
+		mTruncated: stats.GetCounter("truncated"),
+		log:        log,
+		stats:      stats,
+		closeChan:  make(chan struct{}),
+	}
+	if len(t.delim) == 0 {
+		t.delim = []byte("\n")
+	}
+	if conf.MaxBuffer < 1 {
+		return nil, errors.New("max_buffer must be greater than zero")
+	}
+	var err error
+	if t.pollInterval, err = time.ParseDuration(conf.PollInterval); err != nil {
+		return nil, fmt.Errorf("failed to parse poll interval string: %v", err)
+	}
+	if len(conf.Cache) > 0 {
+		if t.cache, err = mgr.GetCache(conf.Cache); err != nil {
+			return nil, fmt.Errorf("failed to obtain cache '%v': %v", conf.Cache, err)
+		}
+	}
+	return t, nil
+}
+
+//------------------------------------------------------------------------------
+
+// getCheckpoint returns the last acknowledged checkpoint stored under a key,
+// if any.
+func (t *Tail) getCheckpoint(key string) (tailCheckpoint, bool) {
+	var c tailCheckpoint
+	if t.cache == nil || len(key) == 0 {
+		return c, false
+	}
+	cBytes, err := t.cache.Get(key)
+	if err != nil {
+		if err != types.ErrKeyNotFound {
+			t.log.Errorf("Failed to read checkpoint of '%v': %v\n", key, err)
+		}
+		return c, false
+	}
+	if err = json.Unmarshal(cBytes, &c); err != nil {
+		t.log.Errorf("Failed to parse checkpoint of '%v': %v\n", key, err)
+		return c, false
+	}
+	return c, true
+}
+
+// open begins tailing a path from its last checkpoint, which is found by the
+// device and inode of the file and otherwise by its path. Files without a
+// checkpoint are read from the beginning unless they were found on the initial
+// scan and start_from_beginning is false.
+func (t *Tail) open(path string, initial bool) (*tailFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	t.seq++
+	f := &tailFile{
+		path:  path,
+		file:  file,
+		inode: fileInode(info),
+		id:    fileID(info),
+		seq:   t.seq,
+	}
+
+	var start int64
+	c, exists := t.getCheckpoint(f.id)
+	if !exists {
+		c, exists = t.getCheckpoint(path)
+	}
+	if exists {
+		if c.Inode == f.inode && c.Offset <= info.Size() {
+			start = c.Offset
+		} else {
+			t.log.Infof("File '%v' has changed since its last checkpoint, reading from the beginning\n", path)
+		}
+	} else if initial && !t.conf.StartFromBeginning {
+		start = info.Size()
+	}
+	if start > 0 {
+		if _, err = file.Seek(start, io.SeekStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	f.offset, f.pos = start, start
+	return f, nil
+}
+
+// scan expands the path patterns in order to detect new files, and checks the
+// files currently being tailed for rotation and truncation. Files are tracked
+// by their device and inode, so a rotated file that still matches a pattern
+// under its new name continues to be tailed rather than being read again.
+func (t *Tail) scan() {
+	initial := !t.scanned
+	t.scanned = true
+	t.lastScan = time.Now()
+
+	matched := map[string]struct{}{}
+	for _, p := range t.conf.Paths {
+		paths, _ := filepath.Glob(p)
+		for _, path := range paths {
+			matched[path] = struct{}{}
+		}
+	}
+	paths := make([]string, 0, len(matched))
+	for path := range matched {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	pathKeys := map[string]string{}
+	pathSizes := map[string]int64{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		key := fileID(info)
+		if len(key) == 0 {
+			key = path
+		}
+		pathKeys[path] = key
+		pathSizes[path] = info.Size()
+	}
+
+	seen := map[string]struct{}{}
+	var newPaths []string
+	for _, path := range paths {
+		key, exists := pathKeys[path]
+		if !exists {
+			continue
+		}
+		if _, exists = seen[key]; exists {
+			// The same file is matched by multiple paths.
+			continue
+		}
+		seen[key] = struct{}{}
+
+		f, exists := t.files[key]
+		if !exists {
+			newPaths = append(newPaths, path)
+			continue
+		}
+		if pathKeys[f.path] != key {
+			t.log.Infof("File '%v' has been rotated to '%v'\n", f.path, path)
+			t.mRotated.Incr(1)
+			f.path = path
+			f.moved = true
+		}
+		if pathSizes[path] < f.pos {
+			t.log.Infof("File '%v' has been truncated\n", path)
+			t.mTruncated.Incr(1)
+			if err := f.rewind(); err != nil {
+				t.log.Errorf("Failed to rewind file '%v': %v\n", path, err)
+			}
+		}
+	}
+
+	for key, f := range t.files {
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		// The file has been rotated, removed or no longer matches, finish
+		// reading what remains of it.
+		if _, exists := pathKeys[f.path]; exists {
+			t.log.Infof("File '%v' has been rotated\n", f.path)
+			t.mRotated.Incr(1)
+		}
+		delete(t.files, key)
+		t.draining = append(t.draining, f)
+	}
+
+	for _, path := range newPaths {
+		f, err := t.open(path, initial)
+		if err != nil {
+			t.log.Errorf("Failed to open file '%v': %v\n", path, err)
+			continue
+		}
+		t.files[f.key()] = f
+	}
+}
+
+// readLine attempts to read the next line of a file. Returns nil if a full
+// line is not yet available. When final is true any remaining data without a
+// trailing delimiter is returned as a line.
+func (t *Tail) readLine(f *tailFile, final bool) ([]byte, error) {
+	chunk := make([]byte, 32*1024)
+	for {
+		if i := bytes.Index(f.buf, t.delim); i >= 0 {
+			line := make([]byte, i)
+			copy(line, f.buf[:i])
+			f.buf = f.buf[i+len(t.delim):]
+			f.offset += int64(i + len(t.delim))
+			return line, nil
+		}
+		if len(f.buf) >= t.conf.MaxBuffer {
+			break
+		}
+		n, err := f.file.Read(chunk)
+		if n > 0 {
+			f.buf = append(f.buf, chunk[:n]...)
+			f.pos += int64(n)
+			continue
+		}
+		if err == io.EOF {
+			if final && len(f.buf) > 0 {
+				break
+			}
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The buffer is either full or this is the end of a finished file, flush
+	// it as a line.
+	line := f.buf
+	f.offset += int64(len(line))
+	f.buf = nil
+	return line, nil
+}
+
+// nextLine returns the next available line from any tailed file. Rotated files
+// are drained first and the remaining files are read in the order they were
+// opened, so that lines are read in the order they were written.
+func (t *Tail) nextLine() ([]byte, *tailFile, error) {
+	for len(t.draining) > 0 {
+		f := t.draining[0]
+		line, err := t.readLine(f, true)
+		if err != nil {
+			t.log.Errorf("Failed to read file '%v': %v\n", f.path, err)
+		}
+		if line != nil {
+			return line, f, nil
+		}
+		f.file.Close()
+		t.draining = t.draining[1:]
+	}
+
+	files := make([]*tailFile, 0, len(t.files))
+	for _, f := range t.files {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].seq < files[j].seq
+	})
+
+	for _, f := range files {
+		line, err := t.readLine(f, f.moved)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read file '%v': %v", f.path, err)
+		}
+		if line != nil {
+			return line, f, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect performs an initial scan of the path patterns.
+func (t *Tail) Connect() error {
+	t.fMut.Lock()
+	defer t.fMut.Unlock()
+
+	select {
+	case <-t.closeChan:
+		return types.ErrTypeClosed
+	default:
+	}
+	if !t.scanned {
+		t.scan()
+		t.log.Infof("Tailing files matching patterns: %v\n", t.conf.Paths)
+	}
+	return nil
+}
+
+// Read attempts to read a new line from a tailed file, blocking until either a
+// line is available or the input is closed.
+func (t *Tail) Read() (types.Message, error) {
+	for {
+		t.fMut.Lock()
+		if time.Since(t.lastScan) >= t.pollInterval {
+			t.scan()
+		}
+		line, f, err := t.nextLine()
+		var path string
+		if line != nil {
+			path = f.path
+			t.pending[f] = tailCheckpoint{
+				Inode:  f.inode,
+				Offset: f.offset,
+			}
+		}
+		t.fMut.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+		if line != nil {
+			msg := message.New([][]byte{line})
+			msg.Get(0).Metadata().Set("path", path)
+			return msg, nil
+		}
+
+		select {
+		case <-time.After(t.pollInterval):
+		case <-t.closeChan:
+			return nil, types.ErrTypeClosed
+		}
+	}
+}
+
+// Acknowledge instructs whether unacknowledged messages have been successfully
+// propagated. Once they have the offsets of their files are checkpointed.
+func (t *Tail) Acknowledge(err error) error {
+	if err != nil {
+		return nil
+	}
+
+	t.fMut.Lock()
+	defer t.fMut.Unlock()
+
+	if t.cache == nil {
+		t.pending = map[*tailFile]tailCheckpoint{}
+		return nil
+	}
+
+	items := make(map[string][]byte, len(t.pending)*2)
+	for f, c := range t.pending {
+		cBytes, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		items[f.path] = cBytes
+		if len(f.id) > 0 {
+			items[f.id] = cBytes
+		}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	if err = t.cache.SetMulti(items); err != nil {
+		t.log.Errorf("Failed to checkpoint file offsets: %v\n", err)
+		return err
+	}
+	t.pending = map[*tailFile]tailCheckpoint{}
+	return nil
+}
+
+// CloseAsync shuts down the Tail input and stops processing requests.
+func (t *Tail) CloseAsync() {
+	t.closeOnce.Do(func() {
+		close(t.closeChan)
+	})
+}
+
+// WaitForClose blocks until the Tail input has closed down.
+func (t *Tail) WaitForClose(timeout time.Duration) error {
+	t.fMut.Lock()
+	for key, f := range t.files {
+		f.file.Close()
+		delete(t.files, key)
+	}
+	for _, f := range t.draining {
+		f.file.Close()
+	}
+	t.draining = nil
+	t.fMut.Unlock()
+	return nil
+}
+
+//------------------------------------------------------------------------------
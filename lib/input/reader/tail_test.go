@@ -0,0 +1,418 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+type tailMemCache struct {
+	types.Cache
+	items map[string][]byte
+}
+
+func (c *tailMemCache) Get(key string) ([]byte, error) {
+	v, exists := c.items[key]
+	if !exists {
+		return nil, types.ErrKeyNotFound
+	}
+	return v, nil
+}
+
+func (c *tailMemCache) SetMulti(items map[string][]byte) error {
+	for k, v := range items {
+		c.items[k] = v
+	}
+	return nil
+}
+
+type tailMgr struct {
+	types.DudMgr
+	cache types.Cache
+}
+
+func (m tailMgr) GetCache(name string) (types.Cache, error) {
+	if name != "foocache" {
+		return nil, types.ErrCacheNotFound
+	}
+	return m.cache, nil
+}
+
+func tailRead(t *testing.T, tail *Tail) (string, string) {
+	t.Helper()
+
+	type result struct {
+		msg types.Message
+		err error
+	}
+	resChan := make(chan result, 1)
+	go func() {
+		msg, err := tail.Read()
+		resChan <- result{msg, err}
+	}()
+
+	select {
+	case res := <-resChan:
+		if res.err != nil {
+			t.Fatal(res.err)
+		}
+		return string(res.msg.Get(0).Get()), res.msg.Get(0).Metadata().Get("path")
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for line")
+	}
+	return "", ""
+}
+
+func tailAppend(t *testing.T, path, content string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestTail(t *testing.T, conf TailConfig, mgr types.Manager) *Tail {
+	t.Helper()
+
+	conf.PollInterval = "10ms"
+	tail, err := NewTail(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tail.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return tail
+}
+
+func closeTestTail(t *testing.T, tail *Tail) {
+	t.Helper()
+
+	tail.CloseAsync()
+	if err := tail.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func TestTailFollow(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "benthos_tail_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fooPath := filepath.Join(tmpDir, "foo.log")
+	tailAppend(t, fooPath, "foo1\nfoo2\n")
+
+	conf := NewTailConfig()
+	conf.Paths = []string{filepath.Join(tmpDir, "*.log")}
+	tail := newTestTail(t, conf, types.NoopMgr())
+	defer closeTestTail(t, tail)
+
+	for _, exp := range []string{"foo1", "foo2"} {
+		if act, path := tailRead(t, tail); act != exp {
+			t.Errorf("Wrong line: %v != %v", act, exp)
+		} else if path != fooPath {
+			t.Errorf("Wrong path: %v != %v", path, fooPath)
+		}
+	}
+
+	tailAppend(t, fooPath, "foo3\nfoo")
+	if act, _ := tailRead(t, tail); act != "foo3" {
+		t.Errorf("Wrong line: %v != %v", act, "foo3")
+	}
+	tailAppend(t, fooPath, "4\n")
+	if act, _ := tailRead(t, tail); act != "foo4" {
+		t.Errorf("Wrong line: %v != %v", act, "foo4")
+	}
+
+	barPath := filepath.Join(tmpDir, "bar.log")
+	tailAppend(t, barPath, "bar1\n")
+	if act, path := tailRead(t, tail); act != "bar1" {
+		t.Errorf("Wrong line: %v != %v", act, "bar1")
+	} else if path != barPath {
+		t.Errorf("Wrong path: %v != %v", path, barPath)
+	}
+}
+
+func TestTailStartFromEnd(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "benthos_tail_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fooPath := filepath.Join(tmpDir, "foo.log")
+	tailAppend(t, fooPath, "foo1\nfoo2\n")
+
+	conf := NewTailConfig()
+	conf.Paths = []string{fooPath}
+	conf.StartFromBeginning = false
+	tail := newTestTail(t, conf, types.NoopMgr())
+	defer closeTestTail(t, tail)
+
+	tailAppend(t, fooPath, "foo3\n")
+	if act, _ := tailRead(t, tail); act != "foo3" {
+		t.Errorf("Wrong line: %v != %v", act, "foo3")
+	}
+}
+
+func TestTailRotation(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "benthos_tail_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fooPath := filepath.Join(tmpDir, "foo.log")
+	tailAppend(t, fooPath, "foo1\n")
+
+	conf := NewTailConfig()
+	conf.Paths = []string{fooPath}
+	tail := newTestTail(t, conf, types.NoopMgr())
+	defer closeTestTail(t, tail)
+
+	if act, _ := tailRead(t, tail); act != "foo1" {
+		t.Errorf("Wrong line: %v != %v", act, "foo1")
+	}
+
+	tailAppend(t, fooPath, "foo2\nfoo3")
+	if err = os.Rename(fooPath, fooPath+".1"); err != nil {
+		t.Fatal(err)
+	}
+	tailAppend(t, fooPath, "bar1\n")
+
+	for _, exp := range []string{"foo2", "foo3", "bar1"} {
+		if act, _ := tailRead(t, tail); act != exp {
+			t.Errorf("Wrong line: %v != %v", act, exp)
+		}
+	}
+}
+
+func TestTailRotationGlob(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "benthos_tail_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fooPath := filepath.Join(tmpDir, "foo.log")
+	tailAppend(t, fooPath, "foo1\n")
+
+	conf := NewTailConfig()
+	conf.Paths = []string{filepath.Join(tmpDir, "*.log*")}
+	tail := newTestTail(t, conf, types.NoopMgr())
+	defer closeTestTail(t, tail)
+
+	if act, _ := tailRead(t, tail); act != "foo1" {
+		t.Errorf("Wrong line: %v != %v", act, "foo1")
+	}
+
+	// The rotated file still matches the pattern and should continue to be
+	// read rather than being consumed again from the beginning.
+	tailAppend(t, fooPath, "foo2\nfoo3")
+	if err = os.Rename(fooPath, fooPath+".1"); err != nil {
+		t.Fatal(err)
+	}
+	tailAppend(t, fooPath, "bar1\n")
+
+	for _, exp := range []string{"foo2", "foo3", "bar1"} {
+		if act, _ := tailRead(t, tail); act != exp {
+			t.Errorf("Wrong line: %v != %v", act, exp)
+		}
+	}
+
+	tail.fMut.Lock()
+	tail.scan()
+	line, f, err := tail.nextLine()
+	tail.fMut.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != nil {
+		t.Errorf("Unexpected line from '%v': %s", f.path, line)
+	}
+}
+
+func TestTailTruncation(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "benthos_tail_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fooPath := filepath.Join(tmpDir, "foo.log")
+	tailAppend(t, fooPath, "foo1\nfoo2\n")
+
+	conf := NewTailConfig()
+	conf.Paths = []string{fooPath}
+	tail := newTestTail(t, conf, types.NoopMgr())
+	defer closeTestTail(t, tail)
+
+	for _, exp := range []string{"foo1", "foo2"} {
+		if act, _ := tailRead(t, tail); act != exp {
+			t.Errorf("Wrong line: %v != %v", act, exp)
+		}
+	}
+
+	if err = os.Truncate(fooPath, 0); err != nil {
+		t.Fatal(err)
+	}
+	tailAppend(t, fooPath, "bar1\n")
+	if act, _ := tailRead(t, tail); act != "bar1" {
+		t.Errorf("Wrong line: %v != %v", act, "bar1")
+	}
+}
+
+func TestTailCheckpoints(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "benthos_tail_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fooPath := filepath.Join(tmpDir, "foo.log")
+	tailAppend(t, fooPath, "foo1\nfoo2\nfoo3\n")
+
+	mgr := tailMgr{cache: &tailMemCache{items: map[string][]byte{}}}
+
+	conf := NewTailConfig()
+	conf.Paths = []string{fooPath}
+	conf.Cache = "foocache"
+	conf.StartFromBeginning = false
+
+	tail := newTestTail(t, conf, mgr)
+	tailAppend(t, fooPath, "foo4\nfoo5\n")
+	if act, _ := tailRead(t, tail); act != "foo4" {
+		t.Errorf("Wrong line: %v != %v", act, "foo4")
+	}
+	if err = tail.Acknowledge(nil); err != nil {
+		t.Fatal(err)
+	}
+	if act, _ := tailRead(t, tail); act != "foo5" {
+		t.Errorf("Wrong line: %v != %v", act, "foo5")
+	}
+	closeTestTail(t, tail)
+
+	// The unacknowledged line should be read again, and files with a
+	// checkpoint should not honour start_from_beginning.
+	tail = newTestTail(t, conf, mgr)
+	if act, _ := tailRead(t, tail); act != "foo5" {
+		t.Errorf("Wrong line: %v != %v", act, "foo5")
+	}
+	if err = tail.Acknowledge(nil); err != nil {
+		t.Fatal(err)
+	}
+	closeTestTail(t, tail)
+
+	// A rotated file should be read from the beginning regardless of its
+	// checkpoint.
+	if err = os.Rename(fooPath, fooPath+".1"); err != nil {
+		t.Fatal(err)
+	}
+	tailAppend(t, fooPath, "bar1\n")
+
+	tail = newTestTail(t, conf, mgr)
+	defer closeTestTail(t, tail)
+	if act, _ := tailRead(t, tail); act != "bar1" {
+		t.Errorf("Wrong line: %v != %v", act, "bar1")
+	}
+}
+
+func TestTailCheckpointsRotationGlob(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "benthos_tail_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fooPath := filepath.Join(tmpDir, "foo.log")
+	tailAppend(t, fooPath, "foo1\nfoo2\n")
+
+	mgr := tailMgr{cache: &tailMemCache{items: map[string][]byte{}}}
+
+	conf := NewTailConfig()
+	conf.Paths = []string{filepath.Join(tmpDir, "*.log*")}
+	conf.Cache = "foocache"
+
+	tail := newTestTail(t, conf, mgr)
+	if act, _ := tailRead(t, tail); act != "foo1" {
+		t.Errorf("Wrong line: %v != %v", act, "foo1")
+	}
+	if err = tail.Acknowledge(nil); err != nil {
+		t.Fatal(err)
+	}
+	closeTestTail(t, tail)
+
+	// A file rotated whilst the input was stopped should be resumed from its
+	// checkpoint under the new name, and the new file read from the beginning.
+	if err = os.Rename(fooPath, fooPath+".1"); err != nil {
+		t.Fatal(err)
+	}
+	tailAppend(t, fooPath, "bar1\n")
+
+	tail = newTestTail(t, conf, mgr)
+	defer closeTestTail(t, tail)
+
+	exp := map[string]string{
+		"foo2": fooPath + ".1",
+		"bar1": fooPath,
+	}
+	act := map[string]string{}
+	for i := 0; i < len(exp); i++ {
+		line, path := tailRead(t, tail)
+		act[line] = path
+	}
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong lines: %v != %v", act, exp)
+	}
+}
+
+func TestTailBadCache(t *testing.T) {
+	conf := NewTailConfig()
+	conf.Paths = []string{"/tmp/foo.log"}
+	conf.Cache = "barcache"
+	if _, err := NewTail(conf, types.NoopMgr(), log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing cache")
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -23,6 +23,7 @@ package input
 import (
 	"errors"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -663,4 +664,229 @@ func TestReaderSkipAcks(t *testing.T) {
 	}
 }
 
+func TestReaderDrainInFlight(t *testing.T) {
+	t.Parallel()
+
+	exp := [][]byte{[]byte("foo"), []byte("bar")}
+
+	readerImpl := newMockReader()
+	readerImpl.msgToSnd = message.New(exp)
+	readerImpl.ackRcvd = errors.New("ack not received")
+
+	r, err := NewReader(
+		"foo", readerImpl,
+		log.Noop(), metrics.DudType{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case readerImpl.connChan <- nil:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+	select {
+	case readerImpl.readChan <- nil:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	var ts types.Transaction
+	var open bool
+	select {
+	case ts, open = <-r.TransactionChan():
+		if !open {
+			t.Fatal("Chan closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	r.(types.Drainer).DrainAsync()
+
+	// A closing reader abandons in-flight messages after a second, whereas a
+	// draining reader continues to wait.
+	if err = r.WaitForClose(time.Millisecond * 1500); err != types.ErrTimeout {
+		t.Errorf("Expected timeout whilst draining, received: %v", err)
+	}
+
+	select {
+	case ts.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+	select {
+	case readerImpl.ackChan <- nil:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	if err = r.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+	if readerImpl.ackRcvd != nil {
+		t.Error(readerImpl.ackRcvd)
+	}
+	if _, open = <-r.TransactionChan(); open {
+		t.Error("Transaction chan not closed")
+	}
+}
+
+type readerBlocksOnRead struct {
+	closeChan chan struct{}
+}
+
+func (r *readerBlocksOnRead) Connect() error {
+	return nil
+}
+func (r *readerBlocksOnRead) Read() (types.Message, error) {
+	<-r.closeChan
+	return nil, types.ErrTypeClosed
+}
+func (r *readerBlocksOnRead) Acknowledge(err error) error {
+	return nil
+}
+func (r *readerBlocksOnRead) CloseAsync() {
+	close(r.closeChan)
+}
+func (r *readerBlocksOnRead) WaitForClose(time.Duration) error {
+	return nil
+}
+
+func TestReaderDrainWhileReading(t *testing.T) {
+	t.Parallel()
+
+	readerImpl := &readerBlocksOnRead{
+		closeChan: make(chan struct{}),
+	}
+
+	r, err := NewReader(
+		"foo", readerImpl,
+		log.Noop(), metrics.DudType{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; !r.Connected(); i++ {
+		if i > 100 {
+			t.Fatal("Timed out waiting for connection")
+		}
+		<-time.After(time.Millisecond * 10)
+	}
+
+	r.(types.Drainer).DrainAsync()
+	if err = r.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+
+	// Closing a drained reader should be a no-op.
+	r.CloseAsync()
+}
+
+type readerReturnsAfterClose struct {
+	readingChan chan struct{}
+	releaseChan chan struct{}
+	closeChan   chan struct{}
+	closeOnce   sync.Once
+
+	ackedAfterClose bool
+}
+
+func (r *readerReturnsAfterClose) Connect() error {
+	return nil
+}
+func (r *readerReturnsAfterClose) Read() (types.Message, error) {
+	select {
+	case r.readingChan <- struct{}{}:
+	case <-r.closeChan:
+		return nil, types.ErrTypeClosed
+	}
+	// Mimic a read that completes regardless of the reader being closed.
+	<-r.releaseChan
+	return message.New([][]byte{[]byte("foo")}), nil
+}
+func (r *readerReturnsAfterClose) Acknowledge(err error) error {
+	select {
+	case <-r.closeChan:
+		r.ackedAfterClose = true
+		return types.ErrTypeClosed
+	default:
+	}
+	return nil
+}
+func (r *readerReturnsAfterClose) CloseAsync() {
+	r.closeOnce.Do(func() {
+		close(r.closeChan)
+	})
+}
+func (r *readerReturnsAfterClose) WaitForClose(time.Duration) error {
+	return nil
+}
+
+func TestReaderDrainInterruptsRead(t *testing.T) {
+	t.Parallel()
+
+	readerImpl := &readerReturnsAfterClose{
+		readingChan: make(chan struct{}),
+		releaseChan: make(chan struct{}),
+		closeChan:   make(chan struct{}),
+	}
+
+	r, err := NewReader(
+		"foo", readerImpl,
+		log.Noop(), metrics.DudType{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-readerImpl.readingChan:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	r.(types.Drainer).DrainAsync()
+	close(readerImpl.releaseChan)
+
+	// A message read by an interrupted reader is still propagated, and its
+	// acknowledgement is attempted against the closed reader.
+	var ts types.Transaction
+	var open bool
+	select {
+	case ts, open = <-r.TransactionChan():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+	if !open {
+		t.Fatal("Expected transaction from interrupted read")
+	}
+	if exp, act := "foo", string(ts.Payload.Get(0).Get()); exp != act {
+		t.Errorf("Wrong message: %v != %v", act, exp)
+	}
+	select {
+	case ts.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	select {
+	case _, open = <-r.TransactionChan():
+		if open {
+			t.Error("Unexpected transaction after drain")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	if err = r.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+	if !readerImpl.ackedAfterClose {
+		t.Error("Expected acknowledgement to be attempted after the reader was closed")
+	}
+}
+
 //------------------------------------------------------------------------------
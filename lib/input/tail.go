@@ -0,0 +1,85 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"github.com/Jeffail/benthos/lib/input/reader"
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeTail] = TypeSpec{
+		constructor: NewTail,
+		description: `
+Follows files matching a list of glob patterns and reads lines from them as they
+are written, where each line is a message. The patterns are expanded every
+` + "`poll_interval`" + `, and new files that match are consumed from their
+beginning.
+
+Files are tracked by their device and inode. A file is considered rotated when
+its path points to a different inode, in which case the remainder of the old
+file is read before moving onto the new one. A rotated file that still matches
+a pattern under its new name continues to be followed rather than being consumed
+again. A file is considered truncated when it becomes smaller than the amount
+already read, in which case it is consumed again from the beginning.
+
+If the field ` + "`cache`" + ` is set to the name of a cache resource then the
+byte offset of each file is stored within it, keyed by both its path and its
+device and inode, once the lines read up to that point have been acknowledged by
+the output. When restarted the input resumes each file from its stored offset,
+including files that have since been renamed, unless the file has since been
+truncated. Files that have replaced a rotated file are read from the beginning.
+Files that were present when the input first started and have no stored offset
+are read from the beginning if ` + "`start_from_beginning`" + ` is true,
+otherwise only new lines are read.
+
+If the delimiter field is left empty then line feed (\n) is used. Lines that
+exceed ` + "`max_buffer`" + ` bytes are split.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "``` text" + `
+- path
+` + "```" + `
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewTail creates a new Tail input type.
+func NewTail(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	t, err := reader.NewTail(conf.Tail, mgr, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader("tail", reader.NewPreserver(t), log, stats)
+}
+
+//------------------------------------------------------------------------------
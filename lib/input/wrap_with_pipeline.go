@@ -81,6 +81,17 @@ func (i *WithPipeline) Connected() bool {
 
 //------------------------------------------------------------------------------
 
+// DrainAsync triggers the wrapped input to stop consuming new data, the
+// pipeline closes once it has resolved all in-flight transactions. If the input
+// is unable to drain then it is closed instead.
+func (i *WithPipeline) DrainAsync() {
+	if d, ok := i.in.(types.Drainer); ok {
+		d.DrainAsync()
+		return
+	}
+	i.in.CloseAsync()
+}
+
 // CloseAsync triggers a closure of this object but does not block.
 func (i *WithPipeline) CloseAsync() {
 	i.in.CloseAsync()
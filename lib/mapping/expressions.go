@@ -0,0 +1,379 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mapping
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// deletedValue is a sentinel value that, when assigned to a target, removes
+// the target.
+type deletedValue struct{}
+
+// nothingValue is a sentinel value that, when assigned to a target, leaves the
+// target unchanged.
+type nothingValue struct{}
+
+//------------------------------------------------------------------------------
+
+// execContext contains the state of a mapping execution for a single message
+// part.
+type execContext struct {
+	msg   types.Message
+	index int
+
+	input    interface{}
+	inputErr error
+
+	root interface{}
+	meta types.Metadata
+}
+
+func (c *execContext) getInput() (interface{}, error) {
+	if c.inputErr != nil {
+		return nil, fmt.Errorf("failed to parse message as JSON: %v", c.inputErr)
+	}
+	return c.input, nil
+}
+
+//------------------------------------------------------------------------------
+
+type expression interface {
+	eval(ctx *execContext) (interface{}, error)
+}
+
+type literalExpr struct {
+	value interface{}
+}
+
+func (l literalExpr) eval(ctx *execContext) (interface{}, error) {
+	return l.value, nil
+}
+
+type thisExpr struct{}
+
+func (t thisExpr) eval(ctx *execContext) (interface{}, error) {
+	return ctx.getInput()
+}
+
+type rootExpr struct{}
+
+func (r rootExpr) eval(ctx *execContext) (interface{}, error) {
+	return ctx.root, nil
+}
+
+//------------------------------------------------------------------------------
+
+type fieldExpr struct {
+	target expression
+	field  string
+}
+
+func (f fieldExpr) eval(ctx *execContext) (interface{}, error) {
+	v, err := f.target.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return t[f.field], nil
+	case []interface{}:
+		i, err := strconv.Atoi(f.field)
+		if err != nil {
+			return nil, fmt.Errorf("cannot access field '%v' of an array", f.field)
+		}
+		if i < 0 {
+			i = len(t) + i
+		}
+		if i < 0 || i >= len(t) {
+			return nil, nil
+		}
+		return t[i], nil
+	case nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("cannot access field '%v' of type %v", f.field, typeName(v))
+}
+
+//------------------------------------------------------------------------------
+
+type objectExpr struct {
+	keys   []string
+	values []expression
+}
+
+func (o objectExpr) eval(ctx *execContext) (interface{}, error) {
+	obj := make(map[string]interface{}, len(o.keys))
+	for i, k := range o.keys {
+		v, err := o.values[i].eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch v.(type) {
+		case deletedValue, nothingValue:
+			continue
+		}
+		obj[k] = v
+	}
+	return obj, nil
+}
+
+type arrayExpr struct {
+	values []expression
+}
+
+func (a arrayExpr) eval(ctx *execContext) (interface{}, error) {
+	arr := make([]interface{}, 0, len(a.values))
+	for _, e := range a.values {
+		v, err := e.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch v.(type) {
+		case deletedValue, nothingValue:
+			continue
+		}
+		arr = append(arr, v)
+	}
+	return arr, nil
+}
+
+//------------------------------------------------------------------------------
+
+type ifExpr struct {
+	cond      expression
+	then      expression
+	otherwise expression
+}
+
+func (i ifExpr) eval(ctx *execContext) (interface{}, error) {
+	c, err := i.cond.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := c.(bool)
+	if !ok {
+		return nil, fmt.Errorf("expected bool condition, found %v", typeName(c))
+	}
+	if b {
+		return i.then.eval(ctx)
+	}
+	if i.otherwise != nil {
+		return i.otherwise.eval(ctx)
+	}
+	return nothingValue{}, nil
+}
+
+//------------------------------------------------------------------------------
+
+type unaryExpr struct {
+	op      string
+	operand expression
+}
+
+func (u unaryExpr) eval(ctx *execContext) (interface{}, error) {
+	v, err := u.operand.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch u.op {
+	case "!":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("cannot negate type %v", typeName(v))
+		}
+		return !b, nil
+	case "-":
+		n, ok := toNumber(v)
+		if !ok {
+			return nil, fmt.Errorf("cannot negate type %v", typeName(v))
+		}
+		return -n, nil
+	}
+	return nil, fmt.Errorf("unrecognised operator: %v", u.op)
+}
+
+//------------------------------------------------------------------------------
+
+type binaryExpr struct {
+	op    string
+	left  expression
+	right expression
+}
+
+func (b binaryExpr) evalLogical(ctx *execContext) (interface{}, error) {
+	l, err := b.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lb, ok := l.(bool)
+	if !ok {
+		return nil, fmt.Errorf("operator '%v' expected bool operands, found %v", b.op, typeName(l))
+	}
+	if (b.op == "&&" && !lb) || (b.op == "||" && lb) {
+		return lb, nil
+	}
+	r, err := b.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rb, ok := r.(bool)
+	if !ok {
+		return nil, fmt.Errorf("operator '%v' expected bool operands, found %v", b.op, typeName(r))
+	}
+	return rb, nil
+}
+
+func (b binaryExpr) eval(ctx *execContext) (interface{}, error) {
+	if b.op == "&&" || b.op == "||" {
+		return b.evalLogical(ctx)
+	}
+
+	l, err := b.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r, err := b.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch b.op {
+	case "==":
+		return valuesEqual(l, r), nil
+	case "!=":
+		return !valuesEqual(l, r), nil
+	}
+
+	if ls, ok := l.(string); ok {
+		rs, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot apply operator '%v' to types string and %v", b.op, typeName(r))
+		}
+		switch b.op {
+		case "+":
+			return ls + rs, nil
+		case "<":
+			return ls < rs, nil
+		case "<=":
+			return ls <= rs, nil
+		case ">":
+			return ls > rs, nil
+		case ">=":
+			return ls >= rs, nil
+		}
+		return nil, fmt.Errorf("cannot apply operator '%v' to type string", b.op)
+	}
+
+	ln, lok := toNumber(l)
+	rn, rok := toNumber(r)
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot apply operator '%v' to types %v and %v", b.op, typeName(l), typeName(r))
+	}
+	switch b.op {
+	case "+":
+		return ln + rn, nil
+	case "-":
+		return ln - rn, nil
+	case "*":
+		return ln * rn, nil
+	case "/":
+		if rn == 0 {
+			return nil, fmt.Errorf("attempted to divide by zero")
+		}
+		return ln / rn, nil
+	case "%":
+		if rn == 0 {
+			return nil, fmt.Errorf("attempted to divide by zero")
+		}
+		return math.Mod(ln, rn), nil
+	case "<":
+		return ln < rn, nil
+	case "<=":
+		return ln <= rn, nil
+	case ">":
+		return ln > rn, nil
+	case ">=":
+		return ln >= rn, nil
+	}
+	return nil, fmt.Errorf("unrecognised operator: %v", b.op)
+}
+
+//------------------------------------------------------------------------------
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64, float32, int, int64, uint64, json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case deletedValue:
+		return "deleted"
+	case nothingValue:
+		return "nothing"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case float32:
+		return float64(t), true
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case uint64:
+		return float64(t), true
+	case json.Number:
+		f, err := t.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func valuesEqual(l, r interface{}) bool {
+	if ln, ok := toNumber(l); ok {
+		rn, ok := toNumber(r)
+		return ok && ln == rn
+	}
+	return reflect.DeepEqual(l, r)
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,348 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mapping
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/util/text"
+)
+
+//------------------------------------------------------------------------------
+
+type functionSpec struct {
+	minArgs int
+	maxArgs int
+	fn      func(ctx *execContext, args []interface{}) (interface{}, error)
+}
+
+var functions = map[string]functionSpec{
+	"meta": {
+		minArgs: 1, maxArgs: 1,
+		fn: func(ctx *execContext, args []interface{}) (interface{}, error) {
+			key, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("expected string argument, found %v", typeName(args[0]))
+			}
+			if v := ctx.meta.Get(key); len(v) > 0 {
+				return v, nil
+			}
+			return nil, nil
+		},
+	},
+	"content": {
+		minArgs: 0, maxArgs: 0,
+		fn: func(ctx *execContext, args []interface{}) (interface{}, error) {
+			return string(ctx.msg.Get(ctx.index).Get()), nil
+		},
+	},
+	"deleted": {
+		minArgs: 0, maxArgs: 0,
+		fn: func(ctx *execContext, args []interface{}) (interface{}, error) {
+			return deletedValue{}, nil
+		},
+	},
+}
+
+// getFunction returns the spec of a function, falling back to the function
+// interpolations supported in configs, which take zero or one string argument
+// and return a string.
+func getFunction(name string) (functionSpec, bool) {
+	if spec, exists := functions[name]; exists {
+		return spec, true
+	}
+	ftor, exists := text.FunctionVar(name)
+	if !exists {
+		return functionSpec{}, false
+	}
+	return functionSpec{
+		minArgs: 0, maxArgs: 1,
+		fn: func(ctx *execContext, args []interface{}) (interface{}, error) {
+			var arg string
+			if len(args) > 0 {
+				var ok bool
+				if arg, ok = args[0].(string); !ok {
+					return nil, fmt.Errorf("expected string argument, found %v", typeName(args[0]))
+				}
+			}
+			return string(ftor(message.Lock(ctx.msg, ctx.index), arg)), nil
+		},
+	}, true
+}
+
+type functionExpr struct {
+	name string
+	args []expression
+	fn   func(ctx *execContext, args []interface{}) (interface{}, error)
+}
+
+func (f functionExpr) eval(ctx *execContext) (interface{}, error) {
+	args, err := evalArgs(ctx, f.args)
+	if err != nil {
+		return nil, err
+	}
+	v, err := f.fn(ctx, args)
+	if err != nil {
+		return nil, fmt.Errorf("function '%v': %v", f.name, err)
+	}
+	return v, nil
+}
+
+func evalArgs(ctx *execContext, exprs []expression) ([]interface{}, error) {
+	args := make([]interface{}, len(exprs))
+	for i, e := range exprs {
+		var err error
+		if args[i], err = e.eval(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+//------------------------------------------------------------------------------
+
+type methodSpec struct {
+	minArgs int
+	maxArgs int
+	fn      func(target interface{}, args []interface{}) (interface{}, error)
+}
+
+func stringMethod(fn func(s string, args []string) (interface{}, error)) func(target interface{}, args []interface{}) (interface{}, error) {
+	return func(target interface{}, args []interface{}) (interface{}, error) {
+		s, ok := target.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string value, found %v", typeName(target))
+		}
+		strArgs := make([]string, len(args))
+		for i, a := range args {
+			if strArgs[i], ok = a.(string); !ok {
+				return nil, fmt.Errorf("expected string argument, found %v", typeName(a))
+			}
+		}
+		return fn(s, strArgs)
+	}
+}
+
+var methods = map[string]methodSpec{
+	"uppercase": {
+		minArgs: 0, maxArgs: 0,
+		fn: stringMethod(func(s string, _ []string) (interface{}, error) {
+			return strings.ToUpper(s), nil
+		}),
+	},
+	"lowercase": {
+		minArgs: 0, maxArgs: 0,
+		fn: stringMethod(func(s string, _ []string) (interface{}, error) {
+			return strings.ToLower(s), nil
+		}),
+	},
+	"trim": {
+		minArgs: 0, maxArgs: 0,
+		fn: stringMethod(func(s string, _ []string) (interface{}, error) {
+			return strings.TrimSpace(s), nil
+		}),
+	},
+	"has_prefix": {
+		minArgs: 1, maxArgs: 1,
+		fn: stringMethod(func(s string, args []string) (interface{}, error) {
+			return strings.HasPrefix(s, args[0]), nil
+		}),
+	},
+	"has_suffix": {
+		minArgs: 1, maxArgs: 1,
+		fn: stringMethod(func(s string, args []string) (interface{}, error) {
+			return strings.HasSuffix(s, args[0]), nil
+		}),
+	},
+	"replace": {
+		minArgs: 2, maxArgs: 2,
+		fn: stringMethod(func(s string, args []string) (interface{}, error) {
+			return strings.Replace(s, args[0], args[1], -1), nil
+		}),
+	},
+	"split": {
+		minArgs: 1, maxArgs: 1,
+		fn: stringMethod(func(s string, args []string) (interface{}, error) {
+			parts := strings.Split(s, args[0])
+			arr := make([]interface{}, len(parts))
+			for i, p := range parts {
+				arr[i] = p
+			}
+			return arr, nil
+		}),
+	},
+	"parse_json": {
+		minArgs: 0, maxArgs: 0,
+		fn: stringMethod(func(s string, _ []string) (interface{}, error) {
+			var v interface{}
+			if err := json.Unmarshal([]byte(s), &v); err != nil {
+				return nil, err
+			}
+			return v, nil
+		}),
+	},
+	"contains": {
+		minArgs: 1, maxArgs: 1,
+		fn: func(target interface{}, args []interface{}) (interface{}, error) {
+			switch t := target.(type) {
+			case string:
+				sub, ok := args[0].(string)
+				if !ok {
+					return nil, fmt.Errorf("expected string argument, found %v", typeName(args[0]))
+				}
+				return strings.Contains(t, sub), nil
+			case []interface{}:
+				for _, v := range t {
+					if valuesEqual(v, args[0]) {
+						return true, nil
+					}
+				}
+				return false, nil
+			}
+			return nil, fmt.Errorf("expected string or array value, found %v", typeName(target))
+		},
+	},
+	"length": {
+		minArgs: 0, maxArgs: 0,
+		fn: func(target interface{}, _ []interface{}) (interface{}, error) {
+			switch t := target.(type) {
+			case string:
+				return float64(len(t)), nil
+			case []interface{}:
+				return float64(len(t)), nil
+			case map[string]interface{}:
+				return float64(len(t)), nil
+			}
+			return nil, fmt.Errorf("expected string, array or object value, found %v", typeName(target))
+		},
+	},
+	"join": {
+		minArgs: 1, maxArgs: 1,
+		fn: func(target interface{}, args []interface{}) (interface{}, error) {
+			arr, ok := target.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("expected array value, found %v", typeName(target))
+			}
+			sep, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("expected string argument, found %v", typeName(args[0]))
+			}
+			strs := make([]string, len(arr))
+			for i, v := range arr {
+				if strs[i], ok = v.(string); !ok {
+					return nil, fmt.Errorf("expected array of strings, found element of type %v", typeName(v))
+				}
+			}
+			return strings.Join(strs, sep), nil
+		},
+	},
+	"keys": {
+		minArgs: 0, maxArgs: 0,
+		fn: func(target interface{}, _ []interface{}) (interface{}, error) {
+			obj, ok := target.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("expected object value, found %v", typeName(target))
+			}
+			keys := make([]string, 0, len(obj))
+			for k := range obj {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			arr := make([]interface{}, len(keys))
+			for i, k := range keys {
+				arr[i] = k
+			}
+			return arr, nil
+		},
+	},
+	"number": {
+		minArgs: 0, maxArgs: 0,
+		fn: func(target interface{}, _ []interface{}) (interface{}, error) {
+			if n, ok := toNumber(target); ok {
+				return n, nil
+			}
+			s, ok := target.(string)
+			if !ok {
+				return nil, fmt.Errorf("cannot convert type %v to number", typeName(target))
+			}
+			return strconv.ParseFloat(strings.TrimSpace(s), 64)
+		},
+	},
+	"string": {
+		minArgs: 0, maxArgs: 0,
+		fn: func(target interface{}, _ []interface{}) (interface{}, error) {
+			if s, ok := target.(string); ok {
+				return s, nil
+			}
+			b, err := json.Marshal(target)
+			if err != nil {
+				return nil, err
+			}
+			return string(b), nil
+		},
+	},
+	"type": {
+		minArgs: 0, maxArgs: 0,
+		fn: func(target interface{}, _ []interface{}) (interface{}, error) {
+			return typeName(target), nil
+		},
+	},
+	"or": {
+		minArgs: 1, maxArgs: 1,
+		fn: func(target interface{}, args []interface{}) (interface{}, error) {
+			switch target.(type) {
+			case nil, nothingValue:
+				return args[0], nil
+			}
+			return target, nil
+		},
+	},
+}
+
+type methodExpr struct {
+	target expression
+	name   string
+	args   []expression
+	fn     func(target interface{}, args []interface{}) (interface{}, error)
+}
+
+func (m methodExpr) eval(ctx *execContext) (interface{}, error) {
+	target, err := m.target.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	args, err := evalArgs(ctx, m.args)
+	if err != nil {
+		return nil, err
+	}
+	v, err := m.fn(target, args)
+	if err != nil {
+		return nil, fmt.Errorf("method '%v': %v", m.name, err)
+	}
+	return v, nil
+}
+
+//------------------------------------------------------------------------------
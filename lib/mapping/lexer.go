@@ -0,0 +1,191 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mapping
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//------------------------------------------------------------------------------
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokNewline
+	tokIdent
+	tokNumber
+	tokString
+	tokPunct
+)
+
+type token struct {
+	typ   tokenType
+	value string
+	line  int
+	col   int
+}
+
+func (t token) String() string {
+	switch t.typ {
+	case tokEOF:
+		return "end of input"
+	case tokNewline:
+		return "line break"
+	case tokString:
+		return strconv.Quote(t.value)
+	}
+	return fmt.Sprintf("'%v'", t.value)
+}
+
+// ErrParse is an error returned when a mapping fails to parse, containing the
+// line and column of the problem.
+type ErrParse struct {
+	Line   int
+	Column int
+	Reason string
+}
+
+// Error returns a human readable description of the parse error.
+func (e *ErrParse) Error() string {
+	return fmt.Sprintf("line %v char %v: %v", e.Line, e.Column, e.Reason)
+}
+
+//------------------------------------------------------------------------------
+
+// punctuation is ordered such that longer tokens are matched first.
+var punctuation = []string{
+	"==", "!=", "<=", ">=", "&&", "||",
+	"=", "<", ">", "+", "-", "*", "/", "%", "!",
+	"(", ")", "{", "}", "[", "]", ",", ".", ":",
+}
+
+// lex breaks a mapping into a slice of tokens. Line breaks are only emitted
+// outside of brackets, which allows expressions to span multiple lines when
+// wrapped.
+func lex(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	line, col, depth := 1, 1, 0
+
+	i := 0
+	advance := func(n int) {
+		for j := 0; j < n; j++ {
+			if runes[i] == '\n' {
+				line++
+				col = 1
+			} else {
+				col++
+			}
+			i++
+		}
+	}
+
+lexLoop:
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case r == '\n':
+			if depth == 0 {
+				tokens = append(tokens, token{typ: tokNewline, value: "\n", line: line, col: col})
+			}
+			advance(1)
+			continue lexLoop
+		case unicode.IsSpace(r):
+			advance(1)
+			continue lexLoop
+		case r == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				advance(1)
+			}
+			continue lexLoop
+		case r == '_' || unicode.IsLetter(r):
+			j := i
+			for j < len(runes) && (runes[j] == '_' || unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+			tokens = append(tokens, token{typ: tokIdent, value: string(runes[i:j]), line: line, col: col})
+			advance(j - i)
+			continue lexLoop
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			if j+1 < len(runes) && runes[j] == '.' && unicode.IsDigit(runes[j+1]) {
+				j++
+				for j < len(runes) && unicode.IsDigit(runes[j]) {
+					j++
+				}
+			}
+			tokens = append(tokens, token{typ: tokNumber, value: string(runes[i:j]), line: line, col: col})
+			advance(j - i)
+			continue lexLoop
+		case r == '"':
+			j := i + 1
+			for ; j < len(runes); j++ {
+				if runes[j] == '\\' {
+					j++
+					continue
+				}
+				if runes[j] == '"' || runes[j] == '\n' {
+					break
+				}
+			}
+			if j >= len(runes) || runes[j] != '"' {
+				return nil, &ErrParse{Line: line, Column: col, Reason: "unterminated string literal"}
+			}
+			str, err := strconv.Unquote(string(runes[i : j+1]))
+			if err != nil {
+				return nil, &ErrParse{Line: line, Column: col, Reason: fmt.Sprintf("invalid string literal: %v", err)}
+			}
+			tokens = append(tokens, token{typ: tokString, value: str, line: line, col: col})
+			advance(j + 1 - i)
+			continue lexLoop
+		}
+
+		remaining := string(runes[i:])
+		for _, p := range punctuation {
+			if strings.HasPrefix(remaining, p) {
+				switch p {
+				case "(", "[", "{":
+					depth++
+				case ")", "]", "}":
+					if depth > 0 {
+						depth--
+					}
+				}
+				tokens = append(tokens, token{typ: tokPunct, value: p, line: line, col: col})
+				advance(len(p))
+				continue lexLoop
+			}
+		}
+		return nil, &ErrParse{Line: line, Column: col, Reason: fmt.Sprintf("unexpected character '%c'", r)}
+	}
+
+	tokens = append(tokens, token{typ: tokEOF, line: line, col: col})
+	return tokens, nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,193 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mapping
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+type statement struct {
+	line    int
+	path    []string
+	isMeta  bool
+	metaKey string
+	value   expression
+}
+
+func (s statement) exec(ctx *execContext) error {
+	v, err := s.value.eval(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := v.(nothingValue); ok {
+		return nil
+	}
+	_, isDeleted := v.(deletedValue)
+
+	if s.isMeta {
+		if isDeleted {
+			ctx.meta.Delete(s.metaKey)
+			return nil
+		}
+		str, ok := v.(string)
+		if !ok {
+			str, err = stringify(v)
+			if err != nil {
+				return err
+			}
+		}
+		ctx.meta.Set(s.metaKey, str)
+		return nil
+	}
+
+	if isDeleted {
+		ctx.root = deletePath(ctx.root, s.path)
+	} else {
+		ctx.root = setPath(ctx.root, s.path, v)
+	}
+	return nil
+}
+
+func stringify(v interface{}) (string, error) {
+	s, err := methods["string"].fn(v, nil)
+	if err != nil {
+		return "", err
+	}
+	return s.(string), nil
+}
+
+//------------------------------------------------------------------------------
+
+// setPath sets a value at a path within a document and returns the resulting
+// document, creating objects along the path where they do not exist.
+func setPath(root interface{}, path []string, value interface{}) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	if arr, ok := root.([]interface{}); ok {
+		if i, err := strconv.Atoi(path[0]); err == nil && i >= 0 && i < len(arr) {
+			arr[i] = setPath(arr[i], path[1:], value)
+			return arr
+		}
+	}
+	obj, ok := root.(map[string]interface{})
+	if !ok {
+		obj = map[string]interface{}{}
+	}
+	obj[path[0]] = setPath(obj[path[0]], path[1:], value)
+	return obj
+}
+
+// deletePath removes a value at a path within a document and returns the
+// resulting document.
+func deletePath(root interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return nil
+	}
+	switch t := root.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			delete(t, path[0])
+		} else if child, exists := t[path[0]]; exists {
+			t[path[0]] = deletePath(child, path[1:])
+		}
+	case []interface{}:
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= len(t) {
+			return t
+		}
+		if len(path) == 1 {
+			return append(t[:i], t[i+1:]...)
+		}
+		t[i] = deletePath(t[i], path[1:])
+	}
+	return root
+}
+
+//------------------------------------------------------------------------------
+
+// Mapping is a parsed mapping that can be executed against message parts.
+type Mapping struct {
+	statements []statement
+	targetsDoc bool
+}
+
+// Parse attempts to parse a mapping, returning an error of type *ErrParse if
+// the mapping is invalid.
+func Parse(mapping string) (*Mapping, error) {
+	tokens, err := lex(mapping)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	statements, err := p.parseStatements()
+	if err != nil {
+		return nil, err
+	}
+	m := &Mapping{statements: statements}
+	for _, s := range statements {
+		if !s.isMeta {
+			m.targetsDoc = true
+		}
+	}
+	return m, nil
+}
+
+// MapPart executes the mapping against a message part of an index, replacing
+// its contents and metadata with the result. The part is only modified if the
+// mapping is fully successful.
+func (m *Mapping) MapPart(index int, msg types.Message) error {
+	part := msg.Get(index)
+
+	ctx := &execContext{
+		msg:   msg,
+		index: index,
+		meta:  part.Metadata().Copy(),
+	}
+	if ctx.input, ctx.inputErr = part.JSON(); ctx.inputErr == nil && m.targetsDoc {
+		var err error
+		if ctx.root, err = message.CopyJSON(ctx.input); err != nil {
+			return fmt.Errorf("failed to copy message document: %v", err)
+		}
+	}
+
+	for _, s := range m.statements {
+		if err := s.exec(ctx); err != nil {
+			return fmt.Errorf("line %v: %v", s.line, err)
+		}
+	}
+
+	if m.targetsDoc {
+		if err := part.SetJSON(ctx.root); err != nil {
+			return fmt.Errorf("failed to set message document: %v", err)
+		}
+	}
+	part.SetMetadata(ctx.meta)
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,307 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mapping
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/message"
+)
+
+func TestMappingParseErrors(t *testing.T) {
+	tests := map[string]struct {
+		mapping string
+		line    int
+		column  int
+	}{
+		"bad target": {
+			mapping: `foo = "bar"`,
+			line:    1, column: 1,
+		},
+		"missing assignment": {
+			mapping: "root.foo = 5\nroot.bar \"baz\"",
+			line:    2, column: 10,
+		},
+		"unknown function": {
+			mapping: `root.foo = nope()`,
+			line:    1, column: 12,
+		},
+		"unknown method": {
+			mapping: `root.foo = this.bar.nope()`,
+			line:    1, column: 21,
+		},
+		"wrong arg count": {
+			mapping: `root.foo = this.bar.replace("a")`,
+			line:    1, column: 21,
+		},
+		"unterminated string": {
+			mapping: `root.foo = "bar`,
+			line:    1, column: 12,
+		},
+		"unclosed if block": {
+			mapping: `root.foo = if this.bar { "baz" `,
+			line:    1, column: 32,
+		},
+	}
+
+	for name, test := range tests {
+		_, err := Parse(test.mapping)
+		if err == nil {
+			t.Errorf("%v: expected error", name)
+			continue
+		}
+		pErr, ok := err.(*ErrParse)
+		if !ok {
+			t.Errorf("%v: wrong error type: %T", name, err)
+			continue
+		}
+		if pErr.Line != test.line || pErr.Column != test.column {
+			t.Errorf("%v: wrong error position: %v:%v != %v:%v (%v)", name, pErr.Line, pErr.Column, test.line, test.column, pErr)
+		}
+	}
+}
+
+func TestMappingJSON(t *testing.T) {
+	tests := map[string]struct {
+		mapping string
+		input   string
+		output  string
+	}{
+		"copy and rename": {
+			mapping: `
+root.id = this.user.id
+root.user = deleted()`,
+			input:  `{"user":{"id":"foo","name":"bar"},"other":true}`,
+			output: `{"id":"foo","other":true}`,
+		},
+		"arithmetic": {
+			mapping: `root.result = (this.a + this.b) * 2 - this.c / 4 % 3`,
+			input:   `{"a":1,"b":2,"c":8}`,
+			output:  `{"a":1,"b":2,"c":8,"result":4}`,
+		},
+		"string functions": {
+			mapping: `
+root = {}
+root.name = (this.first + " " + this.last).uppercase()
+root.tags = this.tags.split(",")
+root.count = this.tags.split(",").length()
+root.has_foo = this.tags.contains("foo")`,
+			input:  `{"first":"john","last":"smith","tags":"foo,bar"}`,
+			output: `{"count":2,"has_foo":true,"name":"JOHN SMITH","tags":["foo","bar"]}`,
+		},
+		"conditionals": {
+			mapping: `
+root.group = if this.age >= 18 { "adult" } else if this.age >= 13 { "teen" } else { "child" }
+root.skipped = if this.age > 100 { "old" }`,
+			input:  `{"age":15}`,
+			output: `{"age":15,"group":"teen"}`,
+		},
+		"literals": {
+			mapping: `root = {"a": [1, "two", null, true], "b": {"c": this.c}}`,
+			input:   `{"c":"see"}`,
+			output:  `{"a":[1,"two",null,true],"b":{"c":"see"}}`,
+		},
+		"array indexes": {
+			mapping: `
+root.first = this.items.0.name
+root.last = this.items.-1.name`,
+			input:  `{"items":[{"name":"foo"},{"name":"bar"}]}`,
+			output: `{"first":"foo","items":[{"name":"foo"},{"name":"bar"}],"last":"bar"}`,
+		},
+		"or default": {
+			mapping: `root.name = this.name.or("anonymous")`,
+			input:   `{}`,
+			output:  `{"name":"anonymous"}`,
+		},
+		"reference root": {
+			mapping: `
+root.a = 5
+root.b = root.a + 1`,
+			input:  `{}`,
+			output: `{"a":5,"b":6}`,
+		},
+	}
+
+	for name, test := range tests {
+		m, err := Parse(test.mapping)
+		if err != nil {
+			t.Errorf("%v: failed to parse: %v", name, err)
+			continue
+		}
+		msg := message.New([][]byte{[]byte(test.input)})
+		if err = m.MapPart(0, msg); err != nil {
+			t.Errorf("%v: failed to map: %v", name, err)
+			continue
+		}
+		if act := string(msg.Get(0).Get()); act != test.output {
+			t.Errorf("%v: wrong result: %v != %v", name, act, test.output)
+		}
+	}
+}
+
+func TestMappingMetadata(t *testing.T) {
+	m, err := Parse(`
+meta foo = meta("bar").uppercase()
+meta "baz qux" = this.num
+meta bar = deleted()
+meta nope = meta("does not exist").or("default")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	part := message.NewPart([]byte(`{"num":10}`))
+	part.Metadata().Set("bar", "hello")
+	msg := message.New(nil)
+	msg.Append(part)
+
+	if err = m.MapPart(0, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := map[string]string{
+		"foo":     "HELLO",
+		"baz qux": "10",
+		"nope":    "default",
+	}
+	act := map[string]string{}
+	msg.Get(0).Metadata().Iter(func(k, v string) error {
+		act[k] = v
+		return nil
+	})
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+	if exp, act := `{"num":10}`, string(msg.Get(0).Get()); exp != act {
+		t.Errorf("Wrong content: %v != %v", act, exp)
+	}
+}
+
+func TestMappingNonJSON(t *testing.T) {
+	m, err := Parse(`meta content = content().uppercase()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := message.New([][]byte{[]byte("not json")})
+	if err = m.MapPart(0, msg); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "not json", string(msg.Get(0).Get()); exp != act {
+		t.Errorf("Wrong content: %v != %v", act, exp)
+	}
+	if exp, act := "NOT JSON", msg.Get(0).Metadata().Get("content"); exp != act {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+
+	if m, err = Parse(`root.foo = this.bar`); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.MapPart(0, msg); err == nil {
+		t.Error("Expected error from non JSON content")
+	}
+}
+
+func TestMappingRuntimeErrors(t *testing.T) {
+	tests := map[string]string{
+		"add mismatch":   `root.foo = this.str + this.num`,
+		"divide by zero": `root.foo = this.num / 0`,
+		"bad condition":  `root.foo = if this.str { "a" } else { "b" }`,
+		"bad method":     `root.foo = this.num.uppercase()`,
+	}
+
+	for name, mapping := range tests {
+		m, err := Parse(mapping)
+		if err != nil {
+			t.Errorf("%v: failed to parse: %v", name, err)
+			continue
+		}
+		input := `{"str":"foo","num":5}`
+		part := message.NewPart([]byte(input))
+		part.Metadata().Set("keep", "me")
+		msg := message.New(nil)
+		msg.Append(part)
+		if err = m.MapPart(0, msg); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+		if act := string(msg.Get(0).Get()); act != input {
+			t.Errorf("%v: part was modified: %v", name, act)
+		}
+	}
+}
+
+func TestMappingFunctionVars(t *testing.T) {
+	m, err := Parse(`
+root.host = hostname()
+root.count = count("mapping_test_counter")
+root.echo = echo("foo")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := message.New([][]byte{[]byte(`{}`)})
+	if err = m.MapPart(0, msg); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := msg.Get(0).JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := doc.(map[string]interface{})
+	if exp, act := "1", obj["count"]; exp != act {
+		t.Errorf("Wrong count: %v != %v", act, exp)
+	}
+	if exp, act := "foo", obj["echo"]; exp != act {
+		t.Errorf("Wrong echo: %v != %v", act, exp)
+	}
+	if _, ok := obj["host"].(string); !ok {
+		t.Errorf("Wrong hostname: %v", obj["host"])
+	}
+}
+
+func TestMappingFunctionVarsPart(t *testing.T) {
+	m, err := Parse(`
+root.name = json_field("name")
+root.meta = metadata("foo")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := message.New([][]byte{
+		[]byte(`{"name":"first"}`),
+		[]byte(`{"name":"second"}`),
+	})
+	msg.Get(0).Metadata().Set("foo", "bar0")
+	msg.Get(1).Metadata().Set("foo", "bar1")
+
+	// Function interpolations should resolve against the part being mapped.
+	if err = m.MapPart(1, msg); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := msg.Get(1).JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]interface{}{
+		"name": "second",
+		"meta": "bar1",
+	}
+	if !reflect.DeepEqual(exp, doc) {
+		t.Errorf("Wrong result: %v != %v", doc, exp)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package mapping implements a small expression language for mapping the
+// contents and metadata of a message part into a new document.
+//
+// A mapping is a list of assignments separated by newlines, where each
+// assignment targets either a path of the resulting document (`root`) or a
+// metadata key (`meta`):
+//
+//   root.id = this.user.id
+//   root.name = this.user.first_name + " " + this.user.last_name
+//   root.age_group = if this.user.age >= 18 { "adult" } else { "minor" }
+//   root.user = deleted()
+//   meta topic = "users_" + this.user.region.lowercase()
+//
+// The document resulting from a mapping begins as a copy of the input
+// document, which is referenced with the keyword `this`.
+package mapping
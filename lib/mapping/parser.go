@@ -0,0 +1,415 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mapping
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//------------------------------------------------------------------------------
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isPunct(value string) bool {
+	t := p.peek()
+	return t.typ == tokPunct && t.value == value
+}
+
+func (p *parser) isIdent(value string) bool {
+	t := p.peek()
+	return t.typ == tokIdent && t.value == value
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return &ErrParse{
+		Line:   t.line,
+		Column: t.col,
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *parser) expectPunct(value string) error {
+	if t := p.next(); t.typ != tokPunct || t.value != value {
+		return p.errorf(t, "expected '%v', found %v", value, t)
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+func (p *parser) parseStatements() ([]statement, error) {
+	var statements []statement
+	for {
+		for p.peek().typ == tokNewline {
+			p.next()
+		}
+		if p.peek().typ == tokEOF {
+			return statements, nil
+		}
+		s, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, s)
+		if t := p.next(); t.typ != tokNewline && t.typ != tokEOF {
+			return nil, p.errorf(t, "expected end of statement, found %v", t)
+		}
+	}
+}
+
+func (p *parser) parseStatement() (statement, error) {
+	s := statement{line: p.peek().line}
+
+	t := p.next()
+	switch {
+	case t.typ == tokIdent && t.value == "root":
+		var path []string
+		for p.isPunct(".") {
+			p.next()
+			seg := p.next()
+			switch seg.typ {
+			case tokIdent, tokString:
+				path = append(path, seg.value)
+			case tokNumber:
+				path = append(path, strings.Split(seg.value, ".")...)
+			default:
+				return s, p.errorf(seg, "expected path segment, found %v", seg)
+			}
+		}
+		s.path = path
+	case t.typ == tokIdent && t.value == "meta":
+		key := p.next()
+		if key.typ != tokIdent && key.typ != tokString {
+			return s, p.errorf(key, "expected metadata key, found %v", key)
+		}
+		s.isMeta = true
+		s.metaKey = key.value
+	default:
+		return s, p.errorf(t, "expected assignment target 'root' or 'meta', found %v", t)
+	}
+
+	if err := p.expectPunct("="); err != nil {
+		return s, err
+	}
+
+	var err error
+	s.value, err = p.parseExpression()
+	return s, err
+}
+
+//------------------------------------------------------------------------------
+
+// binaryPrecedence lists the binary operators from lowest to highest
+// precedence.
+var binaryPrecedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseExpression() (expression, error) {
+	return p.parseBinary(0)
+}
+
+func (p *parser) parseBinary(level int) (expression, error) {
+	if level >= len(binaryPrecedence) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.typ != tokPunct {
+			return left, nil
+		}
+		matched := false
+		for _, op := range binaryPrecedence[level] {
+			if t.value == op {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: t.value, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (expression, error) {
+	if p.isPunct("!") || p.isPunct("-") {
+		op := p.next().value
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryExpr{op: op, operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (expression, error) {
+	expr, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct(".") {
+		p.next()
+		t := p.next()
+		switch t.typ {
+		case tokIdent:
+			if p.isPunct("(") {
+				spec, exists := methods[t.value]
+				if !exists {
+					return nil, p.errorf(t, "unrecognised method '%v'", t.value)
+				}
+				args, err := p.parseArgs(t, spec.minArgs, spec.maxArgs)
+				if err != nil {
+					return nil, err
+				}
+				expr = methodExpr{target: expr, name: t.value, args: args, fn: spec.fn}
+			} else {
+				expr = fieldExpr{target: expr, field: t.value}
+			}
+		case tokString:
+			expr = fieldExpr{target: expr, field: t.value}
+		case tokNumber:
+			for _, seg := range strings.Split(t.value, ".") {
+				expr = fieldExpr{target: expr, field: seg}
+			}
+		case tokPunct:
+			// Negative array indexes count backwards from the last element.
+			if n := p.peek(); t.value == "-" && n.typ == tokNumber && !strings.Contains(n.value, ".") {
+				expr = fieldExpr{target: expr, field: "-" + p.next().value}
+				continue
+			}
+			return nil, p.errorf(t, "expected field or method name, found %v", t)
+		default:
+			return nil, p.errorf(t, "expected field or method name, found %v", t)
+		}
+	}
+	return expr, nil
+}
+
+// parseArgs parses a bracketed list of arguments of a function or method call.
+func (p *parser) parseArgs(name token, minArgs, maxArgs int) ([]expression, error) {
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	var args []expression
+	for !p.isPunct(")") {
+		if len(args) > 0 {
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+	if len(args) < minArgs || len(args) > maxArgs {
+		if minArgs == maxArgs {
+			return nil, p.errorf(name, "'%v' expects %v arguments, found %v", name.value, minArgs, len(args))
+		}
+		return nil, p.errorf(name, "'%v' expects between %v and %v arguments, found %v", name.value, minArgs, maxArgs, len(args))
+	}
+	return args, nil
+}
+
+func (p *parser) parsePrimary() (expression, error) {
+	t := p.next()
+	switch t.typ {
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number: %v", err)
+		}
+		return literalExpr{value: f}, nil
+	case tokString:
+		return literalExpr{value: t.value}, nil
+	case tokIdent:
+		switch t.value {
+		case "true":
+			return literalExpr{value: true}, nil
+		case "false":
+			return literalExpr{value: false}, nil
+		case "null":
+			return literalExpr{value: nil}, nil
+		case "this":
+			return thisExpr{}, nil
+		case "root":
+			return rootExpr{}, nil
+		case "if":
+			return p.parseIf()
+		}
+		if !p.isPunct("(") {
+			return nil, p.errorf(t, "unexpected identifier '%v'", t.value)
+		}
+		spec, exists := getFunction(t.value)
+		if !exists {
+			return nil, p.errorf(t, "unrecognised function '%v'", t.value)
+		}
+		args, err := p.parseArgs(t, spec.minArgs, spec.maxArgs)
+		if err != nil {
+			return nil, err
+		}
+		return functionExpr{name: t.value, args: args, fn: spec.fn}, nil
+	case tokPunct:
+		switch t.value {
+		case "(":
+			expr, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+			return expr, nil
+		case "{":
+			return p.parseObject()
+		case "[":
+			return p.parseArray()
+		}
+	}
+	return nil, p.errorf(t, "expected expression, found %v", t)
+}
+
+func (p *parser) parseIf() (expression, error) {
+	cond, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	then, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	expr := ifExpr{cond: cond, then: then}
+	if p.isIdent("else") {
+		p.next()
+		if p.isIdent("if") {
+			p.next()
+			expr.otherwise, err = p.parseIf()
+		} else {
+			expr.otherwise, err = p.parseBlock()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return expr, nil
+}
+
+func (p *parser) parseBlock() (expression, error) {
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	expr, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expectPunct("}"); err != nil {
+		return nil, err
+	}
+	return expr, nil
+}
+
+func (p *parser) parseObject() (expression, error) {
+	obj := objectExpr{}
+	for !p.isPunct("}") {
+		if len(obj.keys) > 0 {
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+			// Allow trailing commas.
+			if p.isPunct("}") {
+				break
+			}
+		}
+		key := p.next()
+		if key.typ != tokString && key.typ != tokIdent {
+			return nil, p.errorf(key, "expected object key, found %v", key)
+		}
+		if err := p.expectPunct(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		obj.keys = append(obj.keys, key.value)
+		obj.values = append(obj.values, value)
+	}
+	p.next()
+	return obj, nil
+}
+
+func (p *parser) parseArray() (expression, error) {
+	arr := arrayExpr{}
+	for !p.isPunct("]") {
+		if len(arr.values) > 0 {
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+			// Allow trailing commas.
+			if p.isPunct("]") {
+				break
+			}
+		}
+		value, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		arr.values = append(arr.values, value)
+	}
+	p.next()
+	return arr, nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,171 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package batch
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// PolicyConfig contains configuration parameters for a batch policy.
+type PolicyConfig struct {
+	ByteSize int    `json:"byte_size" yaml:"byte_size"`
+	Count    int    `json:"count" yaml:"count"`
+	Period   string `json:"period" yaml:"period"`
+}
+
+// NewPolicyConfig creates a default PolicyConfig, which does not batch.
+func NewPolicyConfig() PolicyConfig {
+	return PolicyConfig{
+		ByteSize: 0,
+		Count:    0,
+		Period:   "",
+	}
+}
+
+// IsNoop returns true if this policy configuration does nothing, which is
+// also the case when the count is one and no other fields are set.
+func (p PolicyConfig) IsNoop() bool {
+	return p.ByteSize <= 0 && p.Count <= 1 && len(p.Period) == 0
+}
+
+//------------------------------------------------------------------------------
+
+// Policy implements a batching policy by accumulating message parts until
+// either a count, byte size or period is reached.
+type Policy struct {
+	log log.Modular
+
+	byteSize int
+	count    int
+	period   time.Duration
+
+	sizeTally int
+	parts     []types.Part
+	lastBatch time.Time
+
+	mSizeBatch   metrics.StatCounter
+	mCountBatch  metrics.StatCounter
+	mPeriodBatch metrics.StatCounter
+}
+
+// NewPolicy creates an empty policy with default rules.
+func NewPolicy(
+	conf PolicyConfig,
+	log log.Modular,
+	stats metrics.Type,
+) (*Policy, error) {
+	var err error
+	var period time.Duration
+	if len(conf.Period) > 0 {
+		if period, err = time.ParseDuration(conf.Period); err != nil {
+			return nil, fmt.Errorf("failed to parse duration string: %v", err)
+		}
+	}
+	return &Policy{
+		log: log,
+
+		byteSize: conf.ByteSize,
+		count:    conf.Count,
+		period:   period,
+
+		lastBatch: time.Now(),
+
+		mSizeBatch:   stats.GetCounter("on_size"),
+		mCountBatch:  stats.GetCounter("on_count"),
+		mPeriodBatch: stats.GetCounter("on_period"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Add a new message part to this batch policy. Returns true if this part
+// triggers the conditions of the policy.
+func (p *Policy) Add(part types.Part) bool {
+	p.sizeTally += len(part.Get())
+	p.parts = append(p.parts, part)
+
+	if p.count > 0 && len(p.parts) >= p.count {
+		p.mCountBatch.Incr(1)
+		p.log.Traceln("Batching based on count")
+		return true
+	}
+	if p.byteSize > 0 && p.sizeTally >= p.byteSize {
+		p.mSizeBatch.Incr(1)
+		p.log.Traceln("Batching based on byte_size")
+		return true
+	}
+	if p.period > 0 && time.Since(p.lastBatch) >= p.period {
+		p.mPeriodBatch.Incr(1)
+		p.log.Traceln("Batching based on period")
+		return true
+	}
+	return false
+}
+
+// Flush clears all messages stored by this batch policy. Returns nil if the
+// policy is currently empty.
+func (p *Policy) Flush() types.Message {
+	var newMsg types.Message
+	if len(p.parts) > 0 {
+		newMsg = message.New(nil)
+		newMsg.Append(p.parts...)
+	}
+	p.parts = nil
+	p.sizeTally = 0
+	p.lastBatch = time.Now()
+	return newMsg
+}
+
+// Count returns the number of currently buffered message parts within this
+// policy.
+func (p *Policy) Count() int {
+	return len(p.parts)
+}
+
+// UntilNext returns a duration indicating how long until the current batch
+// should be flushed due to a configured period. A negative duration indicates
+// a period has not been set.
+func (p *Policy) UntilNext() time.Duration {
+	if p.period <= 0 {
+		return -1
+	}
+	if tNext := p.period - time.Since(p.lastBatch); tNext > 0 {
+		return tNext
+	}
+	return 0
+}
+
+// MarkPeriodFlush records that the current batch is being flushed due to the
+// period of the policy elapsing.
+func (p *Policy) MarkPeriodFlush() {
+	p.mPeriodBatch.Incr(1)
+	p.log.Traceln("Batching based on period")
+}
+
+//------------------------------------------------------------------------------
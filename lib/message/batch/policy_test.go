@@ -0,0 +1,168 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package batch
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+)
+
+//------------------------------------------------------------------------------
+
+func TestPolicyNoop(t *testing.T) {
+	conf := NewPolicyConfig()
+	if !conf.IsNoop() {
+		t.Error("Expected default config to be noop")
+	}
+
+	conf.Count = 1
+	if !conf.IsNoop() {
+		t.Error("Expected config with count of one to be noop")
+	}
+
+	conf.Count = 2
+	if conf.IsNoop() {
+		t.Error("Expected config with count to not be noop")
+	}
+
+	conf.Count = 0
+	conf.Period = "1s"
+	if conf.IsNoop() {
+		t.Error("Expected config with period to not be noop")
+	}
+}
+
+func TestPolicyBadPeriod(t *testing.T) {
+	conf := NewPolicyConfig()
+	conf.Period = "not a duration"
+	if _, err := NewPolicy(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad period")
+	}
+}
+
+func TestPolicyCount(t *testing.T) {
+	conf := NewPolicyConfig()
+	conf.Count = 2
+
+	pol, err := NewPolicy(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := pol.UntilNext(); v >= 0 {
+		t.Errorf("Non-negative period: %v", v)
+	}
+	if msg := pol.Flush(); msg != nil {
+		t.Errorf("Expected nil flush from empty policy: %v", msg)
+	}
+
+	if pol.Add(message.NewPart([]byte("foo"))) {
+		t.Error("Unexpected batch")
+	}
+	if exp, act := 1, pol.Count(); exp != act {
+		t.Errorf("Wrong count: %v != %v", act, exp)
+	}
+	if !pol.Add(message.NewPart([]byte("bar"))) {
+		t.Error("Expected batch")
+	}
+
+	msg := pol.Flush()
+	if msg == nil {
+		t.Fatal("Expected message from flush")
+	}
+	if exp, act := [][]byte{[]byte("foo"), []byte("bar")}, message.GetAllBytes(msg); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	if exp, act := 0, pol.Count(); exp != act {
+		t.Errorf("Wrong count: %v != %v", act, exp)
+	}
+	if msg = pol.Flush(); msg != nil {
+		t.Errorf("Expected nil flush after flush: %v", msg)
+	}
+}
+
+func TestPolicyByteSize(t *testing.T) {
+	conf := NewPolicyConfig()
+	conf.ByteSize = 10
+
+	pol, err := NewPolicy(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pol.Add(message.NewPart([]byte("hello"))) {
+		t.Error("Unexpected batch")
+	}
+	if pol.Add(message.NewPart([]byte("wor"))) {
+		t.Error("Unexpected batch")
+	}
+	if !pol.Add(message.NewPart([]byte("ld"))) {
+		t.Error("Expected batch")
+	}
+
+	msg := pol.Flush()
+	if exp, act := [][]byte{[]byte("hello"), []byte("wor"), []byte("ld")}, message.GetAllBytes(msg); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+
+	if pol.Add(message.NewPart([]byte("hello"))) {
+		t.Error("Unexpected batch after flush")
+	}
+}
+
+func TestPolicyPeriod(t *testing.T) {
+	conf := NewPolicyConfig()
+	conf.Period = "50ms"
+
+	pol, err := NewPolicy(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := pol.UntilNext(); v <= 0 || v > time.Millisecond*50 {
+		t.Errorf("Wrong period: %v", v)
+	}
+	if pol.Add(message.NewPart([]byte("foo"))) {
+		t.Error("Unexpected batch")
+	}
+
+	<-time.After(time.Millisecond * 60)
+	if v := pol.UntilNext(); v != 0 {
+		t.Errorf("Wrong period: %v", v)
+	}
+	if !pol.Add(message.NewPart([]byte("bar"))) {
+		t.Error("Expected batch")
+	}
+
+	if exp, act := 2, pol.Flush().Len(); exp != act {
+		t.Errorf("Wrong batch size: %v != %v", act, exp)
+	}
+	if v := pol.UntilNext(); v <= 0 || v > time.Millisecond*50 {
+		t.Errorf("Wrong period after flush: %v", v)
+	}
+}
+
+//------------------------------------------------------------------------------
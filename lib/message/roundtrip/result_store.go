@@ -0,0 +1,130 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package roundtrip
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// ErrNoStore is returned when a message does not have a result store attached.
+var ErrNoStore = errors.New("message does not have a result store")
+
+// ResultStore is a type that can store the results of processing a message and
+// return them to an input.
+type ResultStore interface {
+	// Add a message to the store. The message will be deep copied and have
+	// its context wiped before storing, and is therefore safe to add even when
+	// ownership of the message is about to be yielded.
+	Add(msg types.Message)
+
+	// Get the stored slice of messages.
+	Get() []types.Message
+
+	// Clear any currently stored messages.
+	Clear()
+}
+
+//------------------------------------------------------------------------------
+
+type resultStoreImpl struct {
+	payloads []types.Message
+	mut      sync.Mutex
+}
+
+func (r *resultStoreImpl) Add(msg types.Message) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	strippedParts := make([]types.Part, msg.Len())
+	msg.DeepCopy().Iter(func(i int, p types.Part) error {
+		strippedParts[i] = message.WithContext(context.Background(), p)
+		return nil
+	})
+
+	newMsg := message.New(nil)
+	newMsg.SetAll(strippedParts)
+	r.payloads = append(r.payloads, newMsg)
+}
+
+func (r *resultStoreImpl) Get() []types.Message {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return r.payloads
+}
+
+func (r *resultStoreImpl) Clear() {
+	r.mut.Lock()
+	r.payloads = nil
+	r.mut.Unlock()
+}
+
+// NewResultStore returns an implementation of ResultStore.
+func NewResultStore() ResultStore {
+	return &resultStoreImpl{}
+}
+
+//------------------------------------------------------------------------------
+
+type resultStoreKeyType int
+
+const resultStoreKey resultStoreKeyType = iota
+
+// AddResultStore sets a result store within the context of each part of a
+// message.
+func AddResultStore(msg types.Message, store ResultStore) {
+	parts := make([]types.Part, msg.Len())
+	msg.Iter(func(i int, p types.Part) error {
+		ctx := context.WithValue(message.GetContext(p), resultStoreKey, store)
+		parts[i] = message.WithContext(ctx, p)
+		return nil
+	})
+	msg.SetAll(parts)
+}
+
+// GetResultStore returns the result store attached to a message part, or nil
+// if one is not present.
+func GetResultStore(p types.Part) ResultStore {
+	store, _ := message.GetContext(p).Value(resultStoreKey).(ResultStore)
+	return store
+}
+
+// SetAsResponse takes a mutated message and stores it as a response message,
+// this action fails if the message does not contain a valid result store.
+func SetAsResponse(msg types.Message) error {
+	if msg.Len() == 0 {
+		return nil
+	}
+	store := GetResultStore(msg.Get(0))
+	if store == nil {
+		return ErrNoStore
+	}
+	store.Add(msg)
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,78 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package roundtrip
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/message"
+)
+
+//------------------------------------------------------------------------------
+
+func TestResultStore(t *testing.T) {
+	store := NewResultStore()
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	AddResultStore(msg, store)
+
+	if GetResultStore(msg.Get(1)) != store {
+		t.Error("Expected store to be attached to each part")
+	}
+
+	msg.Get(0).Set([]byte("baz"))
+	msg.Get(0).Metadata().Set("status", "201")
+	if err := SetAsResponse(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mutations after storing should not affect the result.
+	msg.Get(1).Set([]byte("qux"))
+
+	results := store.Get()
+	if exp, act := 1, len(results); exp != act {
+		t.Fatalf("Wrong count of results: %v != %v", act, exp)
+	}
+	if exp, act := [][]byte{[]byte("baz"), []byte("bar")}, message.GetAllBytes(results[0]); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	if exp, act := "201", results[0].Get(0).Metadata().Get("status"); exp != act {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+	if GetResultStore(results[0].Get(0)) != nil {
+		t.Error("Expected stored result to have its context wiped")
+	}
+
+	store.Clear()
+	if exp, act := 0, len(store.Get()); exp != act {
+		t.Errorf("Wrong count of results after clear: %v != %v", act, exp)
+	}
+}
+
+func TestResultStoreMissing(t *testing.T) {
+	msg := message.New([][]byte{[]byte("foo")})
+	if err := SetAsResponse(msg); err != ErrNoStore {
+		t.Errorf("Wrong error: %v != %v", err, ErrNoStore)
+	}
+}
+
+//------------------------------------------------------------------------------
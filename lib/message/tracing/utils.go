@@ -30,6 +30,12 @@ import (
 
 //------------------------------------------------------------------------------
 
+// TraceParentKey is the metadata key through which span contexts are propagated
+// in the W3C Trace Context format.
+const TraceParentKey = "traceparent"
+
+//------------------------------------------------------------------------------
+
 // GetSpan returns a span attached to a message part. Returns nil if the part
 // doesn't have a span attached.
 func GetSpan(p types.Part) opentracing.Span {
@@ -165,6 +171,69 @@ func InitSpansFromParent(operationName string, parent opentracing.SpanContext, m
 	msg.SetAll(tracedParts)
 }
 
+// InitSpansFromTraceParent sets up OpenTracing spans on each message part if one
+// does not already exist. Parts with a traceparent metadata value have their
+// spans created as children of the span context it describes.
+func InitSpansFromTraceParent(operationName string, msg types.Message) {
+	tracedParts := make([]types.Part, msg.Len())
+	msg.Iter(func(i int, p types.Part) error {
+		if GetSpan(p) != nil {
+			tracedParts[i] = p
+			return nil
+		}
+		var span opentracing.Span
+		if parent := ExtractTraceParent(p); parent != nil {
+			span = opentracing.StartSpan(operationName, opentracing.ChildOf(parent))
+		} else {
+			span = opentracing.StartSpan(operationName)
+		}
+		ctx := opentracing.ContextWithSpan(context.Background(), span)
+		tracedParts[i] = message.WithContext(ctx, p)
+		return nil
+	})
+	msg.SetAll(tracedParts)
+}
+
+// ExtractTraceParent attempts to extract a span context from the traceparent
+// metadata value of a message part. Returns nil if the part doesn't have a
+// traceparent or if it cannot be parsed by the tracer.
+func ExtractTraceParent(p types.Part) opentracing.SpanContext {
+	traceParent := p.Metadata().Get(TraceParentKey)
+	if len(traceParent) == 0 {
+		return nil
+	}
+	spanCtx, err := opentracing.GlobalTracer().Extract(
+		opentracing.TextMap,
+		opentracing.TextMapCarrier{TraceParentKey: traceParent},
+	)
+	if err != nil {
+		return nil
+	}
+	return spanCtx
+}
+
+// TraceParent returns a traceparent value describing the span attached to a
+// message part. Returns an empty string if the part doesn't have a span or if
+// the tracer doesn't support the W3C Trace Context format.
+func TraceParent(p types.Part) string {
+	span := GetSpan(p)
+	if span == nil {
+		return ""
+	}
+	return SpanTraceParent(span)
+}
+
+// SpanTraceParent returns a traceparent value describing a span. Returns an
+// empty string if the tracer of the span doesn't support the W3C Trace Context
+// format.
+func SpanTraceParent(span opentracing.Span) string {
+	carrier := opentracing.TextMapCarrier{}
+	if err := span.Tracer().Inject(span.Context(), opentracing.TextMap, carrier); err != nil {
+		return ""
+	}
+	return carrier[TraceParentKey]
+}
+
 // FinishSpans calls Finish on all message parts containing a span.
 func FinishSpans(msg types.Message) {
 	msg.Iter(func(i int, p types.Part) error {
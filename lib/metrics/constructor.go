@@ -52,6 +52,11 @@ type TypeSpec struct {
 // Constructors is a map of all metrics types with their specs.
 var Constructors = map[string]TypeSpec{}
 
+// Description returns a markdown formatted description of the metrics type.
+func (t TypeSpec) Description() string {
+	return t.description
+}
+
 //------------------------------------------------------------------------------
 
 // String constants representing each metric type.
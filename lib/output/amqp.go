@@ -47,7 +47,15 @@ TLS is automatic when connecting to an ` + "`amqps`" + ` URL, but custom
 settings can be enabled in the ` + "`tls`" + ` section.
 
 The field 'key' can be dynamically set using function interpolations described
-[here](../config_interpolation.md#functions).`,
+[here](../config_interpolation.md#functions).
+
+The field ` + "`max_in_flight`" + ` sets the maximum number of messages that can
+be in flight at the same time, increasing it can improve throughput at the cost
+of message ordering.
+
+Setting ` + "`inject_traceparent`" + ` to ` + "`true`" + ` adds a
+[W3C](https://www.w3.org/TR/trace-context/) ` + "`traceparent`" + ` header to
+each message describing its tracing span.`,
 	}
 }
 
@@ -59,8 +67,8 @@ func NewAMQP(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type
 	if err != nil {
 		return nil, err
 	}
-	return NewWriter(
-		"amqp", a, log, stats,
+	return NewAsyncWriter(
+		"amqp", conf.AMQP.MaxInFlight, a, log, stats,
 	)
 }
 
@@ -0,0 +1,294 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message/tracing"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/output/writer"
+	"github.com/Jeffail/benthos/lib/response"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/throttle"
+)
+
+//------------------------------------------------------------------------------
+
+// AsyncWriter is an output type that writes messages to a writer.AsyncType,
+// where up to a maximum number of transactions are written in parallel. Each
+// response from the writer is routed back to the transaction it belongs to.
+type AsyncWriter struct {
+	running     int32
+	isConnected int32
+
+	typeStr     string
+	maxInFlight int
+	writer      writer.AsyncType
+
+	log   log.Modular
+	stats metrics.Type
+
+	transactions <-chan types.Transaction
+
+	ctx     context.Context
+	cancel  func()
+	connMut sync.Mutex
+
+	mCount        metrics.StatCounter
+	mPartsCount   metrics.StatCounter
+	mSuccess      metrics.StatCounter
+	mPartsSuccess metrics.StatCounter
+	mError        metrics.StatCounter
+	mSent         metrics.StatCounter
+	mPartsSent    metrics.StatCounter
+	mConn         metrics.StatCounter
+	mFailedConn   metrics.StatCounter
+	mLostConn     metrics.StatCounter
+
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+// NewAsyncWriter creates a new AsyncWriter output type.
+func NewAsyncWriter(
+	typeStr string,
+	maxInFlight int,
+	w writer.AsyncType,
+	log log.Modular,
+	stats metrics.Type,
+) (Type, error) {
+	if maxInFlight < 1 {
+		return nil, fmt.Errorf("invalid max_in_flight parameter: %v", maxInFlight)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &AsyncWriter{
+		running:      1,
+		typeStr:      typeStr,
+		maxInFlight:  maxInFlight,
+		writer:       w,
+		log:          log,
+		stats:        stats,
+		transactions: nil,
+		ctx:          ctx,
+		cancel:       cancel,
+
+		mCount:        stats.GetCounter("count"),
+		mPartsCount:   stats.GetCounter("parts.count"),
+		mSuccess:      stats.GetCounter("send.success"),
+		mPartsSuccess: stats.GetCounter("parts.send.success"),
+		mError:        stats.GetCounter("send.error"),
+		mSent:         stats.GetCounter("batch.sent"),
+		mPartsSent:    stats.GetCounter("sent"),
+		mConn:         stats.GetCounter("connection.up"),
+		mFailedConn:   stats.GetCounter("connection.failed"),
+		mLostConn:     stats.GetCounter("connection.lost"),
+
+		closeChan:  make(chan struct{}),
+		closedChan: make(chan struct{}),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// connect attempts to connect the writer, calls are serialised so that only
+// one reconnection attempt is made at a time regardless of how many writes are
+// in flight.
+func (w *AsyncWriter) connect() error {
+	w.connMut.Lock()
+	defer w.connMut.Unlock()
+	return w.writer.ConnectWithContext(w.ctx)
+}
+
+// loop is an internal loop that connects the writer and then starts a worker
+// for each transaction that may be in flight.
+func (w *AsyncWriter) loop() {
+	mRunning := w.stats.GetGauge("running")
+
+	defer func() {
+		err := w.writer.WaitForClose(time.Second)
+		for ; err != nil; err = w.writer.WaitForClose(time.Second) {
+		}
+		mRunning.Decr(1)
+		atomic.StoreInt32(&w.isConnected, 0)
+		close(w.closedChan)
+	}()
+	mRunning.Incr(1)
+
+	throt := throttle.New(throttle.OptCloseChan(w.closeChan))
+
+	for {
+		if err := w.connect(); err != nil {
+			// Close immediately if our writer is closed.
+			if err == types.ErrTypeClosed {
+				return
+			}
+
+			w.log.Errorf("Failed to connect to %v: %v\n", w.typeStr, err)
+			w.mFailedConn.Incr(1)
+			if !throt.Retry() {
+				return
+			}
+		} else {
+			break
+		}
+	}
+	w.mConn.Incr(1)
+	atomic.StoreInt32(&w.isConnected, 1)
+
+	wg := sync.WaitGroup{}
+	wg.Add(w.maxInFlight)
+	for i := 0; i < w.maxInFlight; i++ {
+		go func() {
+			defer wg.Done()
+			w.worker()
+		}()
+	}
+	wg.Wait()
+}
+
+// worker consumes transactions and writes them until the output is closed.
+func (w *AsyncWriter) worker() {
+	throt := throttle.New(throttle.OptCloseChan(w.closeChan))
+
+	for atomic.LoadInt32(&w.running) == 1 {
+		var ts types.Transaction
+		var open bool
+		select {
+		case ts, open = <-w.transactions:
+			if !open {
+				return
+			}
+			w.mCount.Incr(1)
+			w.mPartsCount.Incr(int64(ts.Payload.Len()))
+		case <-w.closeChan:
+			return
+		}
+
+		spans := tracing.CreateChildSpans("output_"+w.typeStr, ts.Payload)
+		err := w.writer.WriteWithContext(w.ctx, ts.Payload)
+
+		// If our writer says it is not connected.
+		if err == types.ErrNotConnected {
+			w.mLostConn.Incr(1)
+			atomic.StoreInt32(&w.isConnected, 0)
+
+			// Continue to try to reconnect while still active.
+			for atomic.LoadInt32(&w.running) == 1 {
+				if err = w.connect(); err != nil {
+					// Close immediately if our writer is closed.
+					if err == types.ErrTypeClosed {
+						return
+					}
+
+					w.log.Errorf("Failed to reconnect to %v: %v\n", w.typeStr, err)
+					w.mFailedConn.Incr(1)
+					if !throt.Retry() {
+						return
+					}
+				} else if err = w.writer.WriteWithContext(w.ctx, ts.Payload); err != types.ErrNotConnected {
+					atomic.StoreInt32(&w.isConnected, 1)
+					w.mConn.Incr(1)
+					break
+				} else if !throt.Retry() {
+					return
+				}
+			}
+		}
+
+		// Close immediately if our writer is closed.
+		if err == types.ErrTypeClosed {
+			return
+		}
+
+		if err != nil {
+			w.log.Errorf("Failed to send message to %v: %v\n", w.typeStr, err)
+			w.mError.Incr(1)
+			if !throt.Retry() {
+				return
+			}
+		} else {
+			w.mSuccess.Incr(1)
+			w.mPartsSuccess.Incr(int64(ts.Payload.Len()))
+			w.mSent.Incr(1)
+			w.mPartsSent.Incr(int64(ts.Payload.Len()))
+			throt.Reset()
+		}
+
+		for _, s := range spans {
+			s.Finish()
+		}
+
+		select {
+		case ts.ResponseChan <- response.NewError(err):
+		case <-w.closeChan:
+			// The pipeline is terminating but we still want to attempt to
+			// propagate an acknowledgement from in-transit messages.
+			select {
+			case ts.ResponseChan <- response.NewError(err):
+			case <-time.After(time.Second):
+			}
+			return
+		}
+	}
+}
+
+// Consume assigns a messages channel for the output to read.
+func (w *AsyncWriter) Consume(ts <-chan types.Transaction) error {
+	if w.transactions != nil {
+		return types.ErrAlreadyStarted
+	}
+	w.transactions = ts
+	go w.loop()
+	return nil
+}
+
+// Connected returns a boolean indicating whether this output is currently
+// connected to its target.
+func (w *AsyncWriter) Connected() bool {
+	return atomic.LoadInt32(&w.isConnected) == 1
+}
+
+// CloseAsync shuts down the AsyncWriter output and stops processing messages.
+func (w *AsyncWriter) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&w.running, 1, 0) {
+		w.writer.CloseAsync()
+		w.cancel()
+		close(w.closeChan)
+	}
+}
+
+// WaitForClose blocks until the AsyncWriter output has closed down.
+func (w *AsyncWriter) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-w.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,264 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+type mockAsyncWriter struct {
+	writes    map[string]chan error
+	writesMut sync.Mutex
+	writeChan chan string
+}
+
+func newMockAsyncWriter() *mockAsyncWriter {
+	return &mockAsyncWriter{
+		writes:    map[string]chan error{},
+		writeChan: make(chan string),
+	}
+}
+
+func (w *mockAsyncWriter) ConnectWithContext(ctx context.Context) error {
+	return nil
+}
+
+func (w *mockAsyncWriter) WriteWithContext(ctx context.Context, msg types.Message) error {
+	content := string(msg.Get(0).Get())
+	resChan := make(chan error)
+
+	w.writesMut.Lock()
+	w.writes[content] = resChan
+	w.writesMut.Unlock()
+
+	select {
+	case w.writeChan <- content:
+	case <-ctx.Done():
+		return types.ErrTypeClosed
+	}
+	select {
+	case err := <-resChan:
+		return err
+	case <-ctx.Done():
+		return types.ErrTypeClosed
+	}
+}
+
+func (w *mockAsyncWriter) respond(t *testing.T, content string, err error) {
+	t.Helper()
+
+	w.writesMut.Lock()
+	resChan := w.writes[content]
+	w.writesMut.Unlock()
+
+	select {
+	case resChan <- err:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out responding to write: %v", content)
+	}
+}
+
+func (w *mockAsyncWriter) CloseAsync() {}
+
+func (w *mockAsyncWriter) WaitForClose(time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+func TestAsyncWriterBadMaxInFlight(t *testing.T) {
+	if _, err := NewAsyncWriter("foo", 0, newMockAsyncWriter(), log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from zero max in flight")
+	}
+}
+
+func TestAsyncWriterParallelWrites(t *testing.T) {
+	t.Parallel()
+
+	writerImpl := newMockAsyncWriter()
+
+	w, err := NewAsyncWriter("foo", 2, writerImpl, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tChan := make(chan types.Transaction)
+	if err = w.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	resChanFoo := make(chan types.Response)
+	resChanBar := make(chan types.Response)
+
+	for _, ts := range []types.Transaction{
+		types.NewTransaction(message.New([][]byte{[]byte("foo")}), resChanFoo),
+		types.NewTransaction(message.New([][]byte{[]byte("bar")}), resChanBar),
+	} {
+		select {
+		case tChan <- ts:
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	// Both writes should be in flight at the same time.
+	inFlight := map[string]struct{}{}
+	for i := 0; i < 2; i++ {
+		select {
+		case content := <-writerImpl.writeChan:
+			inFlight[content] = struct{}{}
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+	if _, exists := inFlight["foo"]; !exists {
+		t.Error("Expected foo to be in flight")
+	}
+	if _, exists := inFlight["bar"]; !exists {
+		t.Error("Expected bar to be in flight")
+	}
+
+	// A third transaction must wait for an in flight write to finish.
+	resChanBaz := make(chan types.Response)
+	select {
+	case tChan <- types.NewTransaction(message.New([][]byte{[]byte("baz")}), resChanBaz):
+		t.Fatal("Expected transaction to be blocked")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	// Respond out of order, responses should reach the correct transaction.
+	errTest := errors.New("test err")
+	writerImpl.respond(t, "bar", errTest)
+
+	select {
+	case res := <-resChanBar:
+		if exp, act := errTest, res.Error(); exp != act {
+			t.Errorf("Wrong response: %v != %v", act, exp)
+		}
+	case <-resChanFoo:
+		t.Fatal("Response routed to wrong transaction")
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	writerImpl.respond(t, "foo", nil)
+
+	select {
+	case res := <-resChanFoo:
+		if err = res.Error(); err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	w.CloseAsync()
+	if err = w.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type asyncWriterReconnect struct {
+	connected   int
+	failedWrite bool
+	mut         sync.Mutex
+}
+
+func (w *asyncWriterReconnect) ConnectWithContext(ctx context.Context) error {
+	w.mut.Lock()
+	w.connected++
+	w.mut.Unlock()
+	return nil
+}
+
+func (w *asyncWriterReconnect) WriteWithContext(ctx context.Context, msg types.Message) error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	if !w.failedWrite {
+		w.failedWrite = true
+		return types.ErrNotConnected
+	}
+	return nil
+}
+
+func (w *asyncWriterReconnect) CloseAsync() {}
+
+func (w *asyncWriterReconnect) WaitForClose(time.Duration) error {
+	return nil
+}
+
+func TestAsyncWriterCanReconnect(t *testing.T) {
+	t.Parallel()
+
+	writerImpl := &asyncWriterReconnect{}
+
+	w, err := NewAsyncWriter("foo", 4, writerImpl, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tChan := make(chan types.Transaction)
+	if err = w.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	resChan := make(chan types.Response)
+	select {
+	case tChan <- types.NewTransaction(message.New([][]byte{[]byte("foo")}), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	select {
+	case res := <-resChan:
+		if err = res.Error(); err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	writerImpl.mut.Lock()
+	if exp, act := 2, writerImpl.connected; exp != act {
+		t.Errorf("Wrong count of connects: %v != %v", act, exp)
+	}
+	writerImpl.mut.Unlock()
+
+	w.CloseAsync()
+	if err = w.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+//------------------------------------------------------------------------------
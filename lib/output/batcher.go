@@ -0,0 +1,222 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message/batch"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// Batcher wraps an output with a batching policy. Incoming transactions are
+// accumulated until the policy triggers, at which point the parts of each
+// pending transaction are sent to the child output as a single batch. The
+// response from the child output is then propagated back to every transaction
+// that contributed to the batch.
+type Batcher struct {
+	running int32
+
+	stats metrics.Type
+	log   log.Modular
+
+	child   Type
+	batcher *batch.Policy
+
+	messagesIn  <-chan types.Transaction
+	messagesOut chan types.Transaction
+
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+// NewBatcher creates a new output preceded by a batching mechanism that
+// enforces a given batching policy.
+func NewBatcher(
+	batcher *batch.Policy,
+	child Type,
+	log log.Modular,
+	stats metrics.Type,
+) Type {
+	m := Batcher{
+		running:     1,
+		stats:       stats,
+		log:         log,
+		child:       child,
+		batcher:     batcher,
+		messagesOut: make(chan types.Transaction),
+		closeChan:   make(chan struct{}),
+		closedChan:  make(chan struct{}),
+	}
+	return &m
+}
+
+//------------------------------------------------------------------------------
+
+func (m *Batcher) loop() {
+	var (
+		mRunning   = m.stats.GetGauge("batching.running")
+		mCount     = m.stats.GetCounter("batching.count")
+		mSent      = m.stats.GetCounter("batching.sent")
+		mBatchSent = m.stats.GetCounter("batching.batch.sent")
+	)
+
+	defer func() {
+		close(m.messagesOut)
+		m.child.CloseAsync()
+		err := m.child.WaitForClose(time.Second)
+		for ; err != nil; err = m.child.WaitForClose(time.Second) {
+		}
+		mRunning.Decr(1)
+		close(m.closedChan)
+	}()
+	mRunning.Incr(1)
+
+	var nextTimedBatchChan <-chan time.Time
+	resetTimer := func() {
+		nextTimedBatchChan = nil
+		if tNext := m.batcher.UntilNext(); tNext >= 0 {
+			nextTimedBatchChan = time.After(tNext)
+		}
+	}
+	resetTimer()
+
+	resChan := make(chan types.Response)
+	var pendingResChans []chan<- types.Response
+
+	// flushBatch sends the pending batch to the child output and propagates
+	// the response to all pending transactions. Returns false if the output is
+	// closing.
+	flushBatch := func() bool {
+		defer resetTimer()
+
+		sendMsg := m.batcher.Flush()
+		if sendMsg == nil {
+			return true
+		}
+
+		select {
+		case m.messagesOut <- types.NewTransaction(sendMsg, resChan):
+		case <-m.closeChan:
+			return false
+		}
+
+		var res types.Response
+		select {
+		case res = <-resChan:
+		case <-m.closeChan:
+			return false
+		}
+
+		if res.Error() == nil {
+			mSent.Incr(int64(sendMsg.Len()))
+			mBatchSent.Incr(1)
+		}
+
+		for _, c := range pendingResChans {
+			select {
+			case c <- res:
+			case <-m.closeChan:
+				return false
+			}
+		}
+		pendingResChans = nil
+		return true
+	}
+
+	for atomic.LoadInt32(&m.running) == 1 {
+		flush := false
+
+		select {
+		case tran, open := <-m.messagesIn:
+			if !open {
+				// Flush any remaining messages before closing.
+				flushBatch()
+				return
+			}
+			mCount.Incr(1)
+			tran.Payload.Iter(func(_ int, p types.Part) error {
+				if m.batcher.Add(p.Copy()) {
+					flush = true
+				}
+				return nil
+			})
+			pendingResChans = append(pendingResChans, tran.ResponseChan)
+		case <-nextTimedBatchChan:
+			nextTimedBatchChan = nil
+			if m.batcher.Count() > 0 {
+				m.batcher.MarkPeriodFlush()
+			}
+			flush = true
+		case <-m.closeChan:
+			return
+		}
+
+		if flush && !flushBatch() {
+			return
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Connected returns a boolean indicating whether this output is currently
+// connected to its target.
+func (m *Batcher) Connected() bool {
+	return m.child.Connected()
+}
+
+// Consume assigns a messages channel for the output to read.
+func (m *Batcher) Consume(msgs <-chan types.Transaction) error {
+	if m.messagesIn != nil {
+		return types.ErrAlreadyStarted
+	}
+	if err := m.child.Consume(m.messagesOut); err != nil {
+		return err
+	}
+	m.messagesIn = msgs
+	go m.loop()
+	return nil
+}
+
+// CloseAsync shuts down the Batcher and stops processing messages.
+func (m *Batcher) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&m.running, 1, 0) {
+		close(m.closeChan)
+	}
+}
+
+// WaitForClose blocks until the Batcher output has closed down.
+func (m *Batcher) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-m.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
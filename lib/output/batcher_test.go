@@ -0,0 +1,347 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/message/batch"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/response"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func newTestBatcher(t *testing.T, conf batch.PolicyConfig) (*Batcher, *mockOutput, chan types.Transaction) {
+	t.Helper()
+
+	policy, err := batch.NewPolicy(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mOut := &mockOutput{}
+	b := NewBatcher(policy, mOut, log.Noop(), metrics.Noop()).(*Batcher)
+
+	tChan := make(chan types.Transaction)
+	if err = b.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+	return b, mOut, tChan
+}
+
+func sendTestTransaction(t *testing.T, tChan chan<- types.Transaction, content string) <-chan types.Response {
+	t.Helper()
+
+	resChan := make(chan types.Response, 1)
+	select {
+	case tChan <- types.NewTransaction(message.New([][]byte{[]byte(content)}), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	return resChan
+}
+
+func TestBatcherCount(t *testing.T) {
+	conf := batch.NewPolicyConfig()
+	conf.Count = 3
+
+	b, mOut, tChan := newTestBatcher(t, conf)
+
+	resChans := []<-chan types.Response{
+		sendTestTransaction(t, tChan, "foo"),
+		sendTestTransaction(t, tChan, "bar"),
+	}
+
+	select {
+	case <-mOut.ts:
+		t.Fatal("Unexpected batch before count reached")
+	case <-time.After(time.Millisecond * 50):
+	}
+	for _, c := range resChans {
+		select {
+		case <-c:
+			t.Fatal("Unexpected response before batch written")
+		default:
+		}
+	}
+
+	resChans = append(resChans, sendTestTransaction(t, tChan, "baz"))
+
+	var tran types.Transaction
+	select {
+	case tran = <-mOut.ts:
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	exp := [][]byte{[]byte("foo"), []byte("bar"), []byte("baz")}
+	if act := message.GetAllBytes(tran.Payload); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong batch: %s != %s", act, exp)
+	}
+
+	select {
+	case tran.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	for i, c := range resChans {
+		select {
+		case res := <-c:
+			if err := res.Error(); err != nil {
+				t.Errorf("Unexpected error for transaction %v: %v", i, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for transaction %v", i)
+		}
+	}
+
+	b.CloseAsync()
+	if err := b.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBatcherErrorPropagated(t *testing.T) {
+	conf := batch.NewPolicyConfig()
+	conf.Count = 2
+
+	b, mOut, tChan := newTestBatcher(t, conf)
+
+	resChans := []<-chan types.Response{
+		sendTestTransaction(t, tChan, "foo"),
+		sendTestTransaction(t, tChan, "bar"),
+	}
+
+	var tran types.Transaction
+	select {
+	case tran = <-mOut.ts:
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	errTest := errors.New("test error")
+	select {
+	case tran.ResponseChan <- response.NewError(errTest):
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	for i, c := range resChans {
+		select {
+		case res := <-c:
+			if exp, act := errTest, res.Error(); exp != act {
+				t.Errorf("Wrong error for transaction %v: %v != %v", i, act, exp)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for transaction %v", i)
+		}
+	}
+
+	b.CloseAsync()
+	if err := b.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBatcherPeriod(t *testing.T) {
+	conf := batch.NewPolicyConfig()
+	conf.Count = 10
+	conf.Period = "50ms"
+
+	b, mOut, tChan := newTestBatcher(t, conf)
+
+	resChan := sendTestTransaction(t, tChan, "foo")
+
+	var tran types.Transaction
+	select {
+	case tran = <-mOut.ts:
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	exp := [][]byte{[]byte("foo")}
+	if act := message.GetAllBytes(tran.Payload); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong batch: %s != %s", act, exp)
+	}
+
+	select {
+	case tran.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	select {
+	case res := <-resChan:
+		if err := res.Error(); err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	b.CloseAsync()
+	if err := b.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBatcherFlushOnClose(t *testing.T) {
+	conf := batch.NewPolicyConfig()
+	conf.Count = 10
+
+	b, mOut, tChan := newTestBatcher(t, conf)
+
+	resChan := sendTestTransaction(t, tChan, "foo")
+	close(tChan)
+
+	var tran types.Transaction
+	select {
+	case tran = <-mOut.ts:
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	exp := [][]byte{[]byte("foo")}
+	if act := message.GetAllBytes(tran.Payload); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong batch: %s != %s", act, exp)
+	}
+
+	select {
+	case tran.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	select {
+	case res := <-resChan:
+		if err := res.Error(); err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	if err := b.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBatcherConfigErrs(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeSTDOUT
+	conf.Batching.Period = "not a duration"
+
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad batching period")
+	}
+
+	conf = NewConfig()
+	conf.Type = TypeBroker
+	conf.Batching.Count = 10
+
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from batching on broker")
+	}
+}
+
+func TestBatcherNotConfigured(t *testing.T) {
+	for _, count := range []int{0, 1} {
+		conf := NewConfig()
+		conf.Type = TypeSTDOUT
+		conf.Batching.Count = count
+
+		out, err := New(conf, nil, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := out.(*Batcher); ok {
+			t.Errorf("Expected output with count %v to not be batched", count)
+		}
+		out.CloseAsync()
+		if err = out.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestBatcherSingleInFlightNoPeriod(t *testing.T) {
+	conf := batch.NewPolicyConfig()
+	conf.Count = 2
+
+	mOut := &mockOutput{}
+	out, err := wrapWithBatching(conf, mOut, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tChan := make(chan types.Transaction)
+	if err = out.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	// Emulate an input that waits for each message to be acknowledged before
+	// sending the next, which would never fill a batch of two.
+	for _, content := range []string{"foo", "bar"} {
+		resChan := sendTestTransaction(t, tChan, content)
+
+		var tran types.Transaction
+		select {
+		case tran = <-mOut.ts:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("timed out waiting for batch of %v", content)
+		}
+
+		exp := [][]byte{[]byte(content)}
+		if act := message.GetAllBytes(tran.Payload); !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong batch: %s != %s", act, exp)
+		}
+
+		select {
+		case tran.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+
+		select {
+		case res := <-resChan:
+			if err = res.Error(); err != nil {
+				t.Error(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for response of %v", content)
+		}
+	}
+
+	out.CloseAsync()
+	if err = out.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -28,6 +28,7 @@ import (
 	"strings"
 
 	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message/batch"
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/output/writer"
 	"github.com/Jeffail/benthos/lib/pipeline"
@@ -56,6 +57,11 @@ type TypeSpec struct {
 // Constructors is a map of all output types with their specs.
 var Constructors = map[string]TypeSpec{}
 
+// Description returns a markdown formatted description of the output type.
+func (t TypeSpec) Description() string {
+	return t.description
+}
+
 //------------------------------------------------------------------------------
 
 // String constants representing each output type.
@@ -85,9 +91,11 @@ const (
 	TypeRedisStreams  = "redis_streams"
 	TypeRetry         = "retry"
 	TypeS3            = "s3"
+	TypeSQL           = "sql"
 	TypeSQS           = "sqs"
 	TypeSTDOUT        = "stdout"
 	TypeSwitch        = "switch"
+	TypeSyncResponse  = "sync_response"
 	TypeWebsocket     = "websocket"
 	TypeZMQ4          = "zmq4"
 )
@@ -123,11 +131,14 @@ type Config struct {
 	RedisStreams  writer.RedisStreamsConfig  `json:"redis_streams" yaml:"redis_streams"`
 	Retry         RetryConfig                `json:"retry" yaml:"retry"`
 	S3            writer.AmazonS3Config      `json:"s3" yaml:"s3"`
+	SQL           writer.SQLConfig           `json:"sql" yaml:"sql"`
 	SQS           writer.AmazonSQSConfig     `json:"sqs" yaml:"sqs"`
 	STDOUT        STDOUTConfig               `json:"stdout" yaml:"stdout"`
 	Switch        SwitchConfig               `json:"switch" yaml:"switch"`
+	SyncResponse  writer.SyncResponseConfig  `json:"sync_response" yaml:"sync_response"`
 	Websocket     writer.WebsocketConfig     `json:"websocket" yaml:"websocket"`
 	ZMQ4          *writer.ZMQ4Config         `json:"zmq4,omitempty" yaml:"zmq4,omitempty"`
+	Batching      batch.PolicyConfig         `json:"batching" yaml:"batching"`
 	Processors    []processor.Config         `json:"processors" yaml:"processors"`
 }
 
@@ -161,11 +172,14 @@ func NewConfig() Config {
 		RedisStreams:  writer.NewRedisStreamsConfig(),
 		Retry:         NewRetryConfig(),
 		S3:            writer.NewAmazonS3Config(),
+		SQL:           writer.NewSQLConfig(),
 		SQS:           writer.NewAmazonSQSConfig(),
 		STDOUT:        NewSTDOUTConfig(),
 		Switch:        NewSwitchConfig(),
+		SyncResponse:  writer.NewSyncResponseConfig(),
 		Websocket:     writer.NewWebsocketConfig(),
 		ZMQ4:          writer.NewZMQ4Config(),
+		Batching:      batch.NewPolicyConfig(),
 		Processors:    []processor.Config{},
 	}
 }
@@ -206,6 +220,10 @@ func SanitiseConfig(conf Config) (interface{}, error) {
 		}
 	}
 
+	if !conf.Batching.IsNoop() {
+		outputMap["batching"] = hashMap["batching"]
+	}
+
 	if len(conf.Processors) == 0 {
 		return outputMap, nil
 	}
@@ -321,6 +339,39 @@ with a [` + "`retry`" + `](#retry) output. Some other outputs, such as the
 [` + "`broker`" + `](#broker), might also retry indefinitely depending on their
 configuration.
 
+### Batching
+
+Any output, with the exception of the ` + "[`broker`](#broker)" + `, can be
+configured with a ` + "`batching`" + ` policy, which accumulates messages
+before they reach the output and writes them as a single batch:
+
+` + "``` yaml" + `
+output:
+  type: foo
+  foo:
+    bar: baz
+  batching:
+    count: 10
+    byte_size: 0
+    period: 1s
+` + "```" + `
+
+A batch is flushed when either the ` + "`count`" + ` of message parts or the
+total ` + "`byte_size`" + ` of the batch is reached, or when the ` + "`period`" + `
+has elapsed since the last flush, whichever happens first. A field with a zero
+value (or an empty ` + "`period`" + `) is ignored, and when all fields are zero
+(or only a ` + "`count`" + ` of one is set) batching is disabled and the output
+is not affected.
+
+The acknowledgement of each message that contributed to a batch is withheld
+until the batch has been written, and the result of that write is propagated to
+all of them. This means batches only grow beyond a single message when messages
+reach the output in parallel, for example from multiple
+` + "[pipeline threads](../pipeline.md)" + ` or brokered inputs, and the
+` + "`period`" + ` field bounds the latency of a partial batch. If a
+` + "`count`" + ` or ` + "`byte_size`" + ` is set without a ` + "`period`" + `
+then a period of ` + "`1s`" + ` is used.
+
 ### Multiplexing Outputs
 
 It is possible to perform
@@ -406,11 +457,14 @@ func New(
 			processors := make([]types.Processor, len(conf.Processors))
 			for j, procConf := range conf.Processors {
 				prefix := fmt.Sprintf("processor.%v", *i)
-				var err error
-				processors[j], err = processor.New(procConf, mgr, log.NewModule("."+prefix), metrics.Namespaced(stats, prefix))
+				proc, err := processor.New(procConf, mgr, log.NewModule("."+prefix), metrics.Namespaced(stats, prefix))
 				if err != nil {
 					return nil, fmt.Errorf("failed to create processor '%v': %v", procConf.Type, err)
 				}
+				if ft, ok := mgr.(types.FailureTagger); ok && ft.TagFailures() {
+					proc = processor.WithFailureTagging(procConf.Type, proc)
+				}
+				processors[j] = proc
 				*i++
 			}
 			return pipeline.NewProcessor(log, stats, processors...), nil
@@ -418,12 +472,20 @@ func New(
 	}
 	if c, ok := Constructors[conf.Type]; ok {
 		if c.brokerConstructor != nil {
+			if !conf.Batching.IsNoop() {
+				return nil, fmt.Errorf("batching is not supported by output '%v', configure it on the child outputs instead", conf.Type)
+			}
 			return c.brokerConstructor(conf, mgr, log, stats, pipelines...)
 		}
 		output, err := c.constructor(conf, mgr, log, stats)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create output '%v': %v", conf.Type, err)
 		}
+		if !conf.Batching.IsNoop() {
+			if output, err = wrapWithBatching(conf.Batching, output, log, stats); err != nil {
+				return nil, err
+			}
+		}
 		return WrapWithPipelines(output, pipelines...)
 	}
 	if c, ok := pluginSpecs[conf.Type]; ok {
@@ -431,9 +493,41 @@ func New(
 		if err != nil {
 			return nil, err
 		}
+		if !conf.Batching.IsNoop() {
+			if output, err = wrapWithBatching(conf.Batching, output, log, stats); err != nil {
+				return nil, err
+			}
+		}
 		return WrapWithPipelines(output, pipelines...)
 	}
 	return nil, types.ErrInvalidOutputType
 }
 
 //------------------------------------------------------------------------------
+
+// defaultBatchingPeriod is the period used by batching policies that set a
+// count or byte size without a period. Acknowledgements are withheld until a
+// batch is flushed, and so without a period an input that waits for each
+// message to be acknowledged before reading the next would never complete a
+// batch. Outputs without a batching policy are never wrapped and are therefore
+// unaffected.
+const defaultBatchingPeriod = "1s"
+
+// wrapWithBatching wraps an output with a batching policy, which must not be a
+// noop.
+func wrapWithBatching(conf batch.PolicyConfig, out Type, log log.Modular, stats metrics.Type) (Type, error) {
+	if len(conf.Period) == 0 {
+		log.Warnf(
+			"Batching policy has no period set, defaulting to %v in order to flush partial batches\n",
+			defaultBatchingPeriod,
+		)
+		conf.Period = defaultBatchingPeriod
+	}
+	policy, err := batch.NewPolicy(conf, log.NewModule(".batching"), metrics.Namespaced(stats, "batching"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create batch policy: %v", err)
+	}
+	return NewBatcher(policy, out, log, stats), nil
+}
+
+//------------------------------------------------------------------------------
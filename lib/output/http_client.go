@@ -51,7 +51,13 @@ interpolations described [here](../config_interpolation.md#functions).
 
 The body of the HTTP request is the raw contents of the message payload. If the
 message has multiple parts the request will be sent according to
-[RFC1341](https://www.w3.org/Protocols/rfc1341/7_2_Multipart.html)`,
+[RFC1341](https://www.w3.org/Protocols/rfc1341/7_2_Multipart.html)
+
+When ` + "`inject_traceparent`" + ` is set to ` + "`true`" + ` each request is sent
+with a [W3C](https://www.w3.org/TR/trace-context/) ` + "`traceparent`" + ` header
+describing the tracing span of the first message part of the request, allowing
+the receiver to continue the trace. This requires a
+[tracer](../tracers/README.md) to be configured.`,
 	}
 }
 
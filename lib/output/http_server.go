@@ -23,11 +23,13 @@ package output
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -52,24 +54,74 @@ specified.
 You can leave the 'address' config field blank in order to use the default
 service, but this will ignore TLS options.
 
-You can receive a single, discrete message on the configured 'path' endpoint, or
+You can receive a single, discrete message on the configured 'path' endpoint,
 receive a constant stream of line delimited messages on the configured
-'stream_path' endpoint.`,
+'stream_path' endpoint, receive a stream of
+[Server-Sent Events](https://developer.mozilla.org/en-US/docs/Web/API/Server-sent_events)
+on the configured 'sse_path' endpoint, or receive messages over a websocket on
+the configured 'ws_path' endpoint.
+
+Server-Sent Events are written with an event per message, where each line of
+each message part is written as a ` + "`data`" + ` field.
+
+### Broadcast
+
+By default each message is delivered to exactly one connected client. When
+` + "`broadcast.enabled`" + ` is set to ` + "`true`" + ` every client
+connected to any of the endpoints instead receives a copy of every message,
+which is useful for serving a live feed to many consumers such as browsers. In
+this mode messages are acknowledged as soon as they are handed to the connected
+clients, and are dropped when no clients are connected. Requests to the 'path'
+endpoint wait for the next message to arrive, up to the configured 'timeout'.
+
+Each client has a buffer of ` + "`broadcast.buffer_size`" + ` messages. When the
+buffer of a slow client is full the ` + "`broadcast.drop_policy`" + `
+determines what happens to the next message for that client:
+
+- ` + "`drop_oldest`" + `: The oldest message in the buffer is dropped.
+- ` + "`drop_newest`" + `: The new message is dropped.
+- ` + "`disconnect`" + `: The client is disconnected.
+
+The number of connected clients, dropped messages and disconnected clients are
+tracked per endpoint with the metrics ` + "`broadcast.<endpoint>.clients`" + `,
+` + "`broadcast.<endpoint>.dropped`" + ` and
+` + "`broadcast.<endpoint>.disconnected`" + `, where ` + "`<endpoint>`" + `
+is one of ` + "`get`, `stream`, `sse` or `ws`" + `.`,
 	}
 }
 
 //------------------------------------------------------------------------------
 
+// HTTPServerBroadcastConfig contains configuration fields for the broadcast
+// mode of the HTTPServer output type.
+type HTTPServerBroadcastConfig struct {
+	Enabled    bool   `json:"enabled" yaml:"enabled"`
+	BufferSize int    `json:"buffer_size" yaml:"buffer_size"`
+	DropPolicy string `json:"drop_policy" yaml:"drop_policy"`
+}
+
+// NewHTTPServerBroadcastConfig creates a new HTTPServerBroadcastConfig with
+// default values.
+func NewHTTPServerBroadcastConfig() HTTPServerBroadcastConfig {
+	return HTTPServerBroadcastConfig{
+		Enabled:    false,
+		BufferSize: 100,
+		DropPolicy: "drop_oldest",
+	}
+}
+
 // HTTPServerConfig contains configuration fields for the HTTPServer output
 // type.
 type HTTPServerConfig struct {
-	Address    string `json:"address" yaml:"address"`
-	Path       string `json:"path" yaml:"path"`
-	StreamPath string `json:"stream_path" yaml:"stream_path"`
-	WSPath     string `json:"ws_path" yaml:"ws_path"`
-	Timeout    string `json:"timeout" yaml:"timeout"`
-	CertFile   string `json:"cert_file" yaml:"cert_file"`
-	KeyFile    string `json:"key_file" yaml:"key_file"`
+	Address    string                    `json:"address" yaml:"address"`
+	Path       string                    `json:"path" yaml:"path"`
+	StreamPath string                    `json:"stream_path" yaml:"stream_path"`
+	SSEPath    string                    `json:"sse_path" yaml:"sse_path"`
+	WSPath     string                    `json:"ws_path" yaml:"ws_path"`
+	Timeout    string                    `json:"timeout" yaml:"timeout"`
+	CertFile   string                    `json:"cert_file" yaml:"cert_file"`
+	KeyFile    string                    `json:"key_file" yaml:"key_file"`
+	Broadcast  HTTPServerBroadcastConfig `json:"broadcast" yaml:"broadcast"`
 }
 
 // NewHTTPServerConfig creates a new HTTPServerConfig with default values.
@@ -78,10 +130,110 @@ func NewHTTPServerConfig() HTTPServerConfig {
 		Address:    "",
 		Path:       "/get",
 		StreamPath: "/get/stream",
+		SSEPath:    "/get/sse",
 		WSPath:     "/get/ws",
 		Timeout:    "5s",
 		CertFile:   "",
 		KeyFile:    "",
+		Broadcast:  NewHTTPServerBroadcastConfig(),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// httpBroadcastClient is a client connected to an HTTPServer output in
+// broadcast mode, with a buffer of messages waiting to be sent to it.
+type httpBroadcastClient struct {
+	msgs         chan types.Message
+	disconnected chan struct{}
+
+	mClients      metrics.StatGauge
+	mDropped      metrics.StatCounter
+	mDisconnected metrics.StatCounter
+}
+
+// httpBroadcaster fans out messages to all connected clients.
+type httpBroadcaster struct {
+	bufferSize int
+	dropPolicy string
+
+	mut     sync.Mutex
+	clients map[*httpBroadcastClient]struct{}
+}
+
+func newHTTPBroadcaster(conf HTTPServerBroadcastConfig) (*httpBroadcaster, error) {
+	switch conf.DropPolicy {
+	case "drop_oldest", "drop_newest", "disconnect":
+	default:
+		return nil, fmt.Errorf("broadcast drop policy not recognised: %v", conf.DropPolicy)
+	}
+	if conf.BufferSize < 1 {
+		return nil, errors.New("broadcast buffer size must be at least 1")
+	}
+	return &httpBroadcaster{
+		bufferSize: conf.BufferSize,
+		dropPolicy: conf.DropPolicy,
+		clients:    map[*httpBroadcastClient]struct{}{},
+	}, nil
+}
+
+// subscribe registers a new client that receives all subsequent messages,
+// tracking it within the metrics of an endpoint.
+func (b *httpBroadcaster) subscribe(stats metrics.Type, endpoint string) *httpBroadcastClient {
+	c := &httpBroadcastClient{
+		msgs:          make(chan types.Message, b.bufferSize),
+		disconnected:  make(chan struct{}),
+		mClients:      stats.GetGauge("broadcast." + endpoint + ".clients"),
+		mDropped:      stats.GetCounter("broadcast." + endpoint + ".dropped"),
+		mDisconnected: stats.GetCounter("broadcast." + endpoint + ".disconnected"),
+	}
+	c.mClients.Incr(1)
+
+	b.mut.Lock()
+	b.clients[c] = struct{}{}
+	b.mut.Unlock()
+	return c
+}
+
+// unsubscribe removes a client, this is safe to call after the client has
+// been disconnected by the drop policy.
+func (b *httpBroadcaster) unsubscribe(c *httpBroadcastClient) {
+	b.mut.Lock()
+	delete(b.clients, c)
+	b.mut.Unlock()
+
+	c.mClients.Decr(1)
+}
+
+// broadcast hands a message to all connected clients without blocking,
+// applying the drop policy to clients with a full buffer.
+func (b *httpBroadcaster) broadcast(msg types.Message) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	for c := range b.clients {
+		select {
+		case c.msgs <- msg:
+			continue
+		default:
+		}
+		switch b.dropPolicy {
+		case "drop_oldest":
+			// The buffer can only be drained concurrently, therefore after
+			// removing a message there is always room for the new one.
+			select {
+			case <-c.msgs:
+			default:
+			}
+			c.msgs <- msg
+			c.mDropped.Incr(1)
+		case "drop_newest":
+			c.mDropped.Incr(1)
+		case "disconnect":
+			delete(b.clients, c)
+			close(c.disconnected)
+			c.mDisconnected.Incr(1)
+		}
 	}
 }
 
@@ -100,6 +252,7 @@ type HTTPServer struct {
 	timeout time.Duration
 
 	transactions <-chan types.Transaction
+	broadcaster  *httpBroadcaster
 
 	closeChan  chan struct{}
 	closedChan chan struct{}
@@ -120,6 +273,7 @@ type HTTPServer struct {
 	mWSCount    metrics.StatCounter
 	mWSSendSucc metrics.StatCounter
 	mWSSendErr  metrics.StatCounter
+	mWSClosed   metrics.StatCounter
 
 	mStrmReqRcvd  metrics.StatCounter
 	mStrmErrCast  metrics.StatCounter
@@ -128,6 +282,14 @@ type HTTPServer struct {
 	mStrmCount    metrics.StatCounter
 	mStrmErrWrite metrics.StatCounter
 	mStrmSndSucc  metrics.StatCounter
+
+	mSSEReqRcvd  metrics.StatCounter
+	mSSEErrCast  metrics.StatCounter
+	mSSEErrWrong metrics.StatCounter
+	mSSEClosed   metrics.StatCounter
+	mSSECount    metrics.StatCounter
+	mSSEErrWrite metrics.StatCounter
+	mSSESndSucc  metrics.StatCounter
 }
 
 // NewHTTPServer creates a new HTTPServer output type.
@@ -164,6 +326,7 @@ func NewHTTPServer(conf Config, mgr types.Manager, log log.Modular, stats metric
 		mWSReqRcvd:     stats.GetCounter("stream.request.received"),
 		mWSSendSucc:    stats.GetCounter("ws.send.success"),
 		mWSSendErr:     stats.GetCounter("ws.send.error"),
+		mWSClosed:      stats.GetCounter("ws.client_closed"),
 		mStrmReqRcvd:   stats.GetCounter("stream.request.received"),
 		mStrmErrCast:   stats.GetCounter("stream.error.cast_flusher"),
 		mStrmErrWrong:  stats.GetCounter("stream.error.wrong_method"),
@@ -171,6 +334,13 @@ func NewHTTPServer(conf Config, mgr types.Manager, log log.Modular, stats metric
 		mStrmCount:     stats.GetCounter("stream.count"),
 		mStrmErrWrite:  stats.GetCounter("stream.error.write"),
 		mStrmSndSucc:   stats.GetCounter("stream.send.success"),
+		mSSEReqRcvd:    stats.GetCounter("sse.request.received"),
+		mSSEErrCast:    stats.GetCounter("sse.error.cast_flusher"),
+		mSSEErrWrong:   stats.GetCounter("sse.error.wrong_method"),
+		mSSEClosed:     stats.GetCounter("sse.client_closed"),
+		mSSECount:      stats.GetCounter("sse.count"),
+		mSSEErrWrite:   stats.GetCounter("sse.error.write"),
+		mSSESndSucc:    stats.GetCounter("sse.send.success"),
 	}
 
 	if tout := conf.HTTPServer.Timeout; len(tout) > 0 {
@@ -180,35 +350,61 @@ func NewHTTPServer(conf Config, mgr types.Manager, log log.Modular, stats metric
 		}
 	}
 
+	getHandler := h.getHandler
+	streamHandler := h.streamHandler
+	sseHandler := h.sseHandler
+	wsHandler := h.wsHandler
+
+	if conf.HTTPServer.Broadcast.Enabled {
+		var err error
+		if h.broadcaster, err = newHTTPBroadcaster(conf.HTTPServer.Broadcast); err != nil {
+			return nil, err
+		}
+		getHandler = h.getBroadcastHandler
+		streamHandler = h.streamBroadcastHandler
+		sseHandler = h.sseBroadcastHandler
+		wsHandler = h.wsBroadcastHandler
+	}
+
 	if mux != nil {
 		if len(h.conf.HTTPServer.Path) > 0 {
-			h.mux.HandleFunc(h.conf.HTTPServer.Path, h.getHandler)
+			h.mux.HandleFunc(h.conf.HTTPServer.Path, getHandler)
 		}
 		if len(h.conf.HTTPServer.StreamPath) > 0 {
-			h.mux.HandleFunc(h.conf.HTTPServer.StreamPath, h.streamHandler)
+			h.mux.HandleFunc(h.conf.HTTPServer.StreamPath, streamHandler)
+		}
+		if len(h.conf.HTTPServer.SSEPath) > 0 {
+			h.mux.HandleFunc(h.conf.HTTPServer.SSEPath, sseHandler)
 		}
 		if len(h.conf.HTTPServer.WSPath) > 0 {
-			h.mux.HandleFunc(h.conf.HTTPServer.WSPath, h.wsHandler)
+			h.mux.HandleFunc(h.conf.HTTPServer.WSPath, wsHandler)
 		}
 	} else {
 		if len(h.conf.HTTPServer.Path) > 0 {
 			mgr.RegisterEndpoint(
 				h.conf.HTTPServer.Path, "Read a single message from Benthos.",
-				h.getHandler,
+				getHandler,
 			)
 		}
 		if len(h.conf.HTTPServer.StreamPath) > 0 {
 			mgr.RegisterEndpoint(
 				h.conf.HTTPServer.StreamPath,
 				"Read a continuous stream of messages from Benthos.",
-				h.streamHandler,
+				streamHandler,
+			)
+		}
+		if len(h.conf.HTTPServer.SSEPath) > 0 {
+			mgr.RegisterEndpoint(
+				h.conf.HTTPServer.SSEPath,
+				"Read a continuous stream of messages from Benthos as Server-Sent Events.",
+				sseHandler,
 			)
 		}
 		if len(h.conf.HTTPServer.WSPath) > 0 {
 			mgr.RegisterEndpoint(
 				h.conf.HTTPServer.WSPath,
 				"Read messages from Benthos via websockets.",
-				h.wsHandler,
+				wsHandler,
 			)
 		}
 	}
@@ -217,6 +413,71 @@ func NewHTTPServer(conf Config, mgr types.Manager, log log.Modular, stats metric
 
 //------------------------------------------------------------------------------
 
+// writeGetResponse writes a message as the body of a response, where messages
+// of multiple parts are written as a multipart body.
+func writeGetResponse(w http.ResponseWriter, msg types.Message) {
+	if msg.Len() > 1 {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+
+		var err error
+		for i := 0; i < msg.Len() && err == nil; i++ {
+			var part io.Writer
+			if part, err = writer.CreatePart(textproto.MIMEHeader{
+				"Content-Type": []string{"application/octet-stream"},
+			}); err == nil {
+				_, err = io.Copy(part, bytes.NewReader(msg.Get(i).Get()))
+			}
+		}
+
+		writer.Close()
+		w.Header().Add("Content-Type", writer.FormDataContentType())
+		w.Write(body.Bytes())
+	} else {
+		w.Header().Add("Content-Type", "application/octet-stream")
+		w.Write(msg.Get(0).Get())
+	}
+}
+
+// streamData returns the line delimited form of a message written to stream
+// clients.
+func streamData(msg types.Message) []byte {
+	if msg.Len() == 1 {
+		return msg.Get(0).Get()
+	}
+	return append(bytes.Join(message.GetAllBytes(msg), []byte("\n")), byte('\n'))
+}
+
+// sseEvent returns a message as a Server-Sent Event, where each line of each
+// message part is written as a data field.
+func sseEvent(msg types.Message) []byte {
+	var buf bytes.Buffer
+	for _, part := range message.GetAllBytes(msg) {
+		for _, line := range bytes.Split(part, []byte("\n")) {
+			buf.WriteString("data: ")
+			buf.Write(bytes.TrimSuffix(line, []byte("\r")))
+			buf.WriteByte('\n')
+		}
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func setSSEHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+}
+
+func (h *HTTPServer) incrSent(parts int) {
+	h.mSendSucc.Incr(1)
+	h.mPartsSendSucc.Incr(int64(parts))
+	h.mSent.Incr(1)
+	h.mPartsSent.Incr(int64(parts))
+}
+
+//------------------------------------------------------------------------------
+
 func (h *HTTPServer) getHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
@@ -236,7 +497,6 @@ func (h *HTTPServer) getHandler(w http.ResponseWriter, r *http.Request) {
 
 	var ts types.Transaction
 	var open bool
-	var err error
 
 	select {
 	case ts, open = <-h.transactions:
@@ -253,31 +513,9 @@ func (h *HTTPServer) getHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if ts.Payload.Len() > 1 {
-		body := &bytes.Buffer{}
-		writer := multipart.NewWriter(body)
-
-		for i := 0; i < ts.Payload.Len() && err == nil; i++ {
-			var part io.Writer
-			if part, err = writer.CreatePart(textproto.MIMEHeader{
-				"Content-Type": []string{"application/octet-stream"},
-			}); err == nil {
-				_, err = io.Copy(part, bytes.NewReader(ts.Payload.Get(i).Get()))
-			}
-		}
-
-		writer.Close()
-		w.Header().Add("Content-Type", writer.FormDataContentType())
-		w.Write(body.Bytes())
-	} else {
-		w.Header().Add("Content-Type", "application/octet-stream")
-		w.Write(ts.Payload.Get(0).Get())
-	}
+	writeGetResponse(w, ts.Payload)
 
-	h.mSendSucc.Incr(1)
-	h.mPartsSendSucc.Incr(int64(ts.Payload.Len()))
-	h.mSent.Incr(1)
-	h.mPartsSent.Incr(int64(ts.Payload.Len()))
+	h.incrSent(ts.Payload.Len())
 	h.mGetSendSucc.Incr(1)
 
 	select {
@@ -323,14 +561,7 @@ func (h *HTTPServer) streamHandler(w http.ResponseWriter, r *http.Request) {
 		h.mStrmCount.Incr(1)
 		h.mCount.Incr(1)
 
-		var data []byte
-		if ts.Payload.Len() == 1 {
-			data = ts.Payload.Get(0).Get()
-		} else {
-			data = append(bytes.Join(message.GetAllBytes(ts.Payload), []byte("\n")), byte('\n'))
-		}
-
-		_, err := w.Write(data)
+		_, err := w.Write(streamData(ts.Payload))
 		select {
 		case ts.ResponseChan <- response.NewError(err):
 		case <-h.closeChan:
@@ -345,10 +576,66 @@ func (h *HTTPServer) streamHandler(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("\n"))
 		flusher.Flush()
 		h.mStrmSndSucc.Incr(1)
-		h.mSendSucc.Incr(1)
-		h.mPartsSendSucc.Incr(int64(ts.Payload.Len()))
-		h.mSent.Incr(1)
-		h.mPartsSent.Incr(int64(ts.Payload.Len()))
+		h.incrSent(ts.Payload.Len())
+	}
+}
+
+func (h *HTTPServer) sseHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	h.mSSEReqRcvd.Incr(1)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		h.mSSEErrCast.Incr(1)
+		h.log.Errorln("Failed to cast response writer to flusher")
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Incorrect method", http.StatusMethodNotAllowed)
+		h.mSSEErrWrong.Incr(1)
+		return
+	}
+
+	setSSEHeaders(w)
+	flusher.Flush()
+
+	for atomic.LoadInt32(&h.running) == 1 {
+		var ts types.Transaction
+		var open bool
+
+		select {
+		case ts, open = <-h.transactions:
+			if !open {
+				go h.CloseAsync()
+				return
+			}
+		case <-r.Context().Done():
+			h.mSSEClosed.Incr(1)
+			return
+		case <-h.closeChan:
+			return
+		}
+		h.mSSECount.Incr(1)
+		h.mCount.Incr(1)
+
+		_, err := w.Write(sseEvent(ts.Payload))
+		select {
+		case ts.ResponseChan <- response.NewError(err):
+		case <-h.closeChan:
+			return
+		}
+
+		if err != nil {
+			h.mSSEErrWrite.Incr(1)
+			return
+		}
+
+		flusher.Flush()
+		h.mSSESndSucc.Incr(1)
+		h.incrSent(ts.Payload.Len())
 	}
 }
 
@@ -416,6 +703,241 @@ func (h *HTTPServer) wsHandler(w http.ResponseWriter, r *http.Request) {
 
 //------------------------------------------------------------------------------
 
+func (h *HTTPServer) getBroadcastHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	h.mGetReqRcvd.Incr(1)
+
+	if atomic.LoadInt32(&h.running) != 1 {
+		http.Error(w, "Server closed", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Incorrect method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	client := h.broadcaster.subscribe(h.stats, "get")
+	defer h.broadcaster.unsubscribe(client)
+
+	var msg types.Message
+	select {
+	case msg = <-client.msgs:
+		h.mGetCount.Incr(1)
+	case <-client.disconnected:
+		http.Error(w, "Client disconnected", http.StatusServiceUnavailable)
+		return
+	case <-time.After(h.timeout):
+		http.Error(w, "Timed out waiting for message", http.StatusRequestTimeout)
+		return
+	case <-r.Context().Done():
+		return
+	case <-h.closeChan:
+		http.Error(w, "Server closed", http.StatusServiceUnavailable)
+		return
+	}
+
+	writeGetResponse(w, msg)
+
+	h.incrSent(msg.Len())
+	h.mGetSendSucc.Incr(1)
+}
+
+func (h *HTTPServer) streamBroadcastHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	h.mStrmReqRcvd.Incr(1)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		h.mStrmErrCast.Incr(1)
+		h.log.Errorln("Failed to cast response writer to flusher")
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Incorrect method", http.StatusMethodNotAllowed)
+		h.mStrmErrWrong.Incr(1)
+		return
+	}
+
+	client := h.broadcaster.subscribe(h.stats, "stream")
+	defer h.broadcaster.unsubscribe(client)
+
+	flusher.Flush()
+
+	for atomic.LoadInt32(&h.running) == 1 {
+		var msg types.Message
+
+		select {
+		case msg = <-client.msgs:
+		case <-client.disconnected:
+			return
+		case <-r.Context().Done():
+			h.mStrmClosed.Incr(1)
+			return
+		case <-h.closeChan:
+			return
+		}
+		h.mStrmCount.Incr(1)
+
+		if _, err := w.Write(streamData(msg)); err != nil {
+			h.mStrmErrWrite.Incr(1)
+			return
+		}
+
+		w.Write([]byte("\n"))
+		flusher.Flush()
+		h.mStrmSndSucc.Incr(1)
+		h.incrSent(msg.Len())
+	}
+}
+
+func (h *HTTPServer) sseBroadcastHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	h.mSSEReqRcvd.Incr(1)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		h.mSSEErrCast.Incr(1)
+		h.log.Errorln("Failed to cast response writer to flusher")
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Incorrect method", http.StatusMethodNotAllowed)
+		h.mSSEErrWrong.Incr(1)
+		return
+	}
+
+	client := h.broadcaster.subscribe(h.stats, "sse")
+	defer h.broadcaster.unsubscribe(client)
+
+	setSSEHeaders(w)
+	flusher.Flush()
+
+	for atomic.LoadInt32(&h.running) == 1 {
+		var msg types.Message
+
+		select {
+		case msg = <-client.msgs:
+		case <-client.disconnected:
+			return
+		case <-r.Context().Done():
+			h.mSSEClosed.Incr(1)
+			return
+		case <-h.closeChan:
+			return
+		}
+		h.mSSECount.Incr(1)
+
+		if _, err := w.Write(sseEvent(msg)); err != nil {
+			h.mSSEErrWrite.Incr(1)
+			return
+		}
+
+		flusher.Flush()
+		h.mSSESndSucc.Incr(1)
+		h.incrSent(msg.Len())
+	}
+}
+
+func (h *HTTPServer) wsBroadcastHandler(w http.ResponseWriter, r *http.Request) {
+	h.mWSReqRcvd.Incr(1)
+
+	var err error
+	defer func() {
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			h.log.Warnf("Websocket request failed: %v\n", err)
+			return
+		}
+	}()
+
+	upgrader := websocket.Upgrader{}
+
+	var ws *websocket.Conn
+	if ws, err = upgrader.Upgrade(w, r, nil); err != nil {
+		return
+	}
+	defer ws.Close()
+
+	client := h.broadcaster.subscribe(h.stats, "ws")
+	defer h.broadcaster.unsubscribe(client)
+
+	// Messages sent by the client are discarded, reading is only required in
+	// order to detect that the client has gone away.
+	readerClosed := make(chan struct{})
+	go func() {
+		defer close(readerClosed)
+		for {
+			if _, _, rerr := ws.NextReader(); rerr != nil {
+				return
+			}
+		}
+	}()
+
+	for atomic.LoadInt32(&h.running) == 1 {
+		var msg types.Message
+
+		select {
+		case msg = <-client.msgs:
+		case <-client.disconnected:
+			return
+		case <-readerClosed:
+			h.mWSClosed.Incr(1)
+			return
+		case <-h.closeChan:
+			return
+		}
+		h.mWSCount.Incr(1)
+
+		for _, part := range message.GetAllBytes(msg) {
+			if werr := ws.WriteMessage(websocket.BinaryMessage, part); werr != nil {
+				h.mWSSendErr.Incr(1)
+				return
+			}
+		}
+		h.mWSSendSucc.Incr(1)
+		h.incrSent(msg.Len())
+	}
+}
+
+// broadcastLoop reads transactions and hands them to all connected clients,
+// acknowledging each message once it has been handed over.
+func (h *HTTPServer) broadcastLoop() {
+	for {
+		var ts types.Transaction
+		var open bool
+
+		select {
+		case ts, open = <-h.transactions:
+			if !open {
+				go h.CloseAsync()
+				return
+			}
+		case <-h.closeChan:
+			return
+		}
+		h.mCount.Incr(1)
+		h.mPartsCount.Incr(int64(ts.Payload.Len()))
+
+		h.broadcaster.broadcast(ts.Payload)
+
+		select {
+		case ts.ResponseChan <- response.NewAck():
+		case <-h.closeChan:
+			return
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
 // Consume assigns a messages channel for the output to read.
 func (h *HTTPServer) Consume(ts <-chan types.Transaction) error {
 	if h.transactions != nil {
@@ -423,6 +945,10 @@ func (h *HTTPServer) Consume(ts <-chan types.Transaction) error {
 	}
 	h.transactions = ts
 
+	if h.broadcaster != nil {
+		go h.broadcastLoop()
+	}
+
 	if h.server != nil {
 		go func() {
 			h.mRunning.Incr(1)
@@ -470,6 +996,7 @@ func (h *HTTPServer) CloseAsync() {
 		if h.server != nil {
 			h.server.Shutdown(context.Background())
 		} else {
+			close(h.closeChan)
 			close(h.closedChan)
 		}
 	}
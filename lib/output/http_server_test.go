@@ -21,6 +21,7 @@
 package output
 
 import (
+	"bufio"
 	"fmt"
 	"net/http"
 	"os"
@@ -163,3 +164,260 @@ func TestHTTPTimeout(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestHTTPSSE(t *testing.T) {
+	conf := NewConfig()
+	conf.HTTPServer.Address = "localhost:1238"
+	conf.HTTPServer.SSEPath = "/testsse"
+
+	h, err := NewHTTPServer(conf, nil, log.New(os.Stdout, logConfig), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	if err = h.Consume(msgChan); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		h.CloseAsync()
+		if err := h.WaitForClose(time.Second * 5); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	<-time.After(time.Millisecond * 100)
+
+	res, err := http.Get("http://localhost:1238/testsse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if exp, act := "text/event-stream", res.Header.Get("Content-Type"); exp != act {
+		t.Errorf("Wrong content type: %v != %v", act, exp)
+	}
+
+	go func() {
+		testMsg := message.New([][]byte{[]byte("foo\nbar"), []byte("baz")})
+		select {
+		case msgChan <- types.NewTransaction(testMsg, resChan):
+		case <-time.After(time.Second):
+			t.Error("Timed out waiting for message")
+			return
+		}
+		select {
+		case resMsg := <-resChan:
+			if resMsg.Error() != nil {
+				t.Error(resMsg.Error())
+			}
+		case <-time.After(time.Second):
+			t.Error("Timed out waiting for response")
+		}
+	}()
+
+	reader := bufio.NewReader(res.Body)
+	for _, exp := range []string{
+		"data: foo\n", "data: bar\n", "data: baz\n", "\n",
+	} {
+		act, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp != act {
+			t.Errorf("Wrong event line: %q != %q", act, exp)
+		}
+	}
+}
+
+func waitForBroadcastClients(t *testing.T, h *HTTPServer, n int) {
+	for i := 0; i < 100; i++ {
+		h.broadcaster.mut.Lock()
+		count := len(h.broadcaster.clients)
+		h.broadcaster.mut.Unlock()
+		if count == n {
+			return
+		}
+		<-time.After(time.Millisecond * 10)
+	}
+	t.Fatalf("Timed out waiting for %v broadcast clients", n)
+}
+
+func TestHTTPBroadcast(t *testing.T) {
+	nTestLoops := 10
+
+	conf := NewConfig()
+	conf.HTTPServer.Address = "localhost:1239"
+	conf.HTTPServer.StreamPath = "/teststream"
+	conf.HTTPServer.SSEPath = "/testsse"
+	conf.HTTPServer.Broadcast.Enabled = true
+
+	o, err := NewHTTPServer(conf, nil, log.New(os.Stdout, logConfig), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := o.(*HTTPServer)
+
+	msgChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	if err = h.Consume(msgChan); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		h.CloseAsync()
+		if err := h.WaitForClose(time.Second * 5); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	<-time.After(time.Millisecond * 100)
+
+	// Messages without connected clients are acknowledged and dropped.
+	select {
+	case msgChan <- types.NewTransaction(message.New([][]byte{[]byte("dropped")}), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for message")
+	}
+	select {
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for response")
+	}
+
+	var readers []*bufio.Reader
+	for _, path := range []string{"/teststream", "/teststream", "/testsse", "/testsse"} {
+		res, err := http.Get("http://localhost:1239" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		readers = append(readers, bufio.NewReader(res.Body))
+	}
+
+	waitForBroadcastClients(t, h, len(readers))
+
+	for i := 0; i < nTestLoops; i++ {
+		testStr := fmt.Sprintf("test%v", i)
+		select {
+		case msgChan <- types.NewTransaction(message.New([][]byte{[]byte(testStr)}), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for message")
+		}
+		select {
+		case res := <-resChan:
+			if res.Error() != nil {
+				t.Error(res.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for response")
+		}
+	}
+
+	for i := 0; i < nTestLoops; i++ {
+		testStr := fmt.Sprintf("test%v", i)
+		for j, reader := range readers[:2] {
+			act, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exp := testStr + "\n"; exp != act {
+				t.Errorf("Wrong stream line from client %v: %q != %q", j, act, exp)
+			}
+		}
+		for j, reader := range readers[2:] {
+			exp := []string{"data: " + testStr + "\n", "\n"}
+			for _, e := range exp {
+				act, err := reader.ReadString('\n')
+				if err != nil {
+					t.Fatal(err)
+				}
+				if e != act {
+					t.Errorf("Wrong event line from client %v: %q != %q", j, act, e)
+				}
+			}
+		}
+	}
+}
+
+func TestHTTPBroadcastDropPolicies(t *testing.T) {
+	msgs := []types.Message{
+		message.New([][]byte{[]byte("first")}),
+		message.New([][]byte{[]byte("second")}),
+		message.New([][]byte{[]byte("third")}),
+	}
+
+	type testCase struct {
+		policy       string
+		exp          []string
+		disconnected bool
+	}
+	tests := []testCase{
+		{policy: "drop_oldest", exp: []string{"second", "third"}},
+		{policy: "drop_newest", exp: []string{"first", "second"}},
+		{policy: "disconnect", exp: []string{"first", "second"}, disconnected: true},
+	}
+
+	for _, test := range tests {
+		conf := NewHTTPServerBroadcastConfig()
+		conf.BufferSize = 2
+		conf.DropPolicy = test.policy
+
+		b, err := newHTTPBroadcaster(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		client := b.subscribe(metrics.DudType{}, "stream")
+		for _, msg := range msgs {
+			b.broadcast(msg)
+		}
+
+		var act []string
+		for len(client.msgs) > 0 {
+			act = append(act, string((<-client.msgs).Get(0).Get()))
+		}
+		if fmt.Sprintf("%s", act) != fmt.Sprintf("%s", test.exp) {
+			t.Errorf("Wrong messages with policy %v: %s != %s", test.policy, act, test.exp)
+		}
+
+		select {
+		case <-client.disconnected:
+			if !test.disconnected {
+				t.Errorf("Client unexpectedly disconnected with policy %v", test.policy)
+			}
+		default:
+			if test.disconnected {
+				t.Errorf("Client not disconnected with policy %v", test.policy)
+			}
+		}
+
+		b.unsubscribe(client)
+		if len(b.clients) != 0 {
+			t.Errorf("Client not removed with policy %v", test.policy)
+		}
+	}
+}
+
+func TestHTTPBroadcastBadConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.HTTPServer.Address = "localhost:1240"
+	conf.HTTPServer.Broadcast.Enabled = true
+	conf.HTTPServer.Broadcast.DropPolicy = "nope"
+
+	if _, err := NewHTTPServer(conf, nil, log.New(os.Stdout, logConfig), metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad drop policy")
+	}
+
+	conf.HTTPServer.Broadcast.DropPolicy = "drop_newest"
+	conf.HTTPServer.Broadcast.BufferSize = 0
+
+	if _, err := NewHTTPServer(conf, nil, log.New(os.Stdout, logConfig), metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad buffer size")
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/output/writer"
 	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/kafka/sasl"
 	"github.com/Jeffail/benthos/lib/util/tls"
 )
 
@@ -55,7 +56,43 @@ value. If the key is empty then a partition is chosen at random. You can
 alternatively force the partitioner to round-robin partitions with the field
 ` + "`round_robin_partitions`" + `.
 
-` + tls.Documentation + ``,
+The field ` + "`max_in_flight`" + ` sets the maximum number of messages that can
+be in flight at the same time, increasing it can improve throughput at the cost
+of message ordering.
+
+### Exactly-Once Delivery
+
+Setting ` + "`idempotent_write`" + ` to ` + "`true`" + ` enables the idempotent
+producer, which prevents retried writes from being duplicated within a
+partition. This requires a ` + "`target_version`" + ` of at least 0.11.0.0 and
+forces acknowledgement from all replicas.
+
+When ` + "`transaction.enabled`" + ` is ` + "`true`" + ` each message batch is
+written within a Kafka transaction. If the messages were consumed with a
+` + "`kafka`" + ` or ` + "`kafka_balanced`" + ` input then the consumer offsets
+found in their metadata are committed within the same transaction, giving
+exactly-once semantics for Kafka to Kafka pipelines. Messages that are filtered
+before reaching this output are committed by the input as usual.
+
+The ` + "`transaction.transactional_id`" + ` must be unique to each running
+instance of the pipeline and stable across restarts, as it is used to fence off
+transactions left open by a previous instance. Consumers of the output topic
+must read with an isolation level of ` + "`read_committed`" + ` in order to
+ignore aborted transactions. The field ` + "`max_in_flight`" + ` must be 1 when
+transactions are enabled.
+
+Transactions do not protect against a rebalance of the consumer group moving
+partitions to a different instance with a different transactional ID while a
+transaction is in progress, which may result in duplicates.
+
+When ` + "`inject_traceparent`" + ` is set to ` + "`true`" + ` each message is sent
+with a [W3C](https://www.w3.org/TR/trace-context/) ` + "`traceparent`" + ` header
+describing its tracing span, allowing consumers to continue the trace. This
+requires a [tracer](../tracers/README.md) to be configured.
+
+` + tls.Documentation + `
+
+` + sasl.Documentation + ``,
 	}
 }
 
@@ -63,12 +100,12 @@ alternatively force the partitioner to round-robin partitions with the field
 
 // NewKafka creates a new Kafka output type.
 func NewKafka(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
-	k, err := writer.NewKafka(conf.Kafka, log, stats)
+	k, err := writer.NewKafkaWithMgr(conf.Kafka, mgr, log, stats)
 	if err != nil {
 		return nil, err
 	}
-	return NewWriter(
-		"kafka", k, log, stats,
+	return NewAsyncWriter(
+		"kafka", conf.Kafka.MaxInFlight, k, log, stats,
 	)
 }
 
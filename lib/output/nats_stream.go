@@ -33,7 +33,11 @@ func init() {
 	Constructors[TypeNATSStream] = TypeSpec{
 		constructor: NewNATSStream,
 		description: `
-Publish to a NATS Stream subject.`,
+Publish to a NATS Stream subject.
+
+The field ` + "`max_in_flight`" + ` sets the maximum number of messages that can
+be in flight at the same time, increasing it can improve throughput at the cost
+of message ordering.`,
 	}
 }
 
@@ -45,7 +49,7 @@ func NewNATSStream(conf Config, mgr types.Manager, log log.Modular, stats metric
 	if err != nil {
 		return nil, err
 	}
-	return NewWriter("nats_stream", w, log, stats)
+	return NewAsyncWriter("nats_stream", conf.NATSStream.MaxInFlight, w, log, stats)
 }
 
 //------------------------------------------------------------------------------
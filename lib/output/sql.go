@@ -0,0 +1,83 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/output/writer"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/sql"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeSQL] = TypeSpec{
+		constructor: NewSQL,
+		description: `
+Executes a parameterised statement against a SQL database for each message
+part. The values of ` + "`args`" + ` are the arguments of the statement, in the
+order of its placeholders, and can be dynamically set using function
+interpolations described [here](../config_interpolation.md#functions), which
+are evaluated per message part.
+
+For example, in order to insert the fields of a JSON document into a table:
+
+` + "``` yaml" + `
+output:
+  type: sql
+  sql:
+    driver: mysql
+    data_source: foouser:foopassword@tcp(localhost:3306)/foodb
+    query: "INSERT INTO footable (foo, bar, baz) VALUES (?, ?, ?);"
+    args:
+    - ${!json_field:document.foo}
+    - ${!json_field:document.bar}
+    - ${!metadata:kafka_topic}
+` + "```" + `
+
+When a batch of multiple message parts is sent the statements are executed
+within a single transaction, and if any of them fail the transaction is rolled
+back and the whole batch is reattempted.
+
+The field ` + "`max_in_flight`" + ` sets the maximum number of messages that can
+be in flight at the same time, increasing it can improve throughput at the cost
+of message ordering.
+
+` + sql.Documentation + ``,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewSQL creates a new SQL output type.
+func NewSQL(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	s, err := writer.NewSQL(conf.SQL, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncWriter(
+		"sql", conf.SQL.MaxInFlight, s, log, stats,
+	)
+}
+
+//------------------------------------------------------------------------------
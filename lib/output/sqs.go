@@ -33,7 +33,11 @@ func init() {
 	Constructors[TypeSQS] = TypeSpec{
 		constructor: NewAmazonSQS,
 		description: `
-Sends messages to an SQS queue.`,
+Sends messages to an SQS queue.
+
+The field ` + "`max_in_flight`" + ` sets the maximum number of messages that can
+be in flight at the same time, increasing it can improve throughput at the cost
+of message ordering.`,
 	}
 }
 
@@ -45,8 +49,8 @@ func NewAmazonSQS(conf Config, mgr types.Manager, log log.Modular, stats metrics
 	if err != nil {
 		return nil, err
 	}
-	return NewWriter(
-		"sqs", s, log, stats,
+	return NewAsyncWriter(
+		"sqs", conf.SQS.MaxInFlight, s, log, stats,
 	)
 }
 
@@ -21,8 +21,10 @@
 package writer
 
 import (
+	"context"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/Jeffail/benthos/lib/log"
@@ -48,6 +50,7 @@ const (
 type AmazonSQSConfig struct {
 	sessionConfig  `json:",inline" yaml:",inline"`
 	URL            string `json:"url" yaml:"url"`
+	MaxInFlight    int    `json:"max_in_flight" yaml:"max_in_flight"`
 	retries.Config `json:",inline" yaml:",inline"`
 }
 
@@ -61,8 +64,9 @@ func NewAmazonSQSConfig() AmazonSQSConfig {
 		sessionConfig: sessionConfig{
 			Config: sess.NewConfig(),
 		},
-		URL:    "",
-		Config: rConf,
+		URL:         "",
+		MaxInFlight: 1,
+		Config:      rConf,
 	}
 }
 
@@ -73,9 +77,10 @@ func NewAmazonSQSConfig() AmazonSQSConfig {
 type AmazonSQS struct {
 	conf AmazonSQSConfig
 
-	backoff backoff.BackOff
-	session *session.Session
-	sqs     *sqs.SQS
+	backoffCtor func() backoff.BackOff
+	session     *session.Session
+	sqs         *sqs.SQS
+	connMut     sync.RWMutex
 
 	log   log.Modular
 	stats metrics.Type
@@ -94,7 +99,7 @@ func NewAmazonSQS(
 	}
 
 	var err error
-	if s.backoff, err = conf.Config.Get(); err != nil {
+	if s.backoffCtor, err = conf.Config.GetCtor(); err != nil {
 		return nil, err
 	}
 	return s, nil
@@ -102,6 +107,15 @@ func NewAmazonSQS(
 
 // Connect attempts to establish a connection to the target SQS queue.
 func (a *AmazonSQS) Connect() error {
+	return a.ConnectWithContext(context.Background())
+}
+
+// ConnectWithContext attempts to establish a connection to the target SQS
+// queue.
+func (a *AmazonSQS) ConnectWithContext(ctx context.Context) error {
+	a.connMut.Lock()
+	defer a.connMut.Unlock()
+
 	if a.session != nil {
 		return nil
 	}
@@ -120,10 +134,22 @@ func (a *AmazonSQS) Connect() error {
 
 // Write attempts to write message contents to a target SQS.
 func (a *AmazonSQS) Write(msg types.Message) error {
-	if a.session == nil {
+	return a.WriteWithContext(context.Background(), msg)
+}
+
+// WriteWithContext attempts to write message contents to a target SQS. It is
+// safe to call this method concurrently.
+func (a *AmazonSQS) WriteWithContext(ctx context.Context, msg types.Message) error {
+	a.connMut.RLock()
+	client := a.sqs
+	a.connMut.RUnlock()
+
+	if client == nil {
 		return types.ErrNotConnected
 	}
 
+	backOff := a.backoffCtor()
+
 	entries := []*sqs.SendMessageBatchRequestEntry{}
 	msg.Iter(func(i int, p types.Part) error {
 		entries = append(entries, &sqs.SendMessageBatchRequestEntry{
@@ -147,10 +173,10 @@ func (a *AmazonSQS) Write(msg types.Message) error {
 
 	var err error
 	for len(input.Entries) > 0 {
-		wait := a.backoff.NextBackOff()
+		wait := backOff.NextBackOff()
 
 		var batchResult *sqs.SendMessageBatchOutput
-		if batchResult, err = a.sqs.SendMessageBatch(input); err != nil {
+		if batchResult, err = client.SendMessageBatchWithContext(ctx, input); err != nil {
 			a.log.Warnf("SQS error: %v\n", err)
 			// bail if a message is too large or all retry attempts expired
 			if wait == backoff.Stop {
@@ -194,10 +220,6 @@ func (a *AmazonSQS) Write(msg types.Message) error {
 			}
 		}
 	}
-
-	if err == nil {
-		a.backoff.Reset()
-	}
 	return err
 }
 
@@ -21,13 +21,16 @@
 package writer
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message/tracing"
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/types"
 	"github.com/Jeffail/benthos/lib/util/text"
@@ -48,14 +51,16 @@ type AMQPExchangeDeclareConfig struct {
 
 // AMQPConfig contains configuration fields for the AMQP output type.
 type AMQPConfig struct {
-	URL             string                    `json:"url" yaml:"url"`
-	Exchange        string                    `json:"exchange" yaml:"exchange"`
-	ExchangeDeclare AMQPExchangeDeclareConfig `json:"exchange_declare" yaml:"exchange_declare"`
-	BindingKey      string                    `json:"key" yaml:"key"`
-	Persistent      bool                      `json:"persistent" yaml:"persistent"`
-	Mandatory       bool                      `json:"mandatory" yaml:"mandatory"`
-	Immediate       bool                      `json:"immediate" yaml:"immediate"`
-	TLS             btls.Config               `json:"tls" yaml:"tls"`
+	URL               string                    `json:"url" yaml:"url"`
+	Exchange          string                    `json:"exchange" yaml:"exchange"`
+	ExchangeDeclare   AMQPExchangeDeclareConfig `json:"exchange_declare" yaml:"exchange_declare"`
+	BindingKey        string                    `json:"key" yaml:"key"`
+	Persistent        bool                      `json:"persistent" yaml:"persistent"`
+	Mandatory         bool                      `json:"mandatory" yaml:"mandatory"`
+	Immediate         bool                      `json:"immediate" yaml:"immediate"`
+	InjectTraceParent bool                      `json:"inject_traceparent" yaml:"inject_traceparent"`
+	TLS               btls.Config               `json:"tls" yaml:"tls"`
+	MaxInFlight       int                       `json:"max_in_flight" yaml:"max_in_flight"`
 }
 
 // NewAMQPConfig creates a new AMQPConfig with default values.
@@ -68,11 +73,13 @@ func NewAMQPConfig() AMQPConfig {
 			Type:    "direct",
 			Durable: true,
 		},
-		BindingKey: "benthos-key",
-		Persistent: false,
-		Mandatory:  false,
-		Immediate:  false,
-		TLS:        btls.NewConfig(),
+		BindingKey:        "benthos-key",
+		Persistent:        false,
+		Mandatory:         false,
+		Immediate:         false,
+		InjectTraceParent: false,
+		TLS:               btls.NewConfig(),
+		MaxInFlight:       1,
 	}
 }
 
@@ -88,10 +95,9 @@ type AMQP struct {
 	conf    AMQPConfig
 	tlsConf *tls.Config
 
-	conn        *amqp.Connection
-	amqpChan    *amqp.Channel
-	confirmChan <-chan amqp.Confirmation
-	returnChan  <-chan amqp.Return
+	conn     *amqp.Connection
+	amqpChan *amqp.Channel
+	confirms *amqpConfirmTracker
 
 	deliveryMode uint8
 
@@ -123,9 +129,18 @@ func NewAMQP(conf AMQPConfig, log log.Modular, stats metrics.Type) (*AMQP, error
 
 // Connect establishes a connection to an AMQP server.
 func (a *AMQP) Connect() error {
+	return a.ConnectWithContext(context.Background())
+}
+
+// ConnectWithContext establishes a connection to an AMQP server.
+func (a *AMQP) ConnectWithContext(ctx context.Context) error {
 	a.connLock.Lock()
 	defer a.connLock.Unlock()
 
+	if a.conn != nil {
+		return nil
+	}
+
 	var conn *amqp.Connection
 	var err error
 
@@ -167,12 +182,17 @@ func (a *AMQP) Connect() error {
 		return fmt.Errorf("amqp channel could not be put into confirm mode: %v", err)
 	}
 
-	a.conn = conn
-	a.amqpChan = amqpChan
-	a.confirmChan = amqpChan.NotifyPublish(make(chan amqp.Confirmation, 1))
+	// The notification channels are unbuffered in order to guarantee that a
+	// return is received before the confirmation of the same message.
+	var returnChan <-chan amqp.Return
 	if a.conf.Mandatory || a.conf.Immediate {
-		a.returnChan = amqpChan.NotifyReturn(make(chan amqp.Return, 1))
+		returnChan = amqpChan.NotifyReturn(make(chan amqp.Return))
 	}
+	confirmChan := amqpChan.NotifyPublish(make(chan amqp.Confirmation))
+
+	a.conn = conn
+	a.amqpChan = amqpChan
+	a.confirms = newAMQPConfirmTracker(confirmChan, returnChan)
 
 	a.log.Infof("Sending AMQP messages to exchange: %v\n", a.conf.Exchange)
 	return nil
@@ -182,9 +202,25 @@ func (a *AMQP) Connect() error {
 func (a *AMQP) disconnect() error {
 	a.connLock.Lock()
 	defer a.connLock.Unlock()
+	return a.disconnectUnlocked()
+}
 
+// disconnectFrom closes a connection to an AMQP server only if it is still the
+// current connection, as it might have already been replaced by a concurrent
+// write.
+func (a *AMQP) disconnectFrom(conn *amqp.Connection) error {
+	a.connLock.Lock()
+	defer a.connLock.Unlock()
+	if a.conn != conn {
+		return nil
+	}
+	return a.disconnectUnlocked()
+}
+
+func (a *AMQP) disconnectUnlocked() error {
 	if a.amqpChan != nil {
 		a.amqpChan = nil
+		a.confirms = nil
 	}
 	if a.conn != nil {
 		if err := a.conn.Close(); err != nil {
@@ -200,11 +236,17 @@ func (a *AMQP) disconnect() error {
 // Write will attempt to write a message over AMQP, wait for acknowledgement,
 // and returns an error if applicable.
 func (a *AMQP) Write(msg types.Message) error {
+	return a.WriteWithContext(context.Background(), msg)
+}
+
+// WriteWithContext will attempt to write a message over AMQP, wait for
+// acknowledgement, and returns an error if applicable. It is safe to call this
+// method concurrently.
+func (a *AMQP) WriteWithContext(ctx context.Context, msg types.Message) error {
 	a.connLock.RLock()
 	conn := a.conn
 	amqpChan := a.amqpChan
-	confirmChan := a.confirmChan
-	returnChan := a.returnChan
+	confirms := a.confirms
 	a.connLock.RUnlock()
 
 	if conn == nil {
@@ -219,41 +261,50 @@ func (a *AMQP) Write(msg types.Message) error {
 			headers[strings.Replace(k, "_", "-", -1)] = v
 			return nil
 		})
-		err := amqpChan.Publish(
-			a.conf.Exchange,  // publish to an exchange
-			bindingKey,       // routing to 0 or more queues
-			a.conf.Mandatory, // mandatory
-			a.conf.Immediate, // immediate
-			amqp.Publishing{
-				Headers:         headers,
-				ContentType:     "application/octet-stream",
-				ContentEncoding: "",
-				Body:            p.Get(),
-				DeliveryMode:    a.deliveryMode, // 1=non-persistent, 2=persistent
-				Priority:        0,              // 0-9
-				// a bunch of application/implementation-specific fields
-			},
-		)
+		if a.conf.InjectTraceParent {
+			if traceParent := tracing.TraceParent(p); len(traceParent) > 0 {
+				headers[tracing.TraceParentKey] = traceParent
+			}
+		}
+		confirmChan, err := confirms.publish(func(messageID string) error {
+			return amqpChan.Publish(
+				a.conf.Exchange,  // publish to an exchange
+				bindingKey,       // routing to 0 or more queues
+				a.conf.Mandatory, // mandatory
+				a.conf.Immediate, // immediate
+				amqp.Publishing{
+					Headers:         headers,
+					ContentType:     "application/octet-stream",
+					ContentEncoding: "",
+					Body:            p.Get(),
+					DeliveryMode:    a.deliveryMode, // 1=non-persistent, 2=persistent
+					Priority:        0,              // 0-9
+					MessageId:       messageID,
+					// a bunch of application/implementation-specific fields
+				},
+			)
+		})
 		if err != nil {
-			a.disconnect()
+			a.disconnectFrom(conn)
 			a.log.Errorf("Failed to send message: %v\n", err)
 			return types.ErrNotConnected
 		}
 		select {
 		case confirm, open := <-confirmChan:
 			if !open {
+				a.disconnectFrom(conn)
 				a.log.Errorln("Failed to send message, ensure your target exchange exists.")
 				return types.ErrNotConnected
 			}
-			if !confirm.Ack {
-				a.log.Errorln("Failed to acknowledge message.")
+			if confirm.returned {
 				return types.ErrNoAck
 			}
-		case _, open := <-returnChan:
-			if !open {
-				return fmt.Errorf("acknowledgement not supported, ensure server supports immediate and mandatory flags")
+			if !confirm.ack {
+				a.log.Errorln("Failed to acknowledge message.")
+				return types.ErrNoAck
 			}
-			return types.ErrNoAck
+		case <-ctx.Done():
+			return types.ErrTimeout
 		}
 		return nil
 	})
@@ -270,3 +321,108 @@ func (a *AMQP) WaitForClose(timeout time.Duration) error {
 }
 
 //------------------------------------------------------------------------------
+
+// amqpConfirmation is the outcome of publishing a message in confirm mode.
+type amqpConfirmation struct {
+	ack      bool
+	returned bool
+}
+
+// amqpConfirmTracker routes the publisher confirmations of a channel in
+// confirm mode to the writes waiting on them, which allows multiple messages
+// to be in flight on the same channel. Confirmations are matched by delivery
+// tag, and returned messages are matched by a message ID set to the delivery
+// tag of the message.
+type amqpConfirmTracker struct {
+	pubMut  sync.Mutex
+	lastTag uint64
+
+	mut      sync.Mutex
+	pending  map[uint64]chan amqpConfirmation
+	returned map[uint64]struct{}
+	closed   bool
+}
+
+func newAMQPConfirmTracker(confirmChan <-chan amqp.Confirmation, returnChan <-chan amqp.Return) *amqpConfirmTracker {
+	t := &amqpConfirmTracker{
+		pending:  map[uint64]chan amqpConfirmation{},
+		returned: map[uint64]struct{}{},
+	}
+	go t.loop(confirmChan, returnChan)
+	return t
+}
+
+// publish calls a publishing func with the message ID that should be set on
+// the message and returns a channel that receives its confirmation. Calls are
+// serialised so that delivery tags are allocated in the order of publishing.
+func (t *amqpConfirmTracker) publish(fn func(messageID string) error) (<-chan amqpConfirmation, error) {
+	t.pubMut.Lock()
+	defer t.pubMut.Unlock()
+
+	// The confirmation channel is registered before publishing as the
+	// confirmation could arrive before the publish call returns.
+	tag := t.lastTag + 1
+	c := make(chan amqpConfirmation, 1)
+
+	t.mut.Lock()
+	if t.closed {
+		t.mut.Unlock()
+		return nil, types.ErrNotConnected
+	}
+	t.pending[tag] = c
+	t.mut.Unlock()
+
+	if err := fn(strconv.FormatUint(tag, 10)); err != nil {
+		t.mut.Lock()
+		delete(t.pending, tag)
+		t.mut.Unlock()
+		return nil, err
+	}
+	t.lastTag = tag
+	return c, nil
+}
+
+func (t *amqpConfirmTracker) loop(confirmChan <-chan amqp.Confirmation, returnChan <-chan amqp.Return) {
+	defer func() {
+		t.mut.Lock()
+		t.closed = true
+		for _, c := range t.pending {
+			close(c)
+		}
+		t.pending = nil
+		t.mut.Unlock()
+	}()
+
+	for {
+		select {
+		case confirm, open := <-confirmChan:
+			if !open {
+				return
+			}
+			t.mut.Lock()
+			c, exists := t.pending[confirm.DeliveryTag]
+			_, returned := t.returned[confirm.DeliveryTag]
+			delete(t.pending, confirm.DeliveryTag)
+			delete(t.returned, confirm.DeliveryTag)
+			t.mut.Unlock()
+			if exists {
+				c <- amqpConfirmation{
+					ack:      confirm.Ack,
+					returned: returned,
+				}
+			}
+		case ret, open := <-returnChan:
+			if !open {
+				returnChan = nil
+				continue
+			}
+			if tag, err := strconv.ParseUint(ret.MessageId, 10, 64); err == nil {
+				t.mut.Lock()
+				t.returned[tag] = struct{}{}
+				t.mut.Unlock()
+			}
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
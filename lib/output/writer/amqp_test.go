@@ -0,0 +1,99 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/streadway/amqp"
+)
+
+//------------------------------------------------------------------------------
+
+func TestAMQPConfirmTracker(t *testing.T) {
+	confirmChan := make(chan amqp.Confirmation)
+	returnChan := make(chan amqp.Return)
+
+	tracker := newAMQPConfirmTracker(confirmChan, returnChan)
+
+	var ids []string
+	var confirms []<-chan amqpConfirmation
+	for i := 0; i < 3; i++ {
+		c, err := tracker.publish(func(messageID string) error {
+			ids = append(ids, messageID)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		confirms = append(confirms, c)
+	}
+	if exp, act := []string{"1", "2", "3"}, ids; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong message IDs: %v != %v", act, exp)
+	}
+
+	// Confirmations arriving out of order reach the right publish.
+	confirmChan <- amqp.Confirmation{DeliveryTag: 3, Ack: true}
+	confirmChan <- amqp.Confirmation{DeliveryTag: 1, Ack: false}
+	returnChan <- amqp.Return{MessageId: "2"}
+	confirmChan <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
+
+	exp := []amqpConfirmation{
+		{ack: false},
+		{ack: true, returned: true},
+		{ack: true},
+	}
+	for i, c := range confirms {
+		select {
+		case act := <-c:
+			if act != exp[i] {
+				t.Errorf("Wrong confirmation %v: %+v != %+v", i, act, exp[i])
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for confirmation %v", i)
+		}
+	}
+
+	// Pending publishes are released when the channel closes.
+	c, err := tracker.publish(func(string) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(confirmChan)
+
+	select {
+	case _, open := <-c:
+		if open {
+			t.Error("Expected confirmation channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	if _, err = tracker.publish(func(string) error { return nil }); err != types.ErrNotConnected {
+		t.Errorf("Wrong error after close: %v != %v", err, types.ErrNotConnected)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -21,6 +21,8 @@
 package writer
 
 import (
+	"context"
+
 	"github.com/Jeffail/benthos/lib/types"
 )
 
@@ -40,3 +42,22 @@ type Type interface {
 
 	types.Closable
 }
+
+// AsyncType is a type that writes Benthos messages to a third party sink and
+// supports multiple writes being in flight at the same time. If the protocol
+// supports a form of acknowledgement then it will be returned by the call to
+// WriteWithContext.
+type AsyncType interface {
+	// ConnectWithContext attempts to establish a connection to the sink, if
+	// unsuccessful returns an error. If the attempt is successful (or not
+	// necessary) returns nil. This may be called concurrently with calls to
+	// WriteWithContext and must therefore be a noop when already connected.
+	ConnectWithContext(ctx context.Context) error
+
+	// WriteWithContext should block until either the message is sent (and
+	// acknowledged) to a sink, or a transport specific error has occurred, or
+	// the Type is closed. This method must be safe to call concurrently.
+	WriteWithContext(ctx context.Context, msg types.Message) error
+
+	types.Closable
+}
@@ -21,7 +21,9 @@
 package writer
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -29,8 +31,10 @@ import (
 
 	"github.com/Jeffail/benthos/lib/log"
 	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/message/tracing"
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/kafka/sasl"
 	"github.com/Jeffail/benthos/lib/util/text"
 	btls "github.com/Jeffail/benthos/lib/util/tls"
 	"github.com/Shopify/sarama"
@@ -38,19 +42,42 @@ import (
 
 //------------------------------------------------------------------------------
 
+// KafkaTransactionConfig contains configuration fields for writing messages
+// to Kafka within transactions.
+type KafkaTransactionConfig struct {
+	Enabled         bool   `json:"enabled" yaml:"enabled"`
+	TransactionalID string `json:"transactional_id" yaml:"transactional_id"`
+	Timeout         string `json:"timeout" yaml:"timeout"`
+}
+
+// NewKafkaTransactionConfig creates a new KafkaTransactionConfig with default
+// values.
+func NewKafkaTransactionConfig() KafkaTransactionConfig {
+	return KafkaTransactionConfig{
+		Enabled:         false,
+		TransactionalID: "",
+		Timeout:         "60s",
+	}
+}
+
 // KafkaConfig contains configuration fields for the Kafka output type.
 type KafkaConfig struct {
-	Addresses            []string    `json:"addresses" yaml:"addresses"`
-	ClientID             string      `json:"client_id" yaml:"client_id"`
-	Key                  string      `json:"key" yaml:"key"`
-	RoundRobinPartitions bool        `json:"round_robin_partitions" yaml:"round_robin_partitions"`
-	Topic                string      `json:"topic" yaml:"topic"`
-	Compression          string      `json:"compression" yaml:"compression"`
-	MaxMsgBytes          int         `json:"max_msg_bytes" yaml:"max_msg_bytes"`
-	Timeout              string      `json:"timeout" yaml:"timeout"`
-	AckReplicas          bool        `json:"ack_replicas" yaml:"ack_replicas"`
-	TargetVersion        string      `json:"target_version" yaml:"target_version"`
-	TLS                  btls.Config `json:"tls" yaml:"tls"`
+	Addresses            []string               `json:"addresses" yaml:"addresses"`
+	ClientID             string                 `json:"client_id" yaml:"client_id"`
+	Key                  string                 `json:"key" yaml:"key"`
+	RoundRobinPartitions bool                   `json:"round_robin_partitions" yaml:"round_robin_partitions"`
+	Topic                string                 `json:"topic" yaml:"topic"`
+	Compression          string                 `json:"compression" yaml:"compression"`
+	MaxMsgBytes          int                    `json:"max_msg_bytes" yaml:"max_msg_bytes"`
+	Timeout              string                 `json:"timeout" yaml:"timeout"`
+	AckReplicas          bool                   `json:"ack_replicas" yaml:"ack_replicas"`
+	IdempotentWrite      bool                   `json:"idempotent_write" yaml:"idempotent_write"`
+	Transaction          KafkaTransactionConfig `json:"transaction" yaml:"transaction"`
+	InjectTraceParent    bool                   `json:"inject_traceparent" yaml:"inject_traceparent"`
+	TargetVersion        string                 `json:"target_version" yaml:"target_version"`
+	TLS                  btls.Config            `json:"tls" yaml:"tls"`
+	SASL                 sasl.Config            `json:"sasl" yaml:"sasl"`
+	MaxInFlight          int                    `json:"max_in_flight" yaml:"max_in_flight"`
 }
 
 // NewKafkaConfig creates a new KafkaConfig with default values.
@@ -65,8 +92,13 @@ func NewKafkaConfig() KafkaConfig {
 		MaxMsgBytes:          1000000,
 		Timeout:              "5s",
 		AckReplicas:          false,
+		IdempotentWrite:      false,
+		Transaction:          NewKafkaTransactionConfig(),
+		InjectTraceParent:    false,
 		TargetVersion:        sarama.V1_0_0_0.String(),
 		TLS:                  btls.NewConfig(),
+		SASL:                 sasl.NewConfig(),
+		MaxInFlight:          1,
 	}
 }
 
@@ -75,10 +107,12 @@ func NewKafkaConfig() KafkaConfig {
 // Kafka is a writer type that writes messages into kafka.
 type Kafka struct {
 	log   log.Modular
+	mgr   types.Manager
 	stats metrics.Type
 
-	tlsConf *tls.Config
-	timeout time.Duration
+	tlsConf    *tls.Config
+	timeout    time.Duration
+	txnTimeout time.Duration
 
 	addresses []string
 	version   sarama.KafkaVersion
@@ -90,6 +124,7 @@ type Kafka struct {
 	topic *text.InterpolatedString
 
 	producer    sarama.SyncProducer
+	txnProducer *kafkaTxnProducer
 	compression sarama.CompressionCodec
 
 	connMut sync.RWMutex
@@ -97,6 +132,12 @@ type Kafka struct {
 
 // NewKafka creates a new Kafka writer type.
 func NewKafka(conf KafkaConfig, log log.Modular, stats metrics.Type) (*Kafka, error) {
+	return NewKafkaWithMgr(conf, types.NoopMgr(), log, stats)
+}
+
+// NewKafkaWithMgr creates a new Kafka writer type with a manager, which is used
+// for obtaining the resources referenced by its config.
+func NewKafkaWithMgr(conf KafkaConfig, mgr types.Manager, log log.Modular, stats metrics.Type) (*Kafka, error) {
 	compression, err := strToCompressionCodec(conf.Compression)
 	if err != nil {
 		return nil, err
@@ -104,6 +145,7 @@ func NewKafka(conf KafkaConfig, log log.Modular, stats metrics.Type) (*Kafka, er
 
 	k := Kafka{
 		log:   log,
+		mgr:   mgr,
 		stats: stats,
 
 		conf:        conf,
@@ -130,6 +172,25 @@ func NewKafka(conf KafkaConfig, log log.Modular, stats metrics.Type) (*Kafka, er
 		return nil, err
 	}
 
+	if conf.IdempotentWrite || conf.Transaction.Enabled {
+		if !k.version.IsAtLeast(sarama.V0_11_0_0) {
+			return nil, fmt.Errorf("idempotent and transactional writes require a target_version of at least %v", sarama.V0_11_0_0)
+		}
+	}
+	if conf.Transaction.Enabled {
+		if len(conf.Transaction.TransactionalID) == 0 {
+			return nil, errors.New("a transactional_id must be provided when transactions are enabled")
+		}
+		if conf.MaxInFlight > 1 {
+			return nil, errors.New("max_in_flight must be 1 when transactions are enabled")
+		}
+		if tout := conf.Transaction.Timeout; len(tout) > 0 {
+			if k.txnTimeout, err = time.ParseDuration(tout); err != nil {
+				return nil, fmt.Errorf("failed to parse transaction timeout string: %v", err)
+			}
+		}
+	}
+
 	for _, addr := range conf.Addresses {
 		for _, splitAddr := range strings.Split(addr, ",") {
 			if trimmed := strings.TrimSpace(splitAddr); len(trimmed) > 0 {
@@ -159,16 +220,29 @@ func strToCompressionCodec(str string) (sarama.CompressionCodec, error) {
 
 //------------------------------------------------------------------------------
 
-func buildHeaders(part types.Part) []sarama.RecordHeader {
+func buildHeaders(part types.Part, injectTraceParent bool) []sarama.RecordHeader {
 	out := []sarama.RecordHeader{}
+	traceParent := ""
+	if injectTraceParent {
+		traceParent = tracing.TraceParent(part)
+	}
 	meta := part.Metadata()
 	meta.Iter(func(k, v string) error {
+		if len(traceParent) > 0 && k == tracing.TraceParentKey {
+			return nil
+		}
 		out = append(out, sarama.RecordHeader{
 			Key:   []byte(k),
 			Value: []byte(v),
 		})
 		return nil
 	})
+	if len(traceParent) > 0 {
+		out = append(out, sarama.RecordHeader{
+			Key:   []byte(tracing.TraceParentKey),
+			Value: []byte(traceParent),
+		})
+	}
 
 	return out
 }
@@ -177,10 +251,15 @@ func buildHeaders(part types.Part) []sarama.RecordHeader {
 
 // Connect attempts to establish a connection to a Kafka broker.
 func (k *Kafka) Connect() error {
+	return k.ConnectWithContext(context.Background())
+}
+
+// ConnectWithContext attempts to establish a connection to a Kafka broker.
+func (k *Kafka) ConnectWithContext(ctx context.Context) error {
 	k.connMut.Lock()
 	defer k.connMut.Unlock()
 
-	if k.producer != nil {
+	if k.producer != nil || k.txnProducer != nil {
 		return nil
 	}
 
@@ -198,6 +277,9 @@ func (k *Kafka) Connect() error {
 	if k.conf.TLS.Enabled {
 		config.Net.TLS.Config = k.tlsConf
 	}
+	if err := k.conf.SASL.Apply(k.mgr, config); err != nil {
+		return err
+	}
 
 	if k.conf.RoundRobinPartitions {
 		config.Producer.Partitioner = sarama.NewRoundRobinPartitioner
@@ -209,8 +291,22 @@ func (k *Kafka) Connect() error {
 		config.Producer.RequiredAcks = sarama.WaitForLocal
 	}
 
+	// Idempotent writes require acknowledgement from all replicas and only a
+	// single request in flight per broker in order to guarantee ordering.
+	if k.conf.IdempotentWrite || k.conf.Transaction.Enabled {
+		config.Producer.Idempotent = k.conf.IdempotentWrite
+		config.Producer.RequiredAcks = sarama.WaitForAll
+		config.Net.MaxOpenRequests = 1
+	}
+
 	var err error
-	k.producer, err = sarama.NewSyncProducer(k.addresses, config)
+	if k.conf.Transaction.Enabled {
+		k.txnProducer, err = newKafkaTxnProducer(
+			k.addresses, config, k.conf.Transaction.TransactionalID, k.txnTimeout, k.log,
+		)
+	} else {
+		k.producer, err = sarama.NewSyncProducer(k.addresses, config)
+	}
 
 	if err == nil {
 		k.log.Infof("Sending Kafka messages to addresses: %s\n", k.addresses)
@@ -221,11 +317,19 @@ func (k *Kafka) Connect() error {
 // Write will attempt to write a message to Kafka, wait for acknowledgement, and
 // returns an error if applicable.
 func (k *Kafka) Write(msg types.Message) error {
+	return k.WriteWithContext(context.Background(), msg)
+}
+
+// WriteWithContext will attempt to write a message to Kafka, wait for
+// acknowledgement, and returns an error if applicable. It is safe to call this
+// method concurrently.
+func (k *Kafka) WriteWithContext(ctx context.Context, msg types.Message) error {
 	k.connMut.RLock()
 	producer := k.producer
+	txnProducer := k.txnProducer
 	k.connMut.RUnlock()
 
-	if producer == nil {
+	if producer == nil && txnProducer == nil {
 		return types.ErrNotConnected
 	}
 
@@ -237,7 +341,7 @@ func (k *Kafka) Write(msg types.Message) error {
 		nextMsg := &sarama.ProducerMessage{
 			Topic:   k.topic.Get(lMsg),
 			Value:   sarama.ByteEncoder(p.Get()),
-			Headers: buildHeaders(p),
+			Headers: buildHeaders(p, k.conf.InjectTraceParent),
 		}
 		if len(key) > 0 {
 			nextMsg.Key = sarama.ByteEncoder(key)
@@ -246,6 +350,14 @@ func (k *Kafka) Write(msg types.Message) error {
 		return nil
 	})
 
+	if txnProducer != nil {
+		offsets, err := kafkaOffsetsFromMessage(msg)
+		if err != nil {
+			return err
+		}
+		return txnProducer.sendMessages(msgs, offsets)
+	}
+
 	err := producer.SendMessages(msgs)
 	if err != nil {
 		if pErr, ok := err.(sarama.ProducerErrors); ok && len(pErr) > 0 {
@@ -263,6 +375,10 @@ func (k *Kafka) CloseAsync() {
 		k.producer.Close()
 		k.producer = nil
 	}
+	if nil != k.txnProducer {
+		k.txnProducer.close()
+		k.txnProducer = nil
+	}
 	k.connMut.Unlock()
 }
 
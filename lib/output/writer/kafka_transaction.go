@@ -0,0 +1,533 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Shopify/sarama"
+)
+
+//------------------------------------------------------------------------------
+
+// kafkaGroupOffsets maps consumer groups to the next offsets to be committed
+// for each topic partition they consume.
+type kafkaGroupOffsets map[string]map[string]map[int32]int64
+
+// kafkaOffsetsFromMessage extracts the consumer offsets of a message from the
+// metadata added by the kafka and kafka_balanced inputs. Message parts without
+// a consumer group are ignored.
+func kafkaOffsetsFromMessage(msg types.Message) (kafkaGroupOffsets, error) {
+	offsets := kafkaGroupOffsets{}
+	err := msg.Iter(func(i int, p types.Part) error {
+		meta := p.Metadata()
+		group := meta.Get("kafka_consumer_group")
+		if len(group) == 0 {
+			return nil
+		}
+		topic := meta.Get("kafka_topic")
+		partition, err := strconv.ParseInt(meta.Get("kafka_partition"), 10, 32)
+		if err != nil {
+			return fmt.Errorf("failed to parse kafka_partition metadata: %v", err)
+		}
+		offset, err := strconv.ParseInt(meta.Get("kafka_offset"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse kafka_offset metadata: %v", err)
+		}
+		topics, exists := offsets[group]
+		if !exists {
+			topics = map[string]map[int32]int64{}
+			offsets[group] = topics
+		}
+		partitions, exists := topics[topic]
+		if !exists {
+			partitions = map[int32]int64{}
+			topics[topic] = partitions
+		}
+		// The committed offset is the next offset to be consumed.
+		if next := offset + 1; next > partitions[int32(partition)] {
+			partitions[int32(partition)] = next
+		}
+		return nil
+	})
+	return offsets, err
+}
+
+//------------------------------------------------------------------------------
+
+// kafkaTxnProducer writes batches of messages to Kafka within transactions,
+// optionally committing consumer offsets as part of the same transaction.
+type kafkaTxnProducer struct {
+	client  sarama.Client
+	conf    *sarama.Config
+	txnID   string
+	timeout time.Duration
+	log     log.Modular
+
+	partitioners map[string]sarama.Partitioner
+	coordinators map[string]*sarama.Broker
+
+	producerID    int64
+	producerEpoch int16
+	sequences     map[string]map[int32]int32
+
+	mut sync.Mutex
+}
+
+func newKafkaTxnProducer(
+	addresses []string,
+	conf *sarama.Config,
+	txnID string,
+	timeout time.Duration,
+	log log.Modular,
+) (*kafkaTxnProducer, error) {
+	client, err := sarama.NewClient(addresses, conf)
+	if err != nil {
+		return nil, err
+	}
+	p := &kafkaTxnProducer{
+		client:       client,
+		conf:         conf,
+		txnID:        txnID,
+		timeout:      timeout,
+		log:          log,
+		partitioners: map[string]sarama.Partitioner{},
+		coordinators: map[string]*sarama.Broker{},
+		producerID:   -1,
+	}
+	if err = p.initProducerID(); err != nil {
+		p.close()
+		return nil, err
+	}
+	return p, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (p *kafkaTxnProducer) coordinator(key string, cType sarama.CoordinatorType) (*sarama.Broker, error) {
+	cacheKey := strconv.Itoa(int(cType)) + ":" + key
+	if b, exists := p.coordinators[cacheKey]; exists {
+		return b, nil
+	}
+
+	brokers := p.client.Brokers()
+	if len(brokers) == 0 {
+		return nil, sarama.ErrOutOfBrokers
+	}
+
+	var err error
+	for _, b := range brokers {
+		if err = b.Open(p.conf); err != nil && err != sarama.ErrAlreadyConnected {
+			continue
+		}
+		var res *sarama.FindCoordinatorResponse
+		if res, err = b.FindCoordinator(&sarama.FindCoordinatorRequest{
+			Version:         1,
+			CoordinatorKey:  key,
+			CoordinatorType: cType,
+		}); err != nil {
+			continue
+		}
+		if res.Err != sarama.ErrNoError {
+			err = res.Err
+			continue
+		}
+		coord := res.Coordinator
+		if err = coord.Open(p.conf); err != nil && err != sarama.ErrAlreadyConnected {
+			continue
+		}
+		p.coordinators[cacheKey] = coord
+		return coord, nil
+	}
+	return nil, err
+}
+
+func (p *kafkaTxnProducer) resetCoordinators() {
+	for k, b := range p.coordinators {
+		b.Close()
+		delete(p.coordinators, k)
+	}
+}
+
+func (p *kafkaTxnProducer) initProducerID() error {
+	coord, err := p.coordinator(p.txnID, sarama.CoordinatorTransaction)
+	if err != nil {
+		return err
+	}
+	res, err := coord.InitProducerID(&sarama.InitProducerIDRequest{
+		TransactionalID:    &p.txnID,
+		TransactionTimeout: p.timeout,
+	})
+	if err != nil {
+		p.resetCoordinators()
+		return err
+	}
+	if res.Err != sarama.ErrNoError {
+		p.resetCoordinators()
+		return res.Err
+	}
+	p.producerID = res.ProducerID
+	p.producerEpoch = res.ProducerEpoch
+	p.sequences = map[string]map[int32]int32{}
+	return nil
+}
+
+// retry calls fn until it succeeds, returns an error that cannot be retried,
+// or the configured retries are exhausted.
+func (p *kafkaTxnProducer) retry(fn func() error) error {
+	var err error
+	for i := 0; i <= p.conf.Producer.Retry.Max; i++ {
+		if i > 0 {
+			<-time.After(p.conf.Producer.Retry.Backoff)
+		}
+		switch err = fn(); err {
+		case sarama.ErrConcurrentTransactions,
+			sarama.ErrConsumerCoordinatorNotAvailable,
+			sarama.ErrOffsetsLoadInProgress:
+			continue
+		}
+		return err
+	}
+	return err
+}
+
+//------------------------------------------------------------------------------
+
+func (p *kafkaTxnProducer) partition(msg *sarama.ProducerMessage) error {
+	partitioner, exists := p.partitioners[msg.Topic]
+	if !exists {
+		partitioner = p.conf.Producer.Partitioner(msg.Topic)
+		p.partitioners[msg.Topic] = partitioner
+	}
+
+	var partitions []int32
+	var err error
+	if partitioner.RequiresConsistency() {
+		partitions, err = p.client.Partitions(msg.Topic)
+	} else {
+		partitions, err = p.client.WritablePartitions(msg.Topic)
+	}
+	if err != nil {
+		return err
+	}
+	if len(partitions) == 0 {
+		return sarama.ErrLeaderNotAvailable
+	}
+
+	choice, err := partitioner.Partition(msg, int32(len(partitions)))
+	if err != nil {
+		return err
+	}
+	if choice < 0 || choice >= int32(len(partitions)) {
+		return sarama.ErrInvalidPartition
+	}
+	msg.Partition = partitions[choice]
+	return nil
+}
+
+func (p *kafkaTxnProducer) buildBatches(msgs []*sarama.ProducerMessage) (map[string]map[int32]*sarama.RecordBatch, error) {
+	now := time.Now()
+	batches := map[string]map[int32]*sarama.RecordBatch{}
+	for _, msg := range msgs {
+		if err := p.partition(msg); err != nil {
+			return nil, err
+		}
+
+		var key, value []byte
+		var err error
+		if msg.Key != nil {
+			if key, err = msg.Key.Encode(); err != nil {
+				return nil, err
+			}
+		}
+		if msg.Value != nil {
+			if value, err = msg.Value.Encode(); err != nil {
+				return nil, err
+			}
+		}
+
+		partitions, exists := batches[msg.Topic]
+		if !exists {
+			partitions = map[int32]*sarama.RecordBatch{}
+			batches[msg.Topic] = partitions
+		}
+		batch, exists := partitions[msg.Partition]
+		if !exists {
+			batch = &sarama.RecordBatch{
+				Version:          2,
+				Codec:            p.conf.Producer.Compression,
+				CompressionLevel: p.conf.Producer.CompressionLevel,
+				FirstTimestamp:   now,
+				MaxTimestamp:     now,
+				ProducerID:       p.producerID,
+				ProducerEpoch:    p.producerEpoch,
+				FirstSequence:    p.sequences[msg.Topic][msg.Partition],
+				IsTransactional:  true,
+			}
+			partitions[msg.Partition] = batch
+		}
+
+		record := &sarama.Record{
+			Key:         key,
+			Value:       value,
+			OffsetDelta: int64(len(batch.Records)),
+		}
+		for i := range msg.Headers {
+			record.Headers = append(record.Headers, &msg.Headers[i])
+		}
+		batch.Records = append(batch.Records, record)
+		batch.LastOffsetDelta = int32(len(batch.Records) - 1)
+	}
+	return batches, nil
+}
+
+func (p *kafkaTxnProducer) addPartitions(coord *sarama.Broker, batches map[string]map[int32]*sarama.RecordBatch) error {
+	req := &sarama.AddPartitionsToTxnRequest{
+		TransactionalID: p.txnID,
+		ProducerID:      p.producerID,
+		ProducerEpoch:   p.producerEpoch,
+		TopicPartitions: map[string][]int32{},
+	}
+	for topic, partitions := range batches {
+		for partition := range partitions {
+			req.TopicPartitions[topic] = append(req.TopicPartitions[topic], partition)
+		}
+	}
+	return p.retry(func() error {
+		res, err := coord.AddPartitionsToTxn(req)
+		if err != nil {
+			return err
+		}
+		for _, pErrs := range res.Errors {
+			for _, pErr := range pErrs {
+				if pErr.Err != sarama.ErrNoError {
+					return pErr.Err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (p *kafkaTxnProducer) produce(batches map[string]map[int32]*sarama.RecordBatch) error {
+	leaders := map[int32]*sarama.Broker{}
+	requests := map[int32]*sarama.ProduceRequest{}
+
+	// The topic partitions added to the request of each broker, which are the
+	// blocks expected within its response.
+	sent := map[int32]map[string][]int32{}
+
+	for topic, partitions := range batches {
+		for partition, batch := range partitions {
+			leader, err := p.client.Leader(topic, partition)
+			if err != nil {
+				return err
+			}
+			req, exists := requests[leader.ID()]
+			if !exists {
+				req = &sarama.ProduceRequest{
+					TransactionalID: &p.txnID,
+					RequiredAcks:    sarama.WaitForAll,
+					Timeout:         int32(p.conf.Producer.Timeout / time.Millisecond),
+					Version:         3,
+				}
+				leaders[leader.ID()] = leader
+				requests[leader.ID()] = req
+				sent[leader.ID()] = map[string][]int32{}
+			}
+			req.AddBatch(topic, partition, batch)
+			sent[leader.ID()][topic] = append(sent[leader.ID()][topic], partition)
+		}
+	}
+
+	for id, req := range requests {
+		res, err := leaders[id].Produce(req)
+		if err != nil {
+			return err
+		}
+		if res == nil {
+			return errors.New("received empty produce response")
+		}
+		for topic, partitions := range sent[id] {
+			for _, partition := range partitions {
+				block := res.GetBlock(topic, partition)
+				if block == nil {
+					return sarama.ErrIncompleteResponse
+				}
+				if block.Err != sarama.ErrNoError {
+					return block.Err
+				}
+			}
+		}
+	}
+
+	for topic, partitions := range batches {
+		seqs, exists := p.sequences[topic]
+		if !exists {
+			seqs = map[int32]int32{}
+			p.sequences[topic] = seqs
+		}
+		for partition, batch := range partitions {
+			seqs[partition] += int32(len(batch.Records))
+		}
+	}
+	return nil
+}
+
+func (p *kafkaTxnProducer) commitOffsets(coord *sarama.Broker, offsets kafkaGroupOffsets) error {
+	for group, topics := range offsets {
+		if err := p.retry(func() error {
+			res, err := coord.AddOffsetsToTxn(&sarama.AddOffsetsToTxnRequest{
+				TransactionalID: p.txnID,
+				ProducerID:      p.producerID,
+				ProducerEpoch:   p.producerEpoch,
+				GroupID:         group,
+			})
+			if err != nil {
+				return err
+			}
+			return res.Err
+		}); err != nil {
+			return err
+		}
+
+		groupCoord, err := p.coordinator(group, sarama.CoordinatorGroup)
+		if err != nil {
+			return err
+		}
+		req := &sarama.TxnOffsetCommitRequest{
+			TransactionalID: p.txnID,
+			GroupID:         group,
+			ProducerID:      p.producerID,
+			ProducerEpoch:   p.producerEpoch,
+			Topics:          map[string][]*sarama.PartitionOffsetMetadata{},
+		}
+		for topic, partitions := range topics {
+			for partition, offset := range partitions {
+				req.Topics[topic] = append(req.Topics[topic], &sarama.PartitionOffsetMetadata{
+					Partition: partition,
+					Offset:    offset,
+				})
+			}
+		}
+		if err = p.retry(func() error {
+			res, err := groupCoord.TxnOffsetCommit(req)
+			if err != nil {
+				return err
+			}
+			for _, pErrs := range res.Topics {
+				for _, pErr := range pErrs {
+					if pErr.Err != sarama.ErrNoError {
+						return pErr.Err
+					}
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *kafkaTxnProducer) endTxn(coord *sarama.Broker, commit bool) error {
+	return p.retry(func() error {
+		res, err := coord.EndTxn(&sarama.EndTxnRequest{
+			TransactionalID:   p.txnID,
+			ProducerID:        p.producerID,
+			ProducerEpoch:     p.producerEpoch,
+			TransactionResult: commit,
+		})
+		if err != nil {
+			return err
+		}
+		return res.Err
+	})
+}
+
+func (p *kafkaTxnProducer) transact(msgs []*sarama.ProducerMessage, offsets kafkaGroupOffsets) error {
+	batches, err := p.buildBatches(msgs)
+	if err != nil {
+		return err
+	}
+	coord, err := p.coordinator(p.txnID, sarama.CoordinatorTransaction)
+	if err != nil {
+		return err
+	}
+	if err = p.addPartitions(coord, batches); err != nil {
+		return err
+	}
+	if err = p.produce(batches); err != nil {
+		return err
+	}
+	if err = p.commitOffsets(coord, offsets); err != nil {
+		return err
+	}
+	return p.endTxn(coord, true)
+}
+
+//------------------------------------------------------------------------------
+
+// sendMessages writes a batch of messages and commits the provided consumer
+// offsets within a single transaction. If any step of the transaction fails it
+// is aborted and the producer is reinitialised before the next attempt, which
+// also fences any transaction left open by a previous producer sharing our
+// transactional ID.
+func (p *kafkaTxnProducer) sendMessages(msgs []*sarama.ProducerMessage, offsets kafkaGroupOffsets) error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if p.producerID < 0 {
+		if err := p.initProducerID(); err != nil {
+			return err
+		}
+	}
+
+	err := p.transact(msgs, offsets)
+	if err == nil {
+		return nil
+	}
+
+	if coord, cerr := p.coordinator(p.txnID, sarama.CoordinatorTransaction); cerr == nil {
+		if aerr := p.endTxn(coord, false); aerr != nil {
+			p.log.Debugf("Failed to abort Kafka transaction: %v\n", aerr)
+		}
+	}
+	p.resetCoordinators()
+	p.producerID = -1
+	return err
+}
+
+func (p *kafkaTxnProducer) close() error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	p.resetCoordinators()
+	return p.client.Close()
+}
+
+//------------------------------------------------------------------------------
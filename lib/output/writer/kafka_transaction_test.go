@@ -0,0 +1,244 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Shopify/sarama"
+)
+
+//------------------------------------------------------------------------------
+
+func newKafkaTxnMockBroker(t *testing.T, produceRes sarama.MockResponse) *sarama.MockBroker {
+	t.Helper()
+
+	broker := sarama.NewMockBroker(t, 1)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("foo", 0, broker.BrokerID()),
+		"FindCoordinatorRequest": sarama.NewMockWrapper(&sarama.FindCoordinatorResponse{
+			Version:     1,
+			Coordinator: sarama.NewBroker(broker.Addr()),
+		}),
+		"InitProducerIDRequest": sarama.NewMockWrapper(&sarama.InitProducerIDResponse{
+			ProducerID:    5,
+			ProducerEpoch: 1,
+		}),
+		"AddPartitionsToTxnRequest": sarama.NewMockWrapper(&sarama.AddPartitionsToTxnResponse{
+			Errors: map[string][]*sarama.PartitionError{
+				"foo": {{Partition: 0}},
+			},
+		}),
+		"ProduceRequest":         produceRes,
+		"AddOffsetsToTxnRequest": sarama.NewMockWrapper(&sarama.AddOffsetsToTxnResponse{}),
+		"TxnOffsetCommitRequest": sarama.NewMockWrapper(&sarama.TxnOffsetCommitResponse{}),
+		"EndTxnRequest":          sarama.NewMockWrapper(&sarama.EndTxnResponse{}),
+	})
+	return broker
+}
+
+func newKafkaTxnTestMsg() types.Message {
+	msg := message.New([][]byte{[]byte("hello"), []byte("world")})
+	for i, offset := range []string{"11", "10"} {
+		meta := msg.Get(i).Metadata()
+		meta.Set("kafka_consumer_group", "bar")
+		meta.Set("kafka_topic", "baz")
+		meta.Set("kafka_partition", "0")
+		meta.Set("kafka_offset", offset)
+	}
+	return msg
+}
+
+func newKafkaTxnTestWriter(t *testing.T, broker *sarama.MockBroker) *Kafka {
+	t.Helper()
+
+	conf := NewKafkaConfig()
+	conf.Addresses = []string{broker.Addr()}
+	conf.Topic = "foo"
+	conf.Transaction.Enabled = true
+	conf.Transaction.TransactionalID = "foo_txn"
+
+	k, err := NewKafka(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = k.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func countKafkaRequests(broker *sarama.MockBroker, reqType interface{}) int {
+	count := 0
+	for _, rr := range broker.History() {
+		if reflect.TypeOf(rr.Request) == reflect.TypeOf(reqType) {
+			count++
+		}
+	}
+	return count
+}
+
+//------------------------------------------------------------------------------
+
+func TestKafkaOffsetsFromMessage(t *testing.T) {
+	msg := newKafkaTxnTestMsg()
+	msg.Append(message.NewPart([]byte("no metadata")))
+
+	offsets, err := kafkaOffsetsFromMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := kafkaGroupOffsets{
+		"bar": {"baz": {0: 12}},
+	}
+	if !reflect.DeepEqual(exp, offsets) {
+		t.Errorf("Wrong offsets: %v != %v", offsets, exp)
+	}
+
+	msg.Get(0).Metadata().Set("kafka_offset", "nope")
+	if _, err = kafkaOffsetsFromMessage(msg); err == nil {
+		t.Error("Expected error from bad offset")
+	}
+}
+
+func TestKafkaTransactionBadConfig(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Transaction.Enabled = true
+	if _, err := NewKafka(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing transactional_id")
+	}
+
+	conf.Transaction.TransactionalID = "foo"
+	conf.MaxInFlight = 2
+	if _, err := NewKafka(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from max_in_flight")
+	}
+
+	conf = NewKafkaConfig()
+	conf.IdempotentWrite = true
+	conf.TargetVersion = "0.10.2.0"
+	if _, err := NewKafka(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from target_version")
+	}
+}
+
+func TestKafkaTransactionCommit(t *testing.T) {
+	broker := newKafkaTxnMockBroker(t, sarama.NewMockProduceResponse(t).SetVersion(3))
+	defer broker.Close()
+
+	k := newKafkaTxnTestWriter(t, broker)
+	defer k.CloseAsync()
+
+	if err := k.Write(newKafkaTxnTestMsg()); err != nil {
+		t.Fatal(err)
+	}
+
+	var produced, committed, ended bool
+	for _, rr := range broker.History() {
+		switch req := rr.Request.(type) {
+		case *sarama.ProduceRequest:
+			if req.TransactionalID == nil || *req.TransactionalID != "foo_txn" {
+				t.Errorf("Wrong transactional ID: %v", req.TransactionalID)
+			}
+			produced = true
+		case *sarama.TxnOffsetCommitRequest:
+			if exp, act := "bar", req.GroupID; exp != act {
+				t.Errorf("Wrong group ID: %v != %v", act, exp)
+			}
+			if !produced {
+				t.Error("Offsets committed before messages were produced")
+			}
+			offsets := req.Topics["baz"]
+			if len(offsets) != 1 || offsets[0].Partition != 0 || offsets[0].Offset != 12 {
+				t.Errorf("Wrong offsets committed: %v", offsets)
+			}
+			committed = true
+		case *sarama.EndTxnRequest:
+			if !req.TransactionResult {
+				t.Error("Expected transaction to be committed")
+			}
+			if !committed {
+				t.Error("Transaction ended before offsets were committed")
+			}
+			ended = true
+		}
+	}
+	if !produced || !committed || !ended {
+		t.Errorf("Missing requests: %v, %v, %v", produced, committed, ended)
+	}
+}
+
+func TestKafkaTransactionAbort(t *testing.T) {
+	broker := newKafkaTxnMockBroker(
+		t, sarama.NewMockProduceResponse(t).SetVersion(3).SetError("foo", 0, sarama.ErrNotEnoughReplicas),
+	)
+	defer broker.Close()
+
+	k := newKafkaTxnTestWriter(t, broker)
+	defer k.CloseAsync()
+
+	if err := k.Write(newKafkaTxnTestMsg()); err != sarama.ErrNotEnoughReplicas {
+		t.Errorf("Wrong error returned: %v", err)
+	}
+
+	if exp, act := 0, countKafkaRequests(broker, &sarama.TxnOffsetCommitRequest{}); exp != act {
+		t.Errorf("Wrong count of offset commits: %v != %v", act, exp)
+	}
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*sarama.EndTxnRequest); ok && req.TransactionResult {
+			t.Error("Expected transaction to be aborted")
+		}
+	}
+	if exp, act := 1, countKafkaRequests(broker, &sarama.EndTxnRequest{}); exp != act {
+		t.Errorf("Wrong count of end txn requests: %v != %v", act, exp)
+	}
+
+	if err := k.Write(newKafkaTxnTestMsg()); err != sarama.ErrNotEnoughReplicas {
+		t.Errorf("Wrong error returned: %v", err)
+	}
+	if exp, act := 2, countKafkaRequests(broker, &sarama.InitProducerIDRequest{}); exp != act {
+		t.Errorf("Wrong count of producer ID inits: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaTransactionIncompleteResponse(t *testing.T) {
+	broker := newKafkaTxnMockBroker(t, sarama.NewMockWrapper(&sarama.ProduceResponse{Version: 3}))
+	defer broker.Close()
+
+	k := newKafkaTxnTestWriter(t, broker)
+	defer k.CloseAsync()
+
+	if err := k.Write(newKafkaTxnTestMsg()); err != sarama.ErrIncompleteResponse {
+		t.Errorf("Wrong error returned: %v", err)
+	}
+	if exp, act := 0, countKafkaRequests(broker, &sarama.TxnOffsetCommitRequest{}); exp != act {
+		t.Errorf("Wrong count of offset commits: %v != %v", act, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
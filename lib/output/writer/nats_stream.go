@@ -21,6 +21,7 @@
 package writer
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"strings"
@@ -38,19 +39,21 @@ import (
 // NATSStreamConfig contains configuration fields for the NATSStream output
 // type.
 type NATSStreamConfig struct {
-	URLs      []string `json:"urls" yaml:"urls"`
-	ClusterID string   `json:"cluster_id" yaml:"cluster_id"`
-	ClientID  string   `json:"client_id" yaml:"client_id"`
-	Subject   string   `json:"subject" yaml:"subject"`
+	URLs        []string `json:"urls" yaml:"urls"`
+	ClusterID   string   `json:"cluster_id" yaml:"cluster_id"`
+	ClientID    string   `json:"client_id" yaml:"client_id"`
+	Subject     string   `json:"subject" yaml:"subject"`
+	MaxInFlight int      `json:"max_in_flight" yaml:"max_in_flight"`
 }
 
 // NewNATSStreamConfig creates a new NATSStreamConfig with default values.
 func NewNATSStreamConfig() NATSStreamConfig {
 	return NATSStreamConfig{
-		URLs:      []string{stan.DefaultNatsURL},
-		ClusterID: "test-cluster",
-		ClientID:  "benthos_client",
-		Subject:   "benthos_messages",
+		URLs:        []string{stan.DefaultNatsURL},
+		ClusterID:   "test-cluster",
+		ClientID:    "benthos_client",
+		Subject:     "benthos_messages",
+		MaxInFlight: 1,
 	}
 }
 
@@ -91,6 +94,11 @@ func NewNATSStream(conf NATSStreamConfig, log log.Modular, stats metrics.Type) (
 
 // Connect attempts to establish a connection to NATS servers.
 func (n *NATSStream) Connect() error {
+	return n.ConnectWithContext(context.Background())
+}
+
+// ConnectWithContext attempts to establish a connection to NATS servers.
+func (n *NATSStream) ConnectWithContext(ctx context.Context) error {
 	n.connMut.Lock()
 	defer n.connMut.Unlock()
 
@@ -112,6 +120,12 @@ func (n *NATSStream) Connect() error {
 
 // Write attempts to write a message.
 func (n *NATSStream) Write(msg types.Message) error {
+	return n.WriteWithContext(context.Background(), msg)
+}
+
+// WriteWithContext attempts to write a message. It is safe to call this method
+// concurrently.
+func (n *NATSStream) WriteWithContext(ctx context.Context, msg types.Message) error {
 	n.connMut.RLock()
 	conn := n.natsConn
 	n.connMut.RUnlock()
@@ -125,7 +139,10 @@ func (n *NATSStream) Write(msg types.Message) error {
 		if err == stan.ErrConnectionClosed {
 			conn.Close()
 			n.connMut.Lock()
-			n.natsConn = nil
+			// Another write might have already reconnected.
+			if n.natsConn == conn {
+				n.natsConn = nil
+			}
 			n.connMut.Unlock()
 			return types.ErrNotConnected
 		}
@@ -0,0 +1,176 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	bsql "github.com/Jeffail/benthos/lib/util/sql"
+)
+
+//------------------------------------------------------------------------------
+
+// SQLConfig contains configuration fields for the SQL output type.
+type SQLConfig struct {
+	Driver      string   `json:"driver" yaml:"driver"`
+	DataSource  string   `json:"data_source" yaml:"data_source"`
+	Query       string   `json:"query" yaml:"query"`
+	Args        []string `json:"args" yaml:"args"`
+	MaxInFlight int      `json:"max_in_flight" yaml:"max_in_flight"`
+}
+
+// NewSQLConfig creates a new SQLConfig with default values.
+func NewSQLConfig() SQLConfig {
+	return SQLConfig{
+		Driver:      "mysql",
+		DataSource:  "",
+		Query:       "",
+		Args:        []string{},
+		MaxInFlight: 1,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SQL is a writer that executes a parameterised statement against a SQL
+// database for each message part.
+type SQL struct {
+	conf SQLConfig
+	args bsql.Args
+
+	db    *sql.DB
+	dbMut sync.RWMutex
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewSQL creates a new SQL writer type.
+func NewSQL(conf SQLConfig, log log.Modular, stats metrics.Type) (*SQL, error) {
+	if len(conf.Query) == 0 {
+		return nil, errors.New("a query must be specified")
+	}
+	return &SQL{
+		conf:  conf,
+		args:  bsql.NewArgs(conf.Args),
+		log:   log,
+		stats: stats,
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect establishes a connection to the database.
+func (s *SQL) Connect() error {
+	return s.ConnectWithContext(context.Background())
+}
+
+// ConnectWithContext establishes a connection to the database.
+func (s *SQL) ConnectWithContext(ctx context.Context) error {
+	s.dbMut.Lock()
+	defer s.dbMut.Unlock()
+
+	if s.db != nil {
+		return nil
+	}
+
+	db, err := bsql.Open(s.conf.Driver, s.conf.DataSource)
+	if err != nil {
+		return err
+	}
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to connect to database: %v", err)
+	}
+
+	s.db = db
+	s.log.Infof("Writing messages to %v database\n", s.conf.Driver)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Write attempts to execute the statement for each part of a message.
+func (s *SQL) Write(msg types.Message) error {
+	return s.WriteWithContext(context.Background(), msg)
+}
+
+// WriteWithContext attempts to execute the statement for each part of a
+// message. When a message has multiple parts the statements are executed
+// within a transaction, which is rolled back if any of them fail.
+func (s *SQL) WriteWithContext(ctx context.Context, msg types.Message) error {
+	s.dbMut.RLock()
+	db := s.db
+	s.dbMut.RUnlock()
+
+	if db == nil {
+		return types.ErrNotConnected
+	}
+
+	if msg.Len() == 1 {
+		_, err := db.ExecContext(ctx, s.conf.Query, s.args.Get(msg)...)
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = msg.Iter(func(i int, p types.Part) error {
+		_, eErr := tx.ExecContext(ctx, s.conf.Query, s.args.Get(message.Lock(msg, i))...)
+		return eErr
+	}); err != nil {
+		if rErr := tx.Rollback(); rErr != nil {
+			s.log.Errorf("Failed to roll back transaction: %v\n", rErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+//------------------------------------------------------------------------------
+
+// CloseAsync begins cleaning up resources used by this writer asynchronously.
+func (s *SQL) CloseAsync() {
+	s.dbMut.Lock()
+	if s.db != nil {
+		s.db.Close()
+		s.db = nil
+	}
+	s.dbMut.Unlock()
+}
+
+// WaitForClose will block until either the writer is closed or a specified
+// timeout occurs.
+func (s *SQL) WaitForClose(time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
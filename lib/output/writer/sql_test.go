@@ -0,0 +1,160 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func newTestSQL(t *testing.T, dsn string) (*SQL, sqlmock.Sqlmock, func()) {
+	t.Helper()
+
+	db, mock, err := sqlmock.NewWithDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := NewSQLConfig()
+	conf.Driver = "sqlmock"
+	conf.DataSource = dsn
+	conf.Query = "INSERT INTO footable (foo, bar) VALUES (?, ?);"
+	conf.Args = []string{
+		"${!json_field:foo}",
+		"${!metadata:bar}",
+	}
+
+	s, err := NewSQL(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return s, mock, func() {
+		s.CloseAsync()
+		db.Close()
+	}
+}
+
+func newTestSQLMessage(parts ...[2]string) types.Message {
+	msg := message.New(nil)
+	for _, p := range parts {
+		part := message.NewPart([]byte(`{"foo":"` + p[0] + `"}`))
+		part.Metadata().Set("bar", p[1])
+		msg.Append(part)
+	}
+	return msg
+}
+
+func TestSQLWriteSingle(t *testing.T) {
+	s, mock, done := newTestSQL(t, "sql_write_single")
+	defer done()
+
+	query := regexp.QuoteMeta("INSERT INTO footable (foo, bar) VALUES (?, ?);")
+	mock.ExpectExec(query).WithArgs("foo1", "bar1").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := s.Write(newTestSQLMessage([2]string{"foo1", "bar1"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSQLWriteBatch(t *testing.T) {
+	s, mock, done := newTestSQL(t, "sql_write_batch")
+	defer done()
+
+	query := regexp.QuoteMeta("INSERT INTO footable (foo, bar) VALUES (?, ?);")
+	mock.ExpectBegin()
+	mock.ExpectExec(query).WithArgs("foo1", "bar1").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(query).WithArgs("foo2", "bar2").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	if err := s.Write(newTestSQLMessage(
+		[2]string{"foo1", "bar1"},
+		[2]string{"foo2", "bar2"},
+	)); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSQLWriteBatchRollback(t *testing.T) {
+	s, mock, done := newTestSQL(t, "sql_write_batch_rollback")
+	defer done()
+
+	query := regexp.QuoteMeta("INSERT INTO footable (foo, bar) VALUES (?, ?);")
+	mock.ExpectBegin()
+	mock.ExpectExec(query).WithArgs("foo1", "bar1").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(query).WithArgs("foo2", "bar2").WillReturnError(errors.New("constraint violated"))
+	mock.ExpectRollback()
+
+	if err := s.Write(newTestSQLMessage(
+		[2]string{"foo1", "bar1"},
+		[2]string{"foo2", "bar2"},
+	)); err == nil {
+		t.Error("Expected error from failed statement")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSQLNotConnected(t *testing.T) {
+	conf := NewSQLConfig()
+	conf.Query = "INSERT INTO footable (foo) VALUES (?);"
+
+	s, err := NewSQL(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := types.ErrNotConnected, s.Write(message.New([][]byte{[]byte("foo")})); exp != act {
+		t.Errorf("Wrong error: %v != %v", act, exp)
+	}
+}
+
+func TestSQLBadConfig(t *testing.T) {
+	conf := NewSQLConfig()
+	if _, err := NewSQL(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing query")
+	}
+
+	conf.Driver = "notexist"
+	conf.Query = "INSERT INTO footable (foo) VALUES (?);"
+	s, err := NewSQL(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Connect(); err == nil {
+		t.Error("Expected error from unsupported driver")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message/roundtrip"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// SyncResponseConfig contains configuration fields for the SyncResponse output
+// type.
+type SyncResponseConfig struct{}
+
+// NewSyncResponseConfig creates a new SyncResponseConfig with default values.
+func NewSyncResponseConfig() SyncResponseConfig {
+	return SyncResponseConfig{}
+}
+
+//------------------------------------------------------------------------------
+
+// SyncResponse is a writer implementation that adds messages to a ResultStore
+// located in the context of the first message part of each batch. This is
+// essentially a mechanism that returns the result of a pipeline directly back
+// to the origin of the message.
+type SyncResponse struct {
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewSyncResponse creates a new SyncResponse writer.Type.
+func NewSyncResponse(
+	conf SyncResponseConfig,
+	log log.Modular,
+	stats metrics.Type,
+) *SyncResponse {
+	return &SyncResponse{
+		log:   log,
+		stats: stats,
+	}
+}
+
+// Connect does nothing.
+func (s *SyncResponse) Connect() error {
+	return nil
+}
+
+// Write a message batch to a ResultStore located in the first message of the
+// batch. Messages without a ResultStore originate from inputs that do not
+// support sync responses, and are therefore dropped.
+func (s *SyncResponse) Write(msg types.Message) error {
+	if err := roundtrip.SetAsResponse(msg); err != nil {
+		if err != roundtrip.ErrNoStore {
+			return err
+		}
+		s.log.Debugln("Dropping sync response as the message has no result store")
+	}
+	return nil
+}
+
+// CloseAsync begins cleaning up resources used by this writer asynchronously.
+func (s *SyncResponse) CloseAsync() {
+}
+
+// WaitForClose will block until either the writer is closed or a specified
+// timeout occurs.
+func (s *SyncResponse) WaitForClose(time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -95,11 +95,14 @@ func New(
 		processors := make([]types.Processor, len(conf.Processors)+len(processorCtors))
 		for j, procConf := range conf.Processors {
 			prefix := fmt.Sprintf("processor.%v", *i)
-			var err error
-			processors[j], err = processor.New(procConf, mgr, log.NewModule("."+prefix), metrics.Namespaced(stats, prefix))
+			proc, err := processor.New(procConf, mgr, log.NewModule("."+prefix), metrics.Namespaced(stats, prefix))
 			if err != nil {
 				return nil, fmt.Errorf("failed to create processor '%v': %v", procConf.Type, err)
 			}
+			if ft, ok := mgr.(types.FailureTagger); ok && ft.TagFailures() {
+				proc = processor.WithFailureTagging(procConf.Type, proc)
+			}
+			processors[j] = proc
 			*i++
 		}
 		for j, procCtor := range processorCtors {
@@ -181,3 +181,73 @@ func TestProcCtor(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+type failureTaggingMgr struct {
+	types.Manager
+}
+
+func (f failureTaggingMgr) TagFailures() bool {
+	return true
+}
+
+func TestProcFailureTagging(t *testing.T) {
+	procConf := processor.NewConfig()
+	procConf.Type = "jmespath"
+	procConf.JMESPath.Query = "foo"
+
+	conf := NewConfig()
+	conf.Processors = append(conf.Processors, procConf)
+
+	tests := map[string]struct {
+		mgr types.Manager
+		exp string
+	}{
+		"no tagging": {mgr: types.NoopMgr(), exp: ""},
+		"tagging":    {mgr: failureTaggingMgr{types.NoopMgr()}, exp: "jmespath"},
+	}
+
+	for name, test := range tests {
+		pipe, err := New(
+			conf, test.mgr,
+			log.New(os.Stdout, log.Config{LogLevel: "NONE"}),
+			metrics.DudType{},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tChan := make(chan types.Transaction)
+		resChan := make(chan types.Response)
+		if err = pipe.Consume(tChan); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		case tChan <- types.NewTransaction(
+			message.New([][]byte{[]byte("not json")}), resChan,
+		):
+		}
+
+		var tran types.Transaction
+		select {
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		case tran = <-pipe.TransactionChan():
+		}
+
+		part := tran.Payload.Get(0)
+		if !processor.HasFailed(part) {
+			t.Errorf("%v: Expected part to be flagged as failed", name)
+		}
+		if act := processor.FailedProcessor(part); act != test.exp {
+			t.Errorf("%v: Wrong failed processor: %v != %v", name, act, test.exp)
+		}
+
+		pipe.CloseAsync()
+		if err = pipe.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -41,12 +41,18 @@ func init() {
 Performs operations against a [cache resource](../caches) for each message of a
 batch, allowing you to store or retrieve data within message payloads.
 
-This processor will interpolate functions within the ` + "`key` and `value`" + `
+This processor will interpolate functions within the ` + "`key`, `value` and `ttl`" + `
 fields individually for each message of the batch. This allows you to specify
 dynamic keys and values based on the contents of the message payloads and
 metadata. You can find a list of functions
 [here](../config_interpolation.md#functions).
 
+The field ` + "`ttl`" + ` can be set to a duration such as ` + "`60s`" + ` in order
+to give keys written with the ` + "`set` and `add`" + ` operators their own expiry,
+overriding the default TTL of the cache. This is only supported by the
+` + "`memory`, `lru`, `lfu`, `memcached` and `redis`" + ` caches. When left
+empty the default TTL of the cache is used.
+
 ### Operators
 
 #### ` + "`set`" + `
@@ -120,6 +126,7 @@ type CacheConfig struct {
 	Operator string `json:"operator" yaml:"operator"`
 	Key      string `json:"key" yaml:"key"`
 	Value    string `json:"value" yaml:"value"`
+	TTL      string `json:"ttl" yaml:"ttl"`
 }
 
 // NewCacheConfig returns a CacheConfig with default values.
@@ -130,6 +137,7 @@ func NewCacheConfig() CacheConfig {
 		Operator: "set",
 		Key:      "",
 		Value:    "",
+		TTL:      "",
 	}
 }
 
@@ -146,6 +154,7 @@ type Cache struct {
 
 	key   *text.InterpolatedString
 	value *text.InterpolatedBytes
+	ttl   *text.InterpolatedString
 
 	cache    types.Cache
 	operator cacheOperator
@@ -171,6 +180,14 @@ func NewCache(
 		return nil, err
 	}
 
+	var ttl *text.InterpolatedString
+	if len(conf.Cache.TTL) > 0 {
+		if _, ok := c.(types.CacheWithTTL); !ok {
+			return nil, fmt.Errorf("cache resource '%v' does not support per key TTLs", conf.Cache.Cache)
+		}
+		ttl = text.NewInterpolatedString(conf.Cache.TTL)
+	}
+
 	return &Cache{
 		conf:  conf,
 		log:   log,
@@ -180,6 +197,7 @@ func NewCache(
 
 		key:   text.NewInterpolatedString(conf.Cache.Key),
 		value: text.NewInterpolatedBytes([]byte(conf.Cache.Value)),
+		ttl:   ttl,
 
 		cache:    c,
 		operator: op,
@@ -194,24 +212,34 @@ func NewCache(
 
 //------------------------------------------------------------------------------
 
-type cacheOperator func(key string, value []byte) ([]byte, bool, error)
+type cacheOperator func(key string, value []byte, ttl time.Duration) ([]byte, bool, error)
 
 func newCacheSetOperator(cache types.Cache) cacheOperator {
-	return func(key string, value []byte) ([]byte, bool, error) {
-		err := cache.Set(key, value)
+	return func(key string, value []byte, ttl time.Duration) ([]byte, bool, error) {
+		var err error
+		if ttlCache, ok := cache.(types.CacheWithTTL); ok && ttl > 0 {
+			err = ttlCache.SetWithTTL(key, value, ttl)
+		} else {
+			err = cache.Set(key, value)
+		}
 		return nil, false, err
 	}
 }
 
 func newCacheAddOperator(cache types.Cache) cacheOperator {
-	return func(key string, value []byte) ([]byte, bool, error) {
-		err := cache.Add(key, value)
+	return func(key string, value []byte, ttl time.Duration) ([]byte, bool, error) {
+		var err error
+		if ttlCache, ok := cache.(types.CacheWithTTL); ok && ttl > 0 {
+			err = ttlCache.AddWithTTL(key, value, ttl)
+		} else {
+			err = cache.Add(key, value)
+		}
 		return nil, false, err
 	}
 }
 
 func newCacheGetOperator(cache types.Cache) cacheOperator {
-	return func(key string, _ []byte) ([]byte, bool, error) {
+	return func(key string, _ []byte, _ time.Duration) ([]byte, bool, error) {
 		result, err := cache.Get(key)
 		return result, true, err
 	}
@@ -241,7 +269,18 @@ func (c *Cache) ProcessMessage(msg types.Message) ([]types.Message, types.Respon
 		key := c.key.Get(message.Lock(newMsg, index))
 		value := c.value.Get(message.Lock(newMsg, index))
 
-		result, useResult, err := c.operator(key, value)
+		var ttl time.Duration
+		if c.ttl != nil {
+			var err error
+			ttlStr := c.ttl.Get(message.Lock(newMsg, index))
+			if ttl, err = time.ParseDuration(ttlStr); err != nil {
+				c.mErr.Incr(1)
+				c.log.Debugf("Failed to parse ttl '%s': %v\n", ttlStr, err)
+				return fmt.Errorf("failed to parse ttl: %v", err)
+			}
+		}
+
+		result, useResult, err := c.operator(key, value, ttl)
 		if err != nil {
 			if err != types.ErrKeyAlreadyExists {
 				c.mErr.Incr(1)
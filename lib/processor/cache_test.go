@@ -23,6 +23,7 @@ package processor
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/Jeffail/benthos/lib/cache"
 	"github.com/Jeffail/benthos/lib/log"
@@ -272,3 +273,83 @@ func TestCacheGet(t *testing.T) {
 		t.Errorf("Wrong fail flag: %v != %v", act, exp)
 	}
 }
+
+func TestCacheSetTTL(t *testing.T) {
+	lruConf := cache.NewConfig()
+	lruConf.Type = cache.TypeLRU
+	lruCache, err := cache.NewLRU(lruConf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": lruCache,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Cache.Key = "${!json_field:key}"
+	conf.Cache.Value = "${!json_field:value}"
+	conf.Cache.TTL = "${!json_field:ttl}"
+	conf.Cache.Cache = "foocache"
+	proc, err := NewCache(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := message.New([][]byte{
+		[]byte(`{"key":"1","value":"foo 1","ttl":"1ms"}`),
+		[]byte(`{"key":"2","value":"foo 2","ttl":"1h"}`),
+		[]byte(`{"key":"3","value":"foo 3","ttl":"nope"}`),
+	})
+
+	output, res := proc.ProcessMessage(input)
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if len(output) != 1 {
+		t.Fatalf("Wrong count of result messages: %v", len(output))
+	}
+	if exp, act := false, HasFailed(output[0].Get(1)); exp != act {
+		t.Errorf("Wrong fail flag: %v != %v", act, exp)
+	}
+	if exp, act := true, HasFailed(output[0].Get(2)); exp != act {
+		t.Errorf("Wrong fail flag: %v != %v", act, exp)
+	}
+
+	<-time.After(time.Millisecond * 10)
+
+	if _, err = lruCache.Get("1"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected key to have expired: %v", err)
+	}
+	actBytes, err := lruCache.Get("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "foo 2", string(actBytes); exp != act {
+		t.Errorf("Wrong result: %v != %v", act, exp)
+	}
+	if _, err = lruCache.Get("3"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected key to not be set: %v", err)
+	}
+}
+
+func TestCacheTTLUnsupported(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": struct{ types.Cache }{memCache},
+		},
+	}
+
+	conf := NewConfig()
+	conf.Cache.Key = "foo"
+	conf.Cache.TTL = "60s"
+	conf.Cache.Cache = "foocache"
+	if _, err = NewCache(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from cache without TTL support")
+	}
+}
@@ -51,6 +51,11 @@ type TypeSpec struct {
 // Constructors is a map of all condition types with their specs.
 var Constructors = map[string]TypeSpec{}
 
+// Description returns a markdown formatted description of the condition type.
+func (t TypeSpec) Description() string {
+	return t.description
+}
+
 //------------------------------------------------------------------------------
 
 // String constants representing each condition type.
@@ -62,6 +67,7 @@ var (
 	TypeCheckField      = "check_field"
 	TypeCount           = "count"
 	TypeJMESPath        = "jmespath"
+	TypeJSONSchema      = "json_schema"
 	TypeNot             = "not"
 	TypeMetadata        = "metadata"
 	TypeOr              = "or"
@@ -84,6 +90,7 @@ type Config struct {
 	CheckField      CheckFieldConfig      `json:"check_field" yaml:"check_field"`
 	Count           CountConfig           `json:"count" yaml:"count"`
 	JMESPath        JMESPathConfig        `json:"jmespath" yaml:"jmespath"`
+	JSONSchema      JSONSchemaConfig      `json:"json_schema" yaml:"json_schema"`
 	Not             NotConfig             `json:"not" yaml:"not"`
 	Metadata        MetadataConfig        `json:"metadata" yaml:"metadata"`
 	Or              OrConfig              `json:"or" yaml:"or"`
@@ -104,6 +111,7 @@ func NewConfig() Config {
 		CheckField:      NewCheckFieldConfig(),
 		Count:           NewCountConfig(),
 		JMESPath:        NewJMESPathConfig(),
+		JSONSchema:      NewJSONSchemaConfig(),
 		Not:             NewNotConfig(),
 		Metadata:        NewMetadataConfig(),
 		Or:              NewOrConfig(),
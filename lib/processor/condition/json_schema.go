@@ -0,0 +1,161 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package condition
+
+import (
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/jsonschema"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeJSONSchema] = TypeSpec{
+		constructor: NewJSONSchema,
+		description: `
+Parses a message part as a JSON blob and validates it against a
+[JSON Schema](https://json-schema.org/) definition. If the part is valid the
+condition passes, otherwise it does not.
+
+The schema can either be provided inline with the field ` + "`schema`" + `, or
+loaded from a file with the field ` + "`schema_path`" + `, in which case
+relative ` + "`$ref`" + ` references are resolved from the location of the
+file.
+
+For example, with the following config:
+
+` + "``` yaml" + `
+json_schema:
+  part: 0
+  schema_path: ./schemas/event.json
+` + "```" + `
+
+The condition would pass for any message where part 0 conforms to the schema
+in the file ` + "`./schemas/event.json`" + `.
+
+In order to flag messages that fail a schema with the reasons why, such that
+they can be handled with error handling processors, please instead use the
+` + "[`json_schema`](../processors/README.md#json_schema)" + ` processor.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// JSONSchemaConfig is a configuration struct containing fields for the
+// json_schema condition.
+type JSONSchemaConfig struct {
+	Part       int    `json:"part" yaml:"part"`
+	SchemaPath string `json:"schema_path" yaml:"schema_path"`
+	Schema     string `json:"schema" yaml:"schema"`
+}
+
+// NewJSONSchemaConfig returns a JSONSchemaConfig with default values.
+func NewJSONSchemaConfig() JSONSchemaConfig {
+	return JSONSchemaConfig{
+		Part:       0,
+		SchemaPath: "",
+		Schema:     "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// JSONSchema is a condition that checks a message part against a JSON Schema.
+type JSONSchema struct {
+	stats  metrics.Type
+	log    log.Modular
+	part   int
+	schema *gojsonschema.Schema
+
+	mCount    metrics.StatCounter
+	mTrue     metrics.StatCounter
+	mFalse    metrics.StatCounter
+	mErrJSONP metrics.StatCounter
+	mErr      metrics.StatCounter
+}
+
+// NewJSONSchema returns a JSONSchema condition.
+func NewJSONSchema(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	schema, err := jsonschema.Load(conf.JSONSchema.SchemaPath, conf.JSONSchema.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JSONSchema{
+		stats:  stats,
+		log:    log,
+		part:   conf.JSONSchema.Part,
+		schema: schema,
+
+		mCount:    stats.GetCounter("count"),
+		mTrue:     stats.GetCounter("true"),
+		mFalse:    stats.GetCounter("false"),
+		mErrJSONP: stats.GetCounter("error_json_parse"),
+		mErr:      stats.GetCounter("error"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Check attempts to check a message part against a configured condition.
+func (c *JSONSchema) Check(msg types.Message) bool {
+	c.mCount.Incr(1)
+	index := c.part
+	if index < 0 {
+		index = msg.Len() + index
+	}
+
+	if index < 0 || index >= msg.Len() {
+		c.mFalse.Incr(1)
+		return false
+	}
+
+	jsonPart, err := msg.Get(index).JSON()
+	if err != nil {
+		c.log.Debugf("Failed to parse part into json: %v\n", err)
+		c.mErrJSONP.Incr(1)
+		c.mErr.Incr(1)
+		c.mFalse.Incr(1)
+		return false
+	}
+
+	result, err := c.schema.Validate(gojsonschema.NewGoLoader(jsonPart))
+	if err != nil {
+		c.log.Debugf("Failed to validate json: %v\n", err)
+		c.mErr.Incr(1)
+		c.mFalse.Incr(1)
+		return false
+	}
+
+	if result.Valid() {
+		c.mTrue.Incr(1)
+		return true
+	}
+	c.mFalse.Incr(1)
+	return false
+}
+
+//------------------------------------------------------------------------------
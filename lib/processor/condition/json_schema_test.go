@@ -0,0 +1,133 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package condition
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+)
+
+func TestJSONSchemaCheck(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	testMet := metrics.DudType{}
+
+	schema := `{
+	"type": "object",
+	"properties": {
+		"id": { "type": "integer" }
+	},
+	"required": [ "id" ]
+}`
+
+	tests := []struct {
+		name string
+		part int
+		arg  [][]byte
+		want bool
+	}{
+		{
+			name: "valid",
+			part: 0,
+			arg: [][]byte{
+				[]byte(`{"id":1}`),
+			},
+			want: true,
+		},
+		{
+			name: "missing field",
+			part: 0,
+			arg: [][]byte{
+				[]byte(`{"name":"foo"}`),
+			},
+			want: false,
+		},
+		{
+			name: "wrong type",
+			part: 0,
+			arg: [][]byte{
+				[]byte(`{"id":"1"}`),
+			},
+			want: false,
+		},
+		{
+			name: "not json",
+			part: 0,
+			arg: [][]byte{
+				[]byte(`not json`),
+			},
+			want: false,
+		},
+		{
+			name: "negative index",
+			part: -1,
+			arg: [][]byte{
+				[]byte(`{"name":"foo"}`),
+				[]byte(`{"id":2}`),
+			},
+			want: true,
+		},
+		{
+			name: "out of bounds",
+			part: 2,
+			arg: [][]byte{
+				[]byte(`{"id":1}`),
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := NewConfig()
+			conf.Type = "json_schema"
+			conf.JSONSchema.Schema = schema
+			conf.JSONSchema.Part = tt.part
+
+			c, err := NewJSONSchema(conf, nil, testLog, testMet)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got := c.Check(message.New(tt.arg)); got != tt.want {
+				t.Errorf("JSONSchema.Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONSchemaBadSchema(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	testMet := metrics.DudType{}
+
+	conf := NewConfig()
+	conf.Type = "json_schema"
+	if _, err := NewJSONSchema(conf, nil, testLog, testMet); err == nil {
+		t.Error("expected error from missing schema")
+	}
+
+	conf.JSONSchema.SchemaPath = "/does/not/exist.json"
+	if _, err := NewJSONSchema(conf, nil, testLog, testMet); err == nil {
+		t.Error("expected error from missing schema file")
+	}
+}
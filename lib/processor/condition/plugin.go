@@ -29,6 +29,7 @@ import (
 	"github.com/Jeffail/benthos/lib/log"
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/config"
 	yaml "gopkg.in/yaml.v2"
 )
 
@@ -169,3 +170,19 @@ func PluginDescriptions() string {
 }
 
 //------------------------------------------------------------------------------
+
+// PluginDocs returns the documentation of each registered plugin, keyed by the
+// plugin type name.
+func PluginDocs() map[string]config.PluginDoc {
+	docs := map[string]config.PluginDoc{}
+	for name, spec := range pluginSpecs {
+		doc := config.PluginDoc{Description: spec.description}
+		if spec.confConstructor != nil {
+			doc.Config = spec.confConstructor()
+		}
+		docs[name] = doc
+	}
+	return docs
+}
+
+//------------------------------------------------------------------------------
@@ -51,6 +51,11 @@ type TypeSpec struct {
 // Constructors is a map of all processor types with their specs.
 var Constructors = map[string]TypeSpec{}
 
+// Description returns a markdown formatted description of the processor type.
+func (t TypeSpec) Description() string {
+	return t.description
+}
+
 //------------------------------------------------------------------------------
 
 // String constants representing each processor type.
@@ -63,6 +68,7 @@ const (
 	TypeCatch        = "catch"
 	TypeCompress     = "compress"
 	TypeConditional  = "conditional"
+	TypeCrypto       = "crypto"
 	TypeDecode       = "decode"
 	TypeDecompress   = "decompress"
 	TypeDedupe       = "dedupe"
@@ -78,8 +84,10 @@ const (
 	TypeInsertPart   = "insert_part"
 	TypeJMESPath     = "jmespath"
 	TypeJSON         = "json"
+	TypeJSONSchema   = "json_schema"
 	TypeLambda       = "lambda"
 	TypeLog          = "log"
+	TypeMapping      = "mapping"
 	TypeMergeJSON    = "merge_json"
 	TypeMetadata     = "metadata"
 	TypeMetric       = "metric"
@@ -93,6 +101,7 @@ const (
 	TypeSelectParts  = "select_parts"
 	TypeSleep        = "sleep"
 	TypeSplit        = "split"
+	TypeSQL          = "sql"
 	TypeSubprocess   = "subprocess"
 	TypeSwitch       = "switch"
 	TypeText         = "text"
@@ -100,6 +109,7 @@ const (
 	TypeThrottle     = "throttle"
 	TypeUnarchive    = "unarchive"
 	TypeWhile        = "while"
+	TypeWindow       = "window"
 )
 
 //------------------------------------------------------------------------------
@@ -115,6 +125,7 @@ type Config struct {
 	Catch        CatchConfig        `json:"catch" yaml:"catch"`
 	Compress     CompressConfig     `json:"compress" yaml:"compress"`
 	Conditional  ConditionalConfig  `json:"conditional" yaml:"conditional"`
+	Crypto       CryptoConfig       `json:"crypto" yaml:"crypto"`
 	Decode       DecodeConfig       `json:"decode" yaml:"decode"`
 	Decompress   DecompressConfig   `json:"decompress" yaml:"decompress"`
 	Dedupe       DedupeConfig       `json:"dedupe" yaml:"dedupe"`
@@ -130,8 +141,10 @@ type Config struct {
 	InsertPart   InsertPartConfig   `json:"insert_part" yaml:"insert_part"`
 	JMESPath     JMESPathConfig     `json:"jmespath" yaml:"jmespath"`
 	JSON         JSONConfig         `json:"json" yaml:"json"`
+	JSONSchema   JSONSchemaConfig   `json:"json_schema" yaml:"json_schema"`
 	Lambda       LambdaConfig       `json:"lambda" yaml:"lambda"`
 	Log          LogConfig          `json:"log" yaml:"log"`
+	Mapping      MappingConfig      `json:"mapping" yaml:"mapping"`
 	MergeJSON    MergeJSONConfig    `json:"merge_json" yaml:"merge_json"`
 	Metadata     MetadataConfig     `json:"metadata" yaml:"metadata"`
 	Metric       MetricConfig       `json:"metric" yaml:"metric"`
@@ -145,6 +158,7 @@ type Config struct {
 	SelectParts  SelectPartsConfig  `json:"select_parts" yaml:"select_parts"`
 	Sleep        SleepConfig        `json:"sleep" yaml:"sleep"`
 	Split        SplitConfig        `json:"split" yaml:"split"`
+	SQL          SQLConfig          `json:"sql" yaml:"sql"`
 	Subprocess   SubprocessConfig   `json:"subprocess" yaml:"subprocess"`
 	Switch       SwitchConfig       `json:"switch" yaml:"switch"`
 	Text         TextConfig         `json:"text" yaml:"text"`
@@ -152,6 +166,7 @@ type Config struct {
 	Throttle     ThrottleConfig     `json:"throttle" yaml:"throttle"`
 	Unarchive    UnarchiveConfig    `json:"unarchive" yaml:"unarchive"`
 	While        WhileConfig        `json:"while" yaml:"while"`
+	Window       WindowConfig       `json:"window" yaml:"window"`
 }
 
 // NewConfig returns a configuration struct fully populated with default values.
@@ -166,6 +181,7 @@ func NewConfig() Config {
 		Catch:        NewCatchConfig(),
 		Compress:     NewCompressConfig(),
 		Conditional:  NewConditionalConfig(),
+		Crypto:       NewCryptoConfig(),
 		Decode:       NewDecodeConfig(),
 		Decompress:   NewDecompressConfig(),
 		Dedupe:       NewDedupeConfig(),
@@ -181,8 +197,10 @@ func NewConfig() Config {
 		InsertPart:   NewInsertPartConfig(),
 		JMESPath:     NewJMESPathConfig(),
 		JSON:         NewJSONConfig(),
+		JSONSchema:   NewJSONSchemaConfig(),
 		Lambda:       NewLambdaConfig(),
 		Log:          NewLogConfig(),
+		Mapping:      NewMappingConfig(),
 		MergeJSON:    NewMergeJSONConfig(),
 		Metadata:     NewMetadataConfig(),
 		Metric:       NewMetricConfig(),
@@ -196,6 +214,7 @@ func NewConfig() Config {
 		SelectParts:  NewSelectPartsConfig(),
 		Sleep:        NewSleepConfig(),
 		Split:        NewSplitConfig(),
+		SQL:          NewSQLConfig(),
 		Subprocess:   NewSubprocessConfig(),
 		Switch:       NewSwitchConfig(),
 		Text:         NewTextConfig(),
@@ -203,6 +222,7 @@ func NewConfig() Config {
 		Throttle:     NewThrottleConfig(),
 		Unarchive:    NewUnarchiveConfig(),
 		While:        NewWhileConfig(),
+		Window:       NewWindowConfig(),
 	}
 }
 
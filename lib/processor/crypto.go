@@ -0,0 +1,382 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/gabs"
+	"github.com/opentracing/opentracing-go"
+	yaml "gopkg.in/yaml.v2"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeCrypto] = TypeSpec{
+		constructor: NewCrypto,
+		description: `
+Encrypts or decrypts messages with AES-GCM using keys from a keyring file. The
+operator is either ` + "`encrypt`" + ` or ` + "`decrypt`" + `.
+
+When ` + "`paths`" + ` is empty the entire contents of each message part are
+processed, otherwise each part is parsed as JSON and only the values found at
+the listed dot paths are processed, paths that do not exist within a part are
+skipped. Values of any JSON type can be encrypted and are replaced with a
+string, when decrypted the original value (and type) is restored.
+
+### Keyring
+
+The keyring file is a YAML or JSON document that maps key IDs to base64
+encoded keys of 16, 24 or 32 bytes (AES-128, AES-192 or AES-256), along with
+the ID of the primary key:
+
+` + "``` yaml" + `
+primary: 2019-05
+keys:
+  2019-04: 6Xk0nZkVqA/9RiJ8Y2H4HzS6gFfGq2lWjvD8Hk9bQmI=
+  2019-05: LSXnLWQKGota0luyX/172ymHEkgYkqy6Zw52yl0ORDU=
+` + "```" + `
+
+Messages are always encrypted with the primary key, and the ID of the key is
+embedded within the resulting ciphertext, which is base64 encoded. When
+decrypting, the key is selected by the embedded ID, and therefore keys can be
+rotated by adding a new key to the keyring and making it the primary, keeping
+the old keys within the keyring for as long as data encrypted with them needs
+to be decrypted. The keyring is read when the processor is created.
+
+Parts that fail to be encrypted or decrypted, for example because the key ID of
+a ciphertext is not found in the keyring, are flagged as having failed
+processing and can be caught using [error handling methods](../error_handling.md).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// CryptoConfig contains configuration fields for the Crypto processor.
+type CryptoConfig struct {
+	Parts       []int    `json:"parts" yaml:"parts"`
+	Operator    string   `json:"operator" yaml:"operator"`
+	Paths       []string `json:"paths" yaml:"paths"`
+	KeyringPath string   `json:"keyring_path" yaml:"keyring_path"`
+}
+
+// NewCryptoConfig returns a CryptoConfig with default values.
+func NewCryptoConfig() CryptoConfig {
+	return CryptoConfig{
+		Parts:       []int{},
+		Operator:    "encrypt",
+		Paths:       []string{},
+		KeyringPath: "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// cryptoKeyring contains AEAD ciphers indexed by key ID along with the ID of
+// the key used for encryption.
+type cryptoKeyring struct {
+	primary string
+	ciphers map[string]cipher.AEAD
+}
+
+func readCryptoKeyring(path string) (*cryptoKeyring, error) {
+	if len(path) == 0 {
+		return nil, errors.New("a keyring_path must be provided")
+	}
+	keyringBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %v", err)
+	}
+
+	var keyringConf struct {
+		Primary string            `yaml:"primary"`
+		Keys    map[string]string `yaml:"keys"`
+	}
+	if err = yaml.Unmarshal(keyringBytes, &keyringConf); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring: %v", err)
+	}
+
+	keyring := &cryptoKeyring{
+		primary: keyringConf.Primary,
+		ciphers: map[string]cipher.AEAD{},
+	}
+	for id, encodedKey := range keyringConf.Keys {
+		if len(id) == 0 || len(id) > 255 {
+			return nil, fmt.Errorf("key ID '%v' must be between 1 and 255 bytes", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key '%v': %v", id, err)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher for key '%v': %v", id, err)
+		}
+		if keyring.ciphers[id], err = cipher.NewGCM(block); err != nil {
+			return nil, fmt.Errorf("failed to create cipher for key '%v': %v", id, err)
+		}
+	}
+
+	if len(keyring.primary) == 0 {
+		return nil, errors.New("keyring must specify a primary key")
+	}
+	if _, exists := keyring.ciphers[keyring.primary]; !exists {
+		return nil, fmt.Errorf("primary key '%v' not found in keyring", keyring.primary)
+	}
+	return keyring, nil
+}
+
+// encrypt seals a plaintext with the primary key and returns the base64
+// encoding of the key ID length, key ID, nonce and sealed data. The key ID is
+// authenticated as additional data.
+func (k *cryptoKeyring) encrypt(plaintext []byte) ([]byte, error) {
+	aead := k.ciphers[k.primary]
+
+	header := append([]byte{byte(len(k.primary))}, k.primary...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	sealed := aead.Seal(append(header, nonce...), nonce, plaintext, header)
+
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(sealed)))
+	base64.StdEncoding.Encode(encoded, sealed)
+	return encoded, nil
+}
+
+// decrypt reverses encrypt using the key identified within the ciphertext.
+func (k *cryptoKeyring) decrypt(ciphertext []byte) ([]byte, error) {
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(ciphertext)))
+	n, err := base64.StdEncoding.Decode(sealed, ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode ciphertext: %v", err)
+	}
+	sealed = sealed[:n]
+
+	if len(sealed) == 0 || len(sealed) < 1+int(sealed[0]) {
+		return nil, errors.New("ciphertext is too short")
+	}
+	header, sealed := sealed[:1+int(sealed[0])], sealed[1+int(sealed[0]):]
+
+	keyID := string(header[1:])
+	aead, exists := k.ciphers[keyID]
+	if !exists {
+		return nil, fmt.Errorf("key '%v' not found in keyring", keyID)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, sealed, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt with key '%v': %v", keyID, err)
+	}
+	return plaintext, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Crypto is a processor that encrypts or decrypts messages, or fields within
+// messages, using AES-GCM.
+type Crypto struct {
+	parts   []int
+	paths   [][]string
+	encrypt bool
+	keyring *cryptoKeyring
+
+	log   log.Modular
+	stats metrics.Type
+
+	mCount     metrics.StatCounter
+	mErrJSONP  metrics.StatCounter
+	mErrJSONS  metrics.StatCounter
+	mErrCrypto metrics.StatCounter
+	mErr       metrics.StatCounter
+	mSent      metrics.StatCounter
+	mBatchSent metrics.StatCounter
+}
+
+// NewCrypto returns a Crypto processor.
+func NewCrypto(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	c := &Crypto{
+		parts: conf.Crypto.Parts,
+		log:   log,
+		stats: stats,
+
+		mCount:     stats.GetCounter("count"),
+		mErrJSONP:  stats.GetCounter("error.json_parse"),
+		mErrJSONS:  stats.GetCounter("error.json_set"),
+		mErrCrypto: stats.GetCounter("error.crypto"),
+		mErr:       stats.GetCounter("error"),
+		mSent:      stats.GetCounter("sent"),
+		mBatchSent: stats.GetCounter("batch.sent"),
+	}
+
+	switch conf.Crypto.Operator {
+	case "encrypt":
+		c.encrypt = true
+	case "decrypt":
+	default:
+		return nil, fmt.Errorf("operator not recognised: %v", conf.Crypto.Operator)
+	}
+
+	for _, path := range conf.Crypto.Paths {
+		if len(path) == 0 {
+			return nil, errors.New("paths must not be empty")
+		}
+		c.paths = append(c.paths, strings.Split(path, "."))
+	}
+
+	var err error
+	if c.keyring, err = readCryptoKeyring(conf.Crypto.KeyringPath); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+//------------------------------------------------------------------------------
+
+// processValue encrypts the JSON serialisation of a value, or decrypts a
+// string value and parses the result as JSON.
+func (c *Crypto) processValue(value interface{}) (interface{}, error) {
+	if c.encrypt {
+		valueBytes, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		ciphertext, err := c.keyring.encrypt(valueBytes)
+		if err != nil {
+			return nil, err
+		}
+		return string(ciphertext), nil
+	}
+
+	ciphertext, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected string value, found %T", value)
+	}
+	plaintext, err := c.keyring.decrypt([]byte(ciphertext))
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	if err = json.Unmarshal(plaintext, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted value: %v", err)
+	}
+	return result, nil
+}
+
+// ProcessMessage applies the processor to a message, either creating >0
+// resulting messages or a response to be sent back to the message source.
+func (c *Crypto) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	c.mCount.Incr(1)
+	newMsg := msg.Copy()
+
+	proc := func(index int, span opentracing.Span, part types.Part) error {
+		if len(c.paths) == 0 {
+			var result []byte
+			var err error
+			if c.encrypt {
+				result, err = c.keyring.encrypt(part.Get())
+			} else {
+				result, err = c.keyring.decrypt(part.Get())
+			}
+			if err != nil {
+				c.mErrCrypto.Incr(1)
+				c.mErr.Incr(1)
+				c.log.Debugf("Failed to process part: %v\n", err)
+				return err
+			}
+			part.Set(result)
+			return nil
+		}
+
+		jsonPart, err := part.JSON()
+		if err == nil {
+			jsonPart, err = message.CopyJSON(jsonPart)
+		}
+		if err != nil {
+			c.mErrJSONP.Incr(1)
+			c.mErr.Incr(1)
+			c.log.Debugf("Failed to parse part into json: %v\n", err)
+			return err
+		}
+
+		gPart, _ := gabs.Consume(jsonPart)
+		for _, path := range c.paths {
+			if !gPart.Exists(path...) {
+				continue
+			}
+			var value interface{}
+			if value, err = c.processValue(gPart.S(path...).Data()); err != nil {
+				c.mErrCrypto.Incr(1)
+				c.mErr.Incr(1)
+				c.log.Debugf("Failed to process path '%v': %v\n", strings.Join(path, "."), err)
+				return fmt.Errorf("path '%v': %v", strings.Join(path, "."), err)
+			}
+			gPart.Set(value, path...)
+		}
+
+		if err = part.SetJSON(gPart.Data()); err != nil {
+			c.mErrJSONS.Incr(1)
+			c.mErr.Incr(1)
+			c.log.Debugf("Failed to convert json into part: %v\n", err)
+			return err
+		}
+		return nil
+	}
+
+	IteratePartsWithSpan(TypeCrypto, c.parts, newMsg, proc)
+
+	c.mBatchSent.Incr(1)
+	c.mSent.Incr(int64(newMsg.Len()))
+	return []types.Message{newMsg}, nil
+}
+
+// CloseAsync shuts down the processor and stops processing requests.
+func (c *Crypto) CloseAsync() {
+}
+
+// WaitForClose blocks until the processor has closed down.
+func (c *Crypto) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,290 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+const (
+	testCryptoKeyA = "6Xk0nZkVqA/9RiJ8Y2H4HzS6gFfGq2lWjvD8Hk9bQmI="
+	testCryptoKeyB = "MDEyMzQ1Njc4OWFiY2RlZg=="
+)
+
+func writeCryptoKeyring(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestCrypto(t *testing.T, operator, keyringPath string, paths ...string) Type {
+	conf := NewConfig()
+	conf.Crypto.Operator = operator
+	conf.Crypto.KeyringPath = keyringPath
+	conf.Crypto.Paths = paths
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewCrypto(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proc
+}
+
+func processCrypto(t *testing.T, proc Type, msg types.Message) types.Message {
+	msgs, res := proc.ProcessMessage(msg)
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if len(msgs) != 1 {
+		t.Fatal("Wrong count of messages")
+	}
+	return msgs[0]
+}
+
+func TestCryptoWholeParts(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "benthos_crypto_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	keyringPath := writeCryptoKeyring(t, tmpDir, "keyring.yaml", `
+primary: a
+keys:
+  a: `+testCryptoKeyA+`
+`)
+
+	encrypter := newTestCrypto(t, "encrypt", keyringPath)
+	decrypter := newTestCrypto(t, "decrypt", keyringPath)
+
+	input := [][]byte{
+		[]byte("hello world"),
+		[]byte(`{"foo":"bar"}`),
+		{},
+	}
+
+	encrypted := processCrypto(t, encrypter, message.New(input))
+	for i, exp := range input {
+		part := encrypted.Get(i)
+		if HasFailed(part) {
+			t.Errorf("Part %v failed: %v", i, part.Metadata().Get(FailFlagKey))
+		}
+		if len(exp) > 0 && bytes.Contains(part.Get(), exp) {
+			t.Errorf("Part %v contains plaintext: %s", i, part.Get())
+		}
+	}
+
+	// Encrypting the same content twice should not result in the same
+	// ciphertext.
+	if again := processCrypto(t, encrypter, message.New(input)); bytes.Equal(again.Get(0).Get(), encrypted.Get(0).Get()) {
+		t.Error("Expected unique ciphertexts")
+	}
+
+	decrypted := processCrypto(t, decrypter, encrypted)
+	if exp, act := input, message.GetAllBytes(decrypted); !bytes.Equal(bytes.Join(exp, []byte("|")), bytes.Join(act, []byte("|"))) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+
+	// Tampering with a ciphertext must fail authentication.
+	tampered := []byte(strings.ToUpper(string(encrypted.Get(0).Get())))
+	failed := processCrypto(t, decrypter, message.New([][]byte{tampered, []byte("not base64!")}))
+	for i := 0; i < failed.Len(); i++ {
+		if !HasFailed(failed.Get(i)) {
+			t.Errorf("Expected part %v to fail", i)
+		}
+	}
+}
+
+func TestCryptoPaths(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "benthos_crypto_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	keyringPath := writeCryptoKeyring(t, tmpDir, "keyring.json", `{
+	"primary": "a",
+	"keys": {"a": "`+testCryptoKeyA+`"}
+}`)
+
+	encrypter := newTestCrypto(t, "encrypt", keyringPath, "user.email", "user.address", "ssn")
+	decrypter := newTestCrypto(t, "decrypt", keyringPath, "user.email", "user.address", "ssn")
+
+	input := []string{
+		`{"id":1,"ssn":"123-45-6789","user":{"address":{"city":"London","zip":["N1"]},"email":"foo@example.com","name":"foo"}}`,
+		`{"id":2,"user":{"name":"bar"}}`,
+		`{"id":3,"ssn":123456789}`,
+		`not json`,
+	}
+
+	msg := message.New(nil)
+	for _, in := range input {
+		msg.Append(message.NewPart([]byte(in)))
+	}
+
+	encrypted := processCrypto(t, encrypter, msg)
+	for i, in := range input[:3] {
+		part := encrypted.Get(i)
+		if HasFailed(part) {
+			t.Errorf("Part %v failed: %v", i, part.Metadata().Get(FailFlagKey))
+		}
+		for _, secret := range []string{"123-45-6789", "123456789", "London", "foo@example.com"} {
+			if bytes.Contains(part.Get(), []byte(secret)) {
+				t.Errorf("Part %v contains plaintext '%v': %s", i, secret, part.Get())
+			}
+		}
+		if i == 1 && string(part.Get()) != in {
+			t.Errorf("Part without target paths changed: %s != %s", part.Get(), in)
+		}
+	}
+	if !HasFailed(encrypted.Get(3)) {
+		t.Error("Expected non JSON part to fail")
+	}
+
+	decrypted := processCrypto(t, decrypter, encrypted)
+	for i, exp := range input[:3] {
+		if act := string(decrypted.Get(i).Get()); act != exp {
+			t.Errorf("Wrong result for part %v: %v != %v", i, act, exp)
+		}
+	}
+
+	// Values that are not ciphertexts should fail to decrypt and leave the part
+	// unchanged.
+	notEncrypted := `{"ssn":"123-45-6789","user":{"email":1}}`
+	failed := processCrypto(t, decrypter, message.New([][]byte{[]byte(notEncrypted)}))
+	if !HasFailed(failed.Get(0)) {
+		t.Error("Expected part to fail")
+	}
+	if act := string(failed.Get(0).Get()); act != notEncrypted {
+		t.Errorf("Failed part changed: %v != %v", act, notEncrypted)
+	}
+}
+
+func TestCryptoKeyRotation(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "benthos_crypto_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	oldKeyring := writeCryptoKeyring(t, tmpDir, "old.yaml", `
+primary: a
+keys:
+  a: `+testCryptoKeyA+`
+`)
+	newKeyring := writeCryptoKeyring(t, tmpDir, "new.yaml", `
+primary: b
+keys:
+  a: `+testCryptoKeyA+`
+  b: `+testCryptoKeyB+`
+`)
+	onlyNewKeyring := writeCryptoKeyring(t, tmpDir, "only_new.yaml", `
+primary: b
+keys:
+  b: `+testCryptoKeyB+`
+`)
+
+	oldEncrypted := processCrypto(
+		t, newTestCrypto(t, "encrypt", oldKeyring),
+		message.New([][]byte{[]byte("old secret")}),
+	)
+	newEncrypted := processCrypto(
+		t, newTestCrypto(t, "encrypt", newKeyring),
+		message.New([][]byte{[]byte("new secret")}),
+	)
+
+	decrypter := newTestCrypto(t, "decrypt", newKeyring)
+	for _, test := range []struct {
+		msg types.Message
+		exp string
+	}{
+		{msg: oldEncrypted, exp: "old secret"},
+		{msg: newEncrypted, exp: "new secret"},
+	} {
+		res := processCrypto(t, decrypter, test.msg)
+		if HasFailed(res.Get(0)) {
+			t.Errorf("Part failed: %v", res.Get(0).Metadata().Get(FailFlagKey))
+		}
+		if act := string(res.Get(0).Get()); act != test.exp {
+			t.Errorf("Wrong result: %v != %v", act, test.exp)
+		}
+	}
+
+	// Decrypting with a keyring that has dropped the old key should fail.
+	res := processCrypto(t, newTestCrypto(t, "decrypt", onlyNewKeyring), oldEncrypted)
+	if !HasFailed(res.Get(0)) {
+		t.Error("Expected part to fail")
+	}
+	if exp, act := "key 'a' not found in keyring", res.Get(0).Metadata().Get(FailFlagKey); !strings.Contains(act, exp) {
+		t.Errorf("Wrong error: %v does not contain %v", act, exp)
+	}
+}
+
+func TestCryptoBadConfig(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "benthos_crypto_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tests := map[string]string{
+		"no primary":      "keys:\n  a: " + testCryptoKeyA,
+		"missing primary": "primary: b\nkeys:\n  a: " + testCryptoKeyA,
+		"bad base64":      "primary: a\nkeys:\n  a: not base64!",
+		"bad key length":  "primary: a\nkeys:\n  a: Zm9v",
+		"not yaml":        "primary: [",
+	}
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	for name, content := range tests {
+		conf := NewConfig()
+		conf.Crypto.KeyringPath = writeCryptoKeyring(t, tmpDir, "keyring.yaml", content)
+		if _, err := NewCrypto(conf, nil, testLog, metrics.DudType{}); err == nil {
+			t.Errorf("Expected error from %v", name)
+		}
+	}
+
+	conf := NewConfig()
+	conf.Crypto.KeyringPath = writeCryptoKeyring(t, tmpDir, "keyring.yaml", "primary: a\nkeys:\n  a: "+testCryptoKeyA)
+	conf.Crypto.Operator = "nope"
+	if _, err := NewCrypto(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad operator")
+	}
+
+	conf.Crypto.Operator = "encrypt"
+	conf.Crypto.KeyringPath = filepath.Join(tmpDir, "does_not_exist.yaml")
+	if _, err := NewCrypto(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from missing keyring")
+	}
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/response"
 	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/schema"
 	"github.com/opentracing/opentracing-go"
 )
 
@@ -41,7 +42,14 @@ func init() {
 		constructor: NewDecode,
 		description: `
 Decodes messages according to the selected scheme. Supported available schemes
-are: base64.`,
+are: base64, avro, protobuf.
+
+The ` + "`avro` and `protobuf`" + ` schemes convert binary encoded messages into
+JSON documents using a schema. Avro documents are converted into the
+[JSON encoding of Avro](https://avro.apache.org/docs/current/spec.html#json_encoding),
+where values of union types are wrapped in an object keyed by their type.
+
+` + schema.Documentation,
 	}
 }
 
@@ -49,8 +57,9 @@ are: base64.`,
 
 // DecodeConfig contains configuration fields for the Decode processor.
 type DecodeConfig struct {
-	Scheme string `json:"scheme" yaml:"scheme"`
-	Parts  []int  `json:"parts" yaml:"parts"`
+	Scheme string        `json:"scheme" yaml:"scheme"`
+	Parts  []int         `json:"parts" yaml:"parts"`
+	Schema schema.Config `json:"schema" yaml:"schema"`
 }
 
 // NewDecodeConfig returns a DecodeConfig with default values.
@@ -58,6 +67,7 @@ func NewDecodeConfig() DecodeConfig {
 	return DecodeConfig{
 		Scheme: "base64",
 		Parts:  []int{},
+		Schema: schema.NewConfig(),
 	}
 }
 
@@ -70,12 +80,18 @@ func base64Decode(b []byte) ([]byte, error) {
 	return ioutil.ReadAll(e)
 }
 
-func strToDecoder(str string) (decodeFunc, error) {
-	switch str {
+func strToDecoder(conf DecodeConfig, mgr types.Manager) (decodeFunc, error) {
+	switch conf.Scheme {
 	case "base64":
 		return base64Decode, nil
+	case schema.SchemeAvro, schema.SchemeProtobuf:
+		c, err := schema.New(conf.Scheme, conf.Schema, mgr)
+		if err != nil {
+			return nil, err
+		}
+		return c.Decode, nil
 	}
-	return nil, fmt.Errorf("decode scheme not recognised: %v", str)
+	return nil, fmt.Errorf("decode scheme not recognised: %v", conf.Scheme)
 }
 
 //------------------------------------------------------------------------------
@@ -99,7 +115,7 @@ type Decode struct {
 func NewDecode(
 	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
 ) (Type, error) {
-	cor, err := strToDecoder(conf.Decode.Scheme)
+	cor, err := strToDecoder(conf.Decode, mgr)
 	if err != nil {
 		return nil, err
 	}
@@ -23,13 +23,20 @@ package processor
 import (
 	"bytes"
 	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
+	"sync/atomic"
 	"testing"
 
+	"github.com/Jeffail/benthos/lib/cache"
 	"github.com/Jeffail/benthos/lib/log"
 	"github.com/Jeffail/benthos/lib/message"
 	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/schema"
 )
 
 func TestDecodeBadAlgo(t *testing.T) {
@@ -166,3 +173,76 @@ func TestDecodeEmpty(t *testing.T) {
 		t.Error("Expected failure with zero part message")
 	}
 }
+
+const testAvroSchema = `{
+	"type": "record",
+	"name": "foo",
+	"fields": [
+		{"name": "name", "type": "string"},
+		{"name": "n", "type": "int"}
+	]
+}`
+
+func TestDecodeAvroRegistry(t *testing.T) {
+	var reqs int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&reqs, 1)
+		if r.URL.Path != "/schemas/ids/3" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"schema":%q}`, testAvroSchema)
+	}))
+	defer ts.Close()
+
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"schemas": memCache,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Decode.Scheme = "avro"
+	conf.Decode.Schema.RegistryURL = ts.URL
+	conf.Decode.Schema.Cache = "schemas"
+
+	proc, err := NewDecode(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := message.New([][]byte{
+		schema.WriteWireHeader(3, []byte{6, 'f', 'o', 'o', 6}),
+		schema.WriteWireHeader(3, []byte{6, 'b', 'a', 'r', 8}),
+		[]byte("not framed"),
+	})
+	msgs, res := proc.ProcessMessage(input)
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+
+	exp := [][]byte{
+		[]byte(`{"name":"foo","n":3}`),
+		[]byte(`{"name":"bar","n":4}`),
+		[]byte("not framed"),
+	}
+	if act := message.GetAllBytes(msgs[0]); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	if !HasFailed(msgs[0].Get(2)) {
+		t.Error("Expected unframed part to be flagged as failed")
+	}
+	if exp, act := int32(1), atomic.LoadInt32(&reqs); exp != act {
+		t.Errorf("Wrong count of registry requests: %v != %v", act, exp)
+	}
+	if _, err = memCache.Get(ts.URL + "/schemas/ids/3"); err != nil {
+		t.Errorf("Expected schema to be cached: %v", err)
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/response"
 	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/schema"
 	"github.com/opentracing/opentracing-go"
 )
 
@@ -40,7 +41,13 @@ func init() {
 		constructor: NewEncode,
 		description: `
 Encodes messages according to the selected scheme. Supported schemes are:
-base64.`,
+base64, avro, protobuf.
+
+The ` + "`avro` and `protobuf`" + ` schemes convert JSON documents into their
+binary encoding using a schema. When a schema registry is used the latest
+schema of the subject is resolved once and messages are framed with its ID.
+
+` + schema.Documentation,
 	}
 }
 
@@ -48,8 +55,9 @@ base64.`,
 
 // EncodeConfig contains configuration fields for the Encode processor.
 type EncodeConfig struct {
-	Scheme string `json:"scheme" yaml:"scheme"`
-	Parts  []int  `json:"parts" yaml:"parts"`
+	Scheme string        `json:"scheme" yaml:"scheme"`
+	Parts  []int         `json:"parts" yaml:"parts"`
+	Schema schema.Config `json:"schema" yaml:"schema"`
 }
 
 // NewEncodeConfig returns a EncodeConfig with default values.
@@ -57,6 +65,7 @@ func NewEncodeConfig() EncodeConfig {
 	return EncodeConfig{
 		Scheme: "base64",
 		Parts:  []int{},
+		Schema: schema.NewConfig(),
 	}
 }
 
@@ -74,12 +83,18 @@ func base64Encode(b []byte) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func strToEncoder(str string) (encodeFunc, error) {
-	switch str {
+func strToEncoder(conf EncodeConfig, mgr types.Manager) (encodeFunc, error) {
+	switch conf.Scheme {
 	case "base64":
 		return base64Encode, nil
+	case schema.SchemeAvro, schema.SchemeProtobuf:
+		c, err := schema.New(conf.Scheme, conf.Schema, mgr)
+		if err != nil {
+			return nil, err
+		}
+		return c.Encode, nil
 	}
-	return nil, fmt.Errorf("encode scheme not recognised: %v", str)
+	return nil, fmt.Errorf("encode scheme not recognised: %v", conf.Scheme)
 }
 
 //------------------------------------------------------------------------------
@@ -103,7 +118,7 @@ type Encode struct {
 func NewEncode(
 	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
 ) (Type, error) {
-	cor, err := strToEncoder(conf.Encode.Scheme)
+	cor, err := strToEncoder(conf.Encode, mgr)
 	if err != nil {
 		return nil, err
 	}
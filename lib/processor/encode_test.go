@@ -23,6 +23,9 @@ package processor
 import (
 	"bytes"
 	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"testing"
@@ -30,6 +33,7 @@ import (
 	"github.com/Jeffail/benthos/lib/log"
 	"github.com/Jeffail/benthos/lib/message"
 	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/util/schema"
 )
 
 func TestEncodeBadAlgo(t *testing.T) {
@@ -166,3 +170,39 @@ func TestEncodeEmpty(t *testing.T) {
 		t.Error("Expected failure with zero part message")
 	}
 }
+
+func TestEncodeAvroRegistry(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subjects/foo-value/versions/latest" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"id":3,"version":1,"schema":%q}`, testAvroSchema)
+	}))
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.Encode.Scheme = "avro"
+	conf.Encode.Schema.RegistryURL = ts.URL
+	conf.Encode.Schema.Subject = "foo-value"
+
+	proc, err := NewEncode(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := proc.ProcessMessage(message.New([][]byte{
+		[]byte(`{"name":"foo","n":3}`),
+		[]byte(`{"name":"bar"}`),
+	}))
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+
+	if exp, act := schema.WriteWireHeader(3, []byte{6, 'f', 'o', 'o', 6}), msgs[0].Get(0).Get(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %v != %v", act, exp)
+	}
+	if !HasFailed(msgs[0].Get(1)) {
+		t.Error("Expected invalid document to be flagged as failed")
+	}
+}
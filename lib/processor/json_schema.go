@@ -0,0 +1,190 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/jsonschema"
+	"github.com/opentracing/opentracing-go"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeJSONSchema] = TypeSpec{
+		constructor: NewJSONSchema,
+		description: `
+Checks messages against a provided [JSON Schema](https://json-schema.org/)
+definition but does not change the payload under any circumstances. If a message
+does not match the schema it is flagged as having failed processing, with the
+validation errors as the reason, and can be caught using
+[error handling methods](../error_handling.md).
+
+The schema can either be provided inline with the field ` + "`schema`" + `, or
+loaded from a file with the field ` + "`schema_path`" + `, in which case
+relative ` + "`$ref`" + ` references are resolved from the location of the
+file.
+
+For example, with the following config:
+
+` + "``` yaml" + `
+json_schema:
+  schema: |
+    {
+      "type": "object",
+      "properties": {
+        "id": { "type": "integer" },
+        "name": { "type": "string" }
+      },
+      "required": [ "id" ]
+    }
+` + "```" + `
+
+A message ` + "`{\"name\":\"foo\"}`" + ` would be flagged with the error
+` + "`(root): id is required`" + `.
+
+In order to route messages based on whether they pass a schema please instead
+use the ` + "[`json_schema`](../conditions/README.md#json_schema)" + `
+condition.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// JSONSchemaConfig contains configuration fields for the JSONSchema processor.
+type JSONSchemaConfig struct {
+	Parts      []int  `json:"parts" yaml:"parts"`
+	SchemaPath string `json:"schema_path" yaml:"schema_path"`
+	Schema     string `json:"schema" yaml:"schema"`
+}
+
+// NewJSONSchemaConfig returns a JSONSchemaConfig with default values.
+func NewJSONSchemaConfig() JSONSchemaConfig {
+	return JSONSchemaConfig{
+		Parts:      []int{},
+		SchemaPath: "",
+		Schema:     "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// JSONSchema is a processor that validates message parts against a JSON Schema
+// and flags the parts that fail.
+type JSONSchema struct {
+	parts  []int
+	schema *gojsonschema.Schema
+
+	log   log.Modular
+	stats metrics.Type
+
+	mCount     metrics.StatCounter
+	mErrJSONP  metrics.StatCounter
+	mErrInval  metrics.StatCounter
+	mErr       metrics.StatCounter
+	mSent      metrics.StatCounter
+	mBatchSent metrics.StatCounter
+}
+
+// NewJSONSchema returns a JSONSchema processor.
+func NewJSONSchema(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	schema, err := jsonschema.Load(conf.JSONSchema.SchemaPath, conf.JSONSchema.Schema)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONSchema{
+		parts:  conf.JSONSchema.Parts,
+		schema: schema,
+		log:    log,
+		stats:  stats,
+
+		mCount:     stats.GetCounter("count"),
+		mErrJSONP:  stats.GetCounter("error.json_parse"),
+		mErrInval:  stats.GetCounter("error.invalid"),
+		mErr:       stats.GetCounter("error"),
+		mSent:      stats.GetCounter("sent"),
+		mBatchSent: stats.GetCounter("batch.sent"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ProcessMessage applies the processor to a message, either creating >0
+// resulting messages or a response to be sent back to the message source.
+func (p *JSONSchema) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	p.mCount.Incr(1)
+	newMsg := msg.Copy()
+
+	proc := func(index int, span opentracing.Span, part types.Part) error {
+		jsonPart, err := part.JSON()
+		if err != nil {
+			p.mErrJSONP.Incr(1)
+			p.mErr.Incr(1)
+			p.log.Debugf("Failed to parse part into json: %v\n", err)
+			return err
+		}
+
+		result, err := p.schema.Validate(gojsonschema.NewGoLoader(jsonPart))
+		if err != nil {
+			p.mErr.Incr(1)
+			p.log.Debugf("Failed to validate json: %v\n", err)
+			return err
+		}
+		if !result.Valid() {
+			p.mErrInval.Incr(1)
+			p.mErr.Incr(1)
+			errStrs := make([]string, 0, len(result.Errors()))
+			for _, desc := range result.Errors() {
+				errStrs = append(errStrs, desc.String())
+			}
+			err = errors.New(strings.Join(errStrs, "; "))
+			p.log.Debugf("Part failed schema validation: %v\n", err)
+			return err
+		}
+		return nil
+	}
+
+	IteratePartsWithSpan(TypeJSONSchema, p.parts, newMsg, proc)
+
+	p.mBatchSent.Incr(1)
+	p.mSent.Incr(int64(newMsg.Len()))
+	return []types.Message{newMsg}, nil
+}
+
+// CloseAsync shuts down the processor and stops processing requests.
+func (p *JSONSchema) CloseAsync() {
+}
+
+// WaitForClose blocks until the processor has closed down.
+func (p *JSONSchema) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,152 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+)
+
+func TestJSONSchemaInline(t *testing.T) {
+	conf := NewConfig()
+	conf.JSONSchema.Schema = `{
+	"type": "object",
+	"properties": {
+		"id": { "type": "integer" },
+		"name": { "type": "string" }
+	},
+	"required": [ "id" ]
+}`
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	proc, err := NewJSONSchema(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := [][]byte{
+		[]byte(`{"id":1,"name":"foo"}`),
+		[]byte(`{"name":"bar"}`),
+		[]byte(`{"id":"3"}`),
+		[]byte(`not json`),
+	}
+	msgs, res := proc.ProcessMessage(message.New(input))
+	if len(msgs) != 1 {
+		t.Fatal("Wrong count of messages")
+	}
+	if res != nil {
+		t.Fatal("Non-nil result")
+	}
+
+	for i, exp := range input {
+		if act := msgs[0].Get(i).Get(); string(exp) != string(act) {
+			t.Errorf("Payload of part %v was changed: %s != %s", i, act, exp)
+		}
+	}
+
+	if HasFailed(msgs[0].Get(0)) {
+		t.Error("Expected part 0 to pass")
+	}
+	for i := 1; i < 4; i++ {
+		if !HasFailed(msgs[0].Get(i)) {
+			t.Errorf("Expected part %v to fail", i)
+		}
+	}
+	if exp, act := "(root): id is required", msgs[0].Get(1).Metadata().Get(FailFlagKey); exp != act {
+		t.Errorf("Wrong failure reason: %v != %v", act, exp)
+	}
+	if exp, act := "id: Invalid type. Expected: integer, given: string", msgs[0].Get(2).Metadata().Get(FailFlagKey); exp != act {
+		t.Errorf("Wrong failure reason: %v != %v", act, exp)
+	}
+}
+
+func TestJSONSchemaPathRef(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_json_schema_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "name.json"), []byte(`{
+	"type": "string",
+	"minLength": 3
+}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "schema.json"), []byte(`{
+	"type": "object",
+	"properties": {
+		"name": { "$ref": "name.json" }
+	}
+}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := NewConfig()
+	conf.JSONSchema.Parts = []int{1}
+	conf.JSONSchema.SchemaPath = filepath.Join(dir, "schema.json")
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	proc, err := NewJSONSchema(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ := proc.ProcessMessage(message.New([][]byte{
+		[]byte(`{"name":"a"}`),
+		[]byte(`{"name":"b"}`),
+	}))
+	if HasFailed(msgs[0].Get(0)) {
+		t.Error("Expected part 0 to be ignored")
+	}
+	if !HasFailed(msgs[0].Get(1)) {
+		t.Error("Expected part 1 to fail")
+	}
+}
+
+func TestJSONSchemaBadConfig(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	if _, err := NewJSONSchema(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from missing schema")
+	}
+
+	conf.JSONSchema.Schema = `{"type":"object"}`
+	conf.JSONSchema.SchemaPath = "./schema.json"
+	if _, err := NewJSONSchema(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from both schema fields")
+	}
+
+	conf.JSONSchema.SchemaPath = ""
+	conf.JSONSchema.Schema = `{"type":`
+	if _, err := NewJSONSchema(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad schema")
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/mapping"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/response"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/opentracing/opentracing-go"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeMapping] = TypeSpec{
+		constructor: NewMapping,
+		description: `
+Executes a mapping written in a small expression language against each message
+part, where fields of the resulting document and metadata values are assigned
+from the input document, metadata and functions. All assignments are applied
+in a single pass over the parsed document, making it a simpler and more
+efficient alternative to chaining ` + "`json`, `metadata` and `jmespath`" + `
+processors.
+
+Mappings are checked when a config is parsed, and therefore a mapping that
+fails to parse will be reported by the ` + "`--lint`" + ` flag with its line
+and column.
+
+### Assignments
+
+Each line of a mapping is an assignment of an expression to either a path of
+the resulting document (` + "`root`" + `) or a metadata key
+(` + "`meta`" + `). The resulting document begins as a copy of the input
+document, which is referenced with the keyword ` + "`this`" + `:
+
+` + "``` yaml" + `
+mapping:
+  mapping: |
+    root.id = this.user.id
+    root.full_name = this.user.first + " " + this.user.last
+    root.user = deleted()
+    meta topic = "users_" + this.user.region.lowercase()
+` + "```" + `
+
+Assigning ` + "`deleted()`" + ` removes the target field or metadata key.
+
+### Expressions
+
+Expressions support the arithmetic operators ` + "`+ - * / %`" + `, where
+` + "`+`" + ` also concatenates strings, the comparison operators
+` + "`== != < <= > >=`" + ` and the boolean operators ` + "`&& || !`" + `.
+Objects (` + "`{\"a\": this.b}`" + `) and arrays (` + "`[1, 2]`" + `) can be
+constructed with literals.
+
+Conditionals are expressed with ` + "`if`" + `, where an ` + "`if`" + ` without
+an ` + "`else`" + ` that fails its condition results in the assignment being
+skipped:
+
+` + "```" + `
+root.size = if this.n > 100 { "big" } else if this.n > 10 { "medium" } else { "small" }
+` + "```" + `
+
+### Functions
+
+The function ` + "`meta(\"key\")`" + ` returns a metadata value (or null),
+` + "`content()`" + ` returns the raw content of the part and
+` + "`deleted()`" + ` marks a target for deletion. All functions supported by
+[function interpolation](../config_interpolation.md#functions) are also
+available, e.g. ` + "`hostname()`, `timestamp_unix()` and `count(\"foo\")`" + `.
+
+### Methods
+
+Values can be transformed with the methods ` + "`uppercase()`" + `,
+` + "`lowercase()`, `trim()`, `length()`, `contains(v)`, `has_prefix(s)`" + `,
+` + "`has_suffix(s)`, `replace(old, new)`, `split(sep)`, `join(sep)`" + `,
+` + "`keys()`, `number()`, `string()`, `parse_json()`, `type()`" + ` and
+` + "`or(default)`" + `, e.g. ` + "`this.name.trim().or(\"anonymous\")`" + `.
+
+If a mapping fails at runtime the message part remains unchanged and is flagged
+as having failed, allowing you to use
+[standard error handling patterns](../error_handling.md).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// MappingConfig contains configuration fields for the Mapping processor.
+type MappingConfig struct {
+	Parts   []int  `json:"parts" yaml:"parts"`
+	Mapping string `json:"mapping" yaml:"mapping"`
+}
+
+// NewMappingConfig returns a MappingConfig with default values.
+func NewMappingConfig() MappingConfig {
+	return MappingConfig{
+		Parts:   []int{},
+		Mapping: "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// UnmarshalJSON ensures that when parsing configs the default values are still
+// applied and the mapping is valid.
+func (m *MappingConfig) UnmarshalJSON(bytes []byte) error {
+	type confAlias MappingConfig
+	aliased := confAlias(NewMappingConfig())
+
+	if err := json.Unmarshal(bytes, &aliased); err != nil {
+		return err
+	}
+	if _, err := mapping.Parse(aliased.Mapping); err != nil {
+		return fmt.Errorf("failed to parse mapping: %v", err)
+	}
+
+	*m = MappingConfig(aliased)
+	return nil
+}
+
+// UnmarshalYAML ensures that when parsing configs the default values are still
+// applied and the mapping is valid.
+func (m *MappingConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type confAlias MappingConfig
+	aliased := confAlias(NewMappingConfig())
+
+	if err := unmarshal(&aliased); err != nil {
+		return err
+	}
+	if _, err := mapping.Parse(aliased.Mapping); err != nil {
+		return fmt.Errorf("failed to parse mapping: %v", err)
+	}
+
+	*m = MappingConfig(aliased)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Mapping is a processor that executes a mapping against message parts.
+type Mapping struct {
+	parts   []int
+	mapping *mapping.Mapping
+
+	log   log.Modular
+	stats metrics.Type
+
+	mCount     metrics.StatCounter
+	mErr       metrics.StatCounter
+	mSent      metrics.StatCounter
+	mBatchSent metrics.StatCounter
+}
+
+// NewMapping returns a Mapping processor.
+func NewMapping(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	m, err := mapping.Parse(conf.Mapping.Mapping)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mapping: %v", err)
+	}
+	return &Mapping{
+		parts:   conf.Mapping.Parts,
+		mapping: m,
+		log:     log,
+		stats:   stats,
+
+		mCount:     stats.GetCounter("count"),
+		mErr:       stats.GetCounter("error"),
+		mSent:      stats.GetCounter("sent"),
+		mBatchSent: stats.GetCounter("batch.sent"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ProcessMessage applies the processor to a message, either creating >0
+// resulting messages or a response to be sent back to the message source.
+func (m *Mapping) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	m.mCount.Incr(1)
+	newMsg := msg.Copy()
+
+	proc := func(index int, span opentracing.Span, part types.Part) error {
+		if err := m.mapping.MapPart(index, newMsg); err != nil {
+			m.mErr.Incr(1)
+			m.log.Debugf("Failed to apply mapping: %v\n", err)
+			return err
+		}
+		return nil
+	}
+
+	if newMsg.Len() == 0 {
+		return nil, response.NewAck()
+	}
+
+	IteratePartsWithSpan(TypeMapping, m.parts, newMsg, proc)
+
+	m.mBatchSent.Incr(1)
+	m.mSent.Incr(int64(newMsg.Len()))
+	msgs := [1]types.Message{newMsg}
+	return msgs[:], nil
+}
+
+// CloseAsync shuts down the processor and stops processing requests.
+func (m *Mapping) CloseAsync() {
+}
+
+// WaitForClose blocks until the processor has closed down.
+func (m *Mapping) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,134 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestMappingJSON(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeMapping
+	conf.Mapping.Mapping = `
+root.id = this.user.id
+root.name = this.user.first + " " + this.user.last
+root.user = deleted()
+meta topic = "users_" + this.user.region.lowercase()`
+
+	proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := message.New([][]byte{
+		[]byte(`{"user":{"id":"foo","first":"a","last":"b","region":"EU"}}`),
+		[]byte(`not json`),
+	})
+	msgs, res := proc.ProcessMessage(input)
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+
+	if exp, act := `{"id":"foo","name":"a b"}`, string(msgs[0].Get(0).Get()); exp != act {
+		t.Errorf("Wrong result: %v != %v", act, exp)
+	}
+	if exp, act := "users_eu", msgs[0].Get(0).Metadata().Get("topic"); exp != act {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+	if HasFailed(msgs[0].Get(0)) {
+		t.Error("Expected first part to succeed")
+	}
+
+	if exp, act := `not json`, string(msgs[0].Get(1).Get()); exp != act {
+		t.Errorf("Wrong result: %v != %v", act, exp)
+	}
+	if !HasFailed(msgs[0].Get(1)) {
+		t.Error("Expected second part to be flagged as failed")
+	}
+
+	if exp, act := `{"user":{"id":"foo","first":"a","last":"b","region":"EU"}}`, string(input.Get(0).Get()); exp != act {
+		t.Errorf("Input message was modified: %v != %v", act, exp)
+	}
+}
+
+func TestMappingParts(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeMapping
+	conf.Mapping.Parts = []int{1}
+	conf.Mapping.Mapping = `root.foo = this.foo.uppercase()`
+
+	proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := proc.ProcessMessage(message.New([][]byte{
+		[]byte(`{"foo":"a"}`),
+		[]byte(`{"foo":"b"}`),
+	}))
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+
+	exp := [][]byte{
+		[]byte(`{"foo":"a"}`),
+		[]byte(`{"foo":"B"}`),
+	}
+	if act := message.GetAllBytes(msgs[0]); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+}
+
+func TestMappingBadConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeMapping
+	conf.Mapping.Mapping = `root.foo = this.foo.nope()`
+
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad mapping")
+	}
+
+	badConf := `
+type: mapping
+mapping:
+  mapping: |
+    root.foo = this.bar
+    root.baz = this.qux.nope()`
+
+	conf = NewConfig()
+	err := yaml.Unmarshal([]byte(badConf), &conf)
+	if err == nil {
+		t.Fatal("Expected error from config parse")
+	}
+	if exp, act := "line 2 char 21", err.Error(); !strings.Contains(act, exp) {
+		t.Errorf("Expected error to contain '%v': %v", exp, act)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+)
+
+func TestSQLReplace(t *testing.T) {
+	db, mock, err := sqlmock.NewWithDSN("sql_proc_replace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conf := NewConfig()
+	conf.SQL.Driver = "sqlmock"
+	conf.SQL.DataSource = "sql_proc_replace"
+	conf.SQL.Query = "SELECT id, name FROM footable WHERE id = ?;"
+	conf.SQL.Args = []string{"${!json_field:id}"}
+
+	proc, err := NewSQL(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.CloseAsync()
+
+	query := regexp.QuoteMeta("SELECT id, name FROM footable WHERE id = ?;")
+	mock.ExpectQuery(query).WithArgs("1").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name"}).AddRow(1, []byte("foo")),
+	)
+	mock.ExpectQuery(query).WithArgs("2").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name"}),
+	)
+
+	msgs, res := proc.ProcessMessage(message.New([][]byte{
+		[]byte(`{"id":"1"}`),
+		[]byte(`{"id":"2"}`),
+	}))
+	if len(msgs) != 1 {
+		t.Fatal("Wrong count of messages")
+	}
+	if res != nil {
+		t.Fatal("Non-nil result")
+	}
+	exp := [][]byte{
+		[]byte(`[{"id":1,"name":"foo"}]`),
+		[]byte(`[]`),
+	}
+	if act := message.GetAllBytes(msgs[0]); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSQLResultPath(t *testing.T) {
+	db, mock, err := sqlmock.NewWithDSN("sql_proc_result_path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conf := NewConfig()
+	conf.SQL.Driver = "sqlmock"
+	conf.SQL.DataSource = "sql_proc_result_path"
+	conf.SQL.Query = "SELECT name, email FROM users WHERE id = ?;"
+	conf.SQL.Args = []string{"${!json_field:user.id}"}
+	conf.SQL.ResultPath = "user.details"
+
+	proc, err := NewSQL(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.CloseAsync()
+
+	query := regexp.QuoteMeta("SELECT name, email FROM users WHERE id = ?;")
+	mock.ExpectQuery(query).WithArgs("1").WillReturnRows(
+		sqlmock.NewRows([]string{"name", "email"}).AddRow("foo", "foo@example.com"),
+	)
+
+	input := message.New([][]byte{[]byte(`{"user":{"id":"1"}}`)})
+	msgs, _ := proc.ProcessMessage(input)
+
+	exp := `{"user":{"details":[{"email":"foo@example.com","name":"foo"}],"id":"1"}}`
+	if act := string(msgs[0].Get(0).Get()); exp != act {
+		t.Errorf("Wrong result: %v != %v", act, exp)
+	}
+	if exp, act := `{"user":{"id":"1"}}`, string(input.Get(0).Get()); exp != act {
+		t.Errorf("Input message was modified: %v != %v", act, exp)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSQLQueryError(t *testing.T) {
+	db, mock, err := sqlmock.NewWithDSN("sql_proc_query_error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conf := NewConfig()
+	conf.SQL.Driver = "sqlmock"
+	conf.SQL.DataSource = "sql_proc_query_error"
+	conf.SQL.Query = "SELECT name FROM footable;"
+
+	proc, err := NewSQL(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.CloseAsync()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT name FROM footable;")).
+		WillReturnError(errors.New("table does not exist"))
+
+	msgs, _ := proc.ProcessMessage(message.New([][]byte{[]byte(`{"id":"1"}`)}))
+	if exp, act := `{"id":"1"}`, string(msgs[0].Get(0).Get()); exp != act {
+		t.Errorf("Wrong result: %v != %v", act, exp)
+	}
+	if !HasFailed(msgs[0].Get(0)) {
+		t.Error("Expected part to be flagged as failed")
+	}
+}
+
+func TestSQLBadConfig(t *testing.T) {
+	conf := NewConfig()
+	if _, err := NewSQL(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing query")
+	}
+
+	conf.SQL.Driver = "notexist"
+	conf.SQL.Query = "SELECT name FROM footable;"
+	if _, err := NewSQL(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from unsupported driver")
+	}
+}
@@ -122,6 +122,20 @@ func NewSubprocess(
 		mBatchSent: stats.GetCounter("batch.sent"),
 	}
 	var err error
+	if dr, ok := mgr.(types.DryRunner); ok && dr.DryRun() {
+		// Avoid executing the command, but check that it can be found.
+		if _, err = exec.LookPath(conf.Subprocess.Name); err != nil {
+			return nil, err
+		}
+		e.subproc = &subprocWrapper{
+			name:       conf.Subprocess.Name,
+			args:       conf.Subprocess.Args,
+			closeChan:  make(chan struct{}),
+			closedChan: make(chan struct{}),
+		}
+		close(e.subproc.closedChan)
+		return e, nil
+	}
 	if e.subproc, err = newSubprocWrapper(conf.Subprocess.Name, conf.Subprocess.Args); err != nil {
 		return nil, err
 	}
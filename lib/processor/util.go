@@ -21,6 +21,8 @@
 package processor
 
 import (
+	"time"
+
 	"github.com/Jeffail/benthos/lib/message/tracing"
 	"github.com/Jeffail/benthos/lib/response"
 	"github.com/Jeffail/benthos/lib/types"
@@ -161,9 +163,67 @@ func HasFailed(part types.Part) bool {
 	return len(part.Metadata().Get(FailFlagKey)) > 0
 }
 
+// FailedProcessorKey is a metadata key used for recording the type of the
+// processor that first flagged a message part as failed.
+var FailedProcessorKey = "benthos_processing_failed_processor"
+
 // ClearFail removes any existing failure flags from a message part.
 func ClearFail(part types.Part) {
 	part.Metadata().Delete(FailFlagKey)
+	part.Metadata().Delete(FailedProcessorKey)
+}
+
+// FailedProcessor returns the type of the processor that first flagged a
+// message part as failed, or an empty string if it is unknown.
+func FailedProcessor(part types.Part) string {
+	return part.Metadata().Get(FailedProcessorKey)
+}
+
+//------------------------------------------------------------------------------
+
+// failTagger wraps a processor and tags any message parts that it flags as
+// failed with the type of the processor.
+type failTagger struct {
+	typeStr string
+	child   types.Processor
+}
+
+// WithFailureTagging wraps a processor so that message parts it flags as
+// failed are tagged with the processor type under FailedProcessorKey. Parts
+// that were already tagged by an earlier processor are left unchanged.
+//
+// Constructors only apply this wrapper when their manager implements
+// types.FailureTagger and requests it.
+func WithFailureTagging(typeStr string, proc types.Processor) types.Processor {
+	return &failTagger{
+		typeStr: typeStr,
+		child:   proc,
+	}
+}
+
+// ProcessMessage executes the child processor and tags failed parts of the
+// resulting messages.
+func (f *failTagger) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	msgs, res := f.child.ProcessMessage(msg)
+	for _, m := range msgs {
+		m.Iter(func(i int, p types.Part) error {
+			if HasFailed(p) && len(FailedProcessor(p)) == 0 {
+				p.Metadata().Set(FailedProcessorKey, f.typeStr)
+			}
+			return nil
+		})
+	}
+	return msgs, res
+}
+
+// CloseAsync shuts down the child processor.
+func (f *failTagger) CloseAsync() {
+	f.child.CloseAsync()
+}
+
+// WaitForClose blocks until the child processor has closed down.
+func (f *failTagger) WaitForClose(timeout time.Duration) error {
+	return f.child.WaitForClose(timeout)
 }
 
 //------------------------------------------------------------------------------
@@ -233,4 +233,59 @@ func TestExecuteAllErrored(t *testing.T) {
 	}
 }
 
+type failer struct {
+	failIndex int
+}
+
+func (f *failer) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	FlagFail(msg.Get(f.failIndex))
+	return []types.Message{msg}, nil
+}
+
+// CloseAsync shuts down the processor and stops processing requests.
+func (f *failer) CloseAsync() {
+}
+
+// WaitForClose blocks until the processor has closed down.
+func (f *failer) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+func TestWithFailureTagging(t *testing.T) {
+	procs := []types.Processor{
+		WithFailureTagging("foo", &passthrough{}),
+		WithFailureTagging("bar", &failer{failIndex: 0}),
+		WithFailureTagging("baz", &failer{failIndex: 1}),
+		WithFailureTagging("qux", &failer{failIndex: 0}),
+	}
+
+	msg := message.New([][]byte{
+		[]byte("test message 1"),
+		[]byte("test message 2"),
+		[]byte("test message 3"),
+	})
+	msgs, res := ExecuteAll(procs, msg)
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+
+	if exp, act := "bar", FailedProcessor(msgs[0].Get(0)); exp != act {
+		t.Errorf("Wrong failed processor: %v != %v", act, exp)
+	}
+	if exp, act := "baz", FailedProcessor(msgs[0].Get(1)); exp != act {
+		t.Errorf("Wrong failed processor: %v != %v", act, exp)
+	}
+	if exp, act := "", FailedProcessor(msgs[0].Get(2)); exp != act {
+		t.Errorf("Wrong failed processor: %v != %v", act, exp)
+	}
+
+	ClearFail(msgs[0].Get(0))
+	if exp, act := "", FailedProcessor(msgs[0].Get(0)); exp != act {
+		t.Errorf("Wrong failed processor: %v != %v", act, exp)
+	}
+}
+
 //------------------------------------------------------------------------------
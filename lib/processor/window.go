@@ -0,0 +1,466 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/response"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/text"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeWindow] = TypeSpec{
+		constructor: NewWindow,
+		description: `
+Aggregates message parts over windows of time, grouped by a key, and emits a
+summary message for each key when a window closes. The key and the value to
+aggregate are
+[function interpolated strings](../config_interpolation.md#functions)
+evaluated per message part.
+
+Windows are either ` + "`tumbling`" + `, where consecutive windows of
+` + "`size`" + ` do not overlap, or ` + "`sliding`" + `, where a window of
+` + "`size`" + ` begins every ` + "`slide`" + ` and a message part therefore
+contributes to multiple windows.
+
+The time of a message part is the processing time by default. If the field
+` + "`timestamp`" + ` is set it is evaluated per message part and parsed either
+as a unix timestamp in seconds or by the format ` + "`timestamp_format`" + `,
+and windows are closed once a message part has been seen with a timestamp
+beyond their end. Message parts that belong only to windows that have already
+closed are dropped.
+
+Each summary is a JSON document of the form:
+
+` + "``` json" + `
+{
+  "key": "foo",
+  "window_start": "2019-06-04T10:00:00Z",
+  "window_end": "2019-06-04T10:00:10Z",
+  "count": 3,
+  "sum": 12,
+  "min": 2,
+  "max": 6,
+  "last": 4
+}
+` + "```" + `
+
+Where ` + "`count`" + ` is the number of message parts within the window,
+` + "`sum`, `min` and `max`" + ` are calculated from values that parse as
+numbers (and are omitted when there are none), and ` + "`last`" + ` is the
+value of the most recent message part.
+
+Message parts are consumed and acknowledged once they are added to a window.
+Similar to the ` + "`batch`" + ` processor, windows are only closed when a
+message is processed, meaning a summary can be emitted later than the end of
+its window when messages are infrequent. Each summary is emitted as a separate
+message.
+
+If the field ` + "`cache`" + ` is set to the name of a
+[cache resource](../caches/README.md) then the state of open windows is
+checkpointed to it under the key ` + "`checkpoint_key`" + ` after each message
+and restored when the processor is created, which prevents windows from being
+lost when the service restarts.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// WindowConfig contains configuration fields for the Window processor.
+type WindowConfig struct {
+	Type            string `json:"type" yaml:"type"`
+	Size            string `json:"size" yaml:"size"`
+	Slide           string `json:"slide" yaml:"slide"`
+	Key             string `json:"key" yaml:"key"`
+	Value           string `json:"value" yaml:"value"`
+	Timestamp       string `json:"timestamp" yaml:"timestamp"`
+	TimestampFormat string `json:"timestamp_format" yaml:"timestamp_format"`
+	Cache           string `json:"cache" yaml:"cache"`
+	CheckpointKey   string `json:"checkpoint_key" yaml:"checkpoint_key"`
+}
+
+// NewWindowConfig returns a WindowConfig with default values.
+func NewWindowConfig() WindowConfig {
+	return WindowConfig{
+		Type:            "tumbling",
+		Size:            "10s",
+		Slide:           "",
+		Key:             "",
+		Value:           "${!content}",
+		Timestamp:       "",
+		TimestampFormat: time.RFC3339,
+		Cache:           "",
+		CheckpointKey:   "benthos_window_state",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// windowAggregate contains the aggregated values of a key within a window.
+type windowAggregate struct {
+	Count int64       `json:"count"`
+	Sum   *float64    `json:"sum,omitempty"`
+	Min   *float64    `json:"min,omitempty"`
+	Max   *float64    `json:"max,omitempty"`
+	Last  interface{} `json:"last"`
+}
+
+func (w *windowAggregate) add(value string) {
+	w.Count++
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		w.Last = value
+		return
+	}
+	w.Last = f
+	if w.Sum == nil {
+		sum, min, max := f, f, f
+		w.Sum, w.Min, w.Max = &sum, &min, &max
+		return
+	}
+	*w.Sum += f
+	if f < *w.Min {
+		*w.Min = f
+	}
+	if f > *w.Max {
+		*w.Max = f
+	}
+}
+
+// windowCheckpoint is the serialised state of all open windows.
+type windowCheckpoint struct {
+	Watermark int64                                 `json:"watermark"`
+	Windows   map[int64]map[string]*windowAggregate `json:"windows"`
+}
+
+// windowSummary is the document emitted when a window closes.
+type windowSummary struct {
+	Key         string `json:"key"`
+	WindowStart string `json:"window_start"`
+	WindowEnd   string `json:"window_end"`
+	*windowAggregate
+}
+
+//------------------------------------------------------------------------------
+
+// Window is a processor that aggregates message parts by a key over tumbling
+// or sliding windows of time, emitting a summary message per key when a window
+// closes.
+type Window struct {
+	log   log.Modular
+	stats metrics.Type
+
+	size  time.Duration
+	slide time.Duration
+
+	key       *text.InterpolatedString
+	value     *text.InterpolatedString
+	timestamp *text.InterpolatedString
+	tsFormat  string
+
+	cache         types.Cache
+	checkpointKey string
+
+	now func() time.Time
+
+	mut       sync.Mutex
+	watermark time.Time
+	windows   map[int64]map[string]*windowAggregate
+
+	mCount      metrics.StatCounter
+	mErr        metrics.StatCounter
+	mLate       metrics.StatCounter
+	mCheckpoint metrics.StatCounter
+	mCheckErr   metrics.StatCounter
+	mWindows    metrics.StatGauge
+	mDropped    metrics.StatCounter
+	mSent       metrics.StatCounter
+	mBatchSent  metrics.StatCounter
+}
+
+// NewWindow returns a Window processor.
+func NewWindow(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	w := &Window{
+		log:           log,
+		stats:         stats,
+		key:           text.NewInterpolatedString(conf.Window.Key),
+		value:         text.NewInterpolatedString(conf.Window.Value),
+		tsFormat:      conf.Window.TimestampFormat,
+		checkpointKey: conf.Window.CheckpointKey,
+		now:           time.Now,
+		windows:       map[int64]map[string]*windowAggregate{},
+
+		mCount:      stats.GetCounter("count"),
+		mErr:        stats.GetCounter("error"),
+		mLate:       stats.GetCounter("late"),
+		mCheckpoint: stats.GetCounter("checkpoint.success"),
+		mCheckErr:   stats.GetCounter("checkpoint.error"),
+		mWindows:    stats.GetGauge("windows"),
+		mDropped:    stats.GetCounter("dropped"),
+		mSent:       stats.GetCounter("sent"),
+		mBatchSent:  stats.GetCounter("batch.sent"),
+	}
+
+	var err error
+	if w.size, err = time.ParseDuration(conf.Window.Size); err != nil {
+		return nil, fmt.Errorf("failed to parse size duration string: %v", err)
+	}
+	if w.size <= 0 {
+		return nil, fmt.Errorf("window size must be greater than zero: %v", conf.Window.Size)
+	}
+
+	switch conf.Window.Type {
+	case "tumbling":
+		w.slide = w.size
+	case "sliding":
+		if len(conf.Window.Slide) == 0 {
+			return nil, fmt.Errorf("a slide duration is required for sliding windows")
+		}
+		if w.slide, err = time.ParseDuration(conf.Window.Slide); err != nil {
+			return nil, fmt.Errorf("failed to parse slide duration string: %v", err)
+		}
+		if w.slide <= 0 || w.slide > w.size {
+			return nil, fmt.Errorf("window slide must be greater than zero and no larger than the size: %v", conf.Window.Slide)
+		}
+	default:
+		return nil, fmt.Errorf("window type not recognised: %v", conf.Window.Type)
+	}
+
+	if len(conf.Window.Timestamp) > 0 {
+		w.timestamp = text.NewInterpolatedString(conf.Window.Timestamp)
+	}
+
+	if len(conf.Window.Cache) > 0 {
+		if w.cache, err = mgr.GetCache(conf.Window.Cache); err != nil {
+			return nil, fmt.Errorf("failed to obtain cache '%v': %v", conf.Window.Cache, err)
+		}
+		if err = w.restore(); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+//------------------------------------------------------------------------------
+
+// restore reads the state of open windows from the checkpoint cache.
+func (w *Window) restore() error {
+	stateBytes, err := w.cache.Get(w.checkpointKey)
+	if err == types.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read window checkpoint: %v", err)
+	}
+	var state windowCheckpoint
+	if err = json.Unmarshal(stateBytes, &state); err != nil {
+		return fmt.Errorf("failed to parse window checkpoint: %v", err)
+	}
+	if state.Watermark > 0 {
+		w.watermark = time.Unix(0, state.Watermark)
+	}
+	if state.Windows != nil {
+		w.windows = state.Windows
+	}
+	w.log.Infof("Restored %v windows from checkpoint\n", len(w.windows))
+	return nil
+}
+
+// checkpoint writes the state of open windows to the checkpoint cache.
+func (w *Window) checkpoint() {
+	state := windowCheckpoint{
+		Windows: w.windows,
+	}
+	if !w.watermark.IsZero() {
+		state.Watermark = w.watermark.UnixNano()
+	}
+	stateBytes, err := json.Marshal(state)
+	if err == nil {
+		err = w.cache.Set(w.checkpointKey, stateBytes)
+	}
+	if err != nil {
+		w.mCheckErr.Incr(1)
+		w.log.Errorf("Failed to checkpoint windows: %v\n", err)
+		return
+	}
+	w.mCheckpoint.Incr(1)
+}
+
+// partTime returns the time of a message part.
+func (w *Window) partTime(msg types.Message, index int) (time.Time, error) {
+	if w.timestamp == nil {
+		return w.now(), nil
+	}
+	tsStr := w.timestamp.Get(message.Lock(msg, index))
+	if f, err := strconv.ParseFloat(tsStr, 64); err == nil {
+		return time.Unix(0, int64(f*float64(time.Second))), nil
+	}
+	ts, err := time.Parse(w.tsFormat, tsStr)
+	if err != nil {
+		return ts, fmt.Errorf("failed to parse timestamp: %v", err)
+	}
+	return ts, nil
+}
+
+// add adds the value of a message part to each open window that it belongs to,
+// returns false if the part does not belong to any open window.
+func (w *Window) add(ts time.Time, key, value string) bool {
+	added := false
+	tsNanos := ts.UnixNano()
+	start := tsNanos - (tsNanos % int64(w.slide))
+	if tsNanos < 0 && tsNanos%int64(w.slide) != 0 {
+		start -= int64(w.slide)
+	}
+	for ; start > tsNanos-int64(w.size); start -= int64(w.slide) {
+		if !w.watermark.IsZero() && start+int64(w.size) <= w.watermark.UnixNano() {
+			// Windows that have already closed are ignored.
+			continue
+		}
+		keys, exists := w.windows[start]
+		if !exists {
+			keys = map[string]*windowAggregate{}
+			w.windows[start] = keys
+		}
+		agg, exists := keys[key]
+		if !exists {
+			agg = &windowAggregate{}
+			keys[key] = agg
+		}
+		agg.add(value)
+		added = true
+	}
+	return added
+}
+
+// closeWindows removes all windows that end before the watermark and returns
+// a summary message for each key within them.
+func (w *Window) closeWindows() []types.Message {
+	var starts []int64
+	for start := range w.windows {
+		if start+int64(w.size) <= w.watermark.UnixNano() {
+			starts = append(starts, start)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i] < starts[j]
+	})
+
+	var msgs []types.Message
+	for _, start := range starts {
+		keys := w.windows[start]
+		delete(w.windows, start)
+
+		sortedKeys := make([]string, 0, len(keys))
+		for k := range keys {
+			sortedKeys = append(sortedKeys, k)
+		}
+		sort.Strings(sortedKeys)
+
+		for _, k := range sortedKeys {
+			summary := windowSummary{
+				Key:             k,
+				WindowStart:     time.Unix(0, start).UTC().Format(time.RFC3339Nano),
+				WindowEnd:       time.Unix(0, start+int64(w.size)).UTC().Format(time.RFC3339Nano),
+				windowAggregate: keys[k],
+			}
+			summaryBytes, err := json.Marshal(summary)
+			if err != nil {
+				w.log.Errorf("Failed to serialise window summary: %v\n", err)
+				w.mErr.Incr(1)
+				continue
+			}
+			msgs = append(msgs, message.New([][]byte{summaryBytes}))
+		}
+	}
+	return msgs
+}
+
+//------------------------------------------------------------------------------
+
+// ProcessMessage applies the processor to a message, either creating >0
+// resulting messages or a response to be sent back to the message source.
+func (w *Window) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	w.mCount.Incr(1)
+
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	msg.Iter(func(i int, p types.Part) error {
+		ts, err := w.partTime(msg, i)
+		if err != nil {
+			w.mErr.Incr(1)
+			w.log.Debugf("Failed to obtain message time: %v\n", err)
+			return nil
+		}
+		lMsg := message.Lock(msg, i)
+		if !w.add(ts, w.key.Get(lMsg), w.value.Get(lMsg)) {
+			w.mLate.Incr(1)
+			w.log.Tracef("Dropping message part with time beyond closed windows: %v\n", ts)
+		}
+		if w.timestamp != nil && ts.After(w.watermark) {
+			w.watermark = ts
+		}
+		return nil
+	})
+	if w.timestamp == nil {
+		w.watermark = w.now()
+	}
+
+	msgs := w.closeWindows()
+	w.mWindows.Set(int64(len(w.windows)))
+	if w.cache != nil {
+		w.checkpoint()
+	}
+
+	if len(msgs) == 0 {
+		w.mDropped.Incr(1)
+		return nil, response.NewAck()
+	}
+
+	w.mBatchSent.Incr(int64(len(msgs)))
+	w.mSent.Incr(int64(len(msgs)))
+	return msgs, nil
+}
+
+// CloseAsync shuts down the processor and stops processing requests.
+func (w *Window) CloseAsync() {
+}
+
+// WaitForClose blocks until the processor has closed down.
+func (w *Window) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,208 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/cache"
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func windowResults(msgs []types.Message) []string {
+	var results []string
+	for _, m := range msgs {
+		m.Iter(func(i int, p types.Part) error {
+			results = append(results, string(p.Get()))
+			return nil
+		})
+	}
+	return results
+}
+
+func TestWindowBadConfig(t *testing.T) {
+	tests := map[string]func(c *WindowConfig){
+		"bad type":      func(c *WindowConfig) { c.Type = "nope" },
+		"bad size":      func(c *WindowConfig) { c.Size = "nope" },
+		"zero size":     func(c *WindowConfig) { c.Size = "0s" },
+		"no slide":      func(c *WindowConfig) { c.Type = "sliding" },
+		"large slide":   func(c *WindowConfig) { c.Type = "sliding"; c.Slide = "1m" },
+		"missing cache": func(c *WindowConfig) { c.Cache = "nope" },
+	}
+	for name, fn := range tests {
+		conf := NewConfig()
+		conf.Type = TypeWindow
+		fn(&conf.Window)
+		if _, err := New(conf, &fakeMgr{}, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("%v: Expected error", name)
+		}
+	}
+}
+
+func TestWindowTumblingProcessingTime(t *testing.T) {
+	conf := NewConfig()
+	conf.Window.Key = "${!json_field:user}"
+	conf.Window.Value = "${!json_field:n}"
+	conf.Window.Size = "10s"
+
+	proc, err := NewWindow(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(100, 0)
+	proc.(*Window).now = func() time.Time { return now }
+
+	msgs, res := proc.ProcessMessage(message.New([][]byte{
+		[]byte(`{"user":"foo","n":3}`),
+		[]byte(`{"user":"bar","n":"nah"}`),
+		[]byte(`{"user":"foo","n":5}`),
+	}))
+	if len(msgs) > 0 {
+		t.Fatalf("Unexpected messages: %s", windowResults(msgs))
+	}
+	if res == nil || res.Error() != nil {
+		t.Fatalf("Expected ack response, received: %v", res)
+	}
+
+	now = time.Unix(109, 0)
+	if msgs, _ = proc.ProcessMessage(message.New([][]byte{
+		[]byte(`{"user":"foo","n":1}`),
+	})); len(msgs) > 0 {
+		t.Fatalf("Unexpected messages: %s", windowResults(msgs))
+	}
+
+	now = time.Unix(110, 0)
+	msgs, res = proc.ProcessMessage(message.New([][]byte{
+		[]byte(`{"user":"foo","n":10}`),
+	}))
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+
+	exp := []string{
+		`{"key":"bar","window_start":"1970-01-01T00:01:40Z","window_end":"1970-01-01T00:01:50Z","count":1,"last":"nah"}`,
+		`{"key":"foo","window_start":"1970-01-01T00:01:40Z","window_end":"1970-01-01T00:01:50Z","count":3,"sum":9,"min":1,"max":5,"last":1}`,
+	}
+	if act := windowResults(msgs); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	if exp, act := 2, len(msgs); exp != act {
+		t.Errorf("Wrong count of summary messages: %v != %v", act, exp)
+	}
+}
+
+func TestWindowSlidingEventTime(t *testing.T) {
+	conf := NewConfig()
+	conf.Window.Type = "sliding"
+	conf.Window.Size = "10s"
+	conf.Window.Slide = "5s"
+	conf.Window.Key = "${!json_field:user}"
+	conf.Window.Value = "${!json_field:n}"
+	conf.Window.Timestamp = "${!json_field:ts}"
+
+	proc, err := NewWindow(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ := proc.ProcessMessage(message.New([][]byte{
+		[]byte(`{"user":"foo","n":1,"ts":"1970-01-01T00:00:01Z"}`),
+		[]byte(`{"user":"foo","n":2,"ts":7}`),
+	}))
+	exp := []string{
+		`{"key":"foo","window_start":"1969-12-31T23:59:55Z","window_end":"1970-01-01T00:00:05Z","count":1,"sum":1,"min":1,"max":1,"last":1}`,
+	}
+	if act := windowResults(msgs); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+
+	msgs, _ = proc.ProcessMessage(message.New([][]byte{
+		[]byte(`{"user":"foo","n":3,"ts":12}`),
+	}))
+	exp = []string{
+		`{"key":"foo","window_start":"1970-01-01T00:00:00Z","window_end":"1970-01-01T00:00:10Z","count":2,"sum":3,"min":1,"max":2,"last":2}`,
+	}
+	if act := windowResults(msgs); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+
+	// A part that only belongs to closed windows is dropped.
+	msgs, _ = proc.ProcessMessage(message.New([][]byte{
+		[]byte(`{"user":"foo","n":4,"ts":3}`),
+		[]byte(`{"user":"foo","n":5,"ts":20}`),
+	}))
+	exp = []string{
+		`{"key":"foo","window_start":"1970-01-01T00:00:05Z","window_end":"1970-01-01T00:00:15Z","count":2,"sum":5,"min":2,"max":3,"last":3}`,
+		`{"key":"foo","window_start":"1970-01-01T00:00:10Z","window_end":"1970-01-01T00:00:20Z","count":1,"sum":3,"min":3,"max":3,"last":3}`,
+	}
+	if act := windowResults(msgs); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+}
+
+func TestWindowCheckpoint(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": memCache,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Window.Key = "${!json_field:user}"
+	conf.Window.Value = "${!json_field:n}"
+	conf.Window.Timestamp = "${!json_field:ts}"
+	conf.Window.Cache = "foocache"
+
+	proc, err := NewWindow(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgs, _ := proc.ProcessMessage(message.New([][]byte{
+		[]byte(`{"user":"foo","n":1,"ts":1}`),
+		[]byte(`{"user":"foo","n":2,"ts":2}`),
+	})); len(msgs) > 0 {
+		t.Fatalf("Unexpected messages: %s", windowResults(msgs))
+	}
+
+	// Simulate a restart by creating a new processor from the same cache.
+	proc, err = NewWindow(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, _ := proc.ProcessMessage(message.New([][]byte{
+		[]byte(`{"user":"foo","n":3,"ts":11}`),
+	}))
+	exp := []string{
+		`{"key":"foo","window_start":"1970-01-01T00:00:00Z","window_end":"1970-01-01T00:00:10Z","count":2,"sum":3,"min":1,"max":2,"last":2}`,
+	}
+	if act := windowResults(msgs); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+}
@@ -45,26 +45,37 @@ type TypeSpec struct {
 // Constructors is a map of all cache types with their specs.
 var Constructors = map[string]TypeSpec{}
 
+// Description returns a markdown formatted description of the rate limit type.
+func (t TypeSpec) Description() string {
+	return t.description
+}
+
 //------------------------------------------------------------------------------
 
 // String constants representing each ratelimit type.
 const (
-	TypeLocal = "local"
+	TypeLocal       = "local"
+	TypeRedis       = "redis"
+	TypeTokenBucket = "token_bucket"
 )
 
 //------------------------------------------------------------------------------
 
 // Config is the all encompassing configuration struct for all cache types.
 type Config struct {
-	Type  string      `json:"type" yaml:"type"`
-	Local LocalConfig `json:"local" yaml:"local"`
+	Type        string            `json:"type" yaml:"type"`
+	Local       LocalConfig       `json:"local" yaml:"local"`
+	Redis       RedisConfig       `json:"redis" yaml:"redis"`
+	TokenBucket TokenBucketConfig `json:"token_bucket" yaml:"token_bucket"`
 }
 
 // NewConfig returns a configuration struct fully populated with default values.
 func NewConfig() Config {
 	return Config{
-		Type:  "local",
-		Local: NewLocalConfig(),
+		Type:        "local",
+		Local:       NewLocalConfig(),
+		Redis:       NewRedisConfig(),
+		TokenBucket: NewTokenBucketConfig(),
 	}
 }
 
@@ -0,0 +1,204 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/go-redis/redis"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeRedis] = TypeSpec{
+		constructor: NewRedis,
+		description: `
+The redis rate limit stores its state in a Redis instance, allowing a rate
+limit of ` + "`count`" + ` accesses every ` + "`interval`" + ` to be shared
+across any number of Benthos instances that target the same ` + "`key`" + `.
+
+Accesses are limited with the generic cell rate algorithm (GCRA), which spreads
+accesses evenly across the interval whilst allowing bursts of up to
+` + "`burst`" + ` accesses. A burst of zero defaults to the value of
+` + "`count`" + `. The algorithm is executed atomically within Redis as a
+script and uses the clock of the Redis server, and therefore the clocks of
+Benthos instances do not need to be synchronised.
+
+If Redis cannot be reached then access is denied and the error is returned to
+the component using the rate limit.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// RedisConfig is a config struct containing rate limit fields for a redis
+// rate limit.
+type RedisConfig struct {
+	URL      string `json:"url" yaml:"url"`
+	Key      string `json:"key" yaml:"key"`
+	Count    int    `json:"count" yaml:"count"`
+	Interval string `json:"interval" yaml:"interval"`
+	Burst    int    `json:"burst" yaml:"burst"`
+}
+
+// NewRedisConfig returns a redis rate limit configuration struct with default
+// values.
+func NewRedisConfig() RedisConfig {
+	return RedisConfig{
+		URL:      "tcp://localhost:6379",
+		Key:      "benthos_rate_limit",
+		Count:    1000,
+		Interval: "1s",
+		Burst:    0,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// gcraScript implements the generic cell rate algorithm, where the key holds
+// the theoretical arrival time (TAT) of the next access in microseconds. An
+// access is permitted when the TAT is within the burst tolerance of the current
+// time, in which case the TAT is advanced by the emission interval. Otherwise
+// the number of microseconds to wait is returned.
+var gcraScript = redis.NewScript(`
+redis.replicate_commands()
+local emission = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+
+local allow_at = tat + emission - tolerance
+if allow_at > now then
+	return math.ceil(allow_at - now)
+end
+
+local new_tat = tat + emission
+redis.call("SET", KEYS[1], string.format("%.0f", new_tat), "PX", math.ceil((new_tat - now) / 1000) + 1)
+return 0
+`)
+
+// Redis is a rate limit that stores its state in a Redis instance and can
+// therefore be shared across multiple Benthos instances.
+type Redis struct {
+	client *redis.Client
+	key    string
+
+	emission  int64 // Microseconds between accesses.
+	tolerance int64 // Microseconds of burst tolerance.
+
+	mAccess  metrics.StatCounter
+	mLimited metrics.StatCounter
+	mErr     metrics.StatCounter
+	log      log.Modular
+}
+
+// NewRedis creates a redis rate limit from a configuration struct. This type
+// is safe to share and call from parallel goroutines.
+func NewRedis(
+	conf Config,
+	mgr types.Manager,
+	logger log.Modular,
+	stats metrics.Type,
+) (types.RateLimit, error) {
+	if conf.Redis.Count <= 0 {
+		return nil, errors.New("count must be larger than zero")
+	}
+	if conf.Redis.Burst < 0 {
+		return nil, errors.New("burst must not be negative")
+	}
+	if len(conf.Redis.Key) == 0 {
+		return nil, errors.New("a key must be specified")
+	}
+	period, err := time.ParseDuration(conf.Redis.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse interval: %v", err)
+	}
+	emission := int64(period/time.Microsecond) / int64(conf.Redis.Count)
+	if emission <= 0 {
+		return nil, errors.New("interval divided by count must be at least one microsecond")
+	}
+	burst := conf.Redis.Burst
+	if burst == 0 {
+		burst = conf.Redis.Count
+	}
+
+	url, err := url.Parse(conf.Redis.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	var pass string
+	if url.User != nil {
+		pass, _ = url.User.Password()
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     url.Host,
+		Network:  url.Scheme,
+		Password: pass,
+	})
+
+	return &Redis{
+		client:    client,
+		key:       conf.Redis.Key,
+		emission:  emission,
+		tolerance: emission * int64(burst),
+		mAccess:   stats.GetCounter("access"),
+		mLimited:  stats.GetCounter("limited"),
+		mErr:      stats.GetCounter("error"),
+		log:       logger,
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Access the rate limited resource. Returns a duration or an error if the rate
+// limit check fails. The returned duration is either zero (meaning the resource
+// can be accessed) or a reasonable length of time to wait before requesting
+// again.
+func (r *Redis) Access() (time.Duration, error) {
+	r.mAccess.Incr(1)
+	wait, err := gcraScript.Run(r.client, []string{r.key}, r.emission, r.tolerance).Int64()
+	if err != nil {
+		r.mErr.Incr(1)
+		r.log.Errorf("Failed to access rate limit: %v\n", err)
+		return 0, err
+	}
+	if wait > 0 {
+		r.mLimited.Incr(1)
+		return time.Duration(wait) * time.Microsecond, nil
+	}
+	return 0, nil
+}
+
+//------------------------------------------------------------------------------
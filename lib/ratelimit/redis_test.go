@@ -0,0 +1,149 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ratelimit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/ory/dockertest"
+)
+
+//------------------------------------------------------------------------------
+
+func TestRedisRateLimitConfErrors(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeRedis
+	conf.Redis.Count = -1
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("expected error from bad count")
+	}
+
+	conf = NewConfig()
+	conf.Type = TypeRedis
+	conf.Redis.Interval = "nope"
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("expected error from bad interval")
+	}
+
+	conf = NewConfig()
+	conf.Type = TypeRedis
+	conf.Redis.Key = ""
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("expected error from empty key")
+	}
+}
+
+func TestRedisRateLimitIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		t.Skipf("Could not connect to docker: %s", err)
+	}
+
+	resource, err := pool.Run("redis", "latest", nil)
+	if err != nil {
+		t.Fatalf("Could not start resource: %s", err)
+	}
+
+	url := fmt.Sprintf("tcp://localhost:%v", resource.GetPort("6379/tcp"))
+
+	if err = pool.Retry(func() error {
+		conf := NewConfig()
+		conf.Type = TypeRedis
+		conf.Redis.URL = url
+		conf.Redis.Key = "benthos_test_redis_connect"
+
+		r, cErr := New(conf, nil, log.Noop(), metrics.Noop())
+		if cErr != nil {
+			return cErr
+		}
+		_, cErr = r.Access()
+		return cErr
+	}); err != nil {
+		t.Fatalf("Could not connect to docker resource: %s", err)
+	}
+
+	defer func() {
+		if err = pool.Purge(resource); err != nil {
+			t.Logf("Failed to clean up docker resource: %v", err)
+		}
+	}()
+
+	t.Run("TestRedisRateLimitShared", func(te *testing.T) {
+		testRedisRateLimitShared(url, te)
+	})
+}
+
+func testRedisRateLimitShared(url string, t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeRedis
+	conf.Redis.URL = url
+	conf.Redis.Key = "benthos_test_redis_shared"
+	conf.Redis.Count = 10
+	conf.Redis.Interval = "10s"
+
+	// Two rate limits targeting the same key share a single budget.
+	rlOne, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rlTwo, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < conf.Redis.Count; i++ {
+		rl := rlOne
+		if i%2 == 0 {
+			rl = rlTwo
+		}
+		period, err := rl.Access()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if period > 0 {
+			t.Errorf("Period above zero: %v", period)
+		}
+	}
+
+	for _, rl := range []interface {
+		Access() (time.Duration, error)
+	}{rlOne, rlTwo} {
+		period, err := rl.Access()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if period == 0 {
+			t.Error("Expected limit beyond burst")
+		} else if period > time.Second {
+			t.Errorf("Period beyond emission interval: %v", period)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,147 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeTokenBucket] = TypeSpec{
+		constructor: NewTokenBucket,
+		description: `
+The token bucket rate limit refills a bucket with ` + "`count`" + ` tokens
+every ` + "`interval`" + `, at an even rate, and each access consumes a token.
+The bucket holds at most ` + "`burst`" + ` tokens, which allows short bursts of
+accesses above the average rate after a period of inactivity. A burst of zero
+defaults to the value of ` + "`count`" + `.
+
+Unlike the ` + "`local`" + ` rate limit, which resets all of its tokens at the
+end of each interval, accesses are spread evenly across the interval. It can be
+shared across any number of components within the pipeline.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// TokenBucketConfig is a config struct containing rate limit fields for a
+// token bucket rate limit.
+type TokenBucketConfig struct {
+	Count    int    `json:"count" yaml:"count"`
+	Interval string `json:"interval" yaml:"interval"`
+	Burst    int    `json:"burst" yaml:"burst"`
+}
+
+// NewTokenBucketConfig returns a token bucket rate limit configuration struct
+// with default values.
+func NewTokenBucketConfig() TokenBucketConfig {
+	return TokenBucketConfig{
+		Count:    1000,
+		Interval: "1s",
+		Burst:    0,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// TokenBucket is a rate limit that refills a bucket of tokens at an even rate
+// up to a burst capacity.
+type TokenBucket struct {
+	mut        sync.Mutex
+	tokens     float64
+	lastRefill time.Time
+
+	burst float64
+	rate  float64 // Tokens per nanosecond.
+
+	now func() time.Time
+}
+
+// NewTokenBucket creates a token bucket rate limit from a configuration struct.
+// This type is safe to share and call from parallel goroutines.
+func NewTokenBucket(
+	conf Config,
+	mgr types.Manager,
+	logger log.Modular,
+	stats metrics.Type,
+) (types.RateLimit, error) {
+	if conf.TokenBucket.Count <= 0 {
+		return nil, errors.New("count must be larger than zero")
+	}
+	if conf.TokenBucket.Burst < 0 {
+		return nil, errors.New("burst must not be negative")
+	}
+	period, err := time.ParseDuration(conf.TokenBucket.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse interval: %v", err)
+	}
+	if period <= 0 {
+		return nil, errors.New("interval must be larger than zero")
+	}
+	burst := conf.TokenBucket.Burst
+	if burst == 0 {
+		burst = conf.TokenBucket.Count
+	}
+	return &TokenBucket{
+		tokens:     float64(burst),
+		lastRefill: time.Now(),
+		burst:      float64(burst),
+		rate:       float64(conf.TokenBucket.Count) / float64(period),
+		now:        time.Now,
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Access the rate limited resource. Returns a duration or an error if the rate
+// limit check fails. The returned duration is either zero (meaning the resource
+// can be accessed) or a reasonable length of time to wait before requesting
+// again.
+func (r *TokenBucket) Access() (time.Duration, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	now := r.now()
+	if elapsed := now.Sub(r.lastRefill); elapsed > 0 {
+		r.tokens += float64(elapsed) * r.rate
+		if r.tokens > r.burst {
+			r.tokens = r.burst
+		}
+	}
+	r.lastRefill = now
+
+	if r.tokens >= 1 {
+		r.tokens--
+		return 0, nil
+	}
+	return time.Duration((1-r.tokens)/r.rate) + 1, nil
+}
+
+//------------------------------------------------------------------------------
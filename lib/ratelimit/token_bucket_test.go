@@ -0,0 +1,129 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+)
+
+//------------------------------------------------------------------------------
+
+func TestTokenBucketRateLimitConfErrors(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeTokenBucket
+	conf.TokenBucket.Count = -1
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("expected error from bad count")
+	}
+
+	conf = NewConfig()
+	conf.Type = TypeTokenBucket
+	conf.TokenBucket.Burst = -1
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("expected error from bad burst")
+	}
+
+	conf = NewConfig()
+	conf.Type = TypeTokenBucket
+	conf.TokenBucket.Interval = "nope"
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("expected error from bad interval")
+	}
+}
+
+func TestTokenBucketRateLimitBurst(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeTokenBucket
+	conf.TokenBucket.Count = 10
+	conf.TokenBucket.Interval = "1s"
+	conf.TokenBucket.Burst = 5
+
+	rl, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	rl.(*TokenBucket).now = func() time.Time { return now }
+
+	for i := 0; i < conf.TokenBucket.Burst; i++ {
+		if period, _ := rl.Access(); period > 0 {
+			t.Errorf("Period above zero: %v", period)
+		}
+	}
+
+	period, _ := rl.Access()
+	if period <= 0 {
+		t.Fatal("Expected limit beyond burst")
+	}
+	if period > 100*time.Millisecond+time.Microsecond {
+		t.Errorf("Period beyond refill of a single token: %v", period)
+	}
+
+	// After the wait period a single token is available.
+	now = now.Add(period)
+	if period, _ = rl.Access(); period > 0 {
+		t.Errorf("Period above zero: %v", period)
+	}
+	if period, _ = rl.Access(); period == 0 {
+		t.Error("Expected limit on following request")
+	}
+
+	// After a long period of inactivity the bucket only refills up to the
+	// burst.
+	now = now.Add(time.Hour)
+	for i := 0; i < conf.TokenBucket.Burst; i++ {
+		if period, _ := rl.Access(); period > 0 {
+			t.Errorf("Period above zero: %v", period)
+		}
+	}
+	if period, _ = rl.Access(); period == 0 {
+		t.Error("Expected limit beyond burst")
+	}
+}
+
+func TestTokenBucketRateLimitDefaultBurst(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeTokenBucket
+	conf.TokenBucket.Count = 10
+	conf.TokenBucket.Interval = "1s"
+
+	rl, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	rl.(*TokenBucket).now = func() time.Time { return now }
+
+	for i := 0; i < conf.TokenBucket.Count; i++ {
+		if period, _ := rl.Access(); period > 0 {
+			t.Errorf("Period above zero: %v", period)
+		}
+	}
+	if period, _ := rl.Access(); period == 0 {
+		t.Error("Expected limit on final request")
+	}
+}
+
+//------------------------------------------------------------------------------
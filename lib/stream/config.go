@@ -30,12 +30,13 @@ import (
 //------------------------------------------------------------------------------
 
 // Config is a configuration struct representing all four layers of a Benthos
-// stream.
+// stream, along with an optional dead letter output.
 type Config struct {
-	Input    input.Config    `json:"input" yaml:"input"`
-	Buffer   buffer.Config   `json:"buffer" yaml:"buffer"`
-	Pipeline pipeline.Config `json:"pipeline" yaml:"pipeline"`
-	Output   output.Config   `json:"output" yaml:"output"`
+	Input      input.Config    `json:"input" yaml:"input"`
+	Buffer     buffer.Config   `json:"buffer" yaml:"buffer"`
+	Pipeline   pipeline.Config `json:"pipeline" yaml:"pipeline"`
+	Output     output.Config   `json:"output" yaml:"output"`
+	DeadLetter *output.Config  `json:"dead_letter,omitempty" yaml:"dead_letter,omitempty"`
 }
 
 // NewConfig returns a new configuration with default values.
@@ -75,16 +76,25 @@ func (c Config) Sanitised() (interface{}, error) {
 		return nil, err
 	}
 
+	var deadLetterConf interface{}
+	if c.DeadLetter != nil {
+		if deadLetterConf, err = output.SanitiseConfig(*c.DeadLetter); err != nil {
+			return nil, err
+		}
+	}
+
 	return struct {
-		Input    interface{} `json:"input" yaml:"input"`
-		Buffer   interface{} `json:"buffer" yaml:"buffer"`
-		Pipeline interface{} `json:"pipeline" yaml:"pipeline"`
-		Output   interface{} `json:"output" yaml:"output"`
+		Input      interface{} `json:"input" yaml:"input"`
+		Buffer     interface{} `json:"buffer" yaml:"buffer"`
+		Pipeline   interface{} `json:"pipeline" yaml:"pipeline"`
+		Output     interface{} `json:"output" yaml:"output"`
+		DeadLetter interface{} `json:"dead_letter,omitempty" yaml:"dead_letter,omitempty"`
 	}{
-		Input:    inConf,
-		Buffer:   bufConf,
-		Pipeline: pipeConf,
-		Output:   outConf,
+		Input:      inConf,
+		Buffer:     bufConf,
+		Pipeline:   pipeConf,
+		Output:     outConf,
+		DeadLetter: deadLetterConf,
 	}, nil
 }
 
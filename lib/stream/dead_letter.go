@@ -0,0 +1,260 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package stream
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/processor"
+	"github.com/Jeffail/benthos/lib/response"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/throttle"
+)
+
+//------------------------------------------------------------------------------
+
+// Metadata keys added to message parts that are routed to a dead letter output.
+const (
+	DeadLetterReasonKey    = "dead_letter_reason"
+	DeadLetterProcessorKey = "dead_letter_processor"
+	DeadLetterTimestampKey = "dead_letter_timestamp"
+)
+
+//------------------------------------------------------------------------------
+
+// failureTaggingManager wraps a manager in order to signal to the processors of
+// a stream with a dead letter output that failed parts should be tagged.
+type failureTaggingManager struct {
+	types.Manager
+}
+
+// TagFailures returns true.
+func (f failureTaggingManager) TagFailures() bool {
+	return true
+}
+
+//------------------------------------------------------------------------------
+
+// deadLetterRouter consumes transactions and separates message parts that are
+// flagged as having failed processing from those that succeeded. Failed parts
+// are sent to a dead letter channel and the remaining parts continue to the
+// main channel.
+type deadLetterRouter struct {
+	running int32
+
+	log   log.Modular
+	stats metrics.Type
+
+	mainOut       chan types.Transaction
+	deadLetterOut chan types.Transaction
+
+	transactionsIn <-chan types.Transaction
+
+	mRouted metrics.StatCounter
+
+	closeChan chan struct{}
+	closed    chan struct{}
+}
+
+// newDeadLetterRouter creates a new dead letter router.
+func newDeadLetterRouter(log log.Modular, stats metrics.Type) *deadLetterRouter {
+	return &deadLetterRouter{
+		running:       1,
+		log:           log,
+		stats:         stats,
+		mainOut:       make(chan types.Transaction),
+		deadLetterOut: make(chan types.Transaction),
+		mRouted:       stats.GetCounter("dead_letter.routed"),
+		closeChan:     make(chan struct{}),
+		closed:        make(chan struct{}),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// markDeadLetter adds the dead letter metadata fields to a failed part.
+func markDeadLetter(part types.Part, now time.Time) {
+	meta := part.Metadata()
+	meta.Set(DeadLetterReasonKey, meta.Get(processor.FailFlagKey))
+	meta.Set(DeadLetterProcessorKey, processor.FailedProcessor(part))
+	meta.Set(DeadLetterTimestampKey, now.Format(time.RFC3339))
+}
+
+// loop is the routing loop of the dead letter router.
+func (d *deadLetterRouter) loop() {
+	defer func() {
+		close(d.mainOut)
+		close(d.deadLetterOut)
+		close(d.closed)
+	}()
+
+	var open bool
+	for atomic.LoadInt32(&d.running) == 1 {
+		var tran types.Transaction
+		select {
+		case tran, open = <-d.transactionsIn:
+			if !open {
+				return
+			}
+		case <-d.closeChan:
+			return
+		}
+
+		now := time.Now()
+		mainMsg, deadMsg := message.New(nil), message.New(nil)
+		tran.Payload.Iter(func(i int, p types.Part) error {
+			if processor.HasFailed(p) {
+				markDeadLetter(p, now)
+				deadMsg.Append(p)
+			} else {
+				mainMsg.Append(p)
+			}
+			return nil
+		})
+		d.mRouted.Incr(int64(deadMsg.Len()))
+
+		if deadMsg.Len() == 0 {
+			select {
+			case d.mainOut <- tran:
+			case <-d.closeChan:
+				return
+			}
+			continue
+		}
+		if mainMsg.Len() == 0 {
+			select {
+			case d.deadLetterOut <- tran:
+			case <-d.closeChan:
+				return
+			}
+			continue
+		}
+		d.dispatchSplit(mainMsg, deadMsg, tran.ResponseChan)
+	}
+}
+
+// dispatchSplit sends the successful and failed parts of a message to their
+// respective outputs in parallel, retrying each until success, and then
+// acknowledges the original transaction. If either part could not be delivered
+// then an error is returned to the source instead so that the message is not
+// lost.
+func (d *deadLetterRouter) dispatchSplit(mainMsg, deadMsg types.Message, ogResChan chan<- types.Response) {
+	sendMsg := func(outChan chan<- types.Transaction, m types.Message) error {
+		throt := throttle.New(throttle.OptCloseChan(d.closeChan))
+		resChan := make(chan types.Response)
+		for {
+			select {
+			case outChan <- types.NewTransaction(m, resChan):
+			case <-d.closeChan:
+				return types.ErrTypeClosed
+			}
+
+			var res types.Response
+			var open bool
+			select {
+			case res, open = <-resChan:
+				if !open {
+					return types.ErrTypeClosed
+				}
+			case <-d.closeChan:
+				return types.ErrTypeClosed
+			}
+			if res.Error() == nil || res.SkipAck() {
+				return nil
+			}
+			if !throt.Retry() {
+				return res.Error()
+			}
+		}
+	}
+
+	var mainErr, deadErr error
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		mainErr = sendMsg(d.mainOut, mainMsg)
+	}()
+	go func() {
+		defer wg.Done()
+		deadErr = sendMsg(d.deadLetterOut, deadMsg)
+	}()
+	wg.Wait()
+
+	var res types.Response = response.NewAck()
+	if mainErr != nil {
+		res = response.NewError(mainErr)
+	} else if deadErr != nil {
+		res = response.NewError(deadErr)
+	}
+	select {
+	case ogResChan <- res:
+	case <-d.closeChan:
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Consume assigns a transactions channel for the router to read.
+func (d *deadLetterRouter) Consume(msgs <-chan types.Transaction) error {
+	if d.transactionsIn != nil {
+		return types.ErrAlreadyStarted
+	}
+	d.transactionsIn = msgs
+	go d.loop()
+	return nil
+}
+
+// TransactionChan returns the channel of transactions containing successfully
+// processed message parts.
+func (d *deadLetterRouter) TransactionChan() <-chan types.Transaction {
+	return d.mainOut
+}
+
+// DeadLetterChan returns the channel of transactions containing message parts
+// that failed processing.
+func (d *deadLetterRouter) DeadLetterChan() <-chan types.Transaction {
+	return d.deadLetterOut
+}
+
+// CloseAsync shuts down the router and stops routing transactions.
+func (d *deadLetterRouter) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&d.running, 1, 0) {
+		close(d.closeChan)
+	}
+}
+
+// WaitForClose blocks until the router has closed down.
+func (d *deadLetterRouter) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-d.closed:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
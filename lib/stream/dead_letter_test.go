@@ -0,0 +1,234 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package stream
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/processor"
+	"github.com/Jeffail/benthos/lib/response"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func TestDeadLetterRouterSplit(t *testing.T) {
+	router := newDeadLetterRouter(log.Noop(), metrics.Noop())
+
+	tChan := make(chan types.Transaction)
+	if err := router.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{
+		[]byte("foo"),
+		[]byte("bar"),
+		[]byte("baz"),
+	})
+	processor.FlagErr(msg.Get(1), errors.New("it broke"))
+	msg.Get(1).Metadata().Set(processor.FailedProcessorKey, "jmespath")
+
+	resChan := make(chan types.Response)
+	select {
+	case tChan <- types.NewTransaction(msg, resChan):
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	var mainTran, deadTran types.Transaction
+	for mainTran.Payload == nil || deadTran.Payload == nil {
+		select {
+		case mainTran = <-router.TransactionChan():
+		case deadTran = <-router.DeadLetterChan():
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	if exp, act := [][]byte{[]byte("foo"), []byte("baz")}, message.GetAllBytes(mainTran.Payload); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong main message: %s != %s", act, exp)
+	}
+	if exp, act := [][]byte{[]byte("bar")}, message.GetAllBytes(deadTran.Payload); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong dead letter message: %s != %s", act, exp)
+	}
+
+	part := deadTran.Payload.Get(0)
+	if exp, act := "it broke", part.Metadata().Get(DeadLetterReasonKey); exp != act {
+		t.Errorf("Wrong reason: %v != %v", act, exp)
+	}
+	if exp, act := "jmespath", part.Metadata().Get(DeadLetterProcessorKey); exp != act {
+		t.Errorf("Wrong processor: %v != %v", act, exp)
+	}
+	if _, err := time.Parse(time.RFC3339, part.Metadata().Get(DeadLetterTimestampKey)); err != nil {
+		t.Errorf("Bad timestamp: %v", err)
+	}
+
+	// A failed dead letter write should be retried rather than returned.
+	select {
+	case deadTran.ResponseChan <- response.NewError(errors.New("nope")):
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	select {
+	case deadTran = <-router.DeadLetterChan():
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	for _, tran := range []types.Transaction{mainTran, deadTran} {
+		select {
+		case tran.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	select {
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	close(tChan)
+	if err := router.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeadLetterRouterSplitClosed(t *testing.T) {
+	router := newDeadLetterRouter(log.Noop(), metrics.Noop())
+
+	tChan := make(chan types.Transaction)
+	if err := router.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{
+		[]byte("foo"),
+		[]byte("bar"),
+	})
+	processor.FlagFail(msg.Get(1))
+
+	resChan := make(chan types.Response, 1)
+	select {
+	case tChan <- types.NewTransaction(msg, resChan):
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	var mainTran types.Transaction
+	select {
+	case mainTran = <-router.TransactionChan():
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	select {
+	case <-router.DeadLetterChan():
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	select {
+	case mainTran.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	// The dead letter output never resolves its transaction, closing the
+	// router must not acknowledge the original message.
+	router.CloseAsync()
+	if err := router.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+	select {
+	case res := <-resChan:
+		if res.Error() == nil {
+			t.Error("Expected error response for undelivered dead letter")
+		}
+	default:
+	}
+}
+
+func TestDeadLetterRouterPassthrough(t *testing.T) {
+	router := newDeadLetterRouter(log.Noop(), metrics.Noop())
+
+	tChan := make(chan types.Transaction)
+	if err := router.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	resChan := make(chan types.Response)
+
+	okMsg := message.New([][]byte{[]byte("foo")})
+	select {
+	case tChan <- types.NewTransaction(okMsg, resChan):
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	select {
+	case tran := <-router.TransactionChan():
+		if tran.Payload != okMsg {
+			t.Error("Unexpected message")
+		}
+		if tran.ResponseChan != resChan {
+			t.Error("Unexpected response channel")
+		}
+	case <-router.DeadLetterChan():
+		t.Fatal("Unexpected dead letter")
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	failedMsg := message.New([][]byte{[]byte("bar")})
+	processor.FlagFail(failedMsg.Get(0))
+	select {
+	case tChan <- types.NewTransaction(failedMsg, resChan):
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	select {
+	case tran := <-router.DeadLetterChan():
+		if tran.ResponseChan != resChan {
+			t.Error("Unexpected response channel")
+		}
+		if exp, act := "true", tran.Payload.Get(0).Metadata().Get(DeadLetterReasonKey); exp != act {
+			t.Errorf("Wrong reason: %v != %v", act, exp)
+		}
+	case <-router.TransactionChan():
+		t.Fatal("Unexpected main message")
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	router.CloseAsync()
+	if err := router.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,137 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package stream
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// Stages of a stream being drained, reported by DrainStatus.
+const (
+	DrainStageInput      = "input"
+	DrainStageBuffer     = "buffer"
+	DrainStagePipeline   = "pipeline"
+	DrainStageOutput     = "output"
+	DrainStageDeadLetter = "dead_letter"
+	DrainStageForced     = "forced"
+	DrainStageDone       = "done"
+)
+
+// DrainStatus describes the progress of a stream that is shutting down. Layers
+// of the stream are drained in order, and the stage indicates the layer that is
+// currently being waited on. If the stream fails to drain within the allocated
+// time the stage becomes DrainStageForced, at which point remaining in-flight
+// messages may be abandoned.
+type DrainStatus struct {
+	Stage    string
+	Started  time.Time
+	Finished time.Time
+}
+
+// Elapsed returns the time spent draining the stream, which stops increasing
+// once the stream has finished shutting down.
+func (d DrainStatus) Elapsed() time.Duration {
+	if !d.Finished.IsZero() {
+		return d.Finished.Sub(d.Started)
+	}
+	return time.Since(d.Started)
+}
+
+// drainTracker records the drain progress of a stream.
+type drainTracker struct {
+	status   DrainStatus
+	draining bool
+	mut      sync.Mutex
+}
+
+func (d *drainTracker) setStage(stage string) {
+	d.mut.Lock()
+	if !d.draining {
+		d.draining = true
+		d.status.Started = time.Now()
+	}
+	d.status.Stage = stage
+	d.mut.Unlock()
+}
+
+func (d *drainTracker) finish(stage string) {
+	d.mut.Lock()
+	d.status.Stage = stage
+	d.status.Finished = time.Now()
+	d.mut.Unlock()
+}
+
+func (d *drainTracker) get() (DrainStatus, bool) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	return d.status, d.draining
+}
+
+//------------------------------------------------------------------------------
+
+// drainOrClose triggers an input to stop consuming new data, falling back to a
+// regular close if the input is unable to drain.
+func drainOrClose(in types.Closable) {
+	if d, ok := in.(types.Drainer); ok {
+		d.DrainAsync()
+		return
+	}
+	in.CloseAsync()
+}
+
+// Draining returns the drain progress of the stream, and a boolean indicating
+// whether the stream has begun shutting down.
+func (t *Type) Draining() (DrainStatus, bool) {
+	return t.drain.get()
+}
+
+func (t *Type) handleDrain(w http.ResponseWriter, r *http.Request) {
+	type drainInfo struct {
+		Draining   bool    `json:"draining"`
+		Stage      string  `json:"stage,omitempty"`
+		Elapsed    float64 `json:"elapsed,omitempty"`
+		ElapsedStr string  `json:"elapsed_str,omitempty"`
+	}
+	var info drainInfo
+	if status, draining := t.Draining(); draining {
+		info = drainInfo{
+			Draining:   true,
+			Stage:      status.Stage,
+			Elapsed:    status.Elapsed().Seconds(),
+			ElapsedStr: status.Elapsed().String(),
+		}
+	}
+	resBytes, err := json.Marshal(info)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Write(resBytes)
+}
+
+//------------------------------------------------------------------------------
@@ -35,6 +35,7 @@ import (
 	"github.com/Jeffail/benthos/lib/output"
 	"github.com/Jeffail/benthos/lib/pipeline"
 	"github.com/Jeffail/benthos/lib/stream"
+	"github.com/Jeffail/benthos/lib/util/text"
 	"github.com/Jeffail/gabs"
 	"github.com/gorilla/mux"
 	yaml "gopkg.in/yaml.v2"
@@ -42,6 +43,27 @@ import (
 
 //------------------------------------------------------------------------------
 
+// drainInfo describes the drain progress of a stream that is shutting down.
+type drainInfo struct {
+	Stage      string  `json:"stage"`
+	Elapsed    float64 `json:"elapsed"`
+	ElapsedStr string  `json:"elapsed_str"`
+}
+
+// newDrainInfo returns the drain progress of a stream, or nil if the stream
+// has not begun shutting down.
+func newDrainInfo(info *StreamStatus) *drainInfo {
+	status, draining := info.Draining()
+	if !draining {
+		return nil
+	}
+	return &drainInfo{
+		Stage:      status.Stage,
+		Elapsed:    status.Elapsed().Seconds(),
+		ElapsedStr: status.Elapsed().String(),
+	}
+}
+
 func (m *Type) registerEndpoints() {
 	m.manager.RegisterEndpoint(
 		"/streams",
@@ -84,9 +106,10 @@ func (m *Type) HandleStreamsCRUD(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	type confInfo struct {
-		Active    bool    `json:"active"`
-		Uptime    float64 `json:"uptime"`
-		UptimeStr string  `json:"uptime_str"`
+		Active    bool       `json:"active"`
+		Uptime    float64    `json:"uptime"`
+		UptimeStr string     `json:"uptime_str"`
+		Draining  *drainInfo `json:"draining,omitempty"`
 	}
 	infos := map[string]confInfo{}
 
@@ -96,6 +119,7 @@ func (m *Type) HandleStreamsCRUD(w http.ResponseWriter, r *http.Request) {
 			Active:    strInfo.IsRunning(),
 			Uptime:    strInfo.Uptime().Seconds(),
 			UptimeStr: strInfo.Uptime().String(),
+			Draining:  newDrainInfo(strInfo),
 		}
 	}
 	m.lock.Unlock()
@@ -268,6 +292,32 @@ func (m *Type) HandleStreamCRUD(w http.ResponseWriter, r *http.Request) {
 			Pipeline: pipeline.Config(aliasedConf.Pipeline),
 			Output:   output.Config(aliasedConf.Output),
 		}
+
+		// A dead letter output that doesn't yet exist is parsed with defaults,
+		// otherwise the patch is applied over the existing config.
+		if confIn.DeadLetter == nil {
+			deadLetterConf := struct {
+				DeadLetter *output.Config `json:"dead_letter"`
+			}{}
+			if err = json.Unmarshal(patchBytes, &deadLetterConf); err != nil {
+				return
+			}
+			confOut.DeadLetter = deadLetterConf.DeadLetter
+		} else {
+			aliasedDeadLetter := aliasedOut(*confIn.DeadLetter)
+			deadLetterConf := struct {
+				DeadLetter *aliasedOut `json:"dead_letter"`
+			}{
+				DeadLetter: &aliasedDeadLetter,
+			}
+			if err = json.Unmarshal(patchBytes, &deadLetterConf); err != nil {
+				return
+			}
+			if deadLetterConf.DeadLetter != nil {
+				deadLetter := output.Config(*deadLetterConf.DeadLetter)
+				confOut.DeadLetter = &deadLetter
+			}
+		}
 		return
 	}
 
@@ -287,17 +337,20 @@ func (m *Type) HandleStreamCRUD(w http.ResponseWriter, r *http.Request) {
 		var info *StreamStatus
 		if info, serverErr = m.Read(id); serverErr == nil {
 			sanit, _ := info.Config().Sanitised()
+			sanit = text.RedactSecrets(sanit)
 
 			var bodyBytes []byte
 			if bodyBytes, serverErr = json.Marshal(struct {
 				Active    bool        `json:"active"`
 				Uptime    float64     `json:"uptime"`
 				UptimeStr string      `json:"uptime_str"`
+				Draining  *drainInfo  `json:"draining,omitempty"`
 				Config    interface{} `json:"config"`
 			}{
 				Active:    info.IsRunning(),
 				Uptime:    info.Uptime().Seconds(),
 				UptimeStr: info.Uptime().String(),
+				Draining:  newDrainInfo(info),
 				Config:    sanit,
 			}); serverErr != nil {
 				return
@@ -318,7 +371,9 @@ func (m *Type) HandleStreamCRUD(w http.ResponseWriter, r *http.Request) {
 			if conf, requestErr = patchConfig(info.Config()); requestErr != nil {
 				return
 			}
-			serverErr = m.Update(id, conf, time.Until(deadline))
+			// Patches of ephemeral streams are derived from configs that may
+			// contain resolved secrets, and therefore remain ephemeral.
+			serverErr = m.update(id, conf, time.Until(deadline), !info.ephemeral)
 		}
 	default:
 		requestErr = fmt.Errorf("verb not supported: %v", r.Method)
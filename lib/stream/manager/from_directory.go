@@ -88,7 +88,9 @@ func LoadStreamConfigsFromDirectory(replaceEnvVars bool, dir string) (map[string
 			return readerr
 		}
 		if replaceEnvVars {
-			streamBytes = text.ReplaceEnvVariables(streamBytes)
+			if streamBytes, readerr = text.ReplaceVariables(streamBytes); readerr != nil {
+				return fmt.Errorf("failed to read stream file '%v': %v", path, readerr)
+			}
 		}
 
 		conf := stream.NewConfig()
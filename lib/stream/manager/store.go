@@ -0,0 +1,319 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Jeffail/benthos/lib/stream"
+	"github.com/Jeffail/benthos/lib/types"
+	yaml "gopkg.in/yaml.v2"
+)
+
+//------------------------------------------------------------------------------
+
+// Store is a persistence backend for stream configurations, allowing streams
+// created through a stream manager to be restored after a restart.
+type Store interface {
+	// Set stores the configuration of a stream, replacing any existing
+	// configuration of the same ID.
+	Set(id string, conf stream.Config) error
+
+	// Delete removes the configuration of a stream. Deleting a stream that
+	// does not exist is not an error.
+	Delete(id string) error
+
+	// ReadAll returns all stored stream configurations mapped by their IDs. If
+	// individual configurations cannot be read then the remaining
+	// configurations are returned along with a StoreReadErrors.
+	ReadAll() (map[string]stream.Config, error)
+}
+
+// StoreReadErrors is returned by Store.ReadAll when individual stream
+// configurations could not be read, and maps the IDs of those streams to the
+// errors encountered.
+type StoreReadErrors map[string]error
+
+// Error returns a message listing the streams that could not be read.
+func (e StoreReadErrors) Error() string {
+	ids := make([]string, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	errs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		errs = append(errs, fmt.Sprintf("stream (%v): %v", id, e[id]))
+	}
+	return "failed to read stored streams: " + strings.Join(errs, ", ")
+}
+
+func marshalStoredConfig(conf stream.Config) ([]byte, error) {
+	sanit, err := conf.Sanitised()
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(sanit)
+}
+
+func unmarshalStoredConfig(confBytes []byte) (stream.Config, error) {
+	conf := stream.NewConfig()
+	err := yaml.Unmarshal(confBytes, &conf)
+	return conf, err
+}
+
+//------------------------------------------------------------------------------
+
+// DirectoryStore is a Store that writes each stream configuration as a YAML
+// file within a directory, where the name of the file is the stream ID.
+type DirectoryStore struct {
+	dir string
+}
+
+// NewDirectoryStore creates a Store that persists stream configurations as
+// files within a directory, which is created if it does not already exist.
+func NewDirectoryStore(dir string) (*DirectoryStore, error) {
+	dir = filepath.Clean(dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DirectoryStore{dir: dir}, nil
+}
+
+func (d *DirectoryStore) path(id, ext string) (string, error) {
+	if strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return "", fmt.Errorf("stream id (%v) cannot be used as a file name", id)
+	}
+	return filepath.Join(d.dir, id+ext), nil
+}
+
+// Set writes the configuration of a stream to a file.
+func (d *DirectoryStore) Set(id string, conf stream.Config) error {
+	path, err := d.path(id, ".yaml")
+	if err != nil {
+		return err
+	}
+	confBytes, err := marshalStoredConfig(conf)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that a crash cannot leave a partial
+	// config behind.
+	tmpPath := path + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, confBytes, 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// Delete removes the file of a stream configuration.
+func (d *DirectoryStore) Delete(id string) error {
+	for _, ext := range []string{".yaml", ".json"} {
+		path, err := d.path(id, ext)
+		if err != nil {
+			return err
+		}
+		if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadAll reads all stream configurations from the directory. Files that
+// cannot be read or parsed are reported within a StoreReadErrors.
+func (d *DirectoryStore) ReadAll() (map[string]stream.Config, error) {
+	infos, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]stream.Config{}, nil
+		}
+		return nil, err
+	}
+
+	confs := map[string]stream.Config{}
+	readErrs := StoreReadErrors{}
+	for _, info := range infos {
+		ext := filepath.Ext(info.Name())
+		if info.IsDir() || (ext != ".yaml" && ext != ".json") {
+			continue
+		}
+		id := strings.TrimSuffix(info.Name(), ext)
+		if _, exists := confs[id]; exists {
+			readErrs[id] = fmt.Errorf("stream id collision from file: %v", info.Name())
+			continue
+		}
+		confBytes, rerr := ioutil.ReadFile(filepath.Join(d.dir, info.Name()))
+		if rerr != nil {
+			readErrs[id] = rerr
+			continue
+		}
+		if confs[id], rerr = unmarshalStoredConfig(confBytes); rerr != nil {
+			delete(confs, id)
+			readErrs[id] = fmt.Errorf("failed to parse file '%v': %v", info.Name(), rerr)
+		}
+	}
+	if len(readErrs) > 0 {
+		return confs, readErrs
+	}
+	return confs, nil
+}
+
+//------------------------------------------------------------------------------
+
+// CacheStore is a Store that writes stream configurations to a cache resource.
+// Since caches cannot be listed the IDs of all stored streams are kept in an
+// index under a separate key.
+type CacheStore struct {
+	cache    types.Cache
+	indexKey string
+	prefix   string
+
+	mut sync.Mutex
+}
+
+// NewCacheStore creates a Store that persists stream configurations within a
+// cache, where all keys written are prefixed with keyPrefix.
+func NewCacheStore(cache types.Cache, keyPrefix string) *CacheStore {
+	return &CacheStore{
+		cache:    cache,
+		indexKey: keyPrefix + "index",
+		prefix:   keyPrefix + "stream_",
+	}
+}
+
+func (c *CacheStore) readIndex() ([]string, error) {
+	indexBytes, err := c.cache.Get(c.indexKey)
+	if err == types.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	if err = json.Unmarshal(indexBytes, &ids); err != nil {
+		return nil, fmt.Errorf("failed to parse stream index: %v", err)
+	}
+	return ids, nil
+}
+
+func (c *CacheStore) writeIndex(ids []string) error {
+	indexBytes, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	return c.cache.Set(c.indexKey, indexBytes)
+}
+
+// Set writes the configuration of a stream to the cache and adds its ID to the
+// index.
+func (c *CacheStore) Set(id string, conf stream.Config) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	confBytes, err := marshalStoredConfig(conf)
+	if err != nil {
+		return err
+	}
+	if err = c.cache.Set(c.prefix+id, confBytes); err != nil {
+		return err
+	}
+
+	ids, err := c.readIndex()
+	if err != nil {
+		return err
+	}
+	for _, existing := range ids {
+		if existing == id {
+			return nil
+		}
+	}
+	return c.writeIndex(append(ids, id))
+}
+
+// Delete removes the ID of a stream from the index and deletes its
+// configuration from the cache.
+func (c *CacheStore) Delete(id string) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	ids, err := c.readIndex()
+	if err != nil {
+		return err
+	}
+	newIDs := make([]string, 0, len(ids))
+	for _, existing := range ids {
+		if existing != id {
+			newIDs = append(newIDs, existing)
+		}
+	}
+	if len(newIDs) != len(ids) {
+		if err = c.writeIndex(newIDs); err != nil {
+			return err
+		}
+	}
+	if err = c.cache.Delete(c.prefix + id); err != nil && err != types.ErrKeyNotFound {
+		return err
+	}
+	return nil
+}
+
+// ReadAll reads all stream configurations listed in the index from the cache.
+func (c *CacheStore) ReadAll() (map[string]stream.Config, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	ids, err := c.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	confs := make(map[string]stream.Config, len(ids))
+	readErrs := StoreReadErrors{}
+	for _, id := range ids {
+		confBytes, err := c.cache.Get(c.prefix + id)
+		if err != nil {
+			readErrs[id] = err
+			continue
+		}
+		if confs[id], err = unmarshalStoredConfig(confBytes); err != nil {
+			delete(confs, id)
+			readErrs[id] = fmt.Errorf("failed to parse config: %v", err)
+		}
+	}
+	if len(readErrs) > 0 {
+		return confs, readErrs
+	}
+	return confs, nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,214 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package manager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/cache"
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/stream"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func storeTestConf(path string) stream.Config {
+	conf := harmlessConf()
+	conf.Input.HTTPServer.Path = path
+	return conf
+}
+
+func checkStoredPaths(t *testing.T, store Store, exp map[string]string) {
+	t.Helper()
+
+	confs, err := store.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	act := map[string]string{}
+	for id, conf := range confs {
+		act[id] = conf.Input.HTTPServer.Path
+	}
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong stored streams: %v != %v", act, exp)
+	}
+}
+
+func testStoreOperations(t *testing.T, store Store) {
+	checkStoredPaths(t, store, map[string]string{})
+
+	if err := store.Set("foo", storeTestConf("/foo")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("bar", storeTestConf("/bar")); err != nil {
+		t.Fatal(err)
+	}
+	checkStoredPaths(t, store, map[string]string{
+		"foo": "/foo",
+		"bar": "/bar",
+	})
+
+	if err := store.Set("foo", storeTestConf("/foo2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("baz"); err != nil {
+		t.Errorf("Unexpected error deleting missing stream: %v", err)
+	}
+	checkStoredPaths(t, store, map[string]string{
+		"foo": "/foo2",
+	})
+}
+
+func checkStoreReadErrors(t *testing.T, store Store, expPaths map[string]string, expErrIDs []string) {
+	t.Helper()
+
+	confs, err := store.ReadAll()
+	readErrs, ok := err.(StoreReadErrors)
+	if !ok {
+		t.Fatalf("Expected StoreReadErrors, received: %v", err)
+	}
+	var errIDs []string
+	for id := range readErrs {
+		errIDs = append(errIDs, id)
+	}
+	sort.Strings(errIDs)
+	if !reflect.DeepEqual(expErrIDs, errIDs) {
+		t.Errorf("Wrong failed streams: %v != %v", errIDs, expErrIDs)
+	}
+	act := map[string]string{}
+	for id, conf := range confs {
+		act[id] = conf.Input.HTTPServer.Path
+	}
+	if !reflect.DeepEqual(expPaths, act) {
+		t.Errorf("Wrong stored streams: %v != %v", act, expPaths)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func TestDirectoryStore(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "streams_store_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	store, err := NewDirectoryStore(filepath.Join(testDir, "streams"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStoreOperations(t, store)
+
+	var files []string
+	infos, err := ioutil.ReadDir(filepath.Join(testDir, "streams"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range infos {
+		files = append(files, info.Name())
+	}
+	sort.Strings(files)
+	if exp := []string{"foo.yaml"}; !reflect.DeepEqual(exp, files) {
+		t.Errorf("Wrong files in store directory: %v != %v", files, exp)
+	}
+
+	if err = store.Set("../foo", harmlessConf()); err == nil {
+		t.Error("Expected error from bad stream id")
+	}
+}
+
+func TestDirectoryStoreCorruptEntry(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "streams_store_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	store, err := NewDirectoryStore(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Set("foo", storeTestConf("/foo")); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(testDir, "bar.yaml"), []byte("input: [ nope"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	checkStoreReadErrors(t, store, map[string]string{
+		"foo": "/foo",
+	}, []string{"bar"})
+}
+
+func TestCacheStore(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewCacheStore(memCache, "test_")
+	testStoreOperations(t, store)
+
+	if _, err = memCache.Get("test_stream_bar"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected deleted stream to be removed from cache: %v", err)
+	}
+	if err = memCache.Set("test_index", []byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.ReadAll(); err == nil {
+		t.Error("Expected error from bad index")
+	}
+}
+
+func TestCacheStoreCorruptEntry(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewCacheStore(memCache, "test_")
+
+	for _, id := range []string{"foo", "bar", "baz"} {
+		if err = store.Set(id, storeTestConf("/"+id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = memCache.Set("test_stream_bar", []byte("input: [ nope")); err != nil {
+		t.Fatal(err)
+	}
+	if err = memCache.Delete("test_stream_baz"); err != nil {
+		t.Fatal(err)
+	}
+
+	checkStoreReadErrors(t, store, map[string]string{
+		"foo": "/foo",
+	}, []string{"bar", "baz"})
+}
+
+//------------------------------------------------------------------------------
@@ -45,6 +45,7 @@ type StreamStatus struct {
 	logger       log.Modular
 	metrics      *metrics.Local
 	createdAt    time.Time
+	ephemeral    bool
 }
 
 // NewStreamStatus creates a new StreamStatus.
@@ -92,6 +93,12 @@ func (s *StreamStatus) Logger() log.Modular {
 	return s.logger
 }
 
+// Draining returns the drain progress of the stream, and a boolean indicating
+// whether the stream has begun shutting down.
+func (s *StreamStatus) Draining() (stream.DrainStatus, bool) {
+	return s.strm.Draining()
+}
+
 // setClosed sets the flag indicating that the stream is closed.
 func (s *StreamStatus) setClosed() {
 	atomic.SwapInt64(&s.stoppedAfter, int64(time.Since(s.createdAt)))
@@ -108,13 +115,15 @@ type StreamProcConstructorFunc func(streamID string) (types.Processor, error)
 // Type manages a collection of streams, providing APIs for CRUD operations on
 // the streams.
 type Type struct {
-	closed  bool
-	streams map[string]*StreamStatus
+	closed   bool
+	stopping bool
+	streams  map[string]*StreamStatus
 
 	manager    types.Manager
 	stats      metrics.Type
 	logger     log.Modular
 	apiTimeout time.Duration
+	store      Store
 
 	pipelineProcCtors []StreamProcConstructorFunc
 
@@ -134,9 +143,37 @@ func New(opts ...func(*Type)) *Type {
 		opt(t)
 	}
 	t.registerEndpoints()
+	t.restoreStreams()
 	return t
 }
 
+// restoreStreams creates all streams found within the store. Streams that
+// fail to be created are logged and left within the store. Restored streams are
+// not written back to the store, but remain persistent so that subsequent
+// changes to them are stored.
+func (m *Type) restoreStreams() {
+	if m.store == nil {
+		return
+	}
+	confs, err := m.store.ReadAll()
+	if readErrs, ok := err.(StoreReadErrors); ok {
+		for id, readErr := range readErrs {
+			m.logger.Errorf("Failed to read stored stream (%v): %v\n", id, readErr)
+		}
+	} else if err != nil {
+		m.logger.Errorf("Failed to read stored streams: %v\n", err)
+		return
+	}
+	for id, conf := range confs {
+		if err = m.create(id, conf, false, false); err != nil {
+			m.logger.Errorf("Failed to restore stored stream (%v): %v\n", id, err)
+		}
+	}
+	if len(confs) > 0 {
+		m.logger.Infof("Restored %v streams from store\n", len(confs))
+	}
+}
+
 //------------------------------------------------------------------------------
 
 // OptSetStats sets the metrics aggregator to be used by the manager and all
@@ -170,6 +207,15 @@ func OptSetAPITimeout(tout time.Duration) func(*Type) {
 	}
 }
 
+// OptSetStore sets a persistence backend for stream configs. Streams within the
+// store are created when the manager is constructed, and streams that are
+// created, updated or deleted are written through to the store.
+func OptSetStore(store Store) func(*Type) {
+	return func(t *Type) {
+		t.store = store
+	}
+}
+
 // OptAddProcessors adds processor constructors that will be called for every
 // new stream and attached to the processor pipelines. The constructor is given
 // the name of the stream as an argument.
@@ -192,10 +238,25 @@ var (
 // Create attempts to construct and run a new stream under a unique ID. If the
 // ID already exists an error is returned.
 func (m *Type) Create(id string, conf stream.Config) error {
+	return m.create(id, conf, true, false)
+}
+
+// CreateEphemeral attempts to construct and run a new stream under a unique ID
+// without writing its config to the store. This should be used for streams
+// that are defined elsewhere, such as static config files, which may contain
+// resolved secrets. If the ID already exists an error is returned.
+func (m *Type) CreateEphemeral(id string, conf stream.Config) error {
+	return m.create(id, conf, false, true)
+}
+
+// create constructs and runs a new stream. When store is true the config is
+// written to the store, and when ephemeral is true the stream is flagged so
+// that changes made to it through the API are also never stored.
+func (m *Type) create(id string, conf stream.Config, store, ephemeral bool) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	if m.closed {
+	if m.closed || m.stopping {
 		return types.ErrTypeClosed
 	}
 
@@ -230,7 +291,15 @@ func (m *Type) Create(id string, conf stream.Config) error {
 		return err
 	}
 
+	if store && m.store != nil {
+		if err = m.store.Set(id, conf); err != nil {
+			strm.Stop(m.apiTimeout)
+			return fmt.Errorf("failed to store stream: %v", err)
+		}
+	}
+
 	wrapper = NewStreamStatus(conf, strm, strmLogger, strmFlatMetrics)
+	wrapper.ephemeral = ephemeral
 	m.streams[id] = wrapper
 	return nil
 }
@@ -256,9 +325,19 @@ func (m *Type) Read(id string) (*StreamStatus, error) {
 // Update attempts to stop an existing stream and replace it with a new version
 // of the same stream.
 func (m *Type) Update(id string, conf stream.Config, timeout time.Duration) error {
+	return m.update(id, conf, timeout, true)
+}
+
+// UpdateEphemeral attempts to stop an existing stream and replace it with a new
+// version of the same stream without writing its config to the store.
+func (m *Type) UpdateEphemeral(id string, conf stream.Config, timeout time.Duration) error {
+	return m.update(id, conf, timeout, false)
+}
+
+func (m *Type) update(id string, conf stream.Config, timeout time.Duration, persist bool) error {
 	m.lock.Lock()
 	wrapper, exists := m.streams[id]
-	closed := m.closed
+	closed := m.closed || m.stopping
 	m.lock.Unlock()
 
 	if closed {
@@ -272,18 +351,30 @@ func (m *Type) Update(id string, conf stream.Config, timeout time.Duration) erro
 		return nil
 	}
 
-	if err := m.Delete(id, timeout); err != nil {
+	if err := m.delete(id, timeout); err != nil {
 		return err
 	}
-	return m.Create(id, conf)
+	return m.create(id, conf, persist, !persist)
 }
 
 // Delete attempts to stop and remove a stream by its ID. Returns an error if
 // the stream was not found, or if clean shutdown fails in the specified period
 // of time.
 func (m *Type) Delete(id string, timeout time.Duration) error {
+	if err := m.delete(id, timeout); err != nil {
+		return err
+	}
+	if m.store != nil {
+		if err := m.store.Delete(id); err != nil {
+			return fmt.Errorf("failed to delete stored stream: %v", err)
+		}
+	}
+	return nil
+}
+
+func (m *Type) delete(id string, timeout time.Duration) error {
 	m.lock.Lock()
-	if m.closed {
+	if m.closed || m.stopping {
 		m.lock.Unlock()
 		return types.ErrTypeClosed
 	}
@@ -308,14 +399,21 @@ func (m *Type) Delete(id string, timeout time.Duration) error {
 //------------------------------------------------------------------------------
 
 // Stop attempts to gracefully shut down all active streams and close the
-// stream manager.
+// stream manager. Streams are drained in parallel, and whilst they are draining
+// their status and drain progress can still be read, but streams can no longer
+// be created, updated or deleted.
 func (m *Type) Stop(timeout time.Duration) error {
 	m.lock.Lock()
-	defer m.lock.Unlock()
+	m.stopping = true
+	streams := make(map[string]*StreamStatus, len(m.streams))
+	for k, v := range m.streams {
+		streams[k] = v
+	}
+	m.lock.Unlock()
 
 	resultChan := make(chan string)
 
-	for k, v := range m.streams {
+	for k, v := range streams {
 		go func(id string, strm *StreamStatus) {
 			if err := strm.strm.Stop(timeout); err != nil {
 				resultChan <- id
@@ -326,14 +424,16 @@ func (m *Type) Stop(timeout time.Duration) error {
 	}
 
 	failedStreams := []string{}
-	for i := 0; i < len(m.streams); i++ {
+	for i := 0; i < len(streams); i++ {
 		if failedStrm := <-resultChan; len(failedStrm) > 0 {
 			failedStreams = append(failedStreams, failedStrm)
 		}
 	}
 
+	m.lock.Lock()
 	m.streams = map[string]*StreamStatus{}
 	m.closed = true
+	m.lock.Unlock()
 
 	if len(failedStreams) > 0 {
 		return fmt.Errorf("failed to gracefully stop the following streams: %v", failedStreams)
@@ -21,7 +21,11 @@
 package manager
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -211,3 +215,212 @@ func TestTypeBasicClose(t *testing.T) {
 		t.Errorf("Unexpected error: %v != %v", act, exp)
 	}
 }
+
+func TestTypeStore(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "streams_store_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	store, err := NewDirectoryStore(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Set("foo", storeTestConf("/foo")); err != nil {
+		t.Fatal(err)
+	}
+	badPath := filepath.Join(testDir, "bar.yaml")
+	if err = ioutil.WriteFile(badPath, []byte("input:\n  type: nope\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := New(
+		OptSetLogger(log.New(os.Stdout, log.Config{LogLevel: "NONE"})),
+		OptSetStats(metrics.DudType{}),
+		OptSetManager(types.DudMgr{}),
+		OptSetStore(store),
+	)
+
+	if info, err := mgr.Read("foo"); err != nil {
+		t.Error(err)
+	} else if exp, act := "/foo", info.Config().Input.HTTPServer.Path; exp != act {
+		t.Errorf("Wrong restored config: %v != %v", act, exp)
+	}
+	if _, err = mgr.Read("bar"); err != ErrStreamDoesNotExist {
+		t.Errorf("Expected bad stream to not be restored: %v", err)
+	}
+	if _, err = os.Stat(badPath); err != nil {
+		t.Errorf("Expected bad stream to remain in store: %v", err)
+	}
+
+	if err = mgr.Create("baz", storeTestConf("/baz")); err != nil {
+		t.Fatal(err)
+	}
+	if err = mgr.Update("foo", storeTestConf("/foo2"), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err = mgr.Delete("baz", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err = mgr.Create("qux", storeTestConf("/qux")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.Remove(badPath); err != nil {
+		t.Fatal(err)
+	}
+	checkStoredPaths(t, store, map[string]string{
+		"foo": "/foo2",
+		"qux": "/qux",
+	})
+
+	if err = mgr.Stop(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTypeStoreRestoredUpdates(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "streams_store_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	store, err := NewDirectoryStore(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Set("foo", storeTestConf("/foo")); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := New(
+		OptSetLogger(log.New(os.Stdout, log.Config{LogLevel: "NONE"})),
+		OptSetStats(metrics.DudType{}),
+		OptSetManager(types.DudMgr{}),
+		OptSetStore(store),
+	)
+
+	// Patches of restored streams should be stored.
+	request := genRequest("PATCH", "/streams/foo", map[string]interface{}{
+		"input": map[string]interface{}{
+			"http_server": map[string]interface{}{
+				"path": "/foobar",
+			},
+		},
+	})
+	response := httptest.NewRecorder()
+	router(mgr).ServeHTTP(response, request)
+	if exp, act := http.StatusOK, response.Code; exp != act {
+		t.Errorf("Unexpected result: %v != %v", act, exp)
+	}
+	checkStoredPaths(t, store, map[string]string{
+		"foo": "/foobar",
+	})
+
+	if err = mgr.Stop(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTypeStoreEphemeral(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "streams_store_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	store, err := NewDirectoryStore(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := New(
+		OptSetLogger(log.New(os.Stdout, log.Config{LogLevel: "NONE"})),
+		OptSetStats(metrics.DudType{}),
+		OptSetManager(types.DudMgr{}),
+		OptSetStore(store),
+	)
+
+	if err = mgr.CreateEphemeral("foo", storeTestConf("/foo")); err != nil {
+		t.Fatal(err)
+	}
+	checkStoredPaths(t, store, map[string]string{})
+
+	if err = mgr.UpdateEphemeral("foo", storeTestConf("/foo2"), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	checkStoredPaths(t, store, map[string]string{})
+
+	if info, err := mgr.Read("foo"); err != nil {
+		t.Error(err)
+	} else if exp, act := "/foo2", info.Config().Input.HTTPServer.Path; exp != act {
+		t.Errorf("Wrong updated config: %v != %v", act, exp)
+	}
+
+	// Patches of ephemeral streams must not be stored either.
+	request := genRequest("PATCH", "/streams/foo", map[string]interface{}{
+		"input": map[string]interface{}{
+			"http_server": map[string]interface{}{
+				"path": "/foobar",
+			},
+		},
+	})
+	response := httptest.NewRecorder()
+	router(mgr).ServeHTTP(response, request)
+	if exp, act := http.StatusOK, response.Code; exp != act {
+		t.Errorf("Unexpected result: %v != %v", act, exp)
+	}
+	checkStoredPaths(t, store, map[string]string{})
+
+	if err = mgr.Update("foo", storeTestConf("/foo3"), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	checkStoredPaths(t, store, map[string]string{
+		"foo": "/foo3",
+	})
+
+	if err = mgr.Stop(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTypeStoreCorruptEntry(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "streams_store_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	store, err := NewDirectoryStore(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Set("foo", storeTestConf("/foo")); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(testDir, "bar.yaml"), []byte("input: [ nope"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := New(
+		OptSetLogger(log.New(os.Stdout, log.Config{LogLevel: "NONE"})),
+		OptSetStats(metrics.DudType{}),
+		OptSetManager(types.DudMgr{}),
+		OptSetStore(store),
+	)
+
+	if info, err := mgr.Read("foo"); err != nil {
+		t.Error(err)
+	} else if exp, act := "/foo", info.Config().Input.HTTPServer.Path; exp != act {
+		t.Errorf("Wrong restored config: %v != %v", act, exp)
+	}
+	if _, err = mgr.Read("bar"); err != ErrStreamDoesNotExist {
+		t.Errorf("Expected corrupt stream to not be restored: %v", err)
+	}
+
+	if err = mgr.Stop(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
@@ -46,6 +46,9 @@ type Type struct {
 	pipelineLayer pipeline.Type
 	outputLayer   output.Type
 
+	deadLetterRouter *deadLetterRouter
+	deadLetterLayer  output.Type
+
 	complementaryProcs []types.ProcessorConstructorFunc
 
 	manager types.Manager
@@ -53,6 +56,7 @@ type Type struct {
 	logger  log.Modular
 
 	onClose func()
+	drain   drainTracker
 }
 
 // New creates a new stream.Type.
@@ -72,6 +76,11 @@ func New(conf Config, opts ...func(*Type)) (*Type, error) {
 	}
 
 	healthCheck := func(w http.ResponseWriter, r *http.Request) {
+		if _, draining := t.Draining(); draining {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("stream is draining\n"))
+			return
+		}
 		connected := true
 		if !t.inputLayer.Connected() {
 			connected = false
@@ -83,6 +92,11 @@ func New(conf Config, opts ...func(*Type)) (*Type, error) {
 			w.WriteHeader(http.StatusServiceUnavailable)
 			w.Write([]byte("output not connected\n"))
 		}
+		if t.deadLetterLayer != nil && !t.deadLetterLayer.Connected() {
+			connected = false
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("dead letter output not connected\n"))
+		}
 		if connected {
 			w.Write([]byte("OK"))
 		}
@@ -92,6 +106,11 @@ func New(conf Config, opts ...func(*Type)) (*Type, error) {
 		"Returns 200 OK if all inputs and outputs are connected, otherwise a 503 is returned.",
 		healthCheck,
 	)
+	t.manager.RegisterEndpoint(
+		"/drain",
+		"Returns an object describing whether the stream is draining, the current stage and the time elapsed.",
+		t.handleDrain,
+	)
 	return t, nil
 }
 
@@ -148,9 +167,15 @@ func OptOnClose(onClose func()) func(*Type) {
 //------------------------------------------------------------------------------
 
 func (t *Type) start() (err error) {
+	// Processors only tag their failures when they might be dead lettered.
+	procMgr := t.manager
+	if t.conf.DeadLetter != nil {
+		procMgr = failureTaggingManager{Manager: t.manager}
+	}
+
 	// Constructors
 	if t.inputLayer, err = input.New(
-		t.conf.Input, t.manager,
+		t.conf.Input, procMgr,
 		t.logger.NewModule(".input"), metrics.Namespaced(t.stats, "input"),
 	); err != nil {
 		return
@@ -164,7 +189,7 @@ func (t *Type) start() (err error) {
 	}
 	if tLen := len(t.complementaryProcs) + len(t.conf.Pipeline.Processors); tLen > 0 {
 		if t.pipelineLayer, err = pipeline.New(
-			t.conf.Pipeline, t.manager,
+			t.conf.Pipeline, procMgr,
 			t.logger.NewModule(".pipeline"), metrics.Namespaced(t.stats, "pipeline"),
 			t.complementaryProcs...,
 		); err != nil {
@@ -172,11 +197,20 @@ func (t *Type) start() (err error) {
 		}
 	}
 	if t.outputLayer, err = output.New(
-		t.conf.Output, t.manager,
+		t.conf.Output, procMgr,
 		t.logger.NewModule(".output"), metrics.Namespaced(t.stats, "output"),
 	); err != nil {
 		return
 	}
+	if t.conf.DeadLetter != nil {
+		if t.deadLetterLayer, err = output.New(
+			*t.conf.DeadLetter, t.manager,
+			t.logger.NewModule(".dead_letter"), metrics.Namespaced(t.stats, "dead_letter"),
+		); err != nil {
+			return
+		}
+		t.deadLetterRouter = newDeadLetterRouter(t.logger.NewModule(".dead_letter"), t.stats)
+	}
 
 	// Start chaining components
 	var nextTranChan <-chan types.Transaction
@@ -194,28 +228,43 @@ func (t *Type) start() (err error) {
 		}
 		nextTranChan = t.pipelineLayer.TransactionChan()
 	}
+	if t.deadLetterRouter != nil {
+		if err = t.deadLetterRouter.Consume(nextTranChan); err != nil {
+			return
+		}
+		if err = t.deadLetterLayer.Consume(t.deadLetterRouter.DeadLetterChan()); err != nil {
+			return
+		}
+		nextTranChan = t.deadLetterRouter.TransactionChan()
+	}
 	if err = t.outputLayer.Consume(nextTranChan); err != nil {
 		return
 	}
 
-	go func(out output.Type) {
+	go func(out, deadLetter output.Type) {
 		for {
 			if err := out.WaitForClose(time.Second); err == nil {
-				t.onClose()
-				return
+				break
+			}
+		}
+		for deadLetter != nil {
+			if err := deadLetter.WaitForClose(time.Second); err == nil {
+				break
 			}
 		}
-	}(t.outputLayer)
+		t.onClose()
+	}(t.outputLayer, t.deadLetterLayer)
 
 	return nil
 }
 
 // stopGracefully attempts to close the stream in the most graceful way by only
-// closing the input layer and waiting for all other layers to terminate by
+// draining the input layer and waiting for all other layers to terminate by
 // proxy. This should guarantee that all in-flight and buffered data is resolved
 // before shutting down.
 func (t *Type) stopGracefully(timeout time.Duration) (err error) {
-	t.inputLayer.CloseAsync()
+	t.drain.setStage(DrainStageInput)
+	drainOrClose(t.inputLayer)
 	started := time.Now()
 	if err = t.inputLayer.WaitForClose(timeout); err != nil {
 		return
@@ -226,6 +275,7 @@ func (t *Type) stopGracefully(timeout time.Duration) (err error) {
 	// If we have a buffer then wait right here. We want to try and allow the
 	// buffer to empty out before prompting the other layers to shut down.
 	if t.bufferLayer != nil {
+		t.drain.setStage(DrainStageBuffer)
 		t.bufferLayer.StopConsuming()
 		remaining = timeout - time.Since(started)
 		if remaining < 0 {
@@ -238,6 +288,7 @@ func (t *Type) stopGracefully(timeout time.Duration) (err error) {
 
 	// After this point we can start closing the remaining components.
 	if t.pipelineLayer != nil {
+		t.drain.setStage(DrainStagePipeline)
 		t.pipelineLayer.CloseAsync()
 		remaining = timeout - time.Since(started)
 		if remaining < 0 {
@@ -248,6 +299,18 @@ func (t *Type) stopGracefully(timeout time.Duration) (err error) {
 		}
 	}
 
+	// The dead letter router closes once its input is drained.
+	if t.deadLetterRouter != nil {
+		remaining = timeout - time.Since(started)
+		if remaining < 0 {
+			return types.ErrTimeout
+		}
+		if err = t.deadLetterRouter.WaitForClose(remaining); err != nil {
+			return
+		}
+	}
+
+	t.drain.setStage(DrainStageOutput)
 	t.outputLayer.CloseAsync()
 	remaining = timeout - time.Since(started)
 	if remaining < 0 {
@@ -257,6 +320,18 @@ func (t *Type) stopGracefully(timeout time.Duration) (err error) {
 		return
 	}
 
+	if t.deadLetterLayer != nil {
+		t.drain.setStage(DrainStageDeadLetter)
+		t.deadLetterLayer.CloseAsync()
+		remaining = timeout - time.Since(started)
+		if remaining < 0 {
+			return types.ErrTimeout
+		}
+		if err = t.deadLetterLayer.WaitForClose(remaining); err != nil {
+			return
+		}
+	}
+
 	return nil
 }
 
@@ -295,6 +370,17 @@ func (t *Type) stopOrdered(timeout time.Duration) (err error) {
 		}
 	}
 
+	if t.deadLetterRouter != nil {
+		t.deadLetterRouter.CloseAsync()
+		remaining = timeout - time.Since(started)
+		if remaining < 0 {
+			return types.ErrTimeout
+		}
+		if err = t.deadLetterRouter.WaitForClose(remaining); err != nil {
+			return
+		}
+	}
+
 	t.outputLayer.CloseAsync()
 	remaining = timeout - time.Since(started)
 	if remaining < 0 {
@@ -304,6 +390,17 @@ func (t *Type) stopOrdered(timeout time.Duration) (err error) {
 		return
 	}
 
+	if t.deadLetterLayer != nil {
+		t.deadLetterLayer.CloseAsync()
+		remaining = timeout - time.Since(started)
+		if remaining < 0 {
+			return types.ErrTimeout
+		}
+		if err = t.deadLetterLayer.WaitForClose(remaining); err != nil {
+			return
+		}
+	}
+
 	return nil
 }
 
@@ -318,7 +415,13 @@ func (t *Type) stopUnordered(timeout time.Duration) (err error) {
 	if t.pipelineLayer != nil {
 		t.pipelineLayer.CloseAsync()
 	}
+	if t.deadLetterRouter != nil {
+		t.deadLetterRouter.CloseAsync()
+	}
 	t.outputLayer.CloseAsync()
+	if t.deadLetterLayer != nil {
+		t.deadLetterLayer.CloseAsync()
+	}
 
 	started := time.Now()
 	if err = t.inputLayer.WaitForClose(timeout); err != nil {
@@ -347,6 +450,16 @@ func (t *Type) stopUnordered(timeout time.Duration) (err error) {
 		}
 	}
 
+	if t.deadLetterRouter != nil {
+		remaining = timeout - time.Since(started)
+		if remaining < 0 {
+			return types.ErrTimeout
+		}
+		if err = t.deadLetterRouter.WaitForClose(remaining); err != nil {
+			return
+		}
+	}
+
 	remaining = timeout - time.Since(started)
 	if remaining < 0 {
 		return types.ErrTimeout
@@ -355,18 +468,32 @@ func (t *Type) stopUnordered(timeout time.Duration) (err error) {
 		return
 	}
 
+	if t.deadLetterLayer != nil {
+		remaining = timeout - time.Since(started)
+		if remaining < 0 {
+			return types.ErrTimeout
+		}
+		if err = t.deadLetterLayer.WaitForClose(remaining); err != nil {
+			return
+		}
+	}
+
 	return nil
 }
 
 // Stop attempts to close the stream within the specified timeout period.
-// Initially the attempt is graceful, but as the timeout draws close the attempt
-// becomes progressively less graceful.
+// Initially the attempt is graceful, where the stream is drained by stopping
+// inputs from consuming new data and allowing in-flight messages to be resolved
+// before closing each layer in order. As the timeout draws close the attempt
+// becomes progressively less graceful. The progress of the drain can be
+// obtained with Draining.
 func (t *Type) Stop(timeout time.Duration) error {
 	tOutUnordered := timeout / 4
 	tOutGraceful := timeout - tOutUnordered
 
 	err := t.stopGracefully(tOutGraceful)
 	if err == nil {
+		t.drain.finish(DrainStageDone)
 		return nil
 	}
 	if err == types.ErrTimeout {
@@ -375,10 +502,13 @@ func (t *Type) Stop(timeout time.Duration) error {
 		t.logger.Errorf("Encountered error whilst shutting down: %v\n", err)
 	}
 
+	t.drain.setStage(DrainStageForced)
 	err = t.stopUnordered(tOutUnordered)
 	if err == nil {
+		t.drain.finish(DrainStageDone)
 		return nil
 	}
+	t.drain.finish(DrainStageForced)
 	if err == types.ErrTimeout {
 		t.logger.Errorln("Failed to stop stream gracefully within target time.")
 
@@ -21,6 +21,9 @@
 package stream
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -202,3 +205,60 @@ func TestTypeCloseUnordered(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestTypeDrainStatus(t *testing.T) {
+	conf := NewConfig()
+	conf.Input.Type = input.TypeNanomsg
+	conf.Output.Type = output.TypeNanomsg
+	conf.Buffer.Type = "memory"
+	conf.Pipeline.Processors = []processor.Config{
+		processor.NewConfig(),
+	}
+
+	strm, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, draining := strm.Draining(); draining {
+		t.Error("Stream draining before being stopped")
+	}
+
+	if err = strm.Stop(time.Second * 10); err != nil {
+		t.Fatal(err)
+	}
+
+	status, draining := strm.Draining()
+	if !draining {
+		t.Fatal("Stream not draining after being stopped")
+	}
+	if exp, act := DrainStageDone, status.Stage; exp != act {
+		t.Errorf("Wrong drain stage: %v != %v", act, exp)
+	}
+	if status.Finished.IsZero() {
+		t.Error("Drain finish time not set")
+	}
+	if exp, act := status.Finished.Sub(status.Started), status.Elapsed(); exp != act {
+		t.Errorf("Wrong elapsed time: %v != %v", act, exp)
+	}
+
+	rec := httptest.NewRecorder()
+	strm.handleDrain(rec, httptest.NewRequest("GET", "/drain", nil))
+
+	var info struct {
+		Draining bool   `json:"draining"`
+		Stage    string `json:"stage"`
+	}
+	if err = json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if !info.Draining {
+		t.Error("Expected draining in response")
+	}
+	if exp, act := DrainStageDone, info.Stage; exp != act {
+		t.Errorf("Wrong drain stage in response: %v != %v", act, exp)
+	}
+	if exp, act := http.StatusOK, rec.Code; exp != act {
+		t.Errorf("Wrong status code: %v != %v", act, exp)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/processor"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// ProcProvider returns compiled processors extracted from a Benthos config.
+type ProcProvider interface {
+	// Provide attempts to create the processors of a target path within a
+	// config with a map of environment variables set for the duration of the
+	// parse.
+	Provide(target string, environment map[string]string) ([]types.Processor, error)
+}
+
+//------------------------------------------------------------------------------
+
+// CaseFailure encapsulates information about a failed test case.
+type CaseFailure struct {
+	Name     string
+	Reported string
+}
+
+// String returns a string representation of the case failure.
+func (c CaseFailure) String() string {
+	return fmt.Sprintf("%v: %v", c.Name, c.Reported)
+}
+
+//------------------------------------------------------------------------------
+
+// Case contains a definition of a single Benthos config test case.
+type Case struct {
+	Name             string            `yaml:"name"`
+	Environment      map[string]string `yaml:"environment"`
+	TargetProcessors string            `yaml:"target_processors"`
+	InputBatch       []InputPart       `yaml:"input_batch"`
+	OutputBatches    [][]ConditionsMap `yaml:"output_batches"`
+}
+
+// NewCase returns a default test case.
+func NewCase() Case {
+	return Case{
+		Name:             "Example test case",
+		Environment:      map[string]string{},
+		TargetProcessors: DefaultTargetProcessors,
+		InputBatch:       []InputPart{},
+		OutputBatches:    [][]ConditionsMap{},
+	}
+}
+
+// UnmarshalYAML ensures that when parsing a test case the default values are
+// still applied.
+func (c *Case) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type caseAlias Case
+	aliased := caseAlias(NewCase())
+
+	if err := unmarshal(&aliased); err != nil {
+		return err
+	}
+
+	*c = Case(aliased)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Execute attempts to execute a test case against a Benthos configuration.
+func (c *Case) Execute(provider ProcProvider) (failures []CaseFailure, err error) {
+	procs, err := provider.Provide(c.TargetProcessors, c.Environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialise processors: %v", err)
+	}
+	defer func() {
+		for _, p := range procs {
+			p.CloseAsync()
+		}
+		for _, p := range procs {
+			p.WaitForClose(time.Second)
+		}
+	}()
+
+	reportFailure := func(reason string) {
+		failures = append(failures, CaseFailure{
+			Name:     c.Name,
+			Reported: reason,
+		})
+	}
+
+	msg := message.New(nil)
+	for _, input := range c.InputBatch {
+		msg.Append(input.ToPart())
+	}
+
+	outputBatches, res := processor.ExecuteAll(procs, msg)
+	if res != nil && res.Error() != nil {
+		reportFailure(fmt.Sprintf("processors resulted in error: %v", res.Error()))
+		return
+	}
+
+	if exp, act := len(c.OutputBatches), len(outputBatches); exp != act {
+		reportFailure(fmt.Sprintf("wrong batch count, expected %v, got %v", exp, act))
+		return
+	}
+
+	for i, batch := range outputBatches {
+		expBatch := c.OutputBatches[i]
+		if exp, act := len(expBatch), batch.Len(); exp != act {
+			reportFailure(fmt.Sprintf("batch %v: wrong batch size, expected %v, got %v", i, exp, act))
+			continue
+		}
+		batch.Iter(func(j int, part types.Part) error {
+			for _, cErr := range expBatch[j].CheckAll(part) {
+				reportFailure(fmt.Sprintf("batch %v message %v: %v", i, j, cErr))
+			}
+			return nil
+		})
+	}
+	return
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,200 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//------------------------------------------------------------------------------
+
+// DefaultSuffix is the filename suffix (less the extension) of test definition
+// files, e.g. the tests for the config `foo.yaml` would be found within the
+// file `foo_benthos_test.yaml`.
+const DefaultSuffix = "_benthos_test"
+
+var configExtensions = []string{".yaml", ".yml", ".json"}
+
+//------------------------------------------------------------------------------
+
+func isDefinitionPath(path, testSuffix string) bool {
+	ext := filepath.Ext(path)
+	if ext != ".yaml" && ext != ".yml" {
+		return false
+	}
+	return strings.HasSuffix(strings.TrimSuffix(path, ext), testSuffix)
+}
+
+// getConfigPath attempts to find the config file targeted by a definition path
+// by trying each supported config extension.
+func getConfigPath(definitionPath, testSuffix string) (string, error) {
+	ext := filepath.Ext(definitionPath)
+	base := strings.TrimSuffix(strings.TrimSuffix(definitionPath, ext), testSuffix)
+
+	candidates := append([]string{ext}, configExtensions...)
+	for _, cExt := range candidates {
+		if _, err := os.Stat(base + cExt); err == nil {
+			return base + cExt, nil
+		}
+	}
+	return "", fmt.Errorf("target config for test definition '%v' was not found", definitionPath)
+}
+
+// getDefinitionPath attempts to find the test definition file of a config
+// path.
+func getDefinitionPath(configPath, testSuffix string) (string, error) {
+	ext := filepath.Ext(configPath)
+	base := strings.TrimSuffix(configPath, ext) + testSuffix
+	for _, dExt := range []string{".yaml", ".yml"} {
+		if _, err := os.Stat(base + dExt); err == nil {
+			return base + dExt, nil
+		}
+	}
+	return "", fmt.Errorf("test definition for config '%v' was not found", configPath)
+}
+
+// resolveTestPaths walks a list of target paths and returns a map of config
+// paths to their respective test definition paths. A path suffixed with
+// '/...' is walked recursively.
+func resolveTestPaths(targetPaths []string, testSuffix string) (map[string]string, error) {
+	paths := map[string]string{}
+	addDefinition := func(defPath string) error {
+		confPath, err := getConfigPath(defPath, testSuffix)
+		if err != nil {
+			return err
+		}
+		paths[confPath] = defPath
+		return nil
+	}
+	for _, target := range targetPaths {
+		if strings.HasSuffix(target, "/...") {
+			target = strings.TrimSuffix(target, "/...")
+			if err := filepath.Walk(target, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.IsDir() || !isDefinitionPath(path, testSuffix) {
+					return nil
+				}
+				return addDefinition(path)
+			}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		info, err := os.Stat(target)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			matches, err := filepath.Glob(filepath.Join(target, "*"))
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range matches {
+				if isDefinitionPath(path, testSuffix) {
+					if err = addDefinition(path); err != nil {
+						return nil, err
+					}
+				}
+			}
+			continue
+		}
+		if isDefinitionPath(target, testSuffix) {
+			if err = addDefinition(target); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		defPath, err := getDefinitionPath(target, testSuffix)
+		if err != nil {
+			return nil, err
+		}
+		paths[target] = defPath
+	}
+	return paths, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Test executes the test definition of a single config path and returns a
+// slice of failures, or an error if the test could not be executed.
+func Test(configPath, definitionPath string) ([]CaseFailure, error) {
+	def, err := ReadDefinition(definitionPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read test definition '%v': %v", definitionPath, err)
+	}
+	provider := NewProcessorsProvider(configPath)
+	defer provider.Close()
+	return def.Execute(provider)
+}
+
+// Run executes the tests of a list of target paths, printing the results to
+// the writer provided. Returns true if all tests passed.
+func Run(w io.Writer, targetPaths []string, testSuffix string) bool {
+	paths, err := resolveTestPaths(targetPaths, testSuffix)
+	if err != nil {
+		fmt.Fprintf(w, "Failed to resolve test targets: %v\n", err)
+		return false
+	}
+	if len(paths) == 0 {
+		fmt.Fprintf(w, "No tests were found at paths: %v\n", strings.Join(targetPaths, ", "))
+		return false
+	}
+
+	confPaths := make([]string, 0, len(paths))
+	for k := range paths {
+		confPaths = append(confPaths, k)
+	}
+	sort.Strings(confPaths)
+
+	passed := true
+	for _, confPath := range confPaths {
+		failures, err := Test(confPath, paths[confPath])
+		if err != nil {
+			fmt.Fprintf(w, "Test '%v' errored: %v\n", confPath, err)
+			passed = false
+			continue
+		}
+		if len(failures) == 0 {
+			fmt.Fprintf(w, "Test '%v' succeeded\n", confPath)
+			continue
+		}
+		passed = false
+		fmt.Fprintf(w, "Test '%v' failed\n", confPath)
+		fmt.Fprintln(w, "  Failures:")
+		for _, f := range failures {
+			fmt.Fprintf(w, "\n    --- %v ---\n\n", f.Name)
+			for _, line := range strings.Split(f.Reported, "\n") {
+				fmt.Fprintf(w, "    %v\n", line)
+			}
+		}
+		fmt.Fprintln(w, "")
+	}
+	return passed
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,140 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func initTestFiles(t *testing.T, files map[string]string) string {
+	testDir, err := ioutil.TempDir("", "benthos_config_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range files {
+		fullPath := filepath.Join(testDir, path)
+		if err = os.MkdirAll(filepath.Dir(fullPath), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(fullPath, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return testDir
+}
+
+func TestRunPasses(t *testing.T) {
+	testDir := initTestFiles(t, map[string]string{
+		"foo.yaml": `
+pipeline:
+  processors:
+  - type: text
+    text:
+      operator: to_upper
+  - type: metadata
+    metadata:
+      operator: set
+      key: bar
+      value: ${BAR_VALUE:default}
+`,
+		"foo_benthos_test.yaml": `
+tests:
+- name: upper case
+  input_batch:
+  - content: hello world
+    metadata:
+      baz: qux
+  output_batches:
+  - - content_equals: HELLO WORLD
+      metadata_equals:
+        bar: default
+        baz: qux
+- name: environment
+  environment:
+    BAR_VALUE: from env
+  input_batch:
+  - content: hello world
+  output_batches:
+  - - metadata_equals:
+        bar: from env
+`,
+	})
+	defer os.RemoveAll(testDir)
+
+	var buf bytes.Buffer
+	if !Run(&buf, []string{testDir}, DefaultSuffix) {
+		t.Errorf("Expected tests to pass: %s", buf.Bytes())
+	}
+	if _, exists := os.LookupEnv("BAR_VALUE"); exists {
+		t.Error("Expected environment variable to be reset")
+	}
+}
+
+func TestRunFails(t *testing.T) {
+	testDir := initTestFiles(t, map[string]string{
+		"nested/foo.yaml": `
+input:
+  processors:
+  - type: text
+    text:
+      operator: to_upper
+`,
+		"nested/foo_benthos_test.yaml": `
+tests:
+- name: wrong content
+  target_processors: input.processors
+  input_batch:
+  - content: hello world
+  output_batches:
+  - - content_equals: hello world
+`,
+	})
+	defer os.RemoveAll(testDir)
+
+	var buf bytes.Buffer
+	if Run(&buf, []string{testDir + "/..."}, DefaultSuffix) {
+		t.Errorf("Expected tests to fail: %s", buf.Bytes())
+	}
+	if !strings.Contains(buf.String(), "- hello world\n") ||
+		!strings.Contains(buf.String(), "+ HELLO WORLD\n") {
+		t.Errorf("Expected diff in output: %s", buf.Bytes())
+	}
+}
+
+func TestRunMissingDefinition(t *testing.T) {
+	testDir := initTestFiles(t, map[string]string{
+		"foo.yaml": `
+pipeline:
+  processors: []
+`,
+	})
+	defer os.RemoveAll(testDir)
+
+	var buf bytes.Buffer
+	if Run(&buf, []string{filepath.Join(testDir, "foo.yaml")}, DefaultSuffix) {
+		t.Errorf("Expected tests to fail: %s", buf.Bytes())
+	}
+}
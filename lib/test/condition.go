@@ -0,0 +1,257 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/processor/condition"
+	"github.com/Jeffail/benthos/lib/types"
+	yaml "gopkg.in/yaml.v2"
+)
+
+//------------------------------------------------------------------------------
+
+// OutputCondition is a test that is applied to a resulting message part.
+type OutputCondition interface {
+	// Check a message part against the condition, returns an error describing
+	// the mismatch if the check fails.
+	Check(part types.Part) error
+}
+
+// ConditionsMap contains a map of conditions to condition string types.
+type ConditionsMap map[string]OutputCondition
+
+// UnmarshalYAML extracts a ConditionsMap from a YAML node.
+func (c *ConditionsMap) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	newMap := map[string]OutputCondition{}
+
+	var raw map[string]interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	for k, v := range raw {
+		rawBytes, err := yaml.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to parse condition '%v': %v", k, err)
+		}
+		var cond OutputCondition
+		switch k {
+		case "content_equals":
+			val := ContentEquals("")
+			err = yaml.Unmarshal(rawBytes, &val)
+			cond = val
+		case "content_matches":
+			var pattern string
+			if err = yaml.Unmarshal(rawBytes, &pattern); err == nil {
+				var re *regexp.Regexp
+				if re, err = regexp.Compile(pattern); err == nil {
+					cond = ContentMatches{re: re}
+				}
+			}
+		case "json_equals":
+			val := JSONEquals{}
+			if err = yaml.Unmarshal(rawBytes, &val.Value); err == nil {
+				cond = val
+			}
+		case "metadata_equals":
+			val := MetadataEquals{}
+			err = yaml.Unmarshal(rawBytes, &val)
+			cond = val
+		case "condition":
+			condConf := condition.NewConfig()
+			if err = yaml.Unmarshal(rawBytes, &condConf); err == nil {
+				cond, err = NewConditionCheck(condConf)
+			}
+		default:
+			return fmt.Errorf("message part condition type not recognised: %v", k)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse condition '%v': %v", k, err)
+		}
+		newMap[k] = cond
+	}
+
+	*c = newMap
+	return nil
+}
+
+// CheckAll applies all conditions to a message part, returns a slice of errors
+// encountered, sorted by the condition type.
+func (c ConditionsMap) CheckAll(part types.Part) []error {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, k := range keys {
+		if err := c[k].Check(part); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v", k, err))
+		}
+	}
+	return errs
+}
+
+//------------------------------------------------------------------------------
+
+// ContentEquals is an output condition that checks whether the content of a
+// message part matches exactly a string.
+type ContentEquals string
+
+// Check this condition against a message part.
+func (c ContentEquals) Check(p types.Part) error {
+	if exp, act := string(c), string(p.Get()); exp != act {
+		return fmt.Errorf("content mismatch\n%v", Diff(exp, act))
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// ContentMatches is an output condition that checks whether the content of a
+// message part matches a regular expression.
+type ContentMatches struct {
+	re *regexp.Regexp
+}
+
+// Check this condition against a message part.
+func (c ContentMatches) Check(p types.Part) error {
+	if !c.re.Match(p.Get()) {
+		return fmt.Errorf("pattern %v did not match content: %s", c.re, p.Get())
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// JSONEquals is an output condition that checks whether the content of a
+// message part is a JSON document structurally equal to a value.
+type JSONEquals struct {
+	Value interface{}
+}
+
+// Check this condition against a message part.
+func (c JSONEquals) Check(p types.Part) error {
+	expBytes, err := json.Marshal(sanitiseYAMLValue(c.Value))
+	if err != nil {
+		return fmt.Errorf("failed to marshal expected value: %v", err)
+	}
+	var exp interface{}
+	if err = json.Unmarshal(expBytes, &exp); err != nil {
+		return fmt.Errorf("failed to parse expected value: %v", err)
+	}
+	var act interface{}
+	if err = json.Unmarshal(p.Get(), &act); err != nil {
+		return fmt.Errorf("failed to parse content as JSON: %v", err)
+	}
+	if !reflect.DeepEqual(exp, act) {
+		expPretty, _ := json.MarshalIndent(exp, "", "  ")
+		actPretty, _ := json.MarshalIndent(act, "", "  ")
+		return fmt.Errorf("JSON content mismatch\n%v", Diff(string(expPretty), string(actPretty)))
+	}
+	return nil
+}
+
+// sanitiseYAMLValue converts the map[interface{}]interface{} types produced by
+// the YAML parser into map[string]interface{} types that can be marshalled as
+// JSON.
+func sanitiseYAMLValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[fmt.Sprintf("%v", k)] = sanitiseYAMLValue(v)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(t))
+		for i, v := range t {
+			s[i] = sanitiseYAMLValue(v)
+		}
+		return s
+	}
+	return v
+}
+
+//------------------------------------------------------------------------------
+
+// MetadataEquals is an output condition that checks whether specific metadata
+// keys of a message part match expected values.
+type MetadataEquals map[string]string
+
+// Check this condition against a message part.
+func (m MetadataEquals) Check(p types.Part) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if exp, act := m[k], p.Metadata().Get(k); exp != act {
+			return fmt.Errorf("metadata key '%v' mismatch\n%v", k, Diff(exp, act))
+		}
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// ConditionCheck is an output condition that applies a standard Benthos
+// condition to a message part.
+type ConditionCheck struct {
+	cond condition.Type
+}
+
+// NewConditionCheck attempts to create a ConditionCheck from a condition
+// config.
+func NewConditionCheck(conf condition.Config) (*ConditionCheck, error) {
+	cond, err := condition.New(conf, types.NoopMgr(), log.Noop(), metrics.Noop())
+	if err != nil {
+		return nil, err
+	}
+	return &ConditionCheck{cond: cond}, nil
+}
+
+// ErrConditionFailed is returned when a condition check on a message part
+// resolves to false.
+var ErrConditionFailed = errors.New("condition resolved to false")
+
+// Check this condition against a message part.
+func (c *ConditionCheck) Check(p types.Part) error {
+	msg := message.New(nil)
+	msg.Append(p)
+	if !c.cond.Check(msg) {
+		return ErrConditionFailed
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
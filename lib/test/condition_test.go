@@ -0,0 +1,135 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package test
+
+import (
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/message"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestConditionsParse(t *testing.T) {
+	confBytes := []byte(`
+content_equals: foo bar
+content_matches: "^foo"
+json_equals:
+  foo: bar
+metadata_equals:
+  baz: qux
+condition:
+  type: text
+  text:
+    operator: contains
+    arg: bar
+`)
+
+	var conds ConditionsMap
+	if err := yaml.Unmarshal(confBytes, &conds); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 5, len(conds); exp != act {
+		t.Errorf("Wrong count of conditions: %v != %v", act, exp)
+	}
+}
+
+func TestConditionsParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown type":  `does_not_exist: foo`,
+		"bad regexp":    `content_matches: "^foo("`,
+		"bad condition": `condition: {type: does_not_exist}`,
+	}
+
+	for name, conf := range tests {
+		var conds ConditionsMap
+		if err := yaml.Unmarshal([]byte(conf), &conds); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+func TestConditionsCheck(t *testing.T) {
+	confBytes := []byte(`
+content_equals: '{"foo":"bar"}'
+content_matches: "^{\"foo"
+json_equals:
+  foo: bar
+metadata_equals:
+  baz: qux
+condition:
+  type: text
+  text:
+    operator: contains
+    arg: bar
+`)
+
+	var conds ConditionsMap
+	if err := yaml.Unmarshal(confBytes, &conds); err != nil {
+		t.Fatal(err)
+	}
+
+	part := message.NewPart([]byte(`{"foo":"bar"}`))
+	part.Metadata().Set("baz", "qux")
+	if errs := conds.CheckAll(part); len(errs) > 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	part = message.NewPart([]byte(`{"foo":"nope"}`))
+	part.Metadata().Set("baz", "quz")
+	if exp, act := 5, len(conds.CheckAll(part)); exp != act {
+		t.Errorf("Wrong count of errors: %v != %v", act, exp)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		exp    string
+		act    string
+		output string
+	}{
+		{
+			exp:    "foo",
+			act:    "foo",
+			output: "  foo",
+		},
+		{
+			exp:    "foo",
+			act:    "bar",
+			output: "- foo\n+ bar",
+		},
+		{
+			exp:    "foo\nbar\nbaz",
+			act:    "foo\nqux\nbaz",
+			output: "  foo\n- bar\n+ qux\n  baz",
+		},
+		{
+			exp:    "foo\nbar",
+			act:    "foo\nbar\nbaz",
+			output: "  foo\n  bar\n+ baz",
+		},
+	}
+
+	for i, test := range tests {
+		if act := Diff(test.exp, test.act); act != test.output {
+			t.Errorf("Wrong result for test %v: %q != %q", i, act, test.output)
+		}
+	}
+}
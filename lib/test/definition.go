@@ -0,0 +1,66 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package test
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+//------------------------------------------------------------------------------
+
+// Definition of a group of tests for a Benthos config file.
+type Definition struct {
+	Cases []Case `yaml:"tests"`
+}
+
+// ReadDefinition attempts to parse a test definition from a file path.
+func ReadDefinition(path string) (*Definition, error) {
+	defBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var def Definition
+	if err = yaml.Unmarshal(defBytes, &def); err != nil {
+		return nil, fmt.Errorf("failed to parse test definition: %v", err)
+	}
+	return &def, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Execute attempts to execute a test definition on a target config. Returns a
+// slice of test failures or an error.
+func (d Definition) Execute(provider ProcProvider) ([]CaseFailure, error) {
+	var totalFailures []CaseFailure
+	for i, c := range d.Cases {
+		failures, err := c.Execute(provider)
+		if err != nil {
+			return nil, fmt.Errorf("test case %v failed: %v", i, err)
+		}
+		totalFailures = append(totalFailures, failures...)
+	}
+	return totalFailures, nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,85 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package test
+
+import (
+	"bytes"
+	"strings"
+)
+
+//------------------------------------------------------------------------------
+
+// Diff returns a human readable line diff between an expected and an actual
+// string, where lines prefixed with a '-' are expected but missing and lines
+// prefixed with a '+' are present but not expected.
+func Diff(expected, actual string) string {
+	expLines := strings.Split(expected, "\n")
+	actLines := strings.Split(actual, "\n")
+
+	// Calculate the longest common subsequence table of the two line slices.
+	lcs := make([][]int, len(expLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(actLines)+1)
+	}
+	for i := len(expLines) - 1; i >= 0; i-- {
+		for j := len(actLines) - 1; j >= 0; j-- {
+			if expLines[i] == actLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	writeLine := func(prefix, line string) {
+		buf.WriteString(prefix)
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+
+	i, j := 0, 0
+	for i < len(expLines) && j < len(actLines) {
+		switch {
+		case expLines[i] == actLines[j]:
+			writeLine("  ", expLines[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			writeLine("- ", expLines[i])
+			i++
+		default:
+			writeLine("+ ", actLines[j])
+			j++
+		}
+	}
+	for ; i < len(expLines); i++ {
+		writeLine("- ", expLines[i])
+	}
+	for ; j < len(actLines); j++ {
+		writeLine("+ ", actLines[j])
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,179 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/config"
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/manager"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/processor"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// DefaultTargetProcessors is the processors path targeted by test cases that
+// do not specify one.
+const DefaultTargetProcessors = "pipeline.processors"
+
+// envLock prevents concurrent config parses from observing the environment
+// variables of each other.
+var envLock sync.Mutex
+
+//------------------------------------------------------------------------------
+
+// ProcessorsProvider consumes a Benthos config and, given a target path within
+// that config, creates the processors found there along with the resources
+// they depend on.
+type ProcessorsProvider struct {
+	configPath string
+	managers   map[string]*manager.Type
+
+	logger log.Modular
+	stats  metrics.Type
+}
+
+// NewProcessorsProvider returns a new processors provider aimed at a filepath.
+func NewProcessorsProvider(filepath string, opts ...func(*ProcessorsProvider)) *ProcessorsProvider {
+	p := &ProcessorsProvider{
+		configPath: filepath,
+		managers:   map[string]*manager.Type{},
+		logger:     log.Noop(),
+		stats:      metrics.Noop(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// OptSetLogger sets the logger used by the processors created by the
+// provider.
+func OptSetLogger(l log.Modular) func(*ProcessorsProvider) {
+	return func(p *ProcessorsProvider) {
+		p.logger = l
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Provide attempts to create the processors of a target path within a config
+// with a map of environment variables set for the duration of the parse.
+func (p *ProcessorsProvider) Provide(target string, environment map[string]string) ([]types.Processor, error) {
+	conf, err := p.readConfig(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	var procConfs []processor.Config
+	switch strings.TrimSuffix(target, ".processors") {
+	case "input":
+		procConfs = conf.Input.Processors
+	case "pipeline", "":
+		procConfs = conf.Pipeline.Processors
+	case "output":
+		procConfs = conf.Output.Processors
+	default:
+		return nil, fmt.Errorf("target processors path not recognised: %v", target)
+	}
+
+	mgr, err := p.getManager(conf.Manager, environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialise resources: %v", err)
+	}
+
+	procs := make([]types.Processor, len(procConfs))
+	for i, pConf := range procConfs {
+		prefix := fmt.Sprintf("%v.%v", target, i)
+		if procs[i], err = processor.New(
+			pConf, mgr,
+			p.logger.NewModule("."+prefix),
+			metrics.Namespaced(p.stats, prefix),
+		); err != nil {
+			return nil, fmt.Errorf("failed to create processor '%v': %v", prefix, err)
+		}
+	}
+	return procs, nil
+}
+
+// Close shuts down any resources created by the provider.
+func (p *ProcessorsProvider) Close() {
+	for _, mgr := range p.managers {
+		mgr.CloseAsync()
+	}
+	for _, mgr := range p.managers {
+		mgr.WaitForClose(time.Second)
+	}
+	p.managers = map[string]*manager.Type{}
+}
+
+//------------------------------------------------------------------------------
+
+// getManager returns a resource manager for an environment, resources are
+// shared between test cases that have matching environments.
+func (p *ProcessorsProvider) getManager(conf manager.Config, environment map[string]string) (*manager.Type, error) {
+	envKey, err := json.Marshal(environment)
+	if err != nil {
+		return nil, err
+	}
+	if mgr, exists := p.managers[string(envKey)]; exists {
+		return mgr, nil
+	}
+	mgr, err := manager.New(conf, types.NoopMgr(), p.logger, p.stats)
+	if err != nil {
+		return nil, err
+	}
+	p.managers[string(envKey)] = mgr
+	return mgr, nil
+}
+
+// readConfig parses the target config file with environment variables set
+// temporarily for the duration of the parse.
+func (p *ProcessorsProvider) readConfig(environment map[string]string) (config.Type, error) {
+	envLock.Lock()
+	defer envLock.Unlock()
+
+	for k, v := range environment {
+		originalVal, exists := os.LookupEnv(k)
+		os.Setenv(k, v)
+		if exists {
+			defer os.Setenv(k, originalVal)
+		} else {
+			defer os.Unsetenv(k)
+		}
+	}
+
+	conf := config.New()
+	if _, err := config.Read(p.configPath, true, &conf); err != nil {
+		return conf, fmt.Errorf("failed to parse config file '%v': %v", p.configPath, err)
+	}
+	return conf, nil
+}
+
+//------------------------------------------------------------------------------
@@ -27,6 +27,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/Jeffail/benthos/lib/log"
 	"github.com/Jeffail/benthos/lib/util/config"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -50,12 +51,18 @@ type TypeSpec struct {
 // Constructors is a map of all tracer types with their specs.
 var Constructors = map[string]TypeSpec{}
 
+// Description returns a markdown formatted description of the tracer type.
+func (t TypeSpec) Description() string {
+	return t.description
+}
+
 //------------------------------------------------------------------------------
 
 // String constants representing each tracer type.
 const (
 	TypeJaeger = "jaeger"
 	TypeNone   = "none"
+	TypeOTLP   = "otlp"
 )
 
 //------------------------------------------------------------------------------
@@ -73,6 +80,7 @@ type Config struct {
 	Type   string       `json:"type" yaml:"type"`
 	Jaeger JaegerConfig `json:"jaeger" yaml:"jaeger"`
 	None   struct{}     `json:"none" yaml:"none"`
+	OTLP   OTLPConfig   `json:"otlp" yaml:"otlp"`
 }
 
 // NewConfig returns a configuration struct fully populated with default values.
@@ -81,6 +89,7 @@ func NewConfig() Config {
 		Type:   TypeNone,
 		Jaeger: NewJaegerConfig(),
 		None:   struct{}{},
+		OTLP:   NewOTLPConfig(),
 	}
 }
 
@@ -155,9 +164,14 @@ processors create spans, and so opentracing is a great way to analyse the
 pathways of individual messages as they progress through a Benthos instance.
 
 Some inputs, such as ` + "`http_server` and `http_client`" + `, are capable of
-extracting a root span from the source of the message (HTTP headers). This is
-a work in progress and should eventually expand so that all inputs have a way of
-doing so.
+extracting a root span from the source of the message (HTTP headers). The
+` + "`kafka`, `kafka_balanced` and `amqp`" + ` inputs are able to do the same
+from a [W3C](https://www.w3.org/TR/trace-context/) ` + "`traceparent`" + ` header
+when ` + "`extract_traceparent`" + ` is enabled, and the ` + "`kafka` and `amqp`" + `
+outputs can send one with ` + "`inject_traceparent`" + `, allowing traces to span
+from the producer of a message through Benthos to its consumers. This is a work
+in progress and should eventually expand so that all inputs have a way of doing
+so.
 
 A tracer config section looks like this:
 
@@ -215,6 +229,21 @@ func Descriptions() string {
 	return buf.String()
 }
 
+// loggerSetter is implemented by tracers that log errors.
+type loggerSetter interface {
+	setLogger(log.Modular)
+}
+
+// OptSetLogger sets the logger used by tracers to report errors, such as spans
+// that fail to send.
+func OptSetLogger(log log.Modular) func(Type) {
+	return func(t Type) {
+		if l, ok := t.(loggerSetter); ok {
+			l.setLogger(log)
+		}
+	}
+}
+
 // New creates a tracer type based on a configuration.
 func New(conf Config, opts ...func(Type)) (Type, error) {
 	if c, ok := Constructors[conf.Type]; ok {
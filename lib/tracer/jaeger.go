@@ -39,7 +39,12 @@ func init() {
 		description: `
 Send spans to a Jaeger agent.
 
-Available sampler types are: const, probabilistic, ratelimiting and remote.`,
+Available sampler types are: const, probabilistic, ratelimiting and remote.
+
+Span contexts are extracted from HTTP headers and message metadata in either
+the jaeger format or the [W3C Trace Context](https://www.w3.org/TR/trace-context/)
+` + "`traceparent`" + ` format, and are injected into message metadata as a
+` + "`traceparent`" + `.`,
 	}
 }
 
@@ -84,24 +89,13 @@ func NewJaeger(config Config, opts ...func(Type)) (Type, error) {
 		opt(j)
 	}
 
-	var sampler *jaegercfg.SamplerConfig
-	if sType := config.Jaeger.SamplerType; len(sType) > 0 {
-		sampler = &jaegercfg.SamplerConfig{
-			Param:             config.Jaeger.SamplerParam,
-			SamplingServerURL: config.Jaeger.SamplerManagerAddress,
-		}
-		switch strings.ToLower(sType) {
-		case "const":
-			sampler.Type = jaeger.SamplerTypeConst
-		case "probabilistic":
-			sampler.Type = jaeger.SamplerTypeProbabilistic
-		case "ratelimiting":
-			sampler.Type = jaeger.SamplerTypeRateLimiting
-		case "remote":
-			sampler.Type = jaeger.SamplerTypeRemote
-		default:
-			return nil, fmt.Errorf("unrecognised sampler type: %v", sType)
-		}
+	sampler, err := samplerConfig(
+		config.Jaeger.SamplerType,
+		config.Jaeger.SamplerParam,
+		config.Jaeger.SamplerManagerAddress,
+	)
+	if err != nil {
+		return nil, err
 	}
 
 	cfg := jaegercfg.Configuration{
@@ -136,7 +130,7 @@ func NewJaeger(config Config, opts ...func(Type)) (Type, error) {
 		cfg.Reporter = reporterConf
 	}
 
-	tracer, closer, err := cfg.NewTracer()
+	tracer, closer, err := cfg.NewTracer(traceParentOptions()...)
 	if err != nil {
 		return nil, err
 	}
@@ -148,6 +142,31 @@ func NewJaeger(config Config, opts ...func(Type)) (Type, error) {
 
 //------------------------------------------------------------------------------
 
+func samplerConfig(sType string, param float64, managerAddress string) (*jaegercfg.SamplerConfig, error) {
+	if len(sType) == 0 {
+		return nil, nil
+	}
+	sampler := &jaegercfg.SamplerConfig{
+		Param:             param,
+		SamplingServerURL: managerAddress,
+	}
+	switch strings.ToLower(sType) {
+	case "const":
+		sampler.Type = jaeger.SamplerTypeConst
+	case "probabilistic":
+		sampler.Type = jaeger.SamplerTypeProbabilistic
+	case "ratelimiting":
+		sampler.Type = jaeger.SamplerTypeRateLimiting
+	case "remote":
+		sampler.Type = jaeger.SamplerTypeRemote
+	default:
+		return nil, fmt.Errorf("unrecognised sampler type: %v", sType)
+	}
+	return sampler, nil
+}
+
+//------------------------------------------------------------------------------
+
 // Close stops the tracer.
 func (j *Jaeger) Close() error {
 	if j.closer != nil {
@@ -0,0 +1,504 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	jaegercfg "github.com/uber/jaeger-client-go/config"
+	jthrift "github.com/uber/jaeger-client-go/thrift-gen/jaeger"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeOTLP] = TypeSpec{
+		constructor: NewOTLP,
+		description: `
+Send spans to an [OpenTelemetry](https://opentelemetry.io/) collector, or any
+other receiver that supports the OTLP/HTTP protocol with JSON encoding. The
+` + "`url`" + ` should be the full path of the traces endpoint of the receiver,
+which is usually ` + "`/v1/traces`" + `.
+
+Spans are sent in batches at the interval specified by ` + "`flush_interval`" + `,
+with each request being given custom headers from the field ` + "`headers`" + `.
+Batches that fail to send are dropped and logged, as are spans that arrive whilst
+too many are waiting to be sent.
+
+Available sampler types are: const, probabilistic and ratelimiting.
+
+Span contexts are extracted from HTTP headers and message metadata in either
+the jaeger format or the [W3C Trace Context](https://www.w3.org/TR/trace-context/)
+` + "`traceparent`" + ` format, and are injected into message metadata as a
+` + "`traceparent`" + `.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// OTLPConfig is config for the OTLP tracer type.
+type OTLPConfig struct {
+	URL           string            `json:"url" yaml:"url"`
+	ServiceName   string            `json:"service_name" yaml:"service_name"`
+	SamplerType   string            `json:"sampler_type" yaml:"sampler_type"`
+	SamplerParam  float64           `json:"sampler_param" yaml:"sampler_param"`
+	Tags          map[string]string `json:"tags" yaml:"tags"`
+	Headers       map[string]string `json:"headers" yaml:"headers"`
+	Timeout       string            `json:"timeout" yaml:"timeout"`
+	FlushInterval string            `json:"flush_interval" yaml:"flush_interval"`
+}
+
+// NewOTLPConfig creates an OTLPConfig struct with default values.
+func NewOTLPConfig() OTLPConfig {
+	return OTLPConfig{
+		URL:           "http://localhost:4318/v1/traces",
+		ServiceName:   "benthos",
+		SamplerType:   "const",
+		SamplerParam:  1.0,
+		Tags:          map[string]string{},
+		Headers:       map[string]string{},
+		Timeout:       "5s",
+		FlushInterval: "1s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// OTLP is a tracer with the capability to push spans to an OTLP receiver.
+type OTLP struct {
+	closer io.Closer
+	log    log.Modular
+}
+
+// NewOTLP creates and returns a new OTLP object.
+func NewOTLP(config Config, opts ...func(Type)) (Type, error) {
+	o := &OTLP{
+		log: log.Noop(),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if len(config.OTLP.URL) == 0 {
+		return nil, fmt.Errorf("a url must be specified")
+	}
+
+	sampler, err := samplerConfig(config.OTLP.SamplerType, config.OTLP.SamplerParam, "")
+	if err != nil {
+		return nil, err
+	}
+	if sampler == nil {
+		sampler = &jaegercfg.SamplerConfig{
+			Type:  jaeger.SamplerTypeConst,
+			Param: 1,
+		}
+	} else if sampler.Type == jaeger.SamplerTypeRemote {
+		return nil, fmt.Errorf("sampler type %v is not supported", sampler.Type)
+	}
+
+	var timeout, flushInterval time.Duration
+	if i := config.OTLP.Timeout; len(i) > 0 {
+		if timeout, err = time.ParseDuration(i); err != nil {
+			return nil, fmt.Errorf("failed to parse timeout '%s': %v", i, err)
+		}
+	}
+	flushInterval = time.Second
+	if i := config.OTLP.FlushInterval; len(i) > 0 {
+		if flushInterval, err = time.ParseDuration(i); err != nil {
+			return nil, fmt.Errorf("failed to parse flush interval '%s': %v", i, err)
+		}
+		if flushInterval <= 0 {
+			return nil, fmt.Errorf("flush interval must be greater than zero: %v", i)
+		}
+	}
+
+	cfg := jaegercfg.Configuration{
+		ServiceName: config.OTLP.ServiceName,
+		Sampler:     sampler,
+	}
+
+	for k, v := range config.OTLP.Tags {
+		cfg.Tags = append(cfg.Tags, opentracing.Tag{
+			Key:   k,
+			Value: v,
+		})
+	}
+
+	reporter := newOTLPReporter(config.OTLP.URL, config.OTLP.Headers, timeout, flushInterval, o.log)
+	tracer, closer, err := cfg.NewTracer(append(
+		traceParentOptions(),
+		jaegercfg.Reporter(reporter),
+		jaegercfg.Gen128Bit(true),
+	)...)
+	if err != nil {
+		reporter.Close()
+		return nil, err
+	}
+	opentracing.SetGlobalTracer(tracer)
+	o.closer = closer
+
+	return o, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Close stops the tracer, flushing any pending spans.
+func (o *OTLP) Close() error {
+	if o.closer != nil {
+		o.closer.Close()
+		o.closer = nil
+	}
+	return nil
+}
+
+// setLogger sets the logger used to report spans that fail to send.
+func (o *OTLP) setLogger(log log.Modular) {
+	o.log = log
+}
+
+//------------------------------------------------------------------------------
+
+const (
+	otlpBatchSize  = 512
+	otlpMaxPending = 8192
+)
+
+// otlpReporter is a jaeger.Reporter that sends finished spans in batches to an
+// OTLP/HTTP receiver using the JSON encoding.
+type otlpReporter struct {
+	url     string
+	headers map[string]string
+	client  http.Client
+
+	mut      sync.Mutex
+	resource *otlpResource
+	pending  []otlpSpan
+	dropped  int
+
+	log log.Modular
+
+	flushInterval time.Duration
+	flushChan     chan struct{}
+
+	closeOnce  sync.Once
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+func newOTLPReporter(
+	url string,
+	headers map[string]string,
+	timeout, flushInterval time.Duration,
+	log log.Modular,
+) *otlpReporter {
+	r := &otlpReporter{
+		url:           url,
+		headers:       headers,
+		log:           log,
+		flushInterval: flushInterval,
+		flushChan:     make(chan struct{}, 1),
+		closeChan:     make(chan struct{}),
+		closedChan:    make(chan struct{}),
+	}
+	r.client.Timeout = timeout
+	go r.loop()
+	return r
+}
+
+// Report adds a finished span to the pending batch. Spans are dropped when the
+// pending batch is full.
+func (r *otlpReporter) Report(span *jaeger.Span) {
+	s := newOTLPSpan(jaeger.BuildJaegerThrift(span))
+
+	r.mut.Lock()
+	if r.resource == nil {
+		r.resource = newOTLPResource(jaeger.BuildJaegerProcessThrift(span))
+	}
+	if len(r.pending) < otlpMaxPending {
+		r.pending = append(r.pending, s)
+	} else {
+		r.dropped++
+	}
+	full := len(r.pending) >= otlpBatchSize
+	r.mut.Unlock()
+
+	if full {
+		select {
+		case r.flushChan <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Close flushes any pending spans and stops the reporter.
+func (r *otlpReporter) Close() {
+	r.closeOnce.Do(func() {
+		close(r.closeChan)
+	})
+	<-r.closedChan
+}
+
+func (r *otlpReporter) loop() {
+	defer close(r.closedChan)
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	flush := func() {
+		if err := r.flush(); err != nil {
+			r.log.Errorf("Failed to send spans: %v\n", err)
+		}
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-r.flushChan:
+		case <-r.closeChan:
+			flush()
+			return
+		}
+		flush()
+	}
+}
+
+func (r *otlpReporter) flush() error {
+	r.mut.Lock()
+	spans, resource, dropped := r.pending, r.resource, r.dropped
+	r.pending, r.dropped = nil, 0
+	r.mut.Unlock()
+
+	if dropped > 0 {
+		r.log.Warnf("Dropped %v spans as too many were pending\n", dropped)
+	}
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(otlpTracesRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: *resource,
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "benthos"},
+				Spans: spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", r.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range r.headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code: %v", res.StatusCode)
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// OTLP span kinds and status codes.
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindServer   = 2
+	otlpSpanKindClient   = 3
+	otlpSpanKindProducer = 4
+	otlpSpanKindConsumer = 5
+
+	otlpStatusCodeError = 2
+)
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code int `json:"code,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BytesValue  []byte   `json:"bytesValue,omitempty"`
+}
+
+//------------------------------------------------------------------------------
+
+func newOTLPResource(process *jthrift.Process) *otlpResource {
+	serviceName := process.ServiceName
+	return &otlpResource{
+		Attributes: append([]otlpKeyValue{{
+			Key:   "service.name",
+			Value: otlpAnyValue{StringValue: &serviceName},
+		}}, newOTLPAttributes(process.Tags)...),
+	}
+}
+
+func newOTLPSpan(span *jthrift.Span) otlpSpan {
+	start := span.StartTime * int64(time.Microsecond)
+	end := start + span.Duration*int64(time.Microsecond)
+
+	s := otlpSpan{
+		TraceID:           fmt.Sprintf("%016x%016x", uint64(span.TraceIdHigh), uint64(span.TraceIdLow)),
+		SpanID:            fmt.Sprintf("%016x", uint64(span.SpanId)),
+		Name:              span.OperationName,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(start, 10),
+		EndTimeUnixNano:   strconv.FormatInt(end, 10),
+		Attributes:        newOTLPAttributes(span.Tags),
+	}
+	if span.ParentSpanId != 0 {
+		s.ParentSpanID = fmt.Sprintf("%016x", uint64(span.ParentSpanId))
+	}
+
+	for _, tag := range span.Tags {
+		switch {
+		case tag.Key == "span.kind" && tag.VStr != nil:
+			switch *tag.VStr {
+			case "server":
+				s.Kind = otlpSpanKindServer
+			case "client":
+				s.Kind = otlpSpanKindClient
+			case "producer":
+				s.Kind = otlpSpanKindProducer
+			case "consumer":
+				s.Kind = otlpSpanKindConsumer
+			}
+		case tag.Key == "error" && tag.VBool != nil && *tag.VBool:
+			s.Status.Code = otlpStatusCodeError
+		}
+	}
+
+	for _, l := range span.Logs {
+		e := otlpEvent{
+			TimeUnixNano: strconv.FormatInt(l.Timestamp*int64(time.Microsecond), 10),
+			Name:         "log",
+		}
+		var fields []*jthrift.Tag
+		for _, f := range l.Fields {
+			if f.Key == "event" && f.VStr != nil {
+				e.Name = *f.VStr
+			} else {
+				fields = append(fields, f)
+			}
+		}
+		e.Attributes = newOTLPAttributes(fields)
+		s.Events = append(s.Events, e)
+	}
+	return s
+}
+
+func newOTLPAttributes(tags []*jthrift.Tag) []otlpKeyValue {
+	var attrs []otlpKeyValue
+	for _, tag := range tags {
+		kv := otlpKeyValue{Key: tag.Key}
+		switch tag.VType {
+		case jthrift.TagType_STRING:
+			kv.Value.StringValue = tag.VStr
+		case jthrift.TagType_BOOL:
+			kv.Value.BoolValue = tag.VBool
+		case jthrift.TagType_LONG:
+			if tag.VLong != nil {
+				v := strconv.FormatInt(*tag.VLong, 10)
+				kv.Value.IntValue = &v
+			}
+		case jthrift.TagType_DOUBLE:
+			kv.Value.DoubleValue = tag.VDouble
+		case jthrift.TagType_BINARY:
+			kv.Value.BytesValue = tag.VBinary
+		}
+		attrs = append(attrs, kv)
+	}
+	return attrs
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,328 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/message/tracing"
+	"github.com/opentracing/opentracing-go"
+	olog "github.com/opentracing/opentracing-go/log"
+)
+
+//------------------------------------------------------------------------------
+
+func newOTLPTestServer(t *testing.T) (*httptest.Server, <-chan otlpTracesRequest) {
+	reqChan := make(chan otlpTracesRequest, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exp, act := "/v1/traces", r.URL.Path; exp != act {
+			t.Errorf("Wrong path: %v != %v", act, exp)
+		}
+		if exp, act := "application/json", r.Header.Get("Content-Type"); exp != act {
+			t.Errorf("Wrong content type: %v != %v", act, exp)
+		}
+		if exp, act := "bar", r.Header.Get("foo"); exp != act {
+			t.Errorf("Wrong custom header: %v != %v", act, exp)
+		}
+		var req otlpTracesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		reqChan <- req
+	}))
+	return ts, reqChan
+}
+
+func newOTLPTestTracer(t *testing.T, url string) Type {
+	conf := NewConfig()
+	conf.Type = TypeOTLP
+	conf.OTLP.URL = url + "/v1/traces"
+	conf.OTLP.ServiceName = "benthos_test"
+	conf.OTLP.Headers["foo"] = "bar"
+	conf.OTLP.Tags["baz"] = "qux"
+	conf.OTLP.FlushInterval = "1h"
+
+	tr, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func resetGlobalTracer() {
+	opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+}
+
+func TestOTLPSpans(t *testing.T) {
+	defer resetGlobalTracer()
+
+	ts, reqChan := newOTLPTestServer(t)
+	defer ts.Close()
+
+	tr := newOTLPTestTracer(t, ts.URL)
+
+	parent := opentracing.StartSpan("foo")
+	child := opentracing.StartSpan("bar", opentracing.ChildOf(parent.Context()))
+	child.SetTag("span.kind", "producer")
+	child.SetTag("error", true)
+	child.LogFields(
+		olog.String("event", "error"),
+		olog.String("type", "it broke"),
+	)
+	child.Finish()
+	parent.Finish()
+
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var req otlpTracesRequest
+	select {
+	case req = <-reqChan:
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	if exp, act := 1, len(req.ResourceSpans); exp != act {
+		t.Fatalf("Wrong count of resource spans: %v != %v", act, exp)
+	}
+	resSpans := req.ResourceSpans[0]
+
+	attrs := map[string]string{}
+	for _, kv := range resSpans.Resource.Attributes {
+		if kv.Value.StringValue != nil {
+			attrs[kv.Key] = *kv.Value.StringValue
+		}
+	}
+	if exp, act := "benthos_test", attrs["service.name"]; exp != act {
+		t.Errorf("Wrong service name: %v != %v", act, exp)
+	}
+	if exp, act := "qux", attrs["baz"]; exp != act {
+		t.Errorf("Wrong tag: %v != %v", act, exp)
+	}
+
+	if exp, act := 1, len(resSpans.ScopeSpans); exp != act {
+		t.Fatalf("Wrong count of scope spans: %v != %v", act, exp)
+	}
+	spans := resSpans.ScopeSpans[0].Spans
+	if exp, act := 2, len(spans); exp != act {
+		t.Fatalf("Wrong count of spans: %v != %v", act, exp)
+	}
+
+	childSpan, parentSpan := spans[0], spans[1]
+	if exp, act := "bar", childSpan.Name; exp != act {
+		t.Errorf("Wrong span name: %v != %v", act, exp)
+	}
+	if exp, act := "foo", parentSpan.Name; exp != act {
+		t.Errorf("Wrong span name: %v != %v", act, exp)
+	}
+	if exp, act := 32, len(parentSpan.TraceID); exp != act {
+		t.Errorf("Wrong trace id length: %v != %v", act, exp)
+	}
+	if exp, act := 16, len(parentSpan.SpanID); exp != act {
+		t.Errorf("Wrong span id length: %v != %v", act, exp)
+	}
+	if exp, act := parentSpan.TraceID, childSpan.TraceID; exp != act {
+		t.Errorf("Wrong trace id: %v != %v", act, exp)
+	}
+	if exp, act := parentSpan.SpanID, childSpan.ParentSpanID; exp != act {
+		t.Errorf("Wrong parent span id: %v != %v", act, exp)
+	}
+	if exp, act := "", parentSpan.ParentSpanID; exp != act {
+		t.Errorf("Wrong parent span id: %v != %v", act, exp)
+	}
+	if exp, act := otlpSpanKindProducer, childSpan.Kind; exp != act {
+		t.Errorf("Wrong span kind: %v != %v", act, exp)
+	}
+	if exp, act := otlpSpanKindInternal, parentSpan.Kind; exp != act {
+		t.Errorf("Wrong span kind: %v != %v", act, exp)
+	}
+	if exp, act := otlpStatusCodeError, childSpan.Status.Code; exp != act {
+		t.Errorf("Wrong status code: %v != %v", act, exp)
+	}
+	if exp, act := 0, parentSpan.Status.Code; exp != act {
+		t.Errorf("Wrong status code: %v != %v", act, exp)
+	}
+	if childSpan.StartTimeUnixNano == "" || childSpan.EndTimeUnixNano == "" {
+		t.Error("Expected span timestamps")
+	}
+
+	if exp, act := 1, len(childSpan.Events); exp != act {
+		t.Fatalf("Wrong count of events: %v != %v", act, exp)
+	}
+	event := childSpan.Events[0]
+	if exp, act := "error", event.Name; exp != act {
+		t.Errorf("Wrong event name: %v != %v", act, exp)
+	}
+	if exp, act := 1, len(event.Attributes); exp != act {
+		t.Fatalf("Wrong count of event attributes: %v != %v", act, exp)
+	}
+	if exp, act := "type", event.Attributes[0].Key; exp != act {
+		t.Errorf("Wrong event attribute: %v != %v", act, exp)
+	}
+	if v := event.Attributes[0].Value.StringValue; v == nil || *v != "it broke" {
+		t.Errorf("Wrong event attribute value: %v", v)
+	}
+}
+
+func TestOTLPTraceParent(t *testing.T) {
+	defer resetGlobalTracer()
+
+	ts, reqChan := newOTLPTestServer(t)
+	defer ts.Close()
+
+	tr := newOTLPTestTracer(t, ts.URL)
+
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	msg.Get(0).Metadata().Set(tracing.TraceParentKey, traceParent)
+
+	tracing.InitSpansFromTraceParent("input_test", msg)
+
+	injected := tracing.TraceParent(msg.Get(0))
+	if exp, act := 55, len(injected); exp != act {
+		t.Fatalf("Wrong traceparent length: %v != %v", act, exp)
+	}
+	if exp, act := traceParent[:36], injected[:36]; exp != act {
+		t.Errorf("Wrong trace id: %v != %v", act, exp)
+	}
+	if exp, act := "-01", injected[52:]; exp != act {
+		t.Errorf("Wrong trace flags: %v != %v", act, exp)
+	}
+	if injected == traceParent {
+		t.Error("Expected a new span id")
+	}
+	if tracing.TraceParent(msg.Get(1))[3:35] == traceParent[3:35] {
+		t.Error("Expected a new trace for a part without a traceparent")
+	}
+
+	tracing.FinishSpans(msg)
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var req otlpTracesRequest
+	select {
+	case req = <-reqChan:
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if exp, act := 2, len(spans); exp != act {
+		t.Fatalf("Wrong count of spans: %v != %v", act, exp)
+	}
+	if exp, act := "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].TraceID; exp != act {
+		t.Errorf("Wrong trace id: %v != %v", act, exp)
+	}
+	if exp, act := "00f067aa0ba902b7", spans[0].ParentSpanID; exp != act {
+		t.Errorf("Wrong parent span id: %v != %v", act, exp)
+	}
+	if exp, act := "", spans[1].ParentSpanID; exp != act {
+		t.Errorf("Wrong parent span id: %v != %v", act, exp)
+	}
+}
+
+func TestOTLPFlushErrors(t *testing.T) {
+	defer resetGlobalTracer()
+
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.Type = TypeOTLP
+	conf.OTLP.URL = ts.URL + "/v1/traces"
+	conf.OTLP.FlushInterval = "1h"
+
+	logBuf := &bytes.Buffer{}
+	logConf := log.NewConfig()
+	logConf.LogLevel = "WARN"
+
+	tr, err := New(conf, OptSetLogger(log.New(logBuf, logConf)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first full batch blocks on the server, causing later spans to be
+	// dropped once too many are pending.
+	for i := 0; i < otlpBatchSize+otlpMaxPending*2; i++ {
+		opentracing.StartSpan("foo").Finish()
+	}
+	close(release)
+
+	if err = tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	logStr := logBuf.String()
+	if exp := "Failed to send spans: unexpected status code: 500"; !strings.Contains(logStr, exp) {
+		t.Errorf("Expected log to contain '%v': %v", exp, logStr)
+	}
+	if exp := "spans as too many were pending"; !strings.Contains(logStr, exp) {
+		t.Errorf("Expected log to contain '%v': %v", exp, logStr)
+	}
+}
+
+func TestOTLPBadConfig(t *testing.T) {
+	tests := map[string]func(c *Config){
+		"no url": func(c *Config) {
+			c.OTLP.URL = ""
+		},
+		"bad sampler": func(c *Config) {
+			c.OTLP.SamplerType = "nope"
+		},
+		"remote sampler": func(c *Config) {
+			c.OTLP.SamplerType = "remote"
+		},
+		"bad flush interval": func(c *Config) {
+			c.OTLP.FlushInterval = "nope"
+		},
+		"zero flush interval": func(c *Config) {
+			c.OTLP.FlushInterval = "0s"
+		},
+		"bad timeout": func(c *Config) {
+			c.OTLP.Timeout = "nope"
+		},
+	}
+
+	for name, test := range tests {
+		conf := NewConfig()
+		conf.Type = TypeOTLP
+		test(&conf)
+		if _, err := New(conf); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,160 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tracer
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	jaegercfg "github.com/uber/jaeger-client-go/config"
+)
+
+//------------------------------------------------------------------------------
+
+// traceParentHeader is the key used to propagate span contexts in the W3C Trace
+// Context format.
+const traceParentHeader = "traceparent"
+
+// traceParentPropagator is an injector and extractor of span contexts in the W3C
+// Trace Context format. Extraction falls back to the native format of the
+// tracer when a traceparent isn't present.
+type traceParentPropagator struct {
+	fallback jaeger.Extractor
+}
+
+// Inject writes a span context as a traceparent to a TextMap carrier.
+func (t traceParentPropagator) Inject(sc jaeger.SpanContext, abstractCarrier interface{}) error {
+	carrier, ok := abstractCarrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	carrier.Set(traceParentHeader, formatTraceParent(sc))
+	return nil
+}
+
+// Extract reads a span context from the traceparent of a TextMap carrier.
+func (t traceParentPropagator) Extract(abstractCarrier interface{}) (jaeger.SpanContext, error) {
+	carrier, ok := abstractCarrier.(opentracing.TextMapReader)
+	if !ok {
+		return jaeger.SpanContext{}, opentracing.ErrInvalidCarrier
+	}
+	var traceParent string
+	if err := carrier.ForeachKey(func(k, v string) error {
+		if strings.ToLower(k) == traceParentHeader {
+			traceParent = v
+		}
+		return nil
+	}); err != nil {
+		return jaeger.SpanContext{}, err
+	}
+	if len(traceParent) == 0 {
+		if t.fallback == nil {
+			return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+		}
+		return t.fallback.Extract(abstractCarrier)
+	}
+	sc, err := parseTraceParent(traceParent)
+	if err != nil {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	return sc, nil
+}
+
+// traceParentOptions returns jaeger tracer options that register the W3C Trace
+// Context format for both TextMap and HTTPHeaders carriers. Span contexts are
+// injected into TextMap carriers as a traceparent, and can be extracted from
+// either carrier as a traceparent or in the native jaeger format.
+func traceParentOptions() []jaegercfg.Option {
+	headers := (&jaeger.HeadersConfig{}).ApplyDefaults()
+	metrics := jaeger.NewNullMetrics()
+
+	textMap := traceParentPropagator{
+		fallback: jaeger.NewTextMapPropagator(headers, *metrics),
+	}
+	httpHeaders := traceParentPropagator{
+		fallback: jaeger.NewHTTPHeaderPropagator(headers, *metrics),
+	}
+	return []jaegercfg.Option{
+		jaegercfg.Injector(opentracing.TextMap, textMap),
+		jaegercfg.Extractor(opentracing.TextMap, textMap),
+		jaegercfg.Extractor(opentracing.HTTPHeaders, httpHeaders),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func formatTraceParent(sc jaeger.SpanContext) string {
+	flags := "00"
+	if sc.IsSampled() {
+		flags = "01"
+	}
+	traceID := sc.TraceID()
+	return fmt.Sprintf(
+		"00-%016x%016x-%016x-%v",
+		traceID.High, traceID.Low, uint64(sc.SpanID()), flags,
+	)
+}
+
+func parseTraceParent(v string) (jaeger.SpanContext, error) {
+	fields := strings.Split(strings.TrimSpace(v), "-")
+	if len(fields) < 4 {
+		return jaeger.SpanContext{}, fmt.Errorf("expected at least four fields, found %v", len(fields))
+	}
+
+	version, err := hex.DecodeString(fields[0])
+	if err != nil || len(version) != 1 || version[0] == 0xff {
+		return jaeger.SpanContext{}, fmt.Errorf("invalid version: %v", fields[0])
+	}
+	if version[0] == 0 && len(fields) != 4 {
+		return jaeger.SpanContext{}, fmt.Errorf("expected four fields, found %v", len(fields))
+	}
+
+	traceID, err := hex.DecodeString(fields[1])
+	if err != nil || len(traceID) != 16 {
+		return jaeger.SpanContext{}, fmt.Errorf("invalid trace id: %v", fields[1])
+	}
+	spanID, err := hex.DecodeString(fields[2])
+	if err != nil || len(spanID) != 8 {
+		return jaeger.SpanContext{}, fmt.Errorf("invalid span id: %v", fields[2])
+	}
+	flags, err := hex.DecodeString(fields[3])
+	if err != nil || len(flags) != 1 {
+		return jaeger.SpanContext{}, fmt.Errorf("invalid trace flags: %v", fields[3])
+	}
+
+	id := jaeger.TraceID{
+		High: binary.BigEndian.Uint64(traceID[:8]),
+		Low:  binary.BigEndian.Uint64(traceID[8:]),
+	}
+	if !id.IsValid() {
+		return jaeger.SpanContext{}, fmt.Errorf("invalid trace id: %v", fields[1])
+	}
+	sid := jaeger.SpanID(binary.BigEndian.Uint64(spanID))
+	if sid == 0 {
+		return jaeger.SpanContext{}, fmt.Errorf("invalid span id: %v", fields[2])
+	}
+	return jaeger.NewSpanContext(id, sid, 0, flags[0]&0x01 == 0x01, nil), nil
+}
+
+//------------------------------------------------------------------------------
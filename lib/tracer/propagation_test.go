@@ -0,0 +1,199 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tracer
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+//------------------------------------------------------------------------------
+
+func TestTraceParentParse(t *testing.T) {
+	type testCase struct {
+		input   string
+		traceID jaeger.TraceID
+		spanID  jaeger.SpanID
+		sampled bool
+	}
+
+	tests := []testCase{
+		{
+			input:   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			traceID: jaeger.TraceID{High: 0x4bf92f3577b34da6, Low: 0xa3ce929d0e0e4736},
+			spanID:  0x00f067aa0ba902b7,
+			sampled: true,
+		},
+		{
+			input:   "00-00000000000000000000000000000001-0000000000000002-00",
+			traceID: jaeger.TraceID{Low: 1},
+			spanID:  2,
+			sampled: false,
+		},
+		{
+			input:   "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03-future",
+			traceID: jaeger.TraceID{High: 0x4bf92f3577b34da6, Low: 0xa3ce929d0e0e4736},
+			spanID:  0x00f067aa0ba902b7,
+			sampled: true,
+		},
+	}
+
+	for _, test := range tests {
+		sc, err := parseTraceParent(test.input)
+		if err != nil {
+			t.Errorf("%v: %v", test.input, err)
+			continue
+		}
+		if exp, act := test.traceID, sc.TraceID(); exp != act {
+			t.Errorf("%v: wrong trace id: %v != %v", test.input, act, exp)
+		}
+		if exp, act := test.spanID, sc.SpanID(); exp != act {
+			t.Errorf("%v: wrong span id: %v != %v", test.input, act, exp)
+		}
+		if exp, act := test.sampled, sc.IsSampled(); exp != act {
+			t.Errorf("%v: wrong sampled flag: %v != %v", test.input, act, exp)
+		}
+	}
+}
+
+func TestTraceParentParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"foo",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"0-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+	}
+
+	for _, test := range tests {
+		if _, err := parseTraceParent(test); err == nil {
+			t.Errorf("%v: expected error", test)
+		}
+	}
+}
+
+func TestTraceParentFormat(t *testing.T) {
+	sc := jaeger.NewSpanContext(
+		jaeger.TraceID{High: 0x4bf92f3577b34da6, Low: 0xa3ce929d0e0e4736},
+		0x00f067aa0ba902b7, 0, true, nil,
+	)
+	if exp, act := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", formatTraceParent(sc); exp != act {
+		t.Errorf("Wrong traceparent: %v != %v", act, exp)
+	}
+
+	sc = jaeger.NewSpanContext(jaeger.TraceID{Low: 1}, 2, 0, false, nil)
+	if exp, act := "00-00000000000000000000000000000001-0000000000000002-00", formatTraceParent(sc); exp != act {
+		t.Errorf("Wrong traceparent: %v != %v", act, exp)
+	}
+}
+
+func TestTraceParentPropagation(t *testing.T) {
+	tracer, closer := jaeger.NewTracer(
+		"benthos_test",
+		jaeger.NewConstSampler(true),
+		jaeger.NewNullReporter(),
+	)
+	defer closer.Close()
+
+	textMap := traceParentPropagator{
+		fallback: jaeger.NewTextMapPropagator((&jaeger.HeadersConfig{}).ApplyDefaults(), *jaeger.NewNullMetrics()),
+	}
+
+	span := tracer.StartSpan("foo")
+	defer span.Finish()
+
+	carrier := opentracing.TextMapCarrier{}
+	if err := textMap.Inject(span.Context().(jaeger.SpanContext), carrier); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 1, len(carrier); exp != act {
+		t.Fatalf("Wrong count of keys: %v != %v", act, exp)
+	}
+
+	sc, err := textMap.Extract(carrier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := span.Context().(jaeger.SpanContext).TraceID(), sc.TraceID(); exp != act {
+		t.Errorf("Wrong trace id: %v != %v", act, exp)
+	}
+	if exp, act := span.Context().(jaeger.SpanContext).SpanID(), sc.SpanID(); exp != act {
+		t.Errorf("Wrong span id: %v != %v", act, exp)
+	}
+
+	// Native jaeger format is used when a traceparent is missing.
+	nativeCarrier := opentracing.TextMapCarrier{}
+	if err = tracer.Inject(span.Context(), opentracing.TextMap, nativeCarrier); err != nil {
+		t.Fatal(err)
+	}
+	if sc, err = textMap.Extract(nativeCarrier); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := span.Context().(jaeger.SpanContext).SpanID(), sc.SpanID(); exp != act {
+		t.Errorf("Wrong span id: %v != %v", act, exp)
+	}
+
+	if _, err = textMap.Extract(opentracing.TextMapCarrier{}); err != opentracing.ErrSpanContextNotFound {
+		t.Errorf("Wrong error: %v != %v", err, opentracing.ErrSpanContextNotFound)
+	}
+	if _, err = textMap.Extract(opentracing.TextMapCarrier{"traceparent": "nope"}); err != opentracing.ErrSpanContextCorrupted {
+		t.Errorf("Wrong error: %v != %v", err, opentracing.ErrSpanContextCorrupted)
+	}
+}
+
+func TestTraceParentHTTPHeaders(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeJaeger
+
+	tr, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		tr.Close()
+		resetGlobalTracer()
+	}()
+
+	header := http.Header{}
+	header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	sc, err := opentracing.GlobalTracer().Extract(
+		opentracing.HTTPHeaders,
+		opentracing.HTTPHeadersCarrier(header),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := jaeger.SpanID(0x00f067aa0ba902b7), sc.(jaeger.SpanContext).SpanID(); exp != act {
+		t.Errorf("Wrong span id: %v != %v", act, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -54,6 +54,23 @@ type Cache interface {
 	// Closable
 }
 
+// CacheWithTTL is an optional extension of Cache implemented by caches that
+// support setting a TTL for individual keys.
+type CacheWithTTL interface {
+	Cache
+
+	// SetWithTTL attempts to set the value of a key with a TTL, after which the
+	// key expires. A TTL of zero results in the default TTL of the cache being
+	// used. Returns an error if the command fails.
+	SetWithTTL(key string, value []byte, ttl time.Duration) error
+
+	// AddWithTTL attempts to set the value of a key with a TTL only if the key
+	// does not already exist, returns an error if the key already exists or if
+	// the command fails. A TTL of zero results in the default TTL of the cache
+	// being used.
+	AddWithTTL(key string, value []byte, ttl time.Duration) error
+}
+
 //------------------------------------------------------------------------------
 
 // RateLimit is a strategy for limiting access to a shared resource, this
@@ -124,6 +141,23 @@ type Manager interface {
 	UnsetPipe(name string, t <-chan Transaction)
 }
 
+// DryRunner is an optional interface implemented by a Manager in order to
+// signal that components are being constructed only to validate their configs,
+// in which case side effects such as executing commands should be avoided.
+type DryRunner interface {
+	// DryRun returns true if components should avoid side effects.
+	DryRun() bool
+}
+
+// FailureTagger is an optional interface implemented by a Manager in order to
+// signal that processors should tag the message parts they fail with their
+// type, which is only needed when failed parts are routed to a dead letter
+// output.
+type FailureTagger interface {
+	// TagFailures returns true if processors should tag failed parts.
+	TagFailures() bool
+}
+
 //------------------------------------------------------------------------------
 
 // Closable defines a type that can be safely closed down and cleaned up. This
@@ -140,6 +174,17 @@ type Closable interface {
 	WaitForClose(timeout time.Duration) error
 }
 
+// Drainer is an optional interface implemented by inputs that are able to stop
+// consuming new data whilst continuing to resolve transactions that are already
+// in flight, allowing a stream to shut down without abandoning messages.
+type Drainer interface {
+	// DrainAsync triggers the input to stop reading new data and to close once
+	// all pending transactions have been resolved, but should not block the
+	// calling goroutine. Calling CloseAsync after DrainAsync abandons the
+	// drain and shuts the component down immediately.
+	DrainAsync()
+}
+
 //------------------------------------------------------------------------------
 
 // Producer is a type that sends messages as transactions and waits for a
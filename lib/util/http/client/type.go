@@ -34,6 +34,7 @@ import (
 
 	"github.com/Jeffail/benthos/lib/log"
 	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/message/tracing"
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/types"
 	"github.com/Jeffail/benthos/lib/util/http/auth"
@@ -48,18 +49,19 @@ import (
 
 // Config is a configuration struct for an HTTP client.
 type Config struct {
-	URL         string            `json:"url" yaml:"url"`
-	Verb        string            `json:"verb" yaml:"verb"`
-	Headers     map[string]string `json:"headers" yaml:"headers"`
-	RateLimit   string            `json:"rate_limit" yaml:"rate_limit"`
-	Timeout     string            `json:"timeout" yaml:"timeout"`
-	Retry       string            `json:"retry_period" yaml:"retry_period"`
-	MaxBackoff  string            `json:"max_retry_backoff" yaml:"max_retry_backoff"`
-	NumRetries  int               `json:"retries" yaml:"retries"`
-	BackoffOn   []int             `json:"backoff_on" yaml:"backoff_on"`
-	DropOn      []int             `json:"drop_on" yaml:"drop_on"`
-	TLS         tls.Config        `json:"tls" yaml:"tls"`
-	auth.Config `json:",inline" yaml:",inline"`
+	URL               string            `json:"url" yaml:"url"`
+	Verb              string            `json:"verb" yaml:"verb"`
+	Headers           map[string]string `json:"headers" yaml:"headers"`
+	RateLimit         string            `json:"rate_limit" yaml:"rate_limit"`
+	Timeout           string            `json:"timeout" yaml:"timeout"`
+	Retry             string            `json:"retry_period" yaml:"retry_period"`
+	MaxBackoff        string            `json:"max_retry_backoff" yaml:"max_retry_backoff"`
+	NumRetries        int               `json:"retries" yaml:"retries"`
+	BackoffOn         []int             `json:"backoff_on" yaml:"backoff_on"`
+	DropOn            []int             `json:"drop_on" yaml:"drop_on"`
+	InjectTraceParent bool              `json:"inject_traceparent" yaml:"inject_traceparent"`
+	TLS               tls.Config        `json:"tls" yaml:"tls"`
+	auth.Config       `json:",inline" yaml:",inline"`
 }
 
 // NewConfig creates a new Config with default values.
@@ -70,15 +72,16 @@ func NewConfig() Config {
 		Headers: map[string]string{
 			"Content-Type": "application/octet-stream",
 		},
-		RateLimit:  "",
-		Timeout:    "5s",
-		Retry:      "1s",
-		MaxBackoff: "300s",
-		NumRetries: 3,
-		BackoffOn:  []int{429},
-		DropOn:     []int{},
-		TLS:        tls.NewConfig(),
-		Config:     auth.NewConfig(),
+		RateLimit:         "",
+		Timeout:           "5s",
+		Retry:             "1s",
+		MaxBackoff:        "300s",
+		NumRetries:        3,
+		BackoffOn:         []int{429},
+		DropOn:            []int{},
+		InjectTraceParent: false,
+		TLS:               tls.NewConfig(),
+		Config:            auth.NewConfig(),
 	}
 }
 
@@ -441,6 +444,17 @@ func (h *Type) checkStatus(code int) (succeeded bool, retStrat retryStrategy) {
 	return true, noRetry
 }
 
+// injectTraceParent adds a traceparent header to a request describing the span
+// of the first message part of the request, if enabled.
+func (h *Type) injectTraceParent(req *http.Request, spans []opentracing.Span) {
+	if !h.conf.InjectTraceParent || len(spans) == 0 {
+		return
+	}
+	if traceParent := tracing.SpanTraceParent(spans[0]); len(traceParent) > 0 {
+		req.Header.Set(tracing.TraceParentKey, traceParent)
+	}
+}
+
 // Do attempts to create and perform an HTTP request from a message payload.
 // This attempt may include retries, and if all retries fail an error is
 // returned.
@@ -476,6 +490,7 @@ func (h *Type) Do(msg types.Message) (res *http.Response, err error) {
 		logErr(err)
 		return nil, err
 	}
+	h.injectTraceParent(req, spans)
 
 	startedAt := time.Now()
 
@@ -512,6 +527,7 @@ func (h *Type) Do(msg types.Message) (res *http.Response, err error) {
 			logErr(err)
 			continue
 		}
+		h.injectTraceParent(req, spans)
 		if rateLimited {
 			if !h.retryThrottle.ExponentialRetry() {
 				return nil, types.ErrTypeClosed
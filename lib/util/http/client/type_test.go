@@ -37,8 +37,11 @@ import (
 
 	"github.com/Jeffail/benthos/lib/log"
 	"github.com/Jeffail/benthos/lib/message"
+	"github.com/Jeffail/benthos/lib/message/tracing"
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/types"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
 )
 
 //------------------------------------------------------------------------------
@@ -71,6 +74,66 @@ func TestHTTPClientRetries(t *testing.T) {
 	}
 }
 
+type traceParentInjector struct{}
+
+func (traceParentInjector) Inject(sc mocktracer.MockSpanContext, carrier interface{}) error {
+	writer, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	writer.Set(tracing.TraceParentKey, fmt.Sprintf("00-%032x-%016x-01", sc.TraceID, sc.SpanID))
+	return nil
+}
+
+func TestHTTPClientInjectTraceParent(t *testing.T) {
+	tracer := mocktracer.New()
+	tracer.RegisterInjector(opentracing.TextMap, traceParentInjector{})
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	headerChan := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headerChan <- r.Header.Get("traceparent")
+	}))
+	defer ts.Close()
+
+	for _, inject := range []bool{false, true} {
+		conf := NewConfig()
+		conf.URL = ts.URL + "/testpost"
+		conf.InjectTraceParent = inject
+
+		h, err := New(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msg := message.New([][]byte{[]byte("test")})
+		tracing.InitSpans("test", msg)
+		traceID := tracing.GetSpan(msg.Get(0)).Context().(mocktracer.MockSpanContext).TraceID
+
+		if _, err = h.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+		tracing.FinishSpans(msg)
+
+		var act string
+		select {
+		case act = <-headerChan:
+		case <-time.After(time.Second):
+			t.Fatal("Action timed out")
+		}
+		if !inject {
+			if len(act) > 0 {
+				t.Errorf("Unexpected traceparent: %v", act)
+			}
+			continue
+		}
+		if exp := fmt.Sprintf("00-%032x-", traceID); !strings.HasPrefix(act, exp) {
+			t.Errorf("Wrong traceparent: %v does not have prefix %v", act, exp)
+		}
+	}
+}
+
 func TestHTTPClientSendBasic(t *testing.T) {
 	nTestLoops := 1000
 
@@ -0,0 +1,64 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jsonschema
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+//------------------------------------------------------------------------------
+
+// Load parses a JSON schema from either a path or an inline document, exactly
+// one of which must be set. A path without a scheme is resolved to an absolute
+// file:// URL so that relative references within the schema are resolved
+// against the directory of the schema file.
+func Load(path, schema string) (*gojsonschema.Schema, error) {
+	var loader gojsonschema.JSONLoader
+	switch {
+	case len(path) > 0 && len(schema) > 0:
+		return nil, errors.New("only one of schema and schema_path may be set")
+	case len(path) > 0:
+		if !strings.HasPrefix(path, "file://") {
+			absPath, err := filepath.Abs(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve schema_path: %v", err)
+			}
+			path = "file://" + filepath.ToSlash(absPath)
+		}
+		loader = gojsonschema.NewReferenceLoader(path)
+	case len(schema) > 0:
+		loader = gojsonschema.NewStringLoader(schema)
+	default:
+		return nil, errors.New("either schema or schema_path must be set")
+	}
+	s, err := gojsonschema.NewSchema(loader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JSON schema: %v", err)
+	}
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
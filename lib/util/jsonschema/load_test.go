@@ -0,0 +1,115 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jsonschema
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func TestLoadPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_jsonschema_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "name.json"), []byte(`{
+	"type": "string",
+	"minLength": 3
+}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	schemaPath := filepath.Join(dir, "schema.json")
+	if err = ioutil.WriteFile(schemaPath, []byte(`{
+	"type": "object",
+	"properties": {
+		"name": { "$ref": "name.json" }
+	}
+}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	relPath, err := filepath.Rel(wd, schemaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{
+		schemaPath,
+		relPath,
+		"file://" + filepath.ToSlash(schemaPath),
+	} {
+		schema, err := Load(path, "")
+		if err != nil {
+			t.Errorf("Failed to load '%v': %v", path, err)
+			continue
+		}
+		for doc, exp := range map[string]bool{
+			`{"name":"foo"}`: true,
+			`{"name":"a"}`:   false,
+		} {
+			res, err := schema.Validate(gojsonschema.NewStringLoader(doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if act := res.Valid(); act != exp {
+				t.Errorf("Wrong result for '%v' from '%v': %v != %v", doc, path, act, exp)
+			}
+		}
+	}
+}
+
+func TestLoadInline(t *testing.T) {
+	schema, err := Load("", `{"type":"object"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := schema.Validate(gojsonschema.NewStringLoader(`"foo"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Valid() {
+		t.Error("Expected string to fail validation")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := map[string][2]string{
+		"no schema":      {"", ""},
+		"both fields":    {"./schema.json", `{"type":"object"}`},
+		"bad schema":     {"", `{"type":`},
+		"missing schema": {"/does/not/exist.json", ""},
+	}
+	for name, test := range tests {
+		if _, err := Load(test[0], test[1]); err == nil {
+			t.Errorf("Expected error from %v", name)
+		}
+	}
+}
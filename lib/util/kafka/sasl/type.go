@@ -0,0 +1,161 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sasl
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Shopify/sarama"
+)
+
+//------------------------------------------------------------------------------
+
+// Documentation is a markdown description of how and why to use SASL settings.
+const Documentation = `### SASL
+
+SASL authentication can be enabled with the ` + "`sasl`" + ` field. The
+following mechanisms are supported:
+
+- ` + "`PLAIN`" + `: Authenticates with ` + "`user`" + ` and ` + "`password`" + `.
+- ` + "`SCRAM-SHA-256`" + ` and ` + "`SCRAM-SHA-512`" + `: Authenticates with
+  ` + "`user`" + ` and ` + "`password`" + ` using a SCRAM exchange.
+- ` + "`OAUTHBEARER`" + `: Authenticates with an ` + "`access_token`" + `, or
+  with a token read from the cache resource ` + "`token_cache`" + ` under the
+  key ` + "`token_key`" + ` each time a connection is established.
+
+` + "``` yaml" + `
+sasl:
+  enabled: true
+  mechanism: SCRAM-SHA-512
+  user: foo
+  password: bar
+` + "```" + ``
+
+//------------------------------------------------------------------------------
+
+// Config contains configuration params for SASL authentication.
+type Config struct {
+	Enabled     bool   `json:"enabled" yaml:"enabled"`
+	Mechanism   string `json:"mechanism" yaml:"mechanism"`
+	User        string `json:"user" yaml:"user"`
+	Password    string `json:"password" yaml:"password"`
+	AccessToken string `json:"access_token" yaml:"access_token"`
+	TokenCache  string `json:"token_cache" yaml:"token_cache"`
+	TokenKey    string `json:"token_key" yaml:"token_key"`
+}
+
+// NewConfig creates a new Config with default values.
+func NewConfig() Config {
+	return Config{
+		Enabled:     false,
+		Mechanism:   sarama.SASLTypePlaintext,
+		User:        "",
+		Password:    "",
+		AccessToken: "",
+		TokenCache:  "",
+		TokenKey:    "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Apply sets the SASL fields of a sarama config based on the configuration
+// values of Config. The manager is used for resolving token cache resources.
+func (c Config) Apply(mgr types.Manager, conf *sarama.Config) error {
+	if !c.Enabled {
+		return nil
+	}
+
+	switch c.Mechanism {
+	case sarama.SASLTypePlaintext:
+	case sarama.SASLTypeSCRAMSHA256:
+		conf.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hashGen: sha256Gen}
+		}
+	case sarama.SASLTypeSCRAMSHA512:
+		conf.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hashGen: sha512Gen}
+		}
+	case sarama.SASLTypeOAuth:
+		provider, err := c.tokenProvider(mgr)
+		if err != nil {
+			return err
+		}
+		conf.Net.SASL.TokenProvider = provider
+	default:
+		return fmt.Errorf("sasl mechanism not recognised: %v", c.Mechanism)
+	}
+
+	conf.Net.SASL.Enable = true
+	conf.Net.SASL.Mechanism = sarama.SASLMechanism(c.Mechanism)
+	conf.Net.SASL.User = c.User
+	conf.Net.SASL.Password = c.Password
+	return nil
+}
+
+// tokenProvider returns an access token provider for OAUTHBEARER, which either
+// returns a static token or reads one from a cache resource.
+func (c Config) tokenProvider(mgr types.Manager) (sarama.AccessTokenProvider, error) {
+	if len(c.TokenCache) == 0 {
+		if len(c.AccessToken) == 0 {
+			return nil, errors.New("an access_token or token_cache must be set for the OAUTHBEARER mechanism")
+		}
+		return staticTokenProvider(c.AccessToken), nil
+	}
+	if _, err := mgr.GetCache(c.TokenCache); err != nil {
+		return nil, fmt.Errorf("failed to obtain token cache '%v': %v", c.TokenCache, err)
+	}
+	return &cacheTokenProvider{
+		mgr:   mgr,
+		cache: c.TokenCache,
+		key:   c.TokenKey,
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+type staticTokenProvider string
+
+func (s staticTokenProvider) Token() (*sarama.AccessToken, error) {
+	return &sarama.AccessToken{Token: string(s)}, nil
+}
+
+type cacheTokenProvider struct {
+	mgr   types.Manager
+	cache string
+	key   string
+}
+
+func (c *cacheTokenProvider) Token() (*sarama.AccessToken, error) {
+	cache, err := c.mgr.GetCache(c.cache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain token cache '%v': %v", c.cache, err)
+	}
+	tok, err := cache.Get(c.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token from cache: %v", err)
+	}
+	return &sarama.AccessToken{Token: string(tok)}, nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,200 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sasl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Shopify/sarama"
+)
+
+//------------------------------------------------------------------------------
+
+type mockCache struct {
+	types.Cache
+	values map[string][]byte
+}
+
+func (m mockCache) Get(key string) ([]byte, error) {
+	v, exists := m.values[key]
+	if !exists {
+		return nil, types.ErrKeyNotFound
+	}
+	return v, nil
+}
+
+type mockMgr struct {
+	types.DudMgr
+	caches map[string]types.Cache
+}
+
+func (m mockMgr) GetCache(name string) (types.Cache, error) {
+	c, exists := m.caches[name]
+	if !exists {
+		return nil, types.ErrCacheNotFound
+	}
+	return c, nil
+}
+
+//------------------------------------------------------------------------------
+
+func TestApplyDisabled(t *testing.T) {
+	conf := NewConfig()
+	conf.User = "foo"
+
+	saramaConf := sarama.NewConfig()
+	if err := conf.Apply(types.NoopMgr(), saramaConf); err != nil {
+		t.Fatal(err)
+	}
+	if saramaConf.Net.SASL.Enable {
+		t.Error("Expected SASL to be disabled")
+	}
+}
+
+func TestApplyPlain(t *testing.T) {
+	conf := NewConfig()
+	conf.Enabled = true
+	conf.User = "foo"
+	conf.Password = "bar"
+
+	saramaConf := sarama.NewConfig()
+	if err := conf.Apply(types.NoopMgr(), saramaConf); err != nil {
+		t.Fatal(err)
+	}
+	if !saramaConf.Net.SASL.Enable {
+		t.Error("Expected SASL to be enabled")
+	}
+	if exp, act := sarama.SASLMechanism(sarama.SASLTypePlaintext), saramaConf.Net.SASL.Mechanism; exp != act {
+		t.Errorf("Wrong mechanism: %v != %v", act, exp)
+	}
+	if exp, act := "foo", saramaConf.Net.SASL.User; exp != act {
+		t.Errorf("Wrong user: %v != %v", act, exp)
+	}
+	if exp, act := "bar", saramaConf.Net.SASL.Password; exp != act {
+		t.Errorf("Wrong password: %v != %v", act, exp)
+	}
+}
+
+func TestApplySCRAM(t *testing.T) {
+	for _, mech := range []string{sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512} {
+		conf := NewConfig()
+		conf.Enabled = true
+		conf.Mechanism = mech
+		conf.User = "foo"
+		conf.Password = "bar"
+
+		saramaConf := sarama.NewConfig()
+		if err := conf.Apply(types.NoopMgr(), saramaConf); err != nil {
+			t.Fatal(err)
+		}
+		if exp, act := sarama.SASLMechanism(mech), saramaConf.Net.SASL.Mechanism; exp != act {
+			t.Errorf("Wrong mechanism: %v != %v", act, exp)
+		}
+		if saramaConf.Net.SASL.SCRAMClientGeneratorFunc == nil {
+			t.Fatal("Expected SCRAM client generator")
+		}
+
+		client := saramaConf.Net.SASL.SCRAMClientGeneratorFunc()
+		if err := client.Begin("foo", "bar", ""); err != nil {
+			t.Fatal(err)
+		}
+		msg, err := client.Step("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(msg, "n,,n=foo,r=") {
+			t.Errorf("Unexpected client first message: %v", msg)
+		}
+		if client.Done() {
+			t.Error("Expected conversation to continue")
+		}
+	}
+}
+
+func TestApplyOAuthStatic(t *testing.T) {
+	conf := NewConfig()
+	conf.Enabled = true
+	conf.Mechanism = sarama.SASLTypeOAuth
+
+	saramaConf := sarama.NewConfig()
+	if err := conf.Apply(types.NoopMgr(), saramaConf); err == nil {
+		t.Error("Expected error from missing access token")
+	}
+
+	conf.AccessToken = "foo"
+	if err := conf.Apply(types.NoopMgr(), saramaConf); err != nil {
+		t.Fatal(err)
+	}
+	tok, err := saramaConf.Net.SASL.TokenProvider.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "foo", tok.Token; exp != act {
+		t.Errorf("Wrong token: %v != %v", act, exp)
+	}
+}
+
+func TestApplyOAuthCache(t *testing.T) {
+	cache := mockCache{values: map[string][]byte{}}
+	mgr := mockMgr{caches: map[string]types.Cache{"tokens": cache}}
+
+	conf := NewConfig()
+	conf.Enabled = true
+	conf.Mechanism = sarama.SASLTypeOAuth
+	conf.TokenCache = "nope"
+	conf.TokenKey = "foo"
+
+	saramaConf := sarama.NewConfig()
+	if err := conf.Apply(mgr, saramaConf); err == nil {
+		t.Error("Expected error from missing cache")
+	}
+
+	conf.TokenCache = "tokens"
+	if err := conf.Apply(mgr, saramaConf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := saramaConf.Net.SASL.TokenProvider.Token(); err == nil {
+		t.Error("Expected error from missing token")
+	}
+
+	cache.values["foo"] = []byte("bar")
+	tok, err := saramaConf.Net.SASL.TokenProvider.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "bar", tok.Token; exp != act {
+		t.Errorf("Wrong token: %v != %v", act, exp)
+	}
+}
+
+func TestApplyBadMechanism(t *testing.T) {
+	conf := NewConfig()
+	conf.Enabled = true
+	conf.Mechanism = "NOPE"
+
+	if err := conf.Apply(types.NoopMgr(), sarama.NewConfig()); err == nil {
+		t.Error("Expected error from bad mechanism")
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,60 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package schema
+
+import (
+	"fmt"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+//------------------------------------------------------------------------------
+
+// avroCodec converts between the binary and JSON encodings of Avro.
+type avroCodec struct {
+	codec *goavro.Codec
+}
+
+func newAvroCodec(schema string) (*avroCodec, error) {
+	c, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse avro schema: %v", err)
+	}
+	return &avroCodec{codec: c}, nil
+}
+
+func (a *avroCodec) toJSON(b []byte) ([]byte, error) {
+	native, _, err := a.codec.NativeFromBinary(b)
+	if err != nil {
+		return nil, err
+	}
+	return a.codec.TextualFromNative(nil, native)
+}
+
+func (a *avroCodec) fromJSON(b []byte) ([]byte, error) {
+	native, _, err := a.codec.NativeFromTextual(b)
+	if err != nil {
+		return nil, err
+	}
+	return a.codec.BinaryFromNative(nil, native)
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,229 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// Schemes supported by codecs.
+const (
+	SchemeAvro     = "avro"
+	SchemeProtobuf = "protobuf"
+)
+
+// codec converts messages between a binary encoding and JSON.
+type codec interface {
+	toJSON(b []byte) ([]byte, error)
+	fromJSON(b []byte) ([]byte, error)
+}
+
+//------------------------------------------------------------------------------
+
+// Codec converts messages between the binary encoding of a scheme and JSON
+// with a schema resolved either from a local file or from a schema registry.
+type Codec struct {
+	scheme  string
+	message string
+
+	local    codec
+	registry *Registry
+	subject  string
+
+	mut      sync.Mutex
+	byID     map[int]codec
+	latest   codec
+	latestID int
+}
+
+// New creates a new codec for a scheme from a schema config. The manager is
+// used to access a cache resource when one is configured.
+func New(scheme string, conf Config, mgr types.Manager) (*Codec, error) {
+	if scheme != SchemeAvro && scheme != SchemeProtobuf {
+		return nil, fmt.Errorf("scheme not recognised: %v", scheme)
+	}
+	c := &Codec{
+		scheme:  scheme,
+		message: conf.Message,
+		subject: conf.Subject,
+		byID:    map[int]codec{},
+	}
+
+	if len(conf.Path) > 0 {
+		if len(conf.RegistryURL) > 0 {
+			return nil, errors.New("a schema path and registry_url cannot both be specified")
+		}
+		var err error
+		if c.local, err = newLocalCodec(scheme, conf.Path, conf.Message); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	if len(conf.RegistryURL) == 0 {
+		return nil, errors.New("either a schema path or registry_url must be specified")
+	}
+
+	var timeout time.Duration
+	if tout := conf.Timeout; len(tout) > 0 {
+		var err error
+		if timeout, err = time.ParseDuration(tout); err != nil {
+			return nil, fmt.Errorf("failed to parse timeout string: %v", err)
+		}
+	}
+
+	var cache types.Cache
+	if len(conf.Cache) > 0 {
+		var err error
+		if cache, err = mgr.GetCache(conf.Cache); err != nil {
+			return nil, fmt.Errorf("failed to obtain cache '%v': %v", conf.Cache, err)
+		}
+	}
+
+	var err error
+	if c.registry, err = NewRegistry(conf.RegistryURL, timeout, cache); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func newLocalCodec(scheme, path, message string) (codec, error) {
+	if scheme == SchemeProtobuf {
+		file, err := parseProtoFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return newProtobufCodec(file, message, false)
+	}
+	schemaBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema file: %v", err)
+	}
+	return newAvroCodec(string(schemaBytes))
+}
+
+func (c *Codec) newRegistryCodec(s Schema) (codec, error) {
+	switch s.Type {
+	case TypeAvro:
+		if c.scheme == SchemeAvro {
+			return newAvroCodec(s.Schema)
+		}
+	case TypeProtobuf:
+		if c.scheme == SchemeProtobuf {
+			file, err := parseProtoString(s.Schema)
+			if err != nil {
+				return nil, err
+			}
+			return newProtobufCodec(file, c.message, true)
+		}
+	}
+	return nil, fmt.Errorf("schema %v of type %v does not match scheme %v", s.ID, s.Type, c.scheme)
+}
+
+//------------------------------------------------------------------------------
+
+func (c *Codec) codecByID(id int) (codec, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if cod, exists := c.byID[id]; exists {
+		return cod, nil
+	}
+	s, err := c.registry.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain schema %v: %v", id, err)
+	}
+	cod, err := c.newRegistryCodec(s)
+	if err != nil {
+		return nil, err
+	}
+	c.byID[id] = cod
+	return cod, nil
+}
+
+func (c *Codec) latestCodec() (codec, int, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.latest != nil {
+		return c.latest, c.latestID, nil
+	}
+	if len(c.subject) == 0 {
+		return nil, 0, errors.New("a schema subject must be specified in order to encode with a registry")
+	}
+	s, err := c.registry.GetLatest(c.subject)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to obtain latest schema of subject '%v': %v", c.subject, err)
+	}
+	cod, err := c.newRegistryCodec(s)
+	if err != nil {
+		return nil, 0, err
+	}
+	c.latest, c.latestID = cod, s.ID
+	return cod, s.ID, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Decode converts a message from its binary encoding into JSON. When a
+// registry is used the message must be framed with the Confluent wire format.
+func (c *Codec) Decode(b []byte) ([]byte, error) {
+	if c.local != nil {
+		return c.local.toJSON(b)
+	}
+	id, payload, err := ReadWireHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	cod, err := c.codecByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return cod.toJSON(payload)
+}
+
+// Encode converts a JSON message into its binary encoding. When a registry is
+// used the latest schema of the configured subject is used and the result is
+// framed with the Confluent wire format.
+func (c *Codec) Encode(b []byte) ([]byte, error) {
+	if c.local != nil {
+		return c.local.fromJSON(b)
+	}
+	cod, id, err := c.latestCodec()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := cod.fromJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	return WriteWireHeader(id, payload), nil
+}
+
+//------------------------------------------------------------------------------
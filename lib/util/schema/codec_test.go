@@ -0,0 +1,265 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package schema
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testAvroSchema = `{
+	"type": "record",
+	"name": "foo",
+	"fields": [
+		{"name": "name", "type": "string"},
+		{"name": "n", "type": "int"}
+	]
+}`
+
+const testProtoSchema = `syntax = "proto3";
+package test;
+
+message Foo {
+	string name = 1;
+	int32 n = 2;
+}
+
+message Bar {
+	message Baz {
+		string name = 1;
+	}
+}`
+
+var (
+	testAvroBinary  = []byte{6, 'f', 'o', 'o', 6}
+	testProtoBinary = []byte{0x0a, 3, 'f', 'o', 'o', 0x10, 3}
+)
+
+func writeTestSchema(t *testing.T, name, schema string) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "benthos_schema_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err = ioutil.WriteFile(path, []byte(schema), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func testRegistry(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/schemas/ids/3", "/subjects/foo-avro/versions/latest":
+			fmt.Fprintf(w, `{"id":3,"schema":%q}`, testAvroSchema)
+		case "/schemas/ids/4", "/subjects/foo-proto/versions/latest":
+			fmt.Fprintf(w, `{"id":4,"schema":%q,"schemaType":"PROTOBUF"}`, testProtoSchema)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+}
+
+//------------------------------------------------------------------------------
+
+func TestCodecBadConfig(t *testing.T) {
+	tests := map[string]Config{
+		"no schema": NewConfig(),
+		"both path and registry": func() Config {
+			c := NewConfig()
+			c.Path = "./foo.avsc"
+			c.RegistryURL = "http://localhost:8081"
+			return c
+		}(),
+		"missing file": func() Config {
+			c := NewConfig()
+			c.Path = "./does_not_exist.avsc"
+			return c
+		}(),
+	}
+	for name, conf := range tests {
+		if _, err := New(SchemeAvro, conf, nil); err == nil {
+			t.Errorf("%v: Expected error", name)
+		}
+	}
+	if _, err := New("nope", NewConfig(), nil); err == nil {
+		t.Error("Expected error from bad scheme")
+	}
+}
+
+func TestCodecAvroLocal(t *testing.T) {
+	path, cleanup := writeTestSchema(t, "foo.avsc", testAvroSchema)
+	defer cleanup()
+
+	conf := NewConfig()
+	conf.Path = path
+
+	c, err := New(SchemeAvro, conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := c.Decode(testAvroBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := `{"name":"foo","n":3}`, string(doc); exp != act {
+		t.Errorf("Wrong decoded result: %v != %v", act, exp)
+	}
+
+	bin, err := c.Encode([]byte(`{"n":3,"name":"foo"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := testAvroBinary, bin; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong encoded result: %v != %v", act, exp)
+	}
+}
+
+func TestCodecAvroRegistry(t *testing.T) {
+	ts := testRegistry(t)
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.RegistryURL = ts.URL
+	conf.Subject = "foo-avro"
+
+	c, err := New(SchemeAvro, conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	framed := WriteWireHeader(3, testAvroBinary)
+
+	doc, err := c.Decode(framed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := `{"name":"foo","n":3}`, string(doc); exp != act {
+		t.Errorf("Wrong decoded result: %v != %v", act, exp)
+	}
+
+	bin, err := c.Encode(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := framed, bin; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong encoded result: %v != %v", act, exp)
+	}
+
+	if _, err = c.Decode(testAvroBinary); err != ErrNoWireHeader {
+		t.Errorf("Expected ErrNoWireHeader, received: %v", err)
+	}
+	if _, err = c.Decode(WriteWireHeader(4, testAvroBinary)); err == nil {
+		t.Error("Expected error from mismatched schema type")
+	}
+	if _, err = c.Decode(WriteWireHeader(5, testAvroBinary)); err == nil {
+		t.Error("Expected error from missing schema")
+	}
+}
+
+func TestCodecProtobufLocal(t *testing.T) {
+	path, cleanup := writeTestSchema(t, "foo.proto", testProtoSchema)
+	defer cleanup()
+
+	conf := NewConfig()
+	conf.Path = path
+
+	if _, err := New(SchemeProtobuf, conf, nil); err == nil {
+		t.Error("Expected error from missing message type")
+	}
+
+	conf.Message = "test.Nope"
+	if _, err := New(SchemeProtobuf, conf, nil); err == nil {
+		t.Error("Expected error from unknown message type")
+	}
+
+	conf.Message = "test.Foo"
+	c, err := New(SchemeProtobuf, conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := c.Decode(testProtoBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := `{"name":"foo","n":3}`, string(doc); exp != act {
+		t.Errorf("Wrong decoded result: %v != %v", act, exp)
+	}
+
+	bin, err := c.Encode([]byte(`{"n":3,"name":"foo"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := testProtoBinary, bin; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong encoded result: %v != %v", act, exp)
+	}
+}
+
+func TestCodecProtobufRegistry(t *testing.T) {
+	ts := testRegistry(t)
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.RegistryURL = ts.URL
+	conf.Subject = "foo-proto"
+	conf.Message = "test.Bar.Baz"
+
+	c, err := New(SchemeProtobuf, conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := c.Decode(WriteWireHeader(4, append([]byte{0}, testProtoBinary...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := `{"name":"foo","n":3}`, string(doc); exp != act {
+		t.Errorf("Wrong decoded result: %v != %v", act, exp)
+	}
+
+	bazBinary := []byte{0x0a, 3, 'b', 'a', 'z'}
+	doc, err = c.Decode(WriteWireHeader(4, append([]byte{4, 2, 0}, bazBinary...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := `{"name":"baz"}`, string(doc); exp != act {
+		t.Errorf("Wrong decoded result: %v != %v", act, exp)
+	}
+
+	bin, err := c.Encode(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := WriteWireHeader(4, append([]byte{4, 2, 0}, bazBinary...)), bin; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong encoded result: %v != %v", act, exp)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package schema
+
+//------------------------------------------------------------------------------
+
+// Documentation is a markdown description of how schemas are resolved.
+const Documentation = `### Schemas
+
+The schema used by the ` + "`avro` and `protobuf`" + ` schemes is either loaded
+from a local file specified with ` + "`schema.path`" + ` or resolved from a
+schema registry HTTP API specified with ` + "`schema.registry_url`" + `.
+
+When a schema registry is used the binary form of messages is expected to be
+framed with the [Confluent wire format](https://docs.confluent.io/current/schema-registry/serializer-formatter.html#wire-format),
+where a magic byte and a four byte schema ID prefix the payload. When decoding,
+the schema of each message is resolved from this ID. When encoding, the latest
+schema version of ` + "`schema.subject`" + ` is used and its ID is written as
+the message prefix.
+
+Schemas fetched from a registry can be cached within a
+[cache resource](../caches/README.md) by specifying its name with
+` + "`schema.cache`" + `, which prevents a registry request for each schema ID
+from each instance of the processor.
+
+Protobuf schemas require the fully qualified name of the message type with
+` + "`schema.message`" + `, e.g. ` + "`foo.bar.Baz`" + `. When decoding
+messages framed by a registry the message type is instead resolved from the
+message indexes of the frame. Protobuf schemas fetched from a registry that
+reference other schemas are not currently supported.`
+
+//------------------------------------------------------------------------------
+
+// Config contains configuration fields for resolving the schema used by a
+// codec.
+type Config struct {
+	Path        string `json:"path" yaml:"path"`
+	Message     string `json:"message" yaml:"message"`
+	RegistryURL string `json:"registry_url" yaml:"registry_url"`
+	Subject     string `json:"subject" yaml:"subject"`
+	Cache       string `json:"cache" yaml:"cache"`
+	Timeout     string `json:"timeout" yaml:"timeout"`
+}
+
+// NewConfig creates a new Config with default values.
+func NewConfig() Config {
+	return Config{
+		Path:        "",
+		Message:     "",
+		RegistryURL: "",
+		Subject:     "",
+		Cache:       "",
+		Timeout:     "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,174 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package schema
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+//------------------------------------------------------------------------------
+
+// protobufCodec converts between the binary and JSON encodings of a Protobuf
+// message type. When framed the binary encoding is prefixed with the message
+// indexes of the Confluent wire format.
+type protobufCodec struct {
+	file   *desc.FileDescriptor
+	msg    *desc.MessageDescriptor
+	framed bool
+}
+
+func parseProtoFile(path string) (*desc.FileDescriptor, error) {
+	p := protoparse.Parser{
+		ImportPaths: []string{filepath.Dir(path)},
+	}
+	files, err := p.ParseFiles(filepath.Base(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse protobuf schema: %v", err)
+	}
+	return files[0], nil
+}
+
+func parseProtoString(schema string) (*desc.FileDescriptor, error) {
+	const name = "schema.proto"
+	p := protoparse.Parser{
+		Accessor: func(filename string) (io.ReadCloser, error) {
+			if filename != name {
+				return nil, fmt.Errorf("schema references are not supported: %v", filename)
+			}
+			return ioutil.NopCloser(strings.NewReader(schema)), nil
+		},
+	}
+	files, err := p.ParseFiles(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse protobuf schema: %v", err)
+	}
+	return files[0], nil
+}
+
+func newProtobufCodec(file *desc.FileDescriptor, message string, framed bool) (*protobufCodec, error) {
+	c := &protobufCodec{
+		file:   file,
+		framed: framed,
+	}
+	if len(message) > 0 {
+		if c.msg = file.FindMessage(message); c.msg == nil {
+			return nil, fmt.Errorf("message type '%v' was not found in schema", message)
+		}
+	} else if !framed {
+		return nil, fmt.Errorf("a message type must be specified")
+	} else if msgs := file.GetMessageTypes(); len(msgs) > 0 {
+		c.msg = msgs[0]
+	}
+	return c, nil
+}
+
+//------------------------------------------------------------------------------
+
+// messageByIndexes returns the message type identified by an array of message
+// indexes, where the first index refers to the top level messages of the file
+// and each subsequent index refers to the nested messages of the last.
+func (p *protobufCodec) messageByIndexes(indexes []int) (*desc.MessageDescriptor, error) {
+	msgs := p.file.GetMessageTypes()
+	var msg *desc.MessageDescriptor
+	for _, index := range indexes {
+		if index >= len(msgs) {
+			return nil, fmt.Errorf("message index %v out of bounds", index)
+		}
+		msg = msgs[index]
+		msgs = msg.GetNestedMessageTypes()
+	}
+	if msg == nil {
+		return nil, fmt.Errorf("message indexes %v do not identify a message type", indexes)
+	}
+	return msg, nil
+}
+
+// indexesOfMessage returns the array of message indexes that identifies a
+// message type.
+func indexesOfMessage(msg *desc.MessageDescriptor) []int {
+	var indexes []int
+	for {
+		var siblings []*desc.MessageDescriptor
+		parent, isMsg := msg.GetParent().(*desc.MessageDescriptor)
+		if isMsg {
+			siblings = parent.GetNestedMessageTypes()
+		} else {
+			siblings = msg.GetFile().GetMessageTypes()
+		}
+		for i, s := range siblings {
+			if s.GetFullyQualifiedName() == msg.GetFullyQualifiedName() {
+				indexes = append([]int{i}, indexes...)
+				break
+			}
+		}
+		if !isMsg {
+			return indexes
+		}
+		msg = parent
+	}
+}
+
+func (p *protobufCodec) toJSON(b []byte) ([]byte, error) {
+	msgType := p.msg
+	if p.framed {
+		indexes, payload, err := readMessageIndexes(b)
+		if err != nil {
+			return nil, err
+		}
+		if msgType, err = p.messageByIndexes(indexes); err != nil {
+			return nil, err
+		}
+		b = payload
+	}
+	msg := dynamic.NewMessage(msgType)
+	if err := msg.Unmarshal(b); err != nil {
+		return nil, err
+	}
+	return msg.MarshalJSON()
+}
+
+func (p *protobufCodec) fromJSON(b []byte) ([]byte, error) {
+	if p.msg == nil {
+		return nil, fmt.Errorf("schema does not contain a message type")
+	}
+	msg := dynamic.NewMessage(p.msg)
+	if err := msg.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+	payload, err := msg.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if p.framed {
+		payload = writeMessageIndexes(indexesOfMessage(p.msg), payload)
+	}
+	return payload, nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,139 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// Schema types as reported by a schema registry.
+const (
+	TypeAvro     = "AVRO"
+	TypeProtobuf = "PROTOBUF"
+)
+
+// Schema is a schema obtained from a schema registry.
+type Schema struct {
+	ID     int    `json:"id"`
+	Type   string `json:"schemaType"`
+	Schema string `json:"schema"`
+}
+
+//------------------------------------------------------------------------------
+
+// Registry is a client of a schema registry HTTP API that optionally caches
+// schemas by their ID within a cache resource.
+type Registry struct {
+	url    string
+	client http.Client
+	cache  types.Cache
+}
+
+// NewRegistry creates a new schema registry client targeting a URL. The cache
+// argument is optional and can be nil.
+func NewRegistry(registryURL string, timeout time.Duration, cache types.Cache) (*Registry, error) {
+	if _, err := url.Parse(registryURL); err != nil {
+		return nil, fmt.Errorf("failed to parse registry URL: %v", err)
+	}
+	return &Registry{
+		url:    strings.TrimSuffix(registryURL, "/"),
+		client: http.Client{Timeout: timeout},
+		cache:  cache,
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (r *Registry) get(path string) ([]byte, error) {
+	res, err := r.client.Get(r.url + path)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry request '%v' returned status %v: %s", path, res.StatusCode, body)
+	}
+	return body, nil
+}
+
+func parseSchema(body []byte) (Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(body, &s); err != nil {
+		return s, fmt.Errorf("failed to parse registry response: %v", err)
+	}
+	if len(s.Type) == 0 {
+		s.Type = TypeAvro
+	}
+	return s, nil
+}
+
+// GetByID returns the schema registered with an ID. Schemas are immutable and
+// are therefore read from and written to the cache when one is configured.
+func (r *Registry) GetByID(id int) (Schema, error) {
+	path := fmt.Sprintf("/schemas/ids/%v", id)
+	cacheKey := r.url + path
+
+	var body []byte
+	if r.cache != nil {
+		body, _ = r.cache.Get(cacheKey)
+	}
+	if body == nil {
+		var err error
+		if body, err = r.get(path); err != nil {
+			return Schema{}, err
+		}
+		if r.cache != nil {
+			// Failing to cache a schema only costs us a future request, and
+			// therefore isn't fatal.
+			r.cache.Set(cacheKey, body)
+		}
+	}
+
+	s, err := parseSchema(body)
+	s.ID = id
+	return s, err
+}
+
+// GetLatest returns the latest version of the schema of a subject.
+func (r *Registry) GetLatest(subject string) (Schema, error) {
+	body, err := r.get(fmt.Sprintf("/subjects/%v/versions/latest", url.PathEscape(subject)))
+	if err != nil {
+		return Schema{}, err
+	}
+	return parseSchema(body)
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,140 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package schema
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+type mapCache map[string][]byte
+
+func (m mapCache) Get(key string) ([]byte, error) {
+	if v, exists := m[key]; exists {
+		return v, nil
+	}
+	return nil, types.ErrKeyNotFound
+}
+func (m mapCache) Set(key string, value []byte) error {
+	m[key] = value
+	return nil
+}
+func (m mapCache) SetMulti(items map[string][]byte) error {
+	for k, v := range items {
+		m[k] = v
+	}
+	return nil
+}
+func (m mapCache) Add(key string, value []byte) error {
+	if _, exists := m[key]; exists {
+		return types.ErrKeyAlreadyExists
+	}
+	m[key] = value
+	return nil
+}
+func (m mapCache) Delete(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func TestRegistryGetByID(t *testing.T) {
+	var reqs int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&reqs, 1)
+		switch r.URL.Path {
+		case "/schemas/ids/1":
+			w.Write([]byte(`{"schema":"\"string\""}`))
+		case "/schemas/ids/2":
+			w.Write([]byte(`{"schema":"syntax = \"proto3\";","schemaType":"PROTOBUF"}`))
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c := mapCache{}
+	r, err := NewRegistry(ts.URL+"/", time.Second, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		s, err := r.GetByID(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp, act := (Schema{ID: 1, Type: TypeAvro, Schema: `"string"`}), s; exp != act {
+			t.Errorf("Wrong schema: %v != %v", act, exp)
+		}
+	}
+	if exp, act := int32(1), atomic.LoadInt32(&reqs); exp != act {
+		t.Errorf("Expected cached schema to be reused: %v != %v", act, exp)
+	}
+	if _, exists := c[ts.URL+"/schemas/ids/1"]; !exists {
+		t.Errorf("Schema missing from cache: %v", c)
+	}
+
+	s, err := r.GetByID(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := TypeProtobuf, s.Type; exp != act {
+		t.Errorf("Wrong schema type: %v != %v", act, exp)
+	}
+
+	if _, err = r.GetByID(3); err == nil {
+		t.Error("Expected error from missing schema")
+	}
+}
+
+func TestRegistryGetLatest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subjects/foo-value/versions/latest" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"subject":"foo-value","version":3,"id":7,"schema":"\"string\""}`)
+	}))
+	defer ts.Close()
+
+	r, err := NewRegistry(ts.URL, time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := r.GetLatest("foo-value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := (Schema{ID: 7, Type: TypeAvro, Schema: `"string"`}), s; exp != act {
+		t.Errorf("Wrong schema: %v != %v", act, exp)
+	}
+
+	if _, err = r.GetLatest("bar-value"); err == nil {
+		t.Error("Expected error from missing subject")
+	}
+}
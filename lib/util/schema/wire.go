@@ -0,0 +1,103 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package schema
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+//------------------------------------------------------------------------------
+
+// ErrNoWireHeader is returned when a message does not begin with the header of
+// the Confluent wire format.
+var ErrNoWireHeader = errors.New("message does not contain a schema registry wire header")
+
+const magicByte = 0
+
+// ReadWireHeader reads the schema ID from the five byte header of a message
+// framed with the Confluent wire format and returns it along with the
+// remaining payload.
+func ReadWireHeader(b []byte) (int, []byte, error) {
+	if len(b) < 5 || b[0] != magicByte {
+		return 0, nil, ErrNoWireHeader
+	}
+	return int(binary.BigEndian.Uint32(b[1:5])), b[5:], nil
+}
+
+// WriteWireHeader returns a payload prefixed with the five byte header of the
+// Confluent wire format for a schema ID.
+func WriteWireHeader(id int, payload []byte) []byte {
+	b := make([]byte, 5, 5+len(payload))
+	b[0] = magicByte
+	binary.BigEndian.PutUint32(b[1:5], uint32(id))
+	return append(b, payload...)
+}
+
+//------------------------------------------------------------------------------
+
+// readMessageIndexes reads the array of message indexes that follows the wire
+// header of Protobuf messages, which identifies the message type within the
+// schema.
+func readMessageIndexes(b []byte) ([]int, []byte, error) {
+	count, n := binary.Varint(b)
+	if n <= 0 || count < 0 {
+		return nil, nil, errors.New("failed to read message indexes")
+	}
+	b = b[n:]
+	if count == 0 {
+		// An empty array is shorthand for the first message type.
+		return []int{0}, b, nil
+	}
+	if count > int64(len(b)) {
+		// Each index occupies at least one byte, reject the count before
+		// allocating so that malformed payloads can't exhaust memory.
+		return nil, nil, errors.New("failed to read message indexes")
+	}
+	indexes := make([]int, count)
+	for i := range indexes {
+		index, n := binary.Varint(b)
+		if n <= 0 || index < 0 {
+			return nil, nil, errors.New("failed to read message indexes")
+		}
+		indexes[i] = int(index)
+		b = b[n:]
+	}
+	return indexes, b, nil
+}
+
+// writeMessageIndexes returns a payload prefixed with an array of message
+// indexes.
+func writeMessageIndexes(indexes []int, payload []byte) []byte {
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return append([]byte{0}, payload...)
+	}
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutVarint(buf, int64(len(indexes)))
+	b := append([]byte{}, buf[:n]...)
+	for _, index := range indexes {
+		n = binary.PutVarint(buf, int64(index))
+		b = append(b, buf[:n]...)
+	}
+	return append(b, payload...)
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,94 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package schema
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestWireHeader(t *testing.T) {
+	b := WriteWireHeader(260, []byte("foo"))
+	if exp, act := []byte{0, 0, 0, 1, 4, 'f', 'o', 'o'}, b; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong framed message: %v != %v", act, exp)
+	}
+
+	id, payload, err := ReadWireHeader(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 260, id; exp != act {
+		t.Errorf("Wrong schema ID: %v != %v", act, exp)
+	}
+	if exp, act := "foo", string(payload); exp != act {
+		t.Errorf("Wrong payload: %v != %v", act, exp)
+	}
+
+	for _, bad := range [][]byte{
+		nil,
+		{0, 0, 0},
+		{1, 0, 0, 0, 1, 'f'},
+	} {
+		if _, _, err = ReadWireHeader(bad); err != ErrNoWireHeader {
+			t.Errorf("Expected ErrNoWireHeader from %v, received: %v", bad, err)
+		}
+	}
+}
+
+func TestMessageIndexes(t *testing.T) {
+	tests := []struct {
+		indexes []int
+		framed  []byte
+	}{
+		{indexes: []int{0}, framed: []byte{0, 'f'}},
+		{indexes: []int{1}, framed: []byte{2, 2, 'f'}},
+		{indexes: []int{1, 2}, framed: []byte{4, 2, 4, 'f'}},
+	}
+
+	for _, test := range tests {
+		framed := writeMessageIndexes(test.indexes, []byte("f"))
+		if !reflect.DeepEqual(test.framed, framed) {
+			t.Errorf("Wrong framed message for %v: %v != %v", test.indexes, framed, test.framed)
+		}
+		indexes, payload, err := readMessageIndexes(framed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(test.indexes, indexes) {
+			t.Errorf("Wrong indexes: %v != %v", indexes, test.indexes)
+		}
+		if exp, act := "f", string(payload); exp != act {
+			t.Errorf("Wrong payload: %v != %v", act, exp)
+		}
+	}
+
+	if _, _, err := readMessageIndexes([]byte{4, 2}); err == nil {
+		t.Error("Expected error from truncated indexes")
+	}
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutVarint(buf, math.MaxInt64)
+	if _, _, err := readMessageIndexes(buf[:n]); err == nil {
+		t.Error("Expected error from huge index count")
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Jeffail/benthos/lib/message"
+)
+
+func TestOpenBadDriver(t *testing.T) {
+	if _, err := Open("notexist", ""); err == nil {
+		t.Error("Expected error from unsupported driver")
+	}
+}
+
+func TestArgs(t *testing.T) {
+	args := NewArgs([]string{"static", "${!json_field:foo}", "${!metadata:bar}"})
+
+	part := message.NewPart([]byte(`{"foo":"fooval"}`))
+	part.Metadata().Set("bar", "barval")
+	msg := message.New(nil)
+	msg.Append(part)
+
+	exp := []interface{}{"static", "fooval", "barval"}
+	if act := args.Get(msg); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong args: %v != %v", act, exp)
+	}
+}
+
+func TestRowsToJSON(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "nickname"}).
+			AddRow(1, []byte("foo"), nil).
+			AddRow(2, "bar", "baz"),
+	)
+
+	rows, err := db.Query("SELECT id, name, nickname FROM footable")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	act, err := RowsToJSON(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []interface{}{
+		map[string]interface{}{"id": int64(1), "name": "foo", "nickname": nil},
+		map[string]interface{}{"id": int64(2), "name": "bar", "nickname": "baz"},
+	}
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong rows: %v != %v", act, exp)
+	}
+}
@@ -22,6 +22,7 @@ package text
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"regexp"
 )
@@ -54,7 +55,27 @@ func ContainsEnvVariables(inBytes []byte) bool {
 // the environment variable is empty or does not exist then either the default
 // value is used or the field will be left empty.
 func ReplaceEnvVariables(inBytes []byte) []byte {
-	return envRegex.ReplaceAllFunc(inBytes, func(content []byte) []byte {
+	outBytes, _ := replaceVariables(inBytes, false)
+	return outBytes
+}
+
+// ReplaceVariables replaces environment variables in the same way as
+// ReplaceEnvVariables, but patterns where the section before the colon matches
+// the prefix of a registered SecretResolver, such as
+// `${file:/run/secrets/foo}`, are instead replaced with the value returned by
+// the resolver for the section after the colon.
+//
+// The fields of the config that contained a secret are recorded so that they
+// can be redacted from configs with RedactSecrets. An error is returned if any
+// resolver fails.
+func ReplaceVariables(inBytes []byte) ([]byte, error) {
+	return replaceVariables(inBytes, true)
+}
+
+func replaceVariables(inBytes []byte, resolveSecrets bool) ([]byte, error) {
+	var err error
+	var secrets []string
+	outBytes := envRegex.ReplaceAllFunc(inBytes, func(content []byte) []byte {
 		var value string
 		if len(content) > 3 {
 			if colonIndex := bytes.IndexByte(content, ':'); colonIndex == -1 {
@@ -63,6 +84,18 @@ func ReplaceEnvVariables(inBytes []byte) []byte {
 				targetVar := content[2:colonIndex]
 				defaultVal := content[colonIndex+1 : len(content)-1]
 
+				if resolver, exists := getSecretResolver(string(targetVar)); resolveSecrets && exists {
+					var rerr error
+					if value, rerr = resolver(string(defaultVal)); rerr != nil {
+						if err == nil {
+							err = fmt.Errorf("failed to resolve %s secret '%s': %v", targetVar, defaultVal, rerr)
+						}
+						return nil
+					}
+					secrets = append(secrets, value)
+					return []byte(secretPlaceholder(len(secrets) - 1))
+				}
+
 				value = os.Getenv(string(targetVar))
 				if len(value) == 0 {
 					value = string(defaultVal)
@@ -71,6 +104,13 @@ func ReplaceEnvVariables(inBytes []byte) []byte {
 		}
 		return []byte(value)
 	})
+	if len(secrets) > 0 {
+		if err == nil {
+			recordSecretFields(outBytes, secrets)
+		}
+		outBytes = []byte(resolveSecretPlaceholders(string(outBytes), secrets))
+	}
+	return outBytes, err
 }
 
 //------------------------------------------------------------------------------
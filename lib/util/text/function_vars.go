@@ -193,6 +193,13 @@ var functionVars = map[string]func(msg Message, arg string) []byte{
 	},
 }
 
+// FunctionVar returns the function of a name used for function interpolations,
+// and a boolean indicating whether it exists.
+func FunctionVar(name string) (func(msg Message, arg string) []byte, bool) {
+	ftor, exists := functionVars[name]
+	return ftor, exists
+}
+
 // ContainsFunctionVariables returns true if inBytes contains function variable
 // replace patterns.
 func ContainsFunctionVariables(inBytes []byte) bool {
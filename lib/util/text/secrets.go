@@ -0,0 +1,256 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package text
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+//------------------------------------------------------------------------------
+
+// SecretResolver is a function that resolves the value of a secret from a key,
+// where the key is everything following the resolver prefix within a variable,
+// e.g. the key of `${file:/run/secrets/foo}` is `/run/secrets/foo`.
+type SecretResolver func(key string) (string, error)
+
+// RedactedSecret is the value that fields containing resolved secrets are
+// replaced with by RedactSecrets.
+const RedactedSecret = "!!!SECRET_SCRUBBED!!!"
+
+var (
+	secretResolversMut sync.RWMutex
+	secretResolvers    = map[string]SecretResolver{}
+
+	// secretFields maps the paths of config fields that contained a secret
+	// reference to the values they were resolved to, and secretValues contains
+	// resolved fields of configs where the paths could not be determined.
+	secretFieldsMut sync.RWMutex
+	secretFields    = map[string]map[string]struct{}{}
+	secretValues    = map[string]struct{}{}
+
+	secretPlaceholderRegex = regexp.MustCompile(`__benthos_secret_placeholder_([0-9]+)__`)
+)
+
+func init() {
+	RegisterSecretResolver("file", fileSecretResolver)
+}
+
+// RegisterSecretResolver registers a resolver under a prefix, after which
+// variables of the form `${prefix:key}` within configs are replaced with the
+// value returned by the resolver for the key. Registering a resolver with the
+// prefix of an existing resolver replaces it.
+//
+// WARNING: This API is experimental and could (is likely) to change.
+func RegisterSecretResolver(prefix string, resolver SecretResolver) {
+	secretResolversMut.Lock()
+	secretResolvers[prefix] = resolver
+	secretResolversMut.Unlock()
+}
+
+func getSecretResolver(prefix string) (SecretResolver, bool) {
+	secretResolversMut.RLock()
+	resolver, exists := secretResolvers[prefix]
+	secretResolversMut.RUnlock()
+	return resolver, exists
+}
+
+// fileSecretResolver reads a secret from a file, trailing line breaks are
+// removed.
+func fileSecretResolver(path string) (string, error) {
+	secretBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(secretBytes, "\r\n")), nil
+}
+
+//------------------------------------------------------------------------------
+
+// secretPlaceholder returns a token that temporarily replaces a secret
+// reference during interpolation, so that the fields containing it can be found
+// before the secret itself is inserted.
+func secretPlaceholder(index int) string {
+	return fmt.Sprintf("__benthos_secret_placeholder_%v__", index)
+}
+
+// resolveSecretPlaceholders replaces the placeholders within a string with the
+// secrets they refer to.
+func resolveSecretPlaceholders(s string, secrets []string) string {
+	return secretPlaceholderRegex.ReplaceAllStringFunc(s, func(p string) string {
+		index, err := strconv.Atoi(secretPlaceholderRegex.FindStringSubmatch(p)[1])
+		if err != nil || index >= len(secrets) {
+			return p
+		}
+		return secrets[index]
+	})
+}
+
+func secretPathKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+func appendPath(path []string, elem string) []string {
+	newPath := make([]string, len(path)+1)
+	copy(newPath, path)
+	newPath[len(path)] = elem
+	return newPath
+}
+
+// recordSecretFields parses a config containing secret placeholders in order to
+// record the path and resolved value of each field that contains a secret. If
+// the config cannot be parsed then any field whose entire value matches a
+// resolved secret is redacted instead.
+func recordSecretFields(placeholderBytes []byte, secrets []string) {
+	var root interface{}
+	if err := yaml.Unmarshal(placeholderBytes, &root); err != nil {
+		secretFieldsMut.Lock()
+		for _, secret := range secrets {
+			if len(secret) > 0 {
+				secretValues[secret] = struct{}{}
+			}
+		}
+		secretFieldsMut.Unlock()
+		return
+	}
+	walkSecretFields(nil, root, secrets)
+}
+
+func walkSecretFields(path []string, v interface{}, secrets []string) {
+	switch t := v.(type) {
+	case string:
+		if !secretPlaceholderRegex.MatchString(t) {
+			return
+		}
+		key := secretPathKey(path)
+		secretFieldsMut.Lock()
+		values, exists := secretFields[key]
+		if !exists {
+			values = map[string]struct{}{}
+			secretFields[key] = values
+		}
+		values[resolveSecretPlaceholders(t, secrets)] = struct{}{}
+		secretFieldsMut.Unlock()
+	case map[interface{}]interface{}:
+		for k, v := range t {
+			walkSecretFields(appendPath(path, resolveSecretPlaceholders(fmt.Sprint(k), secrets)), v, secrets)
+		}
+	case []interface{}:
+		for i, v := range t {
+			walkSecretFields(appendPath(path, strconv.Itoa(i)), v, secrets)
+		}
+	}
+}
+
+// isSecretField returns true if the field at a path has the value of a field
+// that contained a secret.
+func isSecretField(path []string, value string) bool {
+	secretFieldsMut.RLock()
+	defer secretFieldsMut.RUnlock()
+	if _, exists := secretValues[value]; exists {
+		return true
+	}
+	_, exists := secretFields[secretPathKey(path)][value]
+	return exists
+}
+
+// RedactSecrets walks a config structure and replaces the entire value of each
+// field that contained a secret reference when it was interpolated with
+// RedactedSecret, returning the result. Fields are identified by their path
+// within the config, where struct fields are named by their JSON tags, and
+// their value, so that other fields are left intact even when they happen to
+// contain a secret. Maps and slices within the structure are modified in place,
+// and therefore the structure should be a copy, such as a sanitised config.
+func RedactSecrets(v interface{}) interface{} {
+	secretFieldsMut.RLock()
+	noSecrets := len(secretFields) == 0 && len(secretValues) == 0
+	secretFieldsMut.RUnlock()
+	if noSecrets {
+		return v
+	}
+
+	root := reflect.ValueOf(&v).Elem()
+	redactValue(nil, root)
+	return root.Interface()
+}
+
+func redactValue(path []string, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() && isSecretField(path, v.String()) {
+			v.SetString(RedactedSecret)
+		}
+	case reflect.Ptr:
+		if !v.IsNil() {
+			redactValue(path, v.Elem())
+		}
+	case reflect.Interface:
+		if v.IsNil() || !v.CanSet() {
+			return
+		}
+		// Values held by interfaces cannot be set, and therefore we redact a
+		// copy and replace the original.
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		redactValue(path, elem)
+		v.Set(elem)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if len(field.PkgPath) > 0 {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			switch {
+			case name == "-":
+				continue
+			case len(name) > 0:
+				redactValue(appendPath(path, name), v.Field(i))
+			case field.Anonymous:
+				// Embedded structs without a name are inlined.
+				redactValue(path, v.Field(i))
+			default:
+				redactValue(appendPath(path, field.Name), v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			redactValue(appendPath(path, strconv.Itoa(i)), v.Index(i))
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(k))
+			redactValue(appendPath(path, fmt.Sprint(k.Interface())), elem)
+			v.SetMapIndex(k, elem)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------